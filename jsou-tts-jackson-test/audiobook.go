@@ -0,0 +1,214 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/pdf-to-text/pdfprocessor"
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// audiobookSuffix ends the name of an audiobook manifest, e.g.
+// "pdf-input/series/trilogy.audiobook.json". The audio is named after the
+// manifest without it, e.g. "mp3-output/series/trilogy.mp3".
+const audiobookSuffix = ".audiobook.json"
+
+// maxAudiobookDocuments caps the PDFs of an audiobook, which are all read
+// within one invocation.
+const maxAudiobookDocuments = 100
+
+// audiobookManifest lists the PDFs read, in order, as one audiobook.
+type audiobookManifest struct {
+	// Title, if set, is read before the first PDF.
+	Title string `json:"title"`
+	// Voice reads the book, unless the manifest's metadata names one.
+	Voice     string              `json:"voice"`
+	Documents []audiobookDocument `json:"documents"`
+}
+
+// audiobookDocument is a PDF of an audiobook.
+type audiobookDocument struct {
+	// PDF is a gs:// URI, or an object name in the manifest's bucket. It must
+	// be in the manifest's bucket and folder; see audiobookObject.
+	PDF string `json:"pdf"`
+	// Title is read as the heading of the PDF's part of the book. It defaults
+	// to the PDF's file name.
+	Title string `json:"title"`
+	// Pages selects the pages to read, like tts-pages, e.g. "5-" to skip
+	// front matter.
+	Pages string `json:"pages"`
+}
+
+// isAudiobookManifest reports whether an object is an audiobook manifest.
+func isAudiobookManifest(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), audiobookSuffix)
+}
+
+// parseAudiobookManifest parses and checks an audiobook manifest.
+func parseAudiobookManifest(data []byte) (*audiobookManifest, error) {
+	var m audiobookManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if len(m.Documents) == 0 {
+		return nil, fmt.Errorf("it lists no documents")
+	}
+	if len(m.Documents) > maxAudiobookDocuments {
+		return nil, fmt.Errorf("it lists %d documents, over the limit of %d", len(m.Documents), maxAudiobookDocuments)
+	}
+	for i, doc := range m.Documents {
+		if doc.PDF == "" {
+			return nil, fmt.Errorf("document %d has no pdf", i+1)
+		}
+		if _, err := pdfprocessor.ParsePageRanges(doc.Pages); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+	}
+	return &m, nil
+}
+
+// processAudiobook processes the audiobook manifest of e: it extracts the text
+// of each PDF it lists, in order, joins them into one text with a "Part N"
+// heading before each, so each PDF starts a chapter, and synthesizes that text
+// as the manifest's own, into a single output named after the manifest. A
+// failure to extract the text gets an error report like a PDF's. The caller
+// claims the manifest's event and schedules its retries.
+func (p *Pipeline) processAudiobook(ctx context.Context, cfg *Config, e StorageObjectData) error {
+	manifest, document, err := p.extractAudiobook(ctx, cfg, e)
+	if err != nil {
+		p.writeFailureReport(ctx, cfg, e.Bucket, e.Name, failureReport{
+			Input:      fmt.Sprintf("gs://%s/%s", e.Bucket, e.Name),
+			Generation: e.Generation,
+			Stage:      stageExtraction,
+			Error:      err.Error(),
+			Retryable:  isRetryableFailure(err),
+		})
+		return err
+	}
+
+	metadata := map[string]string{}
+	for key, value := range e.Metadata {
+		metadata[key] = value
+	}
+	if metadata["tts-voice"] == "" && manifest.Voice != "" {
+		metadata["tts-voice"] = manifest.Voice
+	}
+	// The text is synthesized as if handed off by the extraction stage of a
+	// staged pipeline: it's not extracted again.
+	e.Metadata, e.extracted, e.audiobook = metadata, document, true
+	return p.processPDFToSpeechHandler(ctx, cfg, e)
+}
+
+// extractAudiobook reads the audiobook manifest of e and returns it with the
+// joined text of its PDFs.
+func (p *Pipeline) extractAudiobook(ctx context.Context, cfg *Config, e StorageObjectData) (*audiobookManifest, *extractedDocument, error) {
+	data, err := p.store.ReadObject(ctx, e.Bucket, e.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read audiobook manifest %s: %w", e.Name, err)
+	}
+	manifest, err := parseAudiobookManifest(data)
+	if err != nil {
+		return nil, nil, permanent(fmt.Errorf("invalid audiobook manifest %s: %w", e.Name, err))
+	}
+	objects := make([]string, len(manifest.Documents))
+	for i, doc := range manifest.Documents {
+		if objects[i], err = audiobookObject(cfg, e, doc.PDF); err != nil {
+			return nil, nil, permanent(fmt.Errorf("invalid audiobook manifest %s: document %d (%s): %w", e.Name, i+1, doc.PDF, err))
+		}
+	}
+	log.Printf("Reading %d PDFs of audiobook %s.", len(manifest.Documents), e.Name)
+
+	receivedAt := time.Now().UTC()
+	var text strings.Builder
+	if manifest.Title != "" {
+		text.WriteString(manifest.Title + "\n\n")
+	}
+	document := &extractedDocument{ReceivedAt: receivedAt}
+	for i, doc := range manifest.Documents {
+		extraction, err := p.extractAudiobookDocument(ctx, cfg, e.Bucket, objects[i], doc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("audiobook %s, document %d (%s): %w", e.Name, i+1, doc.PDF, err)
+		}
+		title := doc.Title
+		if title == "" {
+			base := path.Base(doc.PDF)
+			title = strings.NewReplacer("_", " ", "-", " ").Replace(strings.TrimSuffix(base, path.Ext(base)))
+		}
+		fmt.Fprintf(&text, "Part %d: %s\n\n%s\n\n", i+1, title, strings.TrimSpace(extraction.Text))
+		// Failed pages are numbered across the whole book.
+		for _, page := range extraction.FailedPages {
+			document.FailedPages = append(document.FailedPages, document.Pages+page)
+		}
+		document.Pages += extraction.Pages
+	}
+	document.Text = text.String()
+	document.ExtractionSeconds = time.Since(receivedAt).Seconds()
+	return manifest, document, nil
+}
+
+// audiobookObject returns the name of the object of pdf, a PDF listed by the
+// audiobook manifest of e. It must be in the manifest's bucket, below the input
+// folder of the manifest, or its own folder if it's in none, or one of
+// AUDIOBOOK_SOURCE_PREFIX, so a manifest can't read other buckets or the
+// pipeline's own records.
+func audiobookObject(cfg *Config, e StorageObjectData, pdf string) (string, error) {
+	object := pdf
+	if strings.HasPrefix(pdf, "gs://") {
+		bucket, name, err := storage.ParseGCSURI(pdf)
+		if err != nil {
+			return "", err
+		}
+		if bucket != e.Bucket {
+			return "", fmt.Errorf("it's in bucket %s, not the manifest's bucket %s", bucket, e.Bucket)
+		}
+		object = name
+	}
+	// Cleaning rejects names like "pdf-input/../tts-jobs/x", which a local
+	// storage directory would resolve outside the folder.
+	if path.Clean("/"+object) != "/"+object {
+		return "", fmt.Errorf("invalid object name %q", object)
+	}
+	folder, ok := cfg.inputFolder(e.Name)
+	if !ok {
+		if folder = path.Dir(e.Name) + "/"; folder == "./" {
+			folder = ""
+		}
+	}
+	folders := append([]string{folder}, cfg.AudiobookFolders...)
+	if !slices.ContainsFunc(folders, func(folder string) bool { return strings.HasPrefix(object, folder) }) {
+		return "", fmt.Errorf("it's outside the manifest's folder %q and AUDIOBOOK_SOURCE_PREFIX", folder)
+	}
+	return object, nil
+}
+
+// extractAudiobookDocument extracts the text of doc, a PDF of an audiobook,
+// from object in bucket.
+func (p *Pipeline) extractAudiobookDocument(ctx context.Context, cfg *Config, bucket, object string, doc audiobookDocument) (pdfprocessor.Extraction, error) {
+	r, err := p.store.OpenReaderAt(ctx, bucket, object)
+	if err != nil {
+		return pdfprocessor.Extraction{}, fmt.Errorf("failed to open PDF: %w", err)
+	}
+	if cfg.MaxInputBytes > 0 && r.Size() > cfg.MaxInputBytes {
+		return pdfprocessor.Extraction{}, permanent(fmt.Errorf("PDF is %d bytes, over the MAX_INPUT_BYTES limit of %d", r.Size(), cfg.MaxInputBytes))
+	}
+	if isPDF, err := pdfprocessor.IsPDF(r, r.Size()); err != nil {
+		return pdfprocessor.Extraction{}, fmt.Errorf("failed to read PDF: %w", err)
+	} else if !isPDF {
+		return pdfprocessor.Extraction{}, permanent(fmt.Errorf("it isn't a PDF: it has no %%PDF- header"))
+	}
+	pages, _ := pdfprocessor.ParsePageRanges(doc.Pages) // Checked with the manifest.
+	extraction, err := p.extract(ctx, r, r.Size(), object, pdfprocessor.ExtractOptions{Pages: pages, Workers: cfg.ExtractionConcurrency})
+	if err != nil {
+		return pdfprocessor.Extraction{}, fmt.Errorf("failed to extract text: %w", err)
+	}
+	if strings.TrimSpace(extraction.Text) == "" {
+		return pdfprocessor.Extraction{}, permanent(fmt.Errorf("no text could be extracted"))
+	}
+	return extraction, nil
+}
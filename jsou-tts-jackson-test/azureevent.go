@@ -0,0 +1,84 @@
+package pdftospeech
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// Event Grid event types handled by ProcessAzureBlobEvent.
+const (
+	eventGridValidation  = "Microsoft.EventGrid.SubscriptionValidationEvent"
+	eventGridBlobCreated = "Microsoft.Storage.BlobCreated"
+)
+
+// eventGridEvent is an Azure Event Grid event in the Event Grid schema.
+type eventGridEvent struct {
+	EventType string `json:"eventType"`
+	Subject   string `json:"subject"` // "/blobServices/default/containers/CONTAINER/blobs/BLOB"
+	Data      struct {
+		ValidationCode string `json:"validationCode"`
+		ETag           string `json:"eTag"`
+		ContentType    string `json:"contentType"`
+	} `json:"data"`
+}
+
+// blobEventObject returns the blob a BlobCreated event is about, parsed from its
+// subject.
+func blobEventObject(event eventGridEvent) (StorageObjectData, error) {
+	rest, ok := strings.CutPrefix(event.Subject, "/blobServices/default/containers/")
+	container, blob, found := strings.Cut(rest, "/blobs/")
+	if !ok || !found || container == "" || blob == "" {
+		return StorageObjectData{}, fmt.Errorf("unexpected subject %q in BlobCreated event", event.Subject)
+	}
+	if unescaped, err := url.PathUnescape(blob); err == nil {
+		blob = unescaped
+	}
+	e := StorageObjectData{Bucket: container, Name: blob, ContentType: event.Data.ContentType}
+	if event.Data.ETag != "" {
+		e.Generation = strconv.FormatInt(storage.ETagGeneration(event.Data.ETag), 10)
+	}
+	return e, nil
+}
+
+// processAzureBlobEvent serves the ProcessAzureBlobEvent entry point, which runs
+// the pipeline for blobs created in Azure Blob Storage when the function is
+// deployed with STORAGE_BACKEND=azure. Subscribe it to the storage account's
+// BlobCreated events as an Event Grid webhook; a subject filter such as
+// "/blobServices/default/containers/CONTAINER/blobs/pdf-input/" keeps events
+// for other blobs from reaching it. The subscription's validation handshake is
+// answered with the validation code.
+func processAzureBlobEvent(w http.ResponseWriter, r *http.Request) {
+	body, ok := readEventBody(w, r)
+	if !ok {
+		return
+	}
+	var events []eventGridEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		http.Error(w, fmt.Sprintf("invalid Event Grid event: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var objects []StorageObjectData
+	for _, event := range events {
+		switch event.EventType {
+		case eventGridValidation:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"validationResponse": event.Data.ValidationCode})
+			return
+		case eventGridBlobCreated:
+			e, err := blobEventObject(event)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			objects = append(objects, e)
+		}
+	}
+	processEventObjects(w, r, objects)
+}
@@ -0,0 +1,123 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// usagePrefix holds one usage record per month, e.g. "tts-usage/2025-06.json",
+// with the estimated spend of every document synthesized that month.
+const usagePrefix = "tts-usage/"
+
+// maxUsageUpdateAttempts bounds the read-modify-write retries when concurrent
+// invocations update the same usage record.
+const maxUsageUpdateAttempts = 10
+
+// costEstimate is the expected charge for synthesizing a document.
+type costEstimate struct {
+	Characters int     `json:"characters"`
+	Family     string  `json:"family"`
+	USD        float64 `json:"estimated_usd"`
+}
+
+// monthlyUsage is the content of a usage record.
+type monthlyUsage struct {
+	Documents  int     `json:"documents"`
+	Characters int     `json:"characters"`
+	USD        float64 `json:"estimated_usd"`
+}
+
+// estimateCost prices inputs at the list price of the voice's family.
+func estimateCost(inputs []tts.Input, caps tts.Capabilities) costEstimate {
+	chars := tts.BilledChars(inputs)
+	return costEstimate{
+		Characters: chars,
+		Family:     caps.Family,
+		USD:        float64(chars) / 1e6 * tts.PricePerMillionChars(caps),
+	}
+}
+
+// errOverBudget is returned by reserveBudget when the month's budget is used up.
+var errOverBudget = errors.New("over budget")
+
+// budgetOverride reports whether the document was uploaded with
+// x-goog-meta-tts-budget-override: true, which lets it exceed the budgets.
+func budgetOverride(metadata map[string]string) bool {
+	return strings.EqualFold(metadata["tts-budget-override"], "true")
+}
+
+// usageObjectName returns the usage record of the month of t.
+func usageObjectName(t time.Time) string {
+	return usagePrefix + t.UTC().Format("2006-01") + ".json"
+}
+
+// reserveBudget adds the estimate to this month's usage record, refusing if that
+// would take the month over monthlyLimit (unless override is set). A zero limit
+// still records usage. It returns a function that gives the reservation back,
+// for when synthesis fails.
+func (p *Pipeline) reserveBudget(ctx context.Context, bucketName string, estimate costEstimate, monthlyLimit float64, override bool) (func(), error) {
+	object := usageObjectName(time.Now())
+	if err := p.updateUsage(ctx, bucketName, object, func(u *monthlyUsage) error {
+		if monthlyLimit > 0 && u.USD+estimate.USD > monthlyLimit && !override {
+			return fmt.Errorf("%w: estimated cost $%.2f would exceed the monthly budget of $%.2f ($%.2f used this month)", errOverBudget, estimate.USD, monthlyLimit, u.USD)
+		}
+		u.Documents++
+		u.Characters += estimate.Characters
+		u.USD += estimate.USD
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	release := func() {
+		err := p.updateUsage(context.WithoutCancel(ctx), bucketName, object, func(u *monthlyUsage) error {
+			u.Documents--
+			u.Characters -= estimate.Characters
+			u.USD = max(u.USD-estimate.USD, 0)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error returning $%.2f to the budget in %s: %v", estimate.USD, object, err)
+		}
+	}
+	return release, nil
+}
+
+// updateUsage applies change to a usage record, retrying when another invocation
+// updated it concurrently. An error from change aborts without writing.
+func (p *Pipeline) updateUsage(ctx context.Context, bucketName, object string, change func(*monthlyUsage) error) error {
+	for range maxUsageUpdateAttempts {
+		data, generation, err := p.store.ReadObjectGeneration(ctx, bucketName, object)
+		if err != nil {
+			return fmt.Errorf("failed to read usage record: %w", err)
+		}
+		var usage monthlyUsage
+		if data != nil {
+			if err := json.Unmarshal(data, &usage); err != nil {
+				return fmt.Errorf("invalid usage record %s: %w", object, err)
+			}
+		}
+		if err := change(&usage); err != nil {
+			return err
+		}
+		data, err = json.MarshalIndent(usage, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode usage record: %w", err)
+		}
+		written, err := p.store.UpdateObjectIfGeneration(ctx, bucketName, object, data, "application/json", generation)
+		if err != nil {
+			return fmt.Errorf("failed to update usage record: %w", err)
+		}
+		if written != 0 {
+			return nil
+		}
+	}
+	return transient(fmt.Errorf("usage record %s is changing too often to update", object))
+}
@@ -0,0 +1,57 @@
+package pdftospeech
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReserveBudget(t *testing.T) {
+	estimate := costEstimate{Characters: 1000, USD: 4}
+	tests := []struct {
+		name     string
+		used     float64 // Spent this month before the reservation.
+		limit    float64
+		override bool
+		wantErr  error
+		wantUSD  float64
+		// The usage left once the reservation is released.
+		wantReleasedUSD       float64
+		wantReleasedDocuments int
+	}{
+		{name: "within the budget", used: 5, limit: 10, wantUSD: 9, wantReleasedUSD: 5, wantReleasedDocuments: 1},
+		{name: "up to the budget", used: 6, limit: 10, wantUSD: 10, wantReleasedUSD: 6, wantReleasedDocuments: 1},
+		{name: "over the budget", used: 7, limit: 10, wantErr: errOverBudget, wantUSD: 7},
+		{name: "over the budget with the override", used: 7, limit: 10, override: true, wantUSD: 11, wantReleasedUSD: 7, wantReleasedDocuments: 1},
+		{name: "no budget only records usage", used: 100, wantUSD: 104, wantReleasedUSD: 100, wantReleasedDocuments: 1},
+		{name: "first of the month", limit: 10, wantUSD: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			object := usageObjectName(time.Now())
+			if tt.used > 0 {
+				putJSON(t, p, object, monthlyUsage{Documents: 1, USD: tt.used})
+			}
+			release, err := p.reserveBudget(context.Background(), testBucket, estimate, tt.limit, tt.override)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("reserveBudget() error = %v, want %v", err, tt.wantErr)
+			}
+			var usage monthlyUsage
+			getJSON(t, p, object, &usage)
+			if math.Abs(usage.USD-tt.wantUSD) > 1e-9 {
+				t.Errorf("$%.2f used, want $%.2f", usage.USD, tt.wantUSD)
+			}
+			if err != nil {
+				return
+			}
+			release()
+			getJSON(t, p, object, &usage)
+			if math.Abs(usage.USD-tt.wantReleasedUSD) > 1e-9 || usage.Documents != tt.wantReleasedDocuments {
+				t.Errorf("after the release, $%.2f used by %d documents, want $%.2f by %d", usage.USD, usage.Documents, tt.wantReleasedUSD, tt.wantReleasedDocuments)
+			}
+		})
+	}
+}
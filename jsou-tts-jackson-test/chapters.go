@@ -0,0 +1,166 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+	v2 "github.com/cloudevents/sdk-go/v2"
+)
+
+// chapterTask is the message published to CHAPTER_TOPIC for each chapter of a
+// book that's fanned out, asking SynthesizeChapter to synthesize it.
+type chapterTask struct {
+	Input     string `json:"input"`   // gs:// URI of the PDF, for logs.
+	Chapter   int    `json:"chapter"` // 1-based.
+	Chapters  int    `json:"chapters"`
+	OutputURI string `json:"output_uri"`
+	Provider  string `json:"provider,omitempty"`
+	// ClonedVoice says the segments' voice is an instant custom voice, whose
+	// cloning key isn't published but read again by SynthesizeChapter.
+	ClonedVoice bool              `json:"cloned_voice,omitempty"`
+	Segments    []tts.Segment     `json:"segments"`
+	Settings    tts.AudioSettings `json:"settings"`
+}
+
+// chapterURI returns where chapter i of the output at outputURI is written
+// until it's joined into the output, e.g.
+// "gs://bucket/tmp/mp3-output/book.mp3/chapter-003.mp3".
+func chapterURI(outputURI string, i int, format tts.AudioFormat) (string, error) {
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("gs://%s/%schapter-%03d%s", bucket, tmpObjectPrefix(object), i+1, format.Extension), nil
+}
+
+// chapterErrorName returns the object a failed chapter's error is written to,
+// next to where its audio would be.
+func chapterErrorName(chapterObject string) string {
+	return chapterObject + ".error"
+}
+
+// publishChapters publishes the tasks to CHAPTER_TOPIC.
+func (p *Pipeline) publishChapters(ctx context.Context, tasks []chapterTask) error {
+	if p.chapterPublisher == nil {
+		return fmt.Errorf("CHAPTER_TOPIC is set, but its publisher wasn't created")
+	}
+	for _, task := range tasks {
+		for i := range task.Segments {
+			task.Segments[i].Voice.CloningKey = ""
+		}
+		data, err := json.Marshal(task)
+		if err != nil {
+			return fmt.Errorf("failed to encode chapter %d of %s: %w", task.Chapter, task.Input, err)
+		}
+		if err := p.chapterPublisher.PublishData(ctx, data, nil); err != nil {
+			return fmt.Errorf("failed to publish chapter %d of %s: %w", task.Chapter, task.Input, err)
+		}
+	}
+	log.Printf("Published %d chapters of %s for synthesis.", len(tasks), tasks[0].Input)
+	return nil
+}
+
+// chapterDone reports whether the chapter whose audio goes to part's output is
+// done, or has failed with the error SynthesizeChapter left for it.
+func (p *Pipeline) chapterDone(ctx context.Context, part synthesisPart) (bool, error) {
+	bucket, object, err := storage.ParseGCSURI(part.OutputURI)
+	if err != nil {
+		return false, err
+	}
+	if _, exists, err := p.store.ObjectMetadata(ctx, bucket, object); err != nil || exists {
+		return exists, err
+	}
+	if _, failed, err := p.store.ObjectMetadata(ctx, bucket, chapterErrorName(object)); err != nil || !failed {
+		return false, err
+	}
+	message, err := p.store.ReadObject(ctx, bucket, chapterErrorName(object))
+	if err != nil {
+		return false, err
+	}
+	return true, errors.New(string(message))
+}
+
+// synthesizeChapter serves the SynthesizeChapter entry point, which
+// synthesizes a chapter published to CHAPTER_TOPIC into its object under the
+// book's tmp/ folder, for FinalizePendingSyntheses to join. Malformed messages
+// and chapters already done are acknowledged. A transient failure returns its
+// error, so the subscription redelivers the chapter; any other leaves the
+// error next to the chapter, failing the book.
+func synthesizeChapter(ctx context.Context, e v2.Event) error {
+	var msg pubSubMessage
+	if err := e.DataAs(&msg); err != nil {
+		log.Printf("Error: Invalid Pub/Sub event %s: %v. Dropping it.", e.ID(), err)
+		return nil
+	}
+	var task chapterTask
+	if err := json.Unmarshal(msg.Message.Data, &task); err != nil || task.OutputURI == "" || len(task.Segments) == 0 {
+		log.Printf("Error: Pub/Sub message %s isn't a chapter task (%v). Dropping it.", msg.Message.MessageID, err)
+		return nil
+	}
+	p, err := functionPipeline()
+	if err != nil {
+		return err
+	}
+	cfg := p.cfg
+	bucket, object, err := storage.ParseGCSURI(task.OutputURI)
+	if err != nil {
+		log.Printf("Error: Chapter task %s has an invalid output: %v. Dropping it.", msg.Message.MessageID, err)
+		return nil
+	}
+	if _, exists, err := p.store.ObjectMetadata(ctx, bucket, object); err != nil {
+		return fmt.Errorf("failed to check chapter %d of %s: %w", task.Chapter, task.Input, err)
+	} else if exists {
+		log.Printf("Chapter %d of %s is already done. Dropping the message.", task.Chapter, task.Input)
+		return nil
+	}
+
+	// A chapter cut short by the instance's shutdown keeps its chunks for the redelivery.
+	ctx, done, err := startWork(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	err = p.composeChapter(ctx, cfg, task, bucket, object)
+	if err == nil {
+		log.Printf("Synthesized chapter %d of %d of %s to %s.", task.Chapter, task.Chapters, task.Input, task.OutputURI)
+		return nil
+	}
+	if isRetryableFailure(err) {
+		return fmt.Errorf("failed to synthesize chapter %d of %s: %w", task.Chapter, task.Input, err)
+	}
+	log.Printf("Error: Failed to synthesize chapter %d of %s: %v", task.Chapter, task.Input, err)
+	message := fmt.Sprintf("chapter %d of %d: %v", task.Chapter, task.Chapters, err)
+	if err := p.store.UploadFile(ctx, bucket, chapterErrorName(object), []byte(message), "text/plain"); err != nil {
+		return fmt.Errorf("failed to record the failure of chapter %d of %s: %w", task.Chapter, task.Input, err)
+	}
+	return nil
+}
+
+// composeChapter synthesizes the segments of a chapter chunk by chunk into the
+// given object, resuming from the chunks of an earlier delivery.
+func (p *Pipeline) composeChapter(ctx context.Context, cfg *Config, task chapterTask, bucket, object string) error {
+	composer, ok := p.store.(storage.Composer)
+	if !ok {
+		return fmt.Errorf("chapters can only be composed with Cloud Storage")
+	}
+	synth, err := tts.NewSynthesizer(ctx, task.Provider, p.providerConfig(cfg))
+	if err != nil {
+		return err
+	}
+	if task.ClonedVoice {
+		for i := range task.Segments {
+			if task.Segments[i].Voice, err = p.customVoice(ctx, cfg, task.Segments[i].Voice); err != nil {
+				return err
+			}
+		}
+	}
+	prefix := strings.TrimSuffix(object, task.Settings.Format.Extension) + "/"
+	_, err = tts.ComposeSegments(ctx, synth, composer, task.Segments, task.Settings, cfg.ChunkConcurrency, bucket, prefix, object, storage.ObjectHeaders{}, true)
+	return err
+}
@@ -0,0 +1,89 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// checkpointPrefix holds the checkpoint of each input being processed, in the
+// input bucket, e.g. "tts-checkpoints/pdf-input/book.pdf.json".
+const checkpointPrefix = "tts-checkpoints/"
+
+// checkpoint is how far the processing of a version of an input has got, so an
+// invocation that timed out or crashed can be resumed by the next attempt
+// rather than restarted. The chunks of a chunked synthesis are checkpointed by
+// themselves: they stay under the output's tmp/ folder until it's done.
+type checkpoint struct {
+	Generation string    `json:"generation"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// Extraction is the document's text, saved once it's extracted.
+	Extraction *extractedDocument `json:"extraction,omitempty"`
+	// Output and ContentKey identify the synthesis under way: the chunks in the
+	// output's tmp/ folder and the long audio operations below are only reused
+	// by an attempt with the same output, voice, settings and text.
+	Output     string `json:"output,omitempty"`
+	ContentKey string `json:"content_key,omitempty"`
+	// Operation, or Parts for a split document, are the long audio operations
+	// started for the synthesis.
+	Operation string          `json:"operation,omitempty"`
+	Parts     []synthesisPart `json:"parts,omitempty"`
+}
+
+// checkpointObjectName returns where the checkpoint of an input is stored.
+func checkpointObjectName(input string) string {
+	return checkpointPrefix + input + ".json"
+}
+
+// loadCheckpoint returns the checkpoint of the given version of an input, or a
+// new one if there's none. A checkpoint of another version is discarded. A
+// failure to read it is only logged: the document then starts over.
+func (p *Pipeline) loadCheckpoint(ctx context.Context, bucket, input, generation string) *checkpoint {
+	fresh := &checkpoint{Generation: generation}
+	name := checkpointObjectName(input)
+	data, _, err := p.store.ReadObjectGeneration(ctx, bucket, name)
+	if err != nil {
+		log.Printf("Warning: Failed to read checkpoint %s: %v. Starting %s over.", name, err, input)
+		return fresh
+	}
+	if data == nil {
+		return fresh
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		log.Printf("Warning: Ignoring invalid checkpoint %s: %v", name, err)
+		return fresh
+	}
+	if cp.Generation != generation {
+		return fresh
+	}
+	log.Printf("Resuming %s from its checkpoint of %s.", input, cp.UpdatedAt.Format(time.RFC3339))
+	return &cp
+}
+
+// saveCheckpoint writes the checkpoint of an input. It still runs once ctx is
+// done, so an invocation cut short saves how far it got. A failure is only
+// logged: the document is processed either way, it just can't be resumed.
+func (p *Pipeline) saveCheckpoint(ctx context.Context, bucket, input string, cp *checkpoint) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	cp.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(cp)
+	if err == nil {
+		err = p.store.UploadFile(ctx, bucket, checkpointObjectName(input), data, "application/json")
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to save the checkpoint of %s: %v", input, err)
+	}
+}
+
+// deleteCheckpoint removes the checkpoint of an input once it's no longer
+// needed. A failure is only logged; a stale checkpoint is ignored by the next
+// version of the input.
+func (p *Pipeline) deleteCheckpoint(ctx context.Context, bucket, input string) {
+	if err := p.store.DeleteObject(ctx, bucket, checkpointObjectName(input)); err != nil {
+		log.Printf("Warning: Failed to delete the checkpoint of %s: %v", input, err)
+	}
+}
@@ -0,0 +1,222 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/drive"
+	"MODULE_NAME/jsou-tts/internal/dropbox"
+	"MODULE_NAME/jsou-tts/internal/email"
+	"MODULE_NAME/jsou-tts/internal/events"
+	"MODULE_NAME/jsou-tts/internal/jobtrack"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tasks"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// loadClients creates the TTS chunk slots and rate limiter of p, and each
+// client whose setting is present in its configuration, e.g. the job tracker
+// when JOBS_COLLECTION is set.
+func (p *Pipeline) loadClients(ctx context.Context) error {
+	cfg := p.cfg
+	p.chunkSlots = tts.NewChunkSlots(cfg.InstanceChunkConcurrency)
+	if cfg.MaxConcurrentJobs == 0 {
+		p.ttsLimiter = tts.NewRateLimiter(cfg.QPS)
+	}
+	if cfg.usesGoogleTTS() {
+		c, err := tts.NewClient(ctx, cfg.ttsEndpoint())
+		if err != nil {
+			return err
+		}
+		p.ttsClient = c
+	}
+	if cfg.JobsCollection != "" {
+		t, err := jobtrack.New(ctx, cfg.FirestoreDatabase, cfg.JobsCollection)
+		if err != nil {
+			return err
+		}
+		p.tracker, p.jobs = t, t
+	}
+	if cfg.JobsTopic != "" {
+		pub, err := events.NewPublisher(ctx, cfg.JobsTopic, "pdf-to-speech")
+		if err != nil {
+			return err
+		}
+		p.jobPublisher = pub
+	}
+	if cfg.EmailProvider != "" {
+		m, err := p.newMailer(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		p.mailer = m
+	}
+	if cfg.EventsTopic != "" {
+		pub, err := events.NewPublisher(ctx, cfg.EventsTopic, "pdf-to-speech")
+		if err != nil {
+			return err
+		}
+		p.publisher = pub
+	}
+	if cfg.RetryQueue != "" {
+		q, err := tasks.New(ctx, cfg.RetryQueue, cfg.RetryURL, cfg.RetryServiceAccount)
+		if err != nil {
+			return err
+		}
+		p.retryQueue = q
+	}
+	if cfg.ExtractedTextTopic != "" {
+		pub, err := events.NewPublisher(ctx, cfg.ExtractedTextTopic, "pdf-to-speech")
+		if err != nil {
+			return err
+		}
+		p.textPublisher = pub
+	}
+	if cfg.ChapterTopic != "" {
+		pub, err := events.NewPublisher(ctx, cfg.ChapterTopic, "pdf-to-speech")
+		if err != nil {
+			return err
+		}
+		p.chapterPublisher = pub
+	}
+	if cfg.DriveFolderID != "" {
+		d, err := drive.New(ctx)
+		if err != nil {
+			return err
+		}
+		p.driveClient = d
+	}
+	if cfg.DropboxFolder != "" {
+		d, err := p.newDropboxClient(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		p.dropboxClient = d
+	}
+	if cfg.JobsAPIKeySecret != "" {
+		key, err := p.secrets.Access(ctx, cfg.JobsAPIKeySecret)
+		if err != nil {
+			return fmt.Errorf("failed to read the Jobs API key: %w", err)
+		}
+		p.jobsAPIKey = key
+	}
+	return nil
+}
+
+// newMailer creates the email sender chosen by EMAIL_PROVIDER: SendGrid with
+// the API key in the secret SENDGRID_API_KEY_SECRET, or SES in SES_REGION with
+// the access key in the secret SES_CREDENTIALS_SECRET, a JSON object with
+// access_key_id and secret_access_key.
+func (p *Pipeline) newMailer(ctx context.Context, cfg *Config) (email.Sender, error) {
+	if cfg.EmailProvider == email.ProviderSendGrid {
+		key, err := p.secrets.Access(ctx, cfg.SendGridKeySecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the SendGrid API key: %w", err)
+		}
+		return email.NewSendGrid(strings.TrimSpace(key)), nil
+	}
+	raw, err := p.secrets.Access(ctx, cfg.SESCredentialsSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the SES credentials: %w", err)
+	}
+	var creds email.SESCredentials
+	if err := json.Unmarshal([]byte(raw), &creds); err != nil || creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("SES_CREDENTIALS_SECRET must hold a JSON object with access_key_id and secret_access_key")
+	}
+	return email.NewSES(ctx, cfg.SESRegion, creds)
+}
+
+// newDropboxClient creates the Dropbox client with the app credentials in the
+// secret DROPBOX_CREDENTIALS_SECRET, a JSON object with app_key, app_secret
+// and refresh_token.
+func (p *Pipeline) newDropboxClient(ctx context.Context, cfg *Config) (*dropbox.Client, error) {
+	raw, err := p.secrets.Access(ctx, cfg.DropboxCredentialsSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Dropbox credentials: %w", err)
+	}
+	var creds dropbox.Credentials
+	if err := json.Unmarshal([]byte(raw), &creds); err != nil {
+		return nil, fmt.Errorf("DROPBOX_CREDENTIALS_SECRET must hold a JSON object with app_key, app_secret and refresh_token")
+	}
+	return dropbox.New(creds)
+}
+
+// newStorage creates the storage backend chosen by STORAGE_BACKEND. By default
+// it's Cloud Storage, with the KMS_KEY_NAME key and requests billed to
+// STORAGE_BILLING_PROJECT if they're set. With s3, it's Amazon S3, with
+// KMS_KEY_NAME as an AWS KMS key. With azure, it's the Azure storage account
+// AZURE_STORAGE_ACCOUNT_URL. With local, it's the directory LOCAL_STORAGE_DIR,
+// where each bucket is a subdirectory.
+func (p *Pipeline) newStorage(ctx context.Context, cfg *Config) (storage.Storage, error) {
+	backend := cfg.StorageBackend
+	if backend != "" && backend != "gcs" && cfg.StorageBillingProject != "" {
+		return nil, fmt.Errorf("STORAGE_BILLING_PROJECT is only supported with Cloud Storage, not STORAGE_BACKEND=%s", backend)
+	}
+	switch backend {
+	case "", "gcs":
+		c, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Encrypt everything the function writes with a customer-managed key if one is configured.
+		if cfg.KMSKeyName != "" {
+			c.SetKMSKey(cfg.KMSKeyName)
+		}
+		// Bill requests to our own project, so inputs can be read from other teams' requester-pays buckets.
+		if cfg.StorageBillingProject != "" {
+			c.SetBillingProject(cfg.StorageBillingProject)
+		}
+		// The library sends requests to an emulator such as fake-gcs-server on its own; signed URLs need telling.
+		if cfg.StorageEmulatorHost != "" {
+			c.SetEmulatorHost(cfg.StorageEmulatorHost)
+		}
+		return c, nil
+	case "s3":
+		s, err := storage.NewS3(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.KMSKeyName != "" {
+			s.SetKMSKey(cfg.KMSKeyName)
+		}
+		return s, nil
+	case "azure":
+		return p.newAzureBlob(ctx, cfg)
+	case "local":
+		if cfg.LocalStorageDir == "" {
+			return nil, fmt.Errorf("STORAGE_BACKEND=local needs LOCAL_STORAGE_DIR")
+		}
+		if cfg.KMSKeyName != "" {
+			return nil, fmt.Errorf("KMS_KEY_NAME isn't supported with STORAGE_BACKEND=local")
+		}
+		return storage.NewLocal(cfg.LocalStorageDir)
+	default:
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND %q (want gcs, s3, azure or local)", backend)
+	}
+}
+
+// newAzureBlob creates the Azure Blob Storage backend. AZURE_STORAGE_SAS_SECRET
+// names the Secret Manager secret holding the SAS token requests are authorized
+// with, and the optional AZURE_STORAGE_KEY_SECRET the one holding the account
+// key, which signs the URLs in manifests.
+func (p *Pipeline) newAzureBlob(ctx context.Context, cfg *Config) (storage.Storage, error) {
+	if cfg.AzureStorageAccount == "" || cfg.AzureStorageSASSecret == "" {
+		return nil, fmt.Errorf("STORAGE_BACKEND=azure needs AZURE_STORAGE_ACCOUNT_URL and AZURE_STORAGE_SAS_SECRET")
+	}
+	if cfg.KMSKeyName != "" {
+		return nil, fmt.Errorf("KMS_KEY_NAME isn't supported with STORAGE_BACKEND=azure; set the storage account's encryption key instead")
+	}
+	sas, err := p.secrets.Access(ctx, cfg.AzureStorageSASSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Azure SAS token: %w", err)
+	}
+	var key string
+	if cfg.AzureStorageKeySecret != "" {
+		if key, err = p.secrets.Access(ctx, cfg.AzureStorageKeySecret); err != nil {
+			return nil, fmt.Errorf("failed to read the Azure storage account key: %w", err)
+		}
+	}
+	return storage.NewAzureBlob(cfg.AzureStorageAccount, sas, key)
+}
@@ -0,0 +1,41 @@
+// Command jobsserver serves the Jobs service over gRPC and the Jobs API over
+// REST on one port, for running the pipeline as a service on Cloud Run:
+//
+//	gcloud run deploy pdf-to-speech --source . --use-http2 \
+//	  --set-build-env-vars GOOGLE_BUILDABLE=./cmd/jobsserver ...
+//
+// It reads the same environment variables as the function, and listens on
+// PORT, or 8080, for HTTP/1.1 and unencrypted HTTP/2, which gRPC needs.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	pdftospeech "MODULE_NAME/jsou-tts"
+)
+
+func main() {
+	port := "8080"
+	if envPort := os.Getenv("PORT"); envPort != "" {
+		port = envPort
+	}
+	flag.StringVar(&port, "port", port, "port to listen on (default: PORT, or 8080)")
+	flag.Parse()
+
+	pdftospeech.WatchShutdown()
+	handler, err := pdftospeech.NewServiceHandler()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{Addr: ":" + port, Handler: handler, Protocols: &protocols}
+	log.Printf("Serving the Jobs service on port %s.", port)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
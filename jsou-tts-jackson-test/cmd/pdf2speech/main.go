@@ -0,0 +1,173 @@
+// Command pdf2speech runs the function's pipeline (extraction, normalization
+// and synthesis) against one PDF and writes the audio to a local file, for
+// testing and one-off conversions without deploying the function:
+//
+//	go run ./cmd/pdf2speech -voice en-GB-Neural2-B -o book.mp3 book.pdf
+//	go run ./cmd/pdf2speech gs://my-bucket/books/book.pdf
+//
+// The provider and audio settings come from the same environment variables as
+// the function's. A local PDF is processed with the local storage backend in a
+// temporary directory unless STORAGE_BACKEND is set; a gs:// URI is processed
+// in Cloud Storage, where its output is written as usual before being
+// downloaded. Long audio is always waited for.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pdftospeech "MODULE_NAME/jsou-tts"
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// localBucket is the bucket a local PDF is copied into.
+const localBucket = "pdf2speech"
+
+// options collects repeated -set key=value flags.
+type options map[string]string
+
+func (o options) String() string { return fmt.Sprint(map[string]string(o)) }
+
+func (o options) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("%q isn't key=value", s)
+	}
+	o[key] = value
+	return nil
+}
+
+func main() {
+	out := flag.String("o", "", "audio file to write (default: the PDF's name with the audio's extension, in the current directory)")
+	voice := flag.String("voice", "", "voice to use, unless -set tts-voice names one")
+	opts := options{}
+	flag.Var(opts, "set", "per-document setting as metadata key=value, e.g. -set tts-speaking-rate=1.2 (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: pdf2speech [flags] book.pdf | gs://bucket/book.pdf\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(context.Background(), flag.Arg(0), *out, *voice, opts); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// run converts input to audio at out, read with voice unless opts name one.
+func run(ctx context.Context, input, out, voice string, opts options) error {
+	// The CLI waits for its output: there's no finalizer to hand it to.
+	os.Setenv("ASYNC_LONG_AUDIO", "false")
+	os.Setenv("MAX_SYNTHESIS_WAIT", "0")
+
+	var bucket, object string
+	if strings.HasPrefix(input, "gs://") {
+		var err error
+		if bucket, object, err = storage.ParseGCSURI(input); err != nil {
+			return err
+		}
+	} else {
+		dir, err := os.MkdirTemp("", "pdf2speech-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		if os.Getenv("STORAGE_BACKEND") == "" {
+			os.Setenv("STORAGE_BACKEND", "local")
+			os.Setenv("LOCAL_STORAGE_DIR", dir)
+			os.Setenv("OUTPUT_BUCKET", "")
+			// Long Audio Synthesis writes to Cloud Storage, which the local
+			// backend can't read.
+			if os.Getenv("SYNTHESIS_MODE") == "" {
+				os.Setenv("SYNTHESIS_MODE", "chunked")
+			}
+		}
+		if os.Getenv("STORAGE_BACKEND") != "local" {
+			return fmt.Errorf("a local PDF needs the local storage backend; upload it and pass its gs:// URI instead")
+		}
+		bucket, object = localBucket, filepath.Base(input)
+		if err := copyFile(input, filepath.Join(os.Getenv("LOCAL_STORAGE_DIR"), bucket, object)); err != nil {
+			return err
+		}
+	}
+
+	pipeline, err := pdftospeech.NewPipeline(pdftospeech.WithVoice(voice))
+	if err != nil {
+		return err
+	}
+	defer pipeline.Close()
+	// On SIGTERM, stop starting chunks and save a checkpoint before exiting.
+	pdftospeech.WatchShutdown()
+	output, err := pipeline.Process(ctx, pdftospeech.Source{Bucket: bucket, Object: object, ContentType: "application/pdf", Metadata: opts})
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		return fmt.Errorf("%s produced no audio", input)
+	}
+	if out == "" {
+		name := filepath.Base(object)
+		out = strings.TrimSuffix(name, filepath.Ext(name)) + filepath.Ext(output)
+	}
+	if err := fetchOutput(ctx, output, out); err != nil {
+		return err
+	}
+	log.Printf("Wrote %s.", out)
+	return nil
+}
+
+// fetchOutput copies the audio at outputURI to the file out.
+func fetchOutput(ctx context.Context, outputURI, out string) error {
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		return err
+	}
+	if os.Getenv("STORAGE_BACKEND") == "local" {
+		return copyFile(filepath.Join(os.Getenv("LOCAL_STORAGE_DIR"), bucket, filepath.FromSlash(object)), out)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	r, _, err := client.OpenObject(ctx, bucket, object)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", outputURI, err)
+	}
+	defer r.Close()
+	return writeFile(out, r)
+}
+
+// copyFile copies the file src to dst, creating dst's directory.
+func copyFile(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return writeFile(dst, f)
+}
+
+// writeFile writes everything read from r to the file name.
+func writeFile(name string, r io.Reader) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,208 @@
+// Command sendevent posts a synthetic CloudEvent to a function served by
+// cmd/server, the way Eventarc would, so the handlers can be exercised end to
+// end against emulators:
+//
+//	STORAGE_EMULATOR_HOST=localhost:4443 go run ./cmd/sendevent -bucket test -object pdf-input/book.pdf -file book.pdf -set tts-voice=en-GB-Neural2-B
+//	go run ./cmd/sendevent -type deleted -bucket test -object pdf-input/book.pdf -url http://localhost:8080/CleanUpDeletedInput
+//	go run ./cmd/sendevent -type pubsub -data '{"bucket": "test", "object": "pdf-input/book.pdf"}' -url http://localhost:8080/ProcessPubSubRequest
+//
+// A storage event describes the object as it is in the bucket, with its
+// generation and metadata; -file uploads a local PDF there first, with the
+// -set metadata. A Pub/Sub event wraps -data in a push message. The response
+// status and body are printed, and the command exits non-zero on an error
+// response.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// Event types, as Eventarc names them.
+var eventTypes = map[string]string{
+	"finalized": "google.cloud.storage.object.v1.finalized",
+	"deleted":   "google.cloud.storage.object.v1.deleted",
+	"pubsub":    "google.cloud.pubsub.topic.v1.messagePublished",
+}
+
+// options collects repeated -set key=value flags.
+type options map[string]string
+
+func (o options) String() string { return fmt.Sprint(map[string]string(o)) }
+
+func (o options) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("%q isn't key=value", s)
+	}
+	o[key] = value
+	return nil
+}
+
+func main() {
+	url := flag.String("url", "http://localhost:8080", "URL of the entry point")
+	kind := flag.String("type", "finalized", "event type: finalized, deleted or pubsub")
+	bucket := flag.String("bucket", "", "bucket of the object (storage events)")
+	object := flag.String("object", "", "name of the object (storage events)")
+	file := flag.String("file", "", "local PDF to upload as the object first (finalized events)")
+	generation := flag.String("generation", "", "generation of the object, when it isn't read from the bucket")
+	data := flag.String("data", "", "message data, or @file to read it from (pubsub events)")
+	metadata := options{}
+	flag.Var(metadata, "set", "object metadata as key=value, e.g. -set tts-voice=en-GB-Neural2-B (repeatable)")
+	flag.Parse()
+
+	eventType, ok := eventTypes[*kind]
+	if !ok {
+		log.Fatalf("Error: Unknown event type %q (want finalized, deleted or pubsub)", *kind)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var source, subject string
+	var body any
+	if *kind == "pubsub" {
+		message, err := messageData(*data)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		source = "//pubsub.googleapis.com/projects/local/topics/local"
+		body = map[string]any{
+			"message": map[string]any{
+				"data":        message,
+				"messageId":   strconv.FormatInt(time.Now().UnixNano(), 10),
+				"publishTime": time.Now().UTC().Format(time.RFC3339Nano),
+			},
+			"subscription": "projects/local/subscriptions/local",
+		}
+	} else {
+		if *bucket == "" || *object == "" {
+			log.Fatalf("Error: -bucket and -object are required for %s events", *kind)
+		}
+		obj, err := storageObject(ctx, *bucket, *object, *file, *generation, metadata, *kind == "deleted")
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		source = "//storage.googleapis.com/projects/_/buckets/" + *bucket
+		subject = "objects/" + *object
+		body = obj
+	}
+
+	if err := post(ctx, *url, eventType, source, subject, body); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// messageData returns the data of a Pub/Sub message given as -data.
+func messageData(data string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(data, "@"); ok {
+		return os.ReadFile(name)
+	}
+	if data == "" {
+		return nil, fmt.Errorf("-data is required for pubsub events")
+	}
+	return []byte(data), nil
+}
+
+// storageObject returns the data of a storage event for the object, uploading
+// file as the object first if it's set. The generation is read from the bucket
+// unless it's given, or the object was deleted.
+func storageObject(ctx context.Context, bucket, object, file, generation string, metadata map[string]string, deleted bool) (map[string]any, error) {
+	obj := map[string]any{
+		"bucket":      bucket,
+		"name":        object,
+		"contentType": "application/pdf",
+		"metadata":    metadata,
+	}
+	if file == "" && (deleted || generation != "") {
+		obj["generation"] = generation
+		return obj, nil
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		client.SetEmulatorHost(host)
+	} else {
+		log.Printf("Warning: STORAGE_EMULATOR_HOST isn't set. Using Cloud Storage.")
+	}
+	if file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.UploadFile(ctx, bucket, object, content, "application/pdf"); err != nil {
+			return nil, err
+		}
+		if len(metadata) > 0 {
+			if err := client.UpdateObjectMetadata(ctx, bucket, object, metadata); err != nil {
+				return nil, err
+			}
+		}
+	}
+	content, gen, err := client.ReadObjectGeneration(ctx, bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, fmt.Errorf("gs://%s/%s doesn't exist; upload it with -file", bucket, object)
+	}
+	stored, _, err := client.ObjectMetadata(ctx, bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(content)
+	obj["generation"] = strconv.FormatInt(gen, 10)
+	obj["md5Hash"] = base64.StdEncoding.EncodeToString(sum[:])
+	obj["metadata"] = stored
+	return obj, nil
+}
+
+// post sends a CloudEvent in binary content mode, as Eventarc does, and prints
+// the response.
+func post(ctx context.Context, url, eventType, source, subject string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Id", strconv.FormatInt(time.Now().UnixNano(), 10))
+	req.Header.Set("Ce-Type", eventType)
+	req.Header.Set("Ce-Source", source)
+	req.Header.Set("Ce-Time", time.Now().UTC().Format(time.RFC3339Nano))
+	if subject != "" {
+		req.Header.Set("Ce-Subject", subject)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(resp.Body)
+	fmt.Printf("%s %s\n", resp.Status, strings.TrimSpace(string(response)))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded %s", url, resp.Status)
+	}
+	return nil
+}
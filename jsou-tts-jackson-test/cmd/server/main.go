@@ -0,0 +1,42 @@
+// Command server serves the function's entry points with the Functions
+// Framework, for running the pipeline end to end on a development machine or
+// in CI, e.g. against fake-gcs-server:
+//
+//	STORAGE_EMULATOR_HOST=localhost:4443 go run ./cmd/server -target ProcessPDFToSpeechTest
+//
+// With a target, that entry point is served at /, as Cloud Functions does;
+// without one, every entry point is served at /<name>, e.g.
+// /SynthesizeExtractedText. Post events to it with cmd/sendevent.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	pdftospeech "MODULE_NAME/jsou-tts"
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+)
+
+func main() {
+	port := "8080"
+	if envPort := os.Getenv("PORT"); envPort != "" {
+		port = envPort
+	}
+	flag.StringVar(&port, "port", port, "port to listen on (default: PORT, or 8080)")
+	target := flag.String("target", os.Getenv("FUNCTION_TARGET"), "entry point to serve at / (default: FUNCTION_TARGET)")
+	flag.Parse()
+
+	// The framework reads the target from the environment.
+	if *target != "" {
+		os.Setenv("FUNCTION_TARGET", *target)
+	}
+	if os.Getenv("STORAGE_EMULATOR_HOST") == "" && os.Getenv("STORAGE_BACKEND") == "" {
+		log.Printf("Warning: Neither STORAGE_EMULATOR_HOST nor STORAGE_BACKEND is set. Events will be processed against Cloud Storage.")
+	}
+	pdftospeech.WatchShutdown()
+	log.Printf("Serving on port %s. Post events with: go run ./cmd/sendevent -url http://localhost:%s ...", port, port)
+	if err := funcframework.Start(port); err != nil {
+		log.Fatalf("funcframework.Start: %v", err)
+	}
+}
@@ -0,0 +1,581 @@
+package pdftospeech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/email"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the function's configuration. It's loaded by NewPipeline and
+// passed to the handlers. Every setting has the name of its
+// environment variable in its env tag, and is read from that variable or, if
+// the variable is empty, from the config object CONFIG_OBJECT names. Settings
+// tagged envonly configure the storage the config object is read from, so
+// they're only read from the environment.
+type Config struct {
+	// ConfigObject is the gs:// URI of an optional JSON or YAML object (by its
+	// extension, .json, .yaml or .yml) of settings keyed by variable name.
+	ConfigObject string `env:"CONFIG_OBJECT,envonly"`
+
+	// Storage.
+	StorageBackend        string `env:"STORAGE_BACKEND,envonly"`
+	LocalStorageDir       string `env:"LOCAL_STORAGE_DIR,envonly"`
+	KMSKeyName            string `env:"KMS_KEY_NAME,envonly"`
+	StorageBillingProject string `env:"STORAGE_BILLING_PROJECT,envonly"`
+	StorageEmulatorHost   string `env:"STORAGE_EMULATOR_HOST,envonly"`
+	AzureStorageAccount   string `env:"AZURE_STORAGE_ACCOUNT_URL,envonly"`
+	AzureStorageSASSecret string `env:"AZURE_STORAGE_SAS_SECRET,envonly"`
+	AzureStorageKeySecret string `env:"AZURE_STORAGE_KEY_SECRET,envonly"`
+	// Revision is the deployed revision, set by Cloud Functions.
+	Revision string `env:"K_REVISION,envonly"`
+
+	// Inputs and outputs.
+	BaseBucket        string        `env:"BASE_GCS_BUCKET"`
+	OutputBucket      string        `env:"OUTPUT_BUCKET"`
+	InputPrefix       string        `env:"INPUT_PREFIX"`
+	InputPatterns     string        `env:"INPUT_PATTERNS"`
+	AudiobookSources  string        `env:"AUDIOBOOK_SOURCE_PREFIX"` // Folders audiobook manifests may read PDFs from.
+	OutputPrefix      string        `env:"OUTPUT_PREFIX"`
+	OutputNameFormat  string        `env:"OUTPUT_NAME_TEMPLATE"`
+	MoveProcessed     bool          `env:"MOVE_PROCESSED"`
+	PropagateMetadata string        `env:"PROPAGATE_METADATA"`
+	MaxInputBytes     int64         `env:"MAX_INPUT_BYTES"`
+	TmpMaxAge         time.Duration `env:"TMP_MAX_AGE"`
+	StuckAfter        time.Duration `env:"SWEEP_STUCK_AFTER"`
+	SignedURLTTL      time.Duration `env:"SIGNED_URL_TTL"`
+	CacheControl      string        `env:"OUTPUT_CACHE_CONTROL"`
+	// ContentDisposition is "attachment", "inline" or empty.
+	ContentDisposition string `env:"OUTPUT_CONTENT_DISPOSITION"`
+	ContentLanguage    string `env:"OUTPUT_CONTENT_LANGUAGE"`
+	// DeleteOutputsWithInput has CleanUpDeletedInput delete the outputs of a
+	// PDF deleted from the input folder or processed/.
+	DeleteOutputsWithInput bool `env:"DELETE_OUTPUTS_WITH_INPUT"`
+	// ArchivePreviousOutputs keeps the audio of an earlier version of a
+	// re-uploaded PDF under a versioned name.
+	ArchivePreviousOutputs bool `env:"ARCHIVE_PREVIOUS_OUTPUTS"`
+
+	// Text-to-Speech.
+	Provider      string `env:"TTS_PROVIDER"`
+	ProjectNumber string `env:"PROJECT_NUMBER"`
+	Location      string `env:"GCP_LOCATION"`
+	Region        string `env:"TTS_REGION"`
+	Endpoint      string `env:"TTS_ENDPOINT"`
+	AudioEncoding string `env:"AUDIO_ENCODING"`
+	VoiceMapJSON  string `env:"VOICE_MAP"`
+	ValidateVoice bool   `env:"VALIDATE_VOICE"`
+	// CustomVoiceUsage is "REALTIME", "OFFLINE" or empty.
+	CustomVoiceUsage    string `env:"CUSTOM_VOICE_USAGE"`
+	CustomVoiceModel    string `env:"CUSTOM_VOICE_MODEL"`
+	CloningKeySecret    string `env:"VOICE_CLONING_KEY_SECRET"`
+	PollyEngine         string `env:"POLLY_ENGINE"`
+	PollyOutputBucket   string `env:"POLLY_OUTPUT_BUCKET"`
+	AzureSpeechRegion   string `env:"AZURE_SPEECH_REGION"`
+	AzureSpeechKey      string `env:"AZURE_SPEECH_KEY_SECRET"`
+	ElevenLabsModel     string `env:"ELEVENLABS_MODEL"`
+	ElevenLabsKeySecret string `env:"ELEVENLABS_API_KEY_SECRET"`
+	OpenAIModel         string `env:"OPENAI_TTS_MODEL"`
+	OpenAIKeySecret     string `env:"OPENAI_API_KEY_SECRET"`
+	PiperBinary         string `env:"PIPER_BINARY"`
+	PiperModelDir       string `env:"PIPER_MODEL_DIR"`
+
+	// Defaults of the per-document settings, which the input's metadata overrides.
+	VoiceName       string  `env:"TTS_VOICE_NAME"`
+	VoiceGender     string  `env:"TTS_VOICE_GENDER"`
+	SpeakingRate    float64 `env:"SPEAKING_RATE"`
+	Pitch           float64 `env:"PITCH"`
+	VolumeGainDb    float64 `env:"VOLUME_GAIN_DB"`
+	SampleRateHertz int32   `env:"SAMPLE_RATE_HERTZ"`
+	EffectsProfile  string  `env:"EFFECTS_PROFILE"`
+	GeminiPrompt    string  `env:"GEMINI_TTS_PROMPT"`
+	DialogueMode    bool    `env:"DIALOGUE_MODE"`
+	Timepoints      bool    `env:"TIMEPOINTS"`
+	DryRun          bool    `env:"DRY_RUN"`
+
+	// Text preparation.
+	ExpandAbbreviations bool   `env:"EXPAND_ABBREVIATIONS"`
+	AbbreviationsObject string `env:"ABBREVIATIONS_OBJECT"`
+	SayAs               bool   `env:"SAY_AS"`
+	LexiconObject       string `env:"LEXICON_OBJECT"`
+	DialogueVoiceList   string `env:"DIALOGUE_VOICES"`
+	SpeakerVoicesJSON   string `env:"SPEAKER_VOICES"`
+
+	// Synthesis.
+	SynthesisMode    string `env:"SYNTHESIS_MODE"`
+	ChunkConcurrency int    `env:"CHUNK_CONCURRENCY"`
+	// InstanceChunkConcurrency caps the chunk requests of all documents an
+	// instance handles at once; zero means no cap beyond ChunkConcurrency.
+	InstanceChunkConcurrency int           `env:"INSTANCE_CHUNK_CONCURRENCY"`
+	ExtractionConcurrency    int           `env:"EXTRACTION_CONCURRENCY"`
+	MaxAttempts              int           `env:"TTS_MAX_ATTEMPTS"`
+	AsyncLongAudio           bool          `env:"ASYNC_LONG_AUDIO"`
+	MaxSynthesisWait         time.Duration `env:"MAX_SYNTHESIS_WAIT"`
+	MaxConcurrentJobs        int           `env:"TTS_MAX_CONCURRENT_JOBS"`
+	QPS                      float64       `env:"TTS_QPS"`
+	Deduplicate              bool          `env:"DEDUPLICATE"`
+	Checkpoints              bool          `env:"CHECKPOINTS"`
+	DeduplicateEvents        bool          `env:"DEDUPLICATE_EVENTS"`
+	// Budgets in USD; zero means no limit.
+	MaxCostPerDocument float64 `env:"MAX_COST_PER_DOCUMENT"`
+	MonthlyCostBudget  float64 `env:"MONTHLY_COST_BUDGET"`
+
+	// Delivery over SFTP, enabled by SFTPHost.
+	SFTPHost           string `env:"SFTP_HOST"`
+	SFTPUser           string `env:"SFTP_USER"`
+	SFTPHostKey        string `env:"SFTP_HOST_KEY"`
+	SFTPKeySecret      string `env:"SFTP_KEY_SECRET"`
+	SFTPPasswordSecret string `env:"SFTP_PASSWORD_SECRET"`
+	SFTPDir            string `env:"SFTP_DIR"`
+
+	// Google Drive intake, enabled by DriveFolderID: SyncDriveFolder copies the
+	// PDFs added to that folder into the input folder, and their audio is
+	// uploaded to DriveOutputFolderID, by default the same folder.
+	DriveFolderID       string `env:"DRIVE_FOLDER_ID"`
+	DriveOutputFolderID string `env:"DRIVE_OUTPUT_FOLDER_ID"`
+
+	// Dropbox intake, enabled by DropboxFolder: DropboxWebhook copies the PDFs
+	// added to that folder into the input folder, and their audio is uploaded
+	// to DropboxOutputFolder, by default the same folder. The app's
+	// credentials are in DropboxCredentialsSecret.
+	DropboxFolder            string `env:"DROPBOX_FOLDER"`
+	DropboxOutputFolder      string `env:"DROPBOX_OUTPUT_FOLDER"`
+	DropboxCredentialsSecret string `env:"DROPBOX_CREDENTIALS_SECRET"`
+
+	// Job tracking in Firestore, enabled by JobsCollection, which also keeps
+	// the records of on-demand jobs.
+	JobsCollection    string `env:"JOBS_COLLECTION"`
+	FirestoreDatabase string `env:"FIRESTORE_DATABASE"`
+
+	// On-demand jobs: each queued job is published to JobsTopic
+	// ("projects/P/topics/T"), and run by RunOnDemandJob, subscribed to it.
+	JobsTopic string `env:"JOBS_TOPIC"`
+
+	// The key clients of ProcessOnDemand, the Jobs API and the Jobs service
+	// send, in the secret JobsAPIKeySecret. None serves requests without it.
+	JobsAPIKeySecret string `env:"JOBS_API_KEY_SECRET"`
+
+	// Completion callbacks, signed with the key in WebhookKeySecret.
+	WebhookURL       string `env:"WEBHOOK_URL"`
+	WebhookKeySecret string `env:"WEBHOOK_SIGNING_KEY_SECRET"`
+
+	// Retries of transient failures through Cloud Tasks, enabled by RetryQueue
+	// ("projects/P/locations/L/queues/Q"). Tasks POST to RetryURL, the
+	// RetryDocument entry point, as RetryServiceAccount.
+	RetryQueue          string        `env:"RETRY_QUEUE"`
+	RetryURL            string        `env:"RETRY_URL"`
+	RetryServiceAccount string        `env:"RETRY_SERVICE_ACCOUNT"`
+	RetryMaxAttempts    int           `env:"RETRY_MAX_ATTEMPTS"`
+	RetryBaseDelay      time.Duration `env:"RETRY_BASE_DELAY"`
+
+	// Completion events, published as CloudEvents to the Pub/Sub topic
+	// EventsTopic ("projects/P/topics/T").
+	EventsTopic string `env:"EVENTS_TOPIC"`
+
+	// Staged processing: with ExtractedTextTopic ("projects/P/topics/T") set,
+	// the upload trigger only extracts text, and SynthesizeExtractedText,
+	// subscribed to the topic, synthesizes it.
+	ExtractedTextTopic string `env:"EXTRACTED_TEXT_TOPIC"`
+
+	// Chapter fan-out: with ChapterTopic ("projects/P/topics/T") set, books
+	// with chapters are synthesized by one SynthesizeChapter invocation per
+	// chapter, subscribed to the topic.
+	ChapterTopic string `env:"CHAPTER_TOPIC"`
+
+	// Chat notifications, posted to the Slack or Google Chat incoming webhook
+	// whose URL is in the secret ChatWebhookSecret.
+	ChatWebhookSecret string `env:"CHAT_WEBHOOK_SECRET"`
+
+	// Email notifications, enabled by EmailProvider ("sendgrid" or "ses").
+	EmailProvider        string `env:"EMAIL_PROVIDER"`
+	EmailFrom            string `env:"EMAIL_FROM"`
+	SendGridKeySecret    string `env:"SENDGRID_API_KEY_SECRET"`
+	SESRegion            string `env:"SES_REGION"`
+	SESCredentialsSecret string `env:"SES_CREDENTIALS_SECRET"`
+
+	// Parsed by validate.
+	AudioFormat      tts.AudioFormat
+	OutputTemplate   outputNameTemplate
+	InputRules       []inputRule
+	AudiobookFolders []string // The folders of AudiobookSources, each ending in "/".
+	VoiceMap         tts.VoiceMap
+	SpeakerVoices    map[string]string
+	DialogueVoices   []string
+}
+
+// defaultConfig returns the configuration when nothing is set.
+func defaultConfig() *Config {
+	return &Config{
+		MoveProcessed:          true,
+		PropagateMetadata:      defaultPropagatedMetadata,
+		TmpMaxAge:              defaultTmpMaxAge,
+		StuckAfter:             defaultStuckAfter,
+		ValidateVoice:          true,
+		ExpandAbbreviations:    true,
+		SayAs:                  true,
+		SynthesisMode:          string(modeAuto),
+		ChunkConcurrency:       tts.DefaultChunkConcurrency,
+		ExtractionConcurrency:  1,
+		MaxAttempts:            tts.DefaultRetryPolicy.MaxAttempts,
+		Deduplicate:            true,
+		Checkpoints:            true,
+		DeduplicateEvents:      true,
+		ArchivePreviousOutputs: true,
+		RetryMaxAttempts:       defaultRetryMaxAttempts,
+		RetryBaseDelay:         defaultRetryBaseDelay,
+	}
+}
+
+// loadEnvConfig reads the configuration from the environment alone, as far as
+// it's needed to create the storage the config object is read from.
+func loadEnvConfig() (*Config, error) {
+	c := defaultConfig()
+	return c, c.apply(func(name string, envOnly bool) (string, bool) {
+		v := os.Getenv(name)
+		return v, v != ""
+	})
+}
+
+// loadConfigObject adds the settings of the config object, if CONFIG_OBJECT
+// names one, to c for those not set in the environment, and validates the
+// result. Unknown and environment-only settings in the object are errors, so
+// typos don't go unnoticed.
+func (c *Config) loadConfigObject(ctx context.Context, s storage.Storage) error {
+	if c.ConfigObject != "" {
+		bucket, object, err := storage.ParseGCSURI(c.ConfigObject)
+		if err != nil {
+			return fmt.Errorf("invalid CONFIG_OBJECT: %w", err)
+		}
+		data, err := s.ReadObject(ctx, bucket, object)
+		if err != nil {
+			return fmt.Errorf("failed to read CONFIG_OBJECT %s: %w", c.ConfigObject, err)
+		}
+		settings, err := parseConfigObject(object, data)
+		if err != nil {
+			return fmt.Errorf("invalid CONFIG_OBJECT %s: %w", c.ConfigObject, err)
+		}
+		known := map[string]bool{}
+		err = c.apply(func(name string, envOnly bool) (string, bool) {
+			if envOnly {
+				return "", false
+			}
+			known[name] = true
+			v, ok := settings[name]
+			return v, ok && os.Getenv(name) == ""
+		})
+		if err != nil {
+			return err
+		}
+		for name := range settings {
+			if !known[name] {
+				return fmt.Errorf("CONFIG_OBJECT %s sets %s, which isn't a setting that can be set there", c.ConfigObject, name)
+			}
+		}
+	}
+	return c.validate()
+}
+
+// parseConfigObject decodes a config object into settings keyed by variable
+// name. Scalars are taken as written; a list becomes a comma-separated value and
+// an object its JSON, e.g. for VOICE_MAP.
+func parseConfigObject(name string, data []byte) (map[string]string, error) {
+	raw := map[string]any{}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+	}
+	settings := make(map[string]string, len(raw))
+	for name, value := range raw {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			settings[name] = v
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			settings[name] = strings.Join(items, ",")
+		case map[string]any:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			settings[name] = string(encoded)
+		default:
+			settings[name] = fmt.Sprint(v)
+		}
+	}
+	return settings, nil
+}
+
+// apply sets each field of c that lookup returns a value for, parsed by the
+// field's type.
+func (c *Config) apply(lookup func(name string, envOnly bool) (string, bool)) error {
+	v := reflect.ValueOf(c).Elem()
+	for i := range v.NumField() {
+		tag, ok := v.Type().Field(i).Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		raw, ok := lookup(name, options == "envonly")
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), strings.TrimSpace(raw)); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, raw, err)
+		}
+	}
+	return nil
+}
+
+// setField parses raw into the field f.
+func setField(f reflect.Value, raw string) error {
+	if f.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 10m")
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		f.SetInt(n)
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		f.SetFloat(x)
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.Type())
+	}
+	return nil
+}
+
+// validate checks the settings against each other and their ranges, and parses
+// those with a structure of their own, so a misconfiguration fails before any
+// document is read rather than halfway through one.
+func (c *Config) validate() error {
+	var err error
+	if c.AudioFormat, err = tts.ParseAudioFormat(c.AudioEncoding); err != nil {
+		return fmt.Errorf("invalid AUDIO_ENCODING: %w", err)
+	}
+	if c.OutputTemplate, err = parseOutputNameTemplate(c.OutputNameFormat); err != nil {
+		return fmt.Errorf("invalid OUTPUT_NAME_TEMPLATE: %w", err)
+	}
+	if c.InputRules, err = parseInputRules(c.InputPrefix, c.InputPatterns); err != nil {
+		return fmt.Errorf("invalid INPUT_PREFIX or INPUT_PATTERNS: %w", err)
+	}
+	c.AudiobookFolders = nil
+	for _, prefix := range strings.Split(c.AudiobookSources, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix == "" {
+			continue
+		}
+		folder := strings.Trim(prefix, "/")
+		if folder != "" {
+			folder += "/"
+		}
+		c.AudiobookFolders = append(c.AudiobookFolders, folder)
+	}
+	if c.VoiceMap, err = tts.ParseVoiceMap(c.VoiceMapJSON); err != nil {
+		return fmt.Errorf("invalid VOICE_MAP: %w", err)
+	}
+	if c.SpeakerVoicesJSON != "" {
+		if err := json.Unmarshal([]byte(c.SpeakerVoicesJSON), &c.SpeakerVoices); err != nil {
+			return fmt.Errorf("invalid SPEAKER_VOICES: %w", err)
+		}
+	}
+	c.DialogueVoices = nil
+	for _, name := range strings.Split(c.DialogueVoiceList, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			c.DialogueVoices = append(c.DialogueVoices, name)
+		}
+	}
+	if _, err := tts.ParseGender(c.VoiceGender); err != nil {
+		return fmt.Errorf("invalid TTS_VOICE_GENDER: %w", err)
+	}
+
+	c.SynthesisMode = strings.ToLower(c.SynthesisMode)
+	if !slices.Contains([]synthesisMode{modeAuto, modeChunked, modeStreaming, modeLongAudio}, synthesisMode(c.SynthesisMode)) {
+		return fmt.Errorf("invalid SYNTHESIS_MODE %q (want auto, chunked, streaming or long-audio)", c.SynthesisMode)
+	}
+	if c.SynthesisMode == string(modeLongAudio) && c.usesGoogleTTS() && c.StorageBackend != "" && c.StorageBackend != "gcs" {
+		return fmt.Errorf("SYNTHESIS_MODE=long-audio writes to Cloud Storage and isn't supported with STORAGE_BACKEND=%s", c.StorageBackend)
+	}
+	c.CustomVoiceUsage = strings.ToUpper(c.CustomVoiceUsage)
+	if !slices.Contains([]string{"", "REALTIME", "OFFLINE"}, c.CustomVoiceUsage) {
+		return fmt.Errorf("invalid CUSTOM_VOICE_USAGE %q (want realtime or offline)", c.CustomVoiceUsage)
+	}
+	if c.CustomVoiceModel != "" && c.CloningKeySecret != "" {
+		return fmt.Errorf("set either CUSTOM_VOICE_MODEL or VOICE_CLONING_KEY_SECRET, not both")
+	}
+	if !slices.Contains([]string{"", "attachment", "inline"}, c.ContentDisposition) {
+		return fmt.Errorf("invalid OUTPUT_CONTENT_DISPOSITION %q (want attachment or inline)", c.ContentDisposition)
+	}
+
+	switch {
+	case c.ChunkConcurrency < 1:
+		return fmt.Errorf("invalid CHUNK_CONCURRENCY %d: must be a positive integer", c.ChunkConcurrency)
+	case c.InstanceChunkConcurrency < 0:
+		return fmt.Errorf("invalid INSTANCE_CHUNK_CONCURRENCY %d: must be a non-negative integer", c.InstanceChunkConcurrency)
+	case c.ExtractionConcurrency < 1:
+		return fmt.Errorf("invalid EXTRACTION_CONCURRENCY %d: must be a positive integer", c.ExtractionConcurrency)
+	case c.MaxAttempts < 1:
+		return fmt.Errorf("invalid TTS_MAX_ATTEMPTS %d: must be a positive integer", c.MaxAttempts)
+	case c.MaxSynthesisWait < 0:
+		return fmt.Errorf("invalid MAX_SYNTHESIS_WAIT %v: must be a non-negative duration such as 8m", c.MaxSynthesisWait)
+	case c.MaxConcurrentJobs < 0:
+		return fmt.Errorf("invalid TTS_MAX_CONCURRENT_JOBS %d: must be a non-negative integer", c.MaxConcurrentJobs)
+	case c.QPS < 0:
+		return fmt.Errorf("invalid TTS_QPS %v: must be a non-negative number", c.QPS)
+	case c.MaxInputBytes < 0:
+		return fmt.Errorf("invalid MAX_INPUT_BYTES %d: must be a non-negative number of bytes", c.MaxInputBytes)
+	case c.TmpMaxAge <= 0:
+		return fmt.Errorf("invalid TMP_MAX_AGE %v: must be a positive duration such as 48h", c.TmpMaxAge)
+	case c.StuckAfter <= maxFunctionTimeout:
+		return fmt.Errorf("invalid SWEEP_STUCK_AFTER %v: must be longer than the function timeout, which can be up to %v", c.StuckAfter, maxFunctionTimeout)
+	case c.SignedURLTTL < 0 || c.SignedURLTTL > maxSignedURLTTL:
+		return fmt.Errorf("invalid SIGNED_URL_TTL %v: must be a duration such as 24h, up to 168h", c.SignedURLTTL)
+	case c.MaxCostPerDocument < 0:
+		return fmt.Errorf("MAX_COST_PER_DOCUMENT must not be negative")
+	case c.MonthlyCostBudget < 0:
+		return fmt.Errorf("MONTHLY_COST_BUDGET must not be negative")
+	}
+
+	if c.MaxConcurrentJobs > 0 && c.BaseBucket == "" {
+		return fmt.Errorf("TTS_MAX_CONCURRENT_JOBS is set, but the synthesis slots also need BASE_GCS_BUCKET")
+	}
+	if c.RetryQueue != "" && c.RetryURL == "" {
+		return fmt.Errorf("RETRY_QUEUE is set, but retries also need RETRY_URL")
+	}
+	if c.RetryMaxAttempts < 0 {
+		return fmt.Errorf("invalid RETRY_MAX_ATTEMPTS %d: must be a non-negative integer", c.RetryMaxAttempts)
+	}
+	if c.RetryBaseDelay <= 0 {
+		return fmt.Errorf("invalid RETRY_BASE_DELAY %v: must be a positive duration such as 5m", c.RetryBaseDelay)
+	}
+	if c.WebhookURL != "" && c.WebhookKeySecret == "" {
+		return fmt.Errorf("WEBHOOK_URL is set, but callbacks also need WEBHOOK_SIGNING_KEY_SECRET")
+	}
+	switch c.EmailProvider {
+	case "":
+	case email.ProviderSendGrid:
+		if c.EmailFrom == "" || c.SendGridKeySecret == "" {
+			return fmt.Errorf("EMAIL_PROVIDER=sendgrid needs EMAIL_FROM and SENDGRID_API_KEY_SECRET")
+		}
+	case email.ProviderSES:
+		if c.EmailFrom == "" || c.SESRegion == "" || c.SESCredentialsSecret == "" {
+			return fmt.Errorf("EMAIL_PROVIDER=ses needs EMAIL_FROM, SES_REGION and SES_CREDENTIALS_SECRET")
+		}
+	default:
+		return fmt.Errorf("invalid EMAIL_PROVIDER %q (want sendgrid or ses)", c.EmailProvider)
+	}
+	if c.SFTPHost != "" {
+		if c.SFTPUser == "" || c.SFTPHostKey == "" {
+			return fmt.Errorf("SFTP_HOST is set, but SFTP delivery also needs SFTP_USER and SFTP_HOST_KEY")
+		}
+		if c.SFTPKeySecret == "" && c.SFTPPasswordSecret == "" {
+			return fmt.Errorf("SFTP_HOST is set, but SFTP delivery needs SFTP_KEY_SECRET or SFTP_PASSWORD_SECRET")
+		}
+	}
+	if c.DriveOutputFolderID != "" && c.DriveFolderID == "" {
+		return fmt.Errorf("DRIVE_OUTPUT_FOLDER_ID is set, but Drive intake also needs DRIVE_FOLDER_ID")
+	}
+	if c.DropboxFolder != "" && c.DropboxCredentialsSecret == "" {
+		return fmt.Errorf("DROPBOX_FOLDER is set, but Dropbox intake also needs DROPBOX_CREDENTIALS_SECRET")
+	}
+	if c.DropboxOutputFolder != "" && c.DropboxFolder == "" {
+		return fmt.Errorf("DROPBOX_OUTPUT_FOLDER is set, but Dropbox intake also needs DROPBOX_FOLDER")
+	}
+	return nil
+}
+
+// usesGoogleTTS reports whether TTS_PROVIDER selects Google, the default.
+func (c *Config) usesGoogleTTS() bool {
+	provider := strings.ToLower(strings.TrimSpace(c.Provider))
+	return provider == "" || provider == tts.ProviderGoogle
+}
+
+// ttsEndpoint returns the Text-to-Speech endpoint from TTS_ENDPOINT, or the
+// regional endpoint of TTS_REGION (e.g. "eu"). It's "" for the global endpoint.
+func (c *Config) ttsEndpoint() string {
+	if c.Endpoint != "" {
+		return c.Endpoint
+	}
+	if c.Region != "" {
+		return tts.RegionalEndpoint(c.Region)
+	}
+	return ""
+}
+
+// ttsLocation returns the location Long Audio Synthesis runs in: GCP_LOCATION,
+// or TTS_REGION when only that is set, since a regional endpoint only serves
+// its own location.
+func (c *Config) ttsLocation() string {
+	if c.Location != "" {
+		return c.Location
+	}
+	return c.Region
+}
+
+// outputLocation returns the bucket and folder prefix for a document's audio:
+// OUTPUT_BUCKET, defaulting to the trigger bucket, and OUTPUT_PREFIX, defaulting
+// to "mp3-output/". A dedicated bucket keeps outputs from firing the trigger
+// and from mixing with inputs; set OUTPUT_PREFIX to "/" for its root.
+func (c *Config) outputLocation(inputBucket string) (bucket, prefix string) {
+	bucket = c.OutputBucket
+	if bucket == "" {
+		bucket = inputBucket
+	}
+	if c.OutputPrefix == "" {
+		return bucket, defaultOutputPrefix
+	}
+	prefix = strings.Trim(c.OutputPrefix, "/")
+	if prefix == "" {
+		return bucket, ""
+	}
+	return bucket, prefix + "/"
+}
+
+// pipelineVersion identifies the deployed function in manifests: the revision
+// Cloud Functions sets in K_REVISION, or "dev" when run locally.
+func (c *Config) pipelineVersion() string {
+	if c.Revision != "" {
+		return c.Revision
+	}
+	return "dev"
+}
@@ -0,0 +1,97 @@
+package pdftospeech
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// dedupPrefix holds the content registry: one record per synthesized content
+// key, "tts-dedup/<sha256>.json", pointing at the audio made for it.
+const dedupPrefix = "tts-dedup/"
+
+// dedupRecord is a registry entry.
+type dedupRecord struct {
+	OutputURI   string    `json:"output_uri"`
+	InputObject string    `json:"input_object"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// contentKey identifies the audio a document would produce: a hash of the
+// provider, voices, audio settings and the exact requests, which already carry
+// the normalized text, language, lexicon and pauses. Documents with the same key
+// produce the same audio, whatever their file names.
+func contentKey(provider string, voice tts.Voice, speakers map[string]tts.Voice, settings tts.AudioSettings, inputs []tts.Input) (string, error) {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, v := range []any{provider, voice, speakers, settings} {
+		if err := enc.Encode(v); err != nil {
+			return "", fmt.Errorf("failed to hash document settings: %w", err)
+		}
+	}
+	for _, input := range inputs {
+		fmt.Fprintf(h, "%d:%s%d:%s", len(input.SSML), input.SSML, len(input.Text), input.Text)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dedupObjectName returns the registry record of a content key.
+func dedupObjectName(key string) string {
+	return dedupPrefix + key + ".json"
+}
+
+// reuseSynthesizedAudio copies the audio registered for key to outputURI. It
+// reports false if there's no registered audio, or it no longer exists.
+func (p *Pipeline) reuseSynthesizedAudio(ctx context.Context, registryBucket, key, outputURI string) (bool, error) {
+	data, _, err := p.store.ReadObjectGeneration(ctx, registryBucket, dedupObjectName(key))
+	if err != nil || data == nil {
+		return false, err
+	}
+	var record dedupRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.OutputURI == "" {
+		log.Printf("Warning: Ignoring invalid content registry record %s: %v", dedupObjectName(key), err)
+		return false, nil
+	}
+	if record.OutputURI == outputURI {
+		return true, nil
+	}
+	if path.Ext(record.OutputURI) != path.Ext(outputURI) {
+		return false, nil // Long Audio Synthesis fell back to another format.
+	}
+	srcBucket, srcObject, err := storage.ParseGCSURI(record.OutputURI)
+	if err != nil {
+		return false, err
+	}
+	dstBucket, dstObject, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		return false, err
+	}
+	copied, err := p.store.CopyObject(ctx, srcBucket, srcObject, dstBucket, dstObject)
+	if err != nil {
+		return false, err
+	}
+	if copied {
+		log.Printf("Reused the audio of %s (%s) for identical content.", record.InputObject, record.OutputURI)
+	}
+	return copied, nil
+}
+
+// registerSynthesizedAudio records outputURI as the audio for key. A failure is
+// only logged; the next identical document is just synthesized again.
+func (p *Pipeline) registerSynthesizedAudio(ctx context.Context, registryBucket, key, inputObject, outputURI string) {
+	data, err := json.MarshalIndent(dedupRecord{OutputURI: outputURI, InputObject: inputObject, CreatedAt: time.Now().UTC()}, "", "  ")
+	if err == nil {
+		err = p.store.UploadFile(ctx, registryBucket, dedupObjectName(key), data, "application/json")
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to register %s in the content registry: %v", outputURI, err)
+	}
+}
@@ -0,0 +1,98 @@
+package pdftospeech
+
+import (
+	"context"
+	"testing"
+
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+func TestContentKey(t *testing.T) {
+	voice := tts.Voice{Name: "en-GB-Neural2-B", LanguageCode: "en-GB"}
+	settings := tts.AudioSettings{Format: tts.FormatMP3, SpeakingRate: 1}
+	inputs := []tts.Input{tts.TextInput("Chapter one."), tts.TextInput("Chapter two.")}
+	key := func(provider string, voice tts.Voice, settings tts.AudioSettings, inputs []tts.Input) string {
+		t.Helper()
+		k, err := contentKey(provider, voice, nil, settings, inputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	base := key(tts.ProviderGoogle, voice, settings, inputs)
+	if again := key(tts.ProviderGoogle, voice, settings, inputs); again != base {
+		t.Errorf("the same document has keys %s and %s", base, again)
+	}
+	faster := settings
+	faster.SpeakingRate = 1.2
+	for name, other := range map[string]string{
+		"provider":          key(tts.ProviderPolly, voice, settings, inputs),
+		"voice":             key(tts.ProviderGoogle, tts.Voice{Name: "en-GB-Neural2-A", LanguageCode: "en-GB"}, settings, inputs),
+		"settings":          key(tts.ProviderGoogle, voice, faster, inputs),
+		"text":              key(tts.ProviderGoogle, voice, settings, []tts.Input{tts.TextInput("Chapter one."), tts.TextInput("Chapter 2.")}),
+		"chunk boundaries":  key(tts.ProviderGoogle, voice, settings, []tts.Input{tts.TextInput("Chapter one.Chapter two.")}),
+		"text against SSML": key(tts.ProviderGoogle, voice, settings, []tts.Input{tts.SSMLInput("Chapter one."), tts.TextInput("Chapter two.")}),
+	} {
+		if other == base {
+			t.Errorf("a different %s has the same key", name)
+		}
+	}
+}
+
+func TestReuseSynthesizedAudio(t *testing.T) {
+	const key = "abc"
+	const output = "gs://library/mp3-output/copy.mp3"
+	tests := []struct {
+		name       string
+		record     any // The registry record, if any.
+		audio      string
+		wantReused bool
+	}{
+		{name: "not registered"},
+		{name: "registered", record: dedupRecord{OutputURI: "gs://library/mp3-output/book.mp3"}, audio: "mp3-output/book.mp3", wantReused: true},
+		{name: "registered for this output", record: dedupRecord{OutputURI: output}, wantReused: true},
+		{name: "audio deleted since", record: dedupRecord{OutputURI: "gs://library/mp3-output/book.mp3"}},
+		{name: "other format", record: dedupRecord{OutputURI: "gs://library/mp3-output/book.wav"}, audio: "mp3-output/book.wav"},
+		{name: "invalid record", record: "not a record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			ctx := context.Background()
+			if tt.record != nil {
+				putJSON(t, p, dedupObjectName(key), tt.record)
+			}
+			if tt.audio != "" {
+				if err := p.store.UploadFile(ctx, testBucket, tt.audio, []byte("audio"), "audio/mpeg"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			reused, err := p.reuseSynthesizedAudio(ctx, testBucket, key, output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reused != tt.wantReused {
+				t.Errorf("reused: %v, want %v", reused, tt.wantReused)
+			}
+			if tt.audio == "" || !reused {
+				return
+			}
+			if data, err := p.store.ReadObject(ctx, testBucket, "mp3-output/copy.mp3"); err != nil || string(data) != "audio" {
+				t.Errorf("copied audio %q (%v), want %q", data, err, "audio")
+			}
+		})
+	}
+}
+
+func TestRegisterSynthesizedAudio(t *testing.T) {
+	p := newTestPipeline(t)
+	ctx := context.Background()
+	if err := p.store.UploadFile(ctx, testBucket, "mp3-output/book.mp3", []byte("audio"), "audio/mpeg"); err != nil {
+		t.Fatal(err)
+	}
+	p.registerSynthesizedAudio(ctx, testBucket, "abc", "pdf-input/book.pdf", "gs://library/mp3-output/book.mp3")
+	reused, err := p.reuseSynthesizedAudio(ctx, testBucket, "abc", "gs://library/mp3-output/copy.mp3")
+	if err != nil || !reused {
+		t.Errorf("registered audio reused: %v (%v), want true", reused, err)
+	}
+}
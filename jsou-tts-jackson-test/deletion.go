@@ -0,0 +1,127 @@
+package pdftospeech
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+	v2 "github.com/cloudevents/sdk-go/v2"
+)
+
+// outputKey is the metadata key recording the gs:// URI of an input's audio,
+// set when the input is finished, so the audio can be found again once the
+// input is deleted: the output's name depends on the template, voice and date,
+// and can't be worked out afresh.
+const outputKey = "tts-output"
+
+// recordOutput records the audio of a finished input in the input's metadata. A
+// failure is only logged: the audio is fine, it just won't be deleted with the
+// input.
+func (p *Pipeline) recordOutput(ctx context.Context, bucket, inputName, outputURI string) {
+	if err := p.store.UpdateObjectMetadata(ctx, bucket, inputName, map[string]string{outputKey: outputURI}); err != nil {
+		log.Printf("Warning: Failed to record the output of %s: %v", inputName, err)
+	}
+}
+
+// cleanUpDeletedInput serves the CleanUpDeletedInput entry point, triggered by
+// Cloud Storage object deletion events. When a PDF is deleted from the input
+// folder or processed/, it deletes what was made from it, so the bucket doesn't keep
+// audio without a source. DELETE_OUTPUTS_WITH_INPUT must be set; otherwise the
+// events are only acknowledged.
+func cleanUpDeletedInput(ctx context.Context, e v2.Event) error {
+	var data StorageObjectData
+	if err := e.DataAs(&data); err != nil {
+		return fmt.Errorf("failed to parse event data: %w", err)
+	}
+	if !isPDFInput(data.Name, data.ContentType) {
+		return nil
+	}
+	p, err := functionPipeline()
+	if err != nil {
+		return err
+	}
+	cfg := p.cfg
+	if !cfg.isInput(data.Name) && !strings.HasPrefix(data.Name, processedPrefix) {
+		return nil
+	}
+	if !cfg.DeleteOutputsWithInput {
+		log.Printf("%s was deleted; DELETE_OUTPUTS_WITH_INPUT isn't set, so its outputs are kept.", data.Name)
+		return nil
+	}
+	return p.deleteInputOutputs(ctx, cfg, data)
+}
+
+// deleteInputOutputs deletes the audio of the deleted input in e and what goes
+// with it: the manifest, timepoints, streaming playlist and dry-run report next
+// to it, and the cached text, checkpoint, event claims and failure report kept
+// for the input. Nothing is deleted if the input was only replaced by a newer
+// version, or moved to processed/ once it was done. Failures to delete are
+// logged and the rest is still deleted; only a failure to check the input
+// returns an error, so the event is redelivered.
+func (p *Pipeline) deleteInputOutputs(ctx context.Context, cfg *Config, e StorageObjectData) error {
+	if _, exists, err := p.store.ObjectMetadata(ctx, e.Bucket, e.Name); err != nil {
+		return fmt.Errorf("failed to check %s: %w", e.Name, err)
+	} else if exists {
+		log.Printf("%s was replaced by a newer version. Keeping its outputs.", e.Name)
+		return nil
+	}
+	// The name the input had in the input folder, which its cached text,
+	// checkpoint and failure report are named after.
+	inputName := e.Name
+	if folder, ok := cfg.inputFolder(e.Name); ok {
+		processed := processedObjectName(folder, e.Name)
+		if _, moved, err := p.store.ObjectMetadata(ctx, e.Bucket, processed); err != nil {
+			return fmt.Errorf("failed to check %s: %w", processed, err)
+		} else if moved {
+			log.Printf("%s was moved to %s. Keeping its outputs.", e.Name, processedPrefix)
+			return nil
+		}
+	} else {
+		inputName = cfg.inputName(e.Name, e.Metadata)
+	}
+	inputFolder, _ := cfg.inputFolder(inputName)
+
+	deleteObjects := func(bucket string, names ...string) {
+		for _, name := range names {
+			if err := p.store.DeleteObject(ctx, bucket, name); err != nil {
+				log.Printf("Warning: Failed to delete %s of deleted input %s: %v", name, e.Name, err)
+			}
+		}
+	}
+	deletePrefix := func(bucket, prefix string) {
+		objects, err := p.store.ListObjectsWithPrefix(ctx, bucket, prefix)
+		if err != nil {
+			log.Printf("Warning: Failed to list %s* of deleted input %s: %v", prefix, e.Name, err)
+			return
+		}
+		for _, obj := range objects {
+			deleteObjects(bucket, obj.Name)
+		}
+	}
+
+	if outputURI := e.Metadata[outputKey]; outputURI == "" {
+		log.Printf("%s has no output recorded; only its cached text and reports are deleted.", e.Name)
+	} else if bucket, object, err := storage.ParseGCSURI(outputURI); err != nil {
+		log.Printf("Warning: %s records an invalid output %q: %v", e.Name, outputURI, err)
+	} else {
+		deleteObjects(bucket, object, manifestObjectName(object), timepointsObjectName(object), dryRunObjectName(object))
+		// A streamed output also has a playlist and its segments, under the
+		// output's name without the extension.
+		playlist := newStreamingOutput(p.store, bucket, object, tts.AudioFormat{})
+		if _, streamed, err := p.store.ObjectMetadata(ctx, bucket, playlist.playlistObject); err != nil {
+			log.Printf("Warning: Failed to check for the playlist of %s: %v", outputURI, err)
+		} else if streamed {
+			deleteObjects(bucket, playlist.playlistObject)
+			deletePrefix(bucket, playlist.segmentPrefix+"part-")
+		}
+		p.cleanupIntermediates(ctx, outputURI)
+	}
+	deletePrefix(e.Bucket, extractedTextPrefix+inputName+".")
+	deletePrefix(e.Bucket, eventClaimPrefix+inputName+".")
+	deleteObjects(e.Bucket, checkpointObjectName(inputName), failureObjectName(inputFolder, inputName))
+	log.Printf("Cleaned up the outputs of deleted input %s.", e.Name)
+	return nil
+}
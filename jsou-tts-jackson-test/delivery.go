@@ -0,0 +1,114 @@
+package pdftospeech
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/sftp"
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// deliveredAtKey is the output metadata key recording when the audio was
+// delivered over SFTP, so a retry doesn't deliver it twice.
+const deliveredAtKey = "tts-delivered-at"
+
+// deliveryTimeout bounds a delivery, connection included.
+const deliveryTimeout = 15 * time.Minute
+
+// sftpConfig builds the SFTP connection settings from the configuration. SFTP_HOST
+// is the server, on port 22 unless it names another; SFTP_HOST_KEY its public
+// key in authorized_keys format; and SFTP_KEY_SECRET or SFTP_PASSWORD_SECRET the
+// Secret Manager secret holding the private key or password of SFTP_USER.
+func (p *Pipeline) sftpConfig(ctx context.Context, c *Config) (sftp.Config, error) {
+	addr := c.SFTPHost
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	cfg := sftp.Config{Addr: addr, User: c.SFTPUser, HostKey: c.SFTPHostKey}
+	if c.SFTPKeySecret != "" {
+		key, err := p.secrets.Access(ctx, c.SFTPKeySecret)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read the SFTP private key: %w", err)
+		}
+		cfg.PrivateKey = []byte(key)
+	} else {
+		password, err := p.secrets.Access(ctx, c.SFTPPasswordSecret)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read the SFTP password: %w", err)
+		}
+		cfg.Password = strings.TrimSpace(password)
+	}
+	return cfg, nil
+}
+
+// deliverOutput pushes the audio at outputURI, followed by its manifest if there
+// is one, to SFTP_DIR on the SFTP server when delivery is enabled. Files keep
+// their path below the output prefix. The delivery is recorded in the audio's
+// metadata, and audio that was delivered already is skipped, so a document
+// retried after a failed delivery only repeats the delivery. The audio of a PDF
+// copied from a Google Drive or Dropbox folder is uploaded back to it the same
+// way.
+func (p *Pipeline) deliverOutput(ctx context.Context, c *Config, outputURI string) error {
+	if c.SFTPHost == "" && c.DriveFolderID == "" && c.DropboxFolder == "" {
+		return nil
+	}
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		return err
+	}
+	metadata, exists, err := p.store.ObjectMetadata(ctx, bucket, object)
+	if err != nil {
+		return fmt.Errorf("failed to check the delivery of %s: %w", outputURI, err)
+	}
+	if !exists {
+		return fmt.Errorf("output %s to deliver doesn't exist", outputURI)
+	}
+	if err := p.deliverToIntake(ctx, c, bucket, object, metadata); err != nil {
+		return err
+	}
+	if c.SFTPHost == "" {
+		return nil
+	}
+	if metadata[deliveredAtKey] != "" {
+		log.Printf("Output %s was delivered at %s. Skipping delivery.", outputURI, metadata[deliveredAtKey])
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+	cfg, err := p.sftpConfig(ctx, c)
+	if err != nil {
+		return err
+	}
+	_, outputPrefix := c.outputLocation(bucket)
+	remoteDir := c.SFTPDir
+	objects := []string{object}
+	if _, exists, err := p.store.ObjectMetadata(ctx, bucket, manifestObjectName(object)); err == nil && exists {
+		objects = append(objects, manifestObjectName(object))
+	}
+	var files []sftp.File
+	for _, name := range objects {
+		files = append(files, sftp.File{
+			RemotePath: path.Join(remoteDir, strings.TrimPrefix(name, outputPrefix)),
+			Open: func() (io.ReadCloser, error) {
+				rc, _, err := p.store.OpenObject(ctx, bucket, name)
+				return rc, err
+			},
+		})
+	}
+	if err := sftp.Upload(ctx, cfg, files); err != nil {
+		return fmt.Errorf("failed to deliver %s over SFTP: %w", outputURI, err)
+	}
+
+	deliveredAt := map[string]string{deliveredAtKey: time.Now().UTC().Format(time.RFC3339)}
+	if err := p.store.UpdateObjectMetadata(ctx, bucket, object, deliveredAt); err != nil {
+		log.Printf("Warning: Delivered %s but failed to record it: %v", outputURI, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package pdftospeech
+
+import (
+	"log"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/dialogue"
+	"MODULE_NAME/jsou-tts/internal/ssml"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// minDialogueSpeakers and minSpeakerTurns decide when a document is treated as a
+// dialogue: at least two speakers with at least two turns each.
+const (
+	minDialogueSpeakers = 2
+	minSpeakerTurns     = 2
+)
+
+// dialogueEnabled reports whether dialogue mode is on for a document, via the
+// tts-dialogue object metadata or the DIALOGUE_MODE setting.
+func dialogueEnabled(cfg *Config, metadata map[string]string) bool {
+	return boolSetting(metadata, "tts-dialogue", cfg.DialogueMode)
+}
+
+// speakerVoices assigns a voice to every speaker. SPEAKER_VOICES (a JSON object of
+// speaker name -> voice name) pins specific speakers; everyone else gets the next
+// voice from DIALOGUE_VOICES (comma-separated), in order of first appearance.
+func speakerVoices(cfg *Config, speakers []string, narrator tts.Voice) map[string]tts.Voice {
+	pinned, pool := cfg.SpeakerVoices, cfg.DialogueVoices
+	voices := make(map[string]tts.Voice, len(speakers))
+	next := 0
+	for _, speaker := range speakers {
+		name := ""
+		for k, v := range pinned {
+			if strings.EqualFold(strings.Join(strings.Fields(k), " "), speaker) {
+				name = v
+			}
+		}
+		if name == "" && len(pool) > 0 {
+			name = pool[next%len(pool)]
+			next++
+		}
+		if name == "" {
+			log.Printf("Warning: No voice configured for speaker %s. Using the narrator voice %s.", speaker, narrator.Name)
+			voices[speaker] = narrator
+			continue
+		}
+		voice := tts.ParseVoice(name)
+		if voice.LanguageCode == "" {
+			voice.LanguageCode = narrator.LanguageCode
+		}
+		voices[speaker] = voice
+		log.Printf("Speaker %s will use voice %s.", speaker, name)
+	}
+	return voices
+}
+
+// dialogueSegments turns dialogue segments into synthesis segments, giving each
+// speaker their voice and narration the narrator voice. Speakers not in voices
+// (too few turns to count as dialogue) are read by the narrator as well.
+func dialogueSegments(synth tts.Synthesizer, segments []dialogue.Segment, voices map[string]tts.Voice, narrator tts.Voice, opts ssml.Options) []tts.Segment {
+	var out []tts.Segment
+	for _, segment := range segments {
+		voice, ok := voices[segment.Speaker]
+		text := segment.Text
+		if !ok {
+			voice = narrator
+			if segment.Speaker != "" {
+				text = segment.Speaker + ": " + text
+			}
+		}
+		caps := synth.Capabilities(voice)
+		voiceOpts := opts
+		voiceOpts.LanguageCode = voice.LanguageCode
+		for _, input := range buildInputs(text, voiceOpts, caps.SSML, caps.ChunkBytes()) {
+			out = append(out, tts.Segment{Input: input, Voice: voice})
+		}
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package pdftospeech
+
+import (
+	"context"
+	"io"
+
+	"MODULE_NAME/jsou-tts/internal/drive"
+)
+
+// driveSource is the folder intake of the Google Drive folder DRIVE_FOLDER_ID,
+// whose audio goes to DRIVE_OUTPUT_FOLDER_ID, by default the same folder.
+// Files are identified by their Drive ID and versioned by their MD5 checksum.
+type driveSource struct {
+	cfg    *Config
+	client *drive.Client
+}
+
+// name implements intakeSource.
+func (s driveSource) name() string { return "drive" }
+
+// folder implements intakeSource.
+func (s driveSource) folder() string { return s.cfg.DriveFolderID }
+
+// start implements intakeSource with the start token of the Drive changes
+// feed.
+func (s driveSource) start(ctx context.Context) (string, error) {
+	return s.client.StartPageToken(ctx)
+}
+
+// changes implements intakeSource. The changes feed covers everything the
+// service account sees, so files that are trashed, outside the folder or not
+// PDFs are left out.
+func (s driveSource) changes(ctx context.Context, cursor string) ([]intakeFile, string, error) {
+	files, next, err := s.client.Changes(ctx, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	var pdfs []intakeFile
+	for _, file := range files {
+		if file.Trashed || !file.InFolder(s.cfg.DriveFolderID) || file.MimeType != pdfContentType {
+			continue
+		}
+		pdfs = append(pdfs, intakeFile{ID: file.ID, Name: file.Name, Version: file.MD5, Size: file.Size})
+	}
+	return pdfs, next, nil
+}
+
+// download implements intakeSource.
+func (s driveSource) download(ctx context.Context, id string) (io.ReadCloser, error) {
+	return s.client.Download(ctx, id)
+}
+
+// upload implements intakeSource.
+func (s driveSource) upload(ctx context.Context, name, contentType string, r io.Reader) (string, error) {
+	folder := s.cfg.DriveOutputFolderID
+	if folder == "" {
+		folder = s.cfg.DriveFolderID
+	}
+	return s.client.Upload(ctx, folder, name, contentType, r)
+}
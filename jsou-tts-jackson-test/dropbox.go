@@ -0,0 +1,103 @@
+package pdftospeech
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/dropbox"
+)
+
+// dropboxSource is the folder intake of the Dropbox folder DROPBOX_FOLDER, whose
+// audio goes to DROPBOX_OUTPUT_FOLDER, by default the same folder. Files are
+// identified by their Dropbox ID and versioned by their revision.
+type dropboxSource struct {
+	cfg    *Config
+	client *dropbox.Client
+}
+
+// name implements intakeSource.
+func (s dropboxSource) name() string { return "dropbox" }
+
+// folder implements intakeSource.
+func (s dropboxSource) folder() string { return s.cfg.DropboxFolder }
+
+// start implements intakeSource with the latest cursor of the folder.
+func (s dropboxSource) start(ctx context.Context) (string, error) {
+	return s.client.LatestCursor(ctx, s.cfg.DropboxFolder)
+}
+
+// changes implements intakeSource. Only files named *.pdf are returned, since
+// Dropbox doesn't report content types.
+func (s dropboxSource) changes(ctx context.Context, cursor string) ([]intakeFile, string, error) {
+	files, next, err := s.client.Changes(ctx, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	var pdfs []intakeFile
+	for _, file := range files {
+		if !strings.EqualFold(path.Ext(file.Name), ".pdf") {
+			continue
+		}
+		pdfs = append(pdfs, intakeFile{ID: file.ID, Name: file.Name, Version: file.Rev, Size: file.Size})
+	}
+	return pdfs, next, nil
+}
+
+// download implements intakeSource.
+func (s dropboxSource) download(ctx context.Context, id string) (io.ReadCloser, error) {
+	return s.client.Download(ctx, id)
+}
+
+// upload implements intakeSource. A file of the same name in the output folder
+// isn't overwritten: Dropbox adds a number to the new one's name.
+func (s dropboxSource) upload(ctx context.Context, name, contentType string, r io.Reader) (string, error) {
+	folder := s.cfg.DropboxOutputFolder
+	if folder == "" {
+		folder = s.cfg.DropboxFolder
+	}
+	return s.client.Upload(ctx, strings.TrimSuffix(folder, "/")+"/"+name, r)
+}
+
+// dropboxWebhook serves the DropboxWebhook entry point, registered as the
+// webhook of the Dropbox app. Dropbox verifies it with a GET whose challenge
+// parameter is echoed, and then POSTs a notification, signed with the app
+// secret, whenever files change in the accounts that authorized the app; each
+// syncs DROPBOX_FOLDER like SyncDriveFolder does its folder. A failed sync
+// responds with 500, and Dropbox sends the notification again.
+func dropboxWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		io.WriteString(w, r.URL.Query().Get("challenge"))
+		return
+	}
+	body, ok := readEventBody(w, r)
+	if !ok {
+		return
+	}
+	p, err := functionPipeline()
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, "service unavailable, try again", http.StatusServiceUnavailable)
+		return
+	}
+	cfg := p.cfg
+	if cfg.BaseBucket == "" || p.dropboxClient == nil {
+		http.Error(w, "BASE_GCS_BUCKET and DROPBOX_FOLDER must be set for DropboxWebhook", http.StatusInternalServerError)
+		return
+	}
+	if !p.dropboxClient.VerifySignature(body, r.Header.Get("X-Dropbox-Signature")) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if err := p.syncIntake(r.Context(), cfg, dropboxSource{cfg: cfg, client: p.dropboxClient}); err != nil {
+		log.Printf("Error: Dropbox sync failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// dryRunEnabled reports whether a document should only be planned, not
+// synthesized, via the tts-dry-run object metadata or the DRY_RUN setting.
+func dryRunEnabled(cfg *Config, metadata map[string]string) bool {
+	return boolSetting(metadata, "tts-dry-run", cfg.DryRun)
+}
+
+// dryRunReport is what a dry run writes instead of audio: the plan for the
+// document and what it would cost.
+type dryRunReport struct {
+	Input        string        `json:"input"`
+	Output       string        `json:"output"`
+	Provider     string        `json:"provider"`
+	Voice        string        `json:"voice"`
+	LanguageCode string        `json:"language_code"`
+	AudioFormat  string        `json:"audio_format"`
+	Mode         synthesisMode `json:"mode"`
+	Cost         costEstimate  `json:"cost"`
+	// Budget checks as they'd be made for a real run; zero budgets are unlimited.
+	DocumentBudgetUSD float64 `json:"document_budget_usd,omitempty"`
+	MonthlyBudgetUSD  float64 `json:"monthly_budget_usd,omitempty"`
+	OverDocumentLimit bool    `json:"over_document_budget,omitempty"`
+	// Chunks holds the SSML or text of every request, as it would be sent.
+	Chunks []dryRunChunk `json:"chunks"`
+}
+
+// dryRunChunk is one planned synthesis request.
+type dryRunChunk struct {
+	Bytes int    `json:"bytes"`
+	SSML  string `json:"ssml,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+// dryRunObjectName returns where the report for an output object is written,
+// e.g. "mp3-output/book.dry-run.json" for "mp3-output/book.mp3".
+func dryRunObjectName(outputObjectName string) string {
+	return strings.TrimSuffix(outputObjectName, path.Ext(outputObjectName)) + ".dry-run.json"
+}
+
+// writeDryRunReport fills in the chunks of report from inputs and uploads it.
+func (p *Pipeline) writeDryRunReport(ctx context.Context, bucket, objectName string, report dryRunReport, inputs []tts.Input) error {
+	for _, input := range inputs {
+		report.Chunks = append(report.Chunks, dryRunChunk{Bytes: input.Len(), SSML: input.SSML, Text: input.Text})
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode dry run report: %w", err)
+	}
+	if err := p.store.UploadFile(ctx, bucket, objectName, data, "application/json"); err != nil {
+		return fmt.Errorf("failed to write dry run report: %w", err)
+	}
+	log.Printf("Dry run for %s: %d chunk(s), about $%.2f. Report: gs://%s/%s", report.Input, len(inputs), report.Cost.USD, bucket, objectName)
+	return nil
+}
@@ -0,0 +1,112 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// eventClaimPrefix holds one claim per version of an input, e.g.
+// "tts-events/pdf-input/book.pdf.1712345678901234.json", so an event that's
+// delivered more than once only has the first delivery process the document.
+const eventClaimPrefix = "tts-events/"
+
+// staleEventClaimAge is how old an unfinished claim may get before it's assumed
+// to belong to a crashed invocation and is taken over by a redelivery. Like
+// staleLeaseAge, it must exceed the function timeout.
+const staleEventClaimAge = 2 * time.Hour
+
+// eventClaimRecord is the content of a claim object.
+type eventClaimRecord struct {
+	EventID   string    `json:"event_id,omitempty"`
+	ClaimedAt time.Time `json:"claimed_at"`
+	Done      bool      `json:"done,omitempty"`
+	DoneAt    time.Time `json:"done_at,omitzero"`
+}
+
+// eventClaim is a claim held on processing a version of an input. The
+// generation keeps a holder from touching the claim after it was taken over.
+type eventClaim struct {
+	Object     string
+	Generation int64
+	record     eventClaimRecord
+}
+
+// eventClaimName returns the claim object of a version of an input.
+func eventClaimName(input, generation string) string {
+	return fmt.Sprintf("%s%s.%s.json", eventClaimPrefix, input, generation)
+}
+
+// claimEvent claims the processing of the version of the input in e for its
+// event. If the version is already claimed, it returns no claim but the
+// existing one: done, or held by another delivery still processing it. A claim
+// left by a crashed invocation is taken over once it's stale.
+func (p *Pipeline) claimEvent(ctx context.Context, e StorageObjectData) (*eventClaim, *eventClaimRecord, error) {
+	name := eventClaimName(e.Name, e.Generation)
+	record := eventClaimRecord{EventID: e.eventID, ClaimedAt: time.Now().UTC()}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode event claim: %w", err)
+	}
+	for {
+		generation, err := p.store.CreateObjectIfAbsent(ctx, e.Bucket, name, data, "application/json")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to claim event for %s: %w", e.Name, err)
+		}
+		if generation != 0 {
+			return &eventClaim{Object: name, Generation: generation, record: record}, nil, nil
+		}
+
+		existingData, existingGeneration, err := p.store.ReadObjectGeneration(ctx, e.Bucket, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read event claim %s: %w", name, err)
+		}
+		if existingData == nil {
+			continue // Released in the meantime.
+		}
+		var existing eventClaimRecord
+		if err := json.Unmarshal(existingData, &existing); err != nil {
+			log.Printf("Warning: Replacing invalid event claim %s: %v", name, err)
+		} else if existing.Done || time.Since(existing.ClaimedAt) < staleEventClaimAge {
+			return nil, &existing, nil
+		} else {
+			log.Printf("Warning: Taking over the claim of event %s on %s, held since %v.", existing.EventID, e.Name, existing.ClaimedAt)
+		}
+		if _, err := p.store.DeleteObjectGeneration(ctx, e.Bucket, name, existingGeneration); err != nil {
+			return nil, nil, fmt.Errorf("failed to take over event claim %s: %w", name, err)
+		}
+	}
+}
+
+// finishEvent settles a claim once its document is through: a successful one
+// is marked done, so later deliveries of the event are skipped, and a failed
+// one is released, so a redelivery processes the document again. A nil claim
+// is a no-op. Errors are only logged: a claim that can't be released is taken
+// over once it's stale.
+func (p *Pipeline) finishEvent(ctx context.Context, bucket string, claim *eventClaim, failed bool) {
+	if claim == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	if failed {
+		if _, err := p.store.DeleteObjectGeneration(ctx, bucket, claim.Object, claim.Generation); err != nil {
+			log.Printf("Warning: Failed to release event claim %s: %v", claim.Object, err)
+		}
+		return
+	}
+	claim.record.Done, claim.record.DoneAt = true, time.Now().UTC()
+	data, err := json.Marshal(claim.record)
+	if err != nil {
+		log.Printf("Warning: Failed to encode event claim %s: %v", claim.Object, err)
+		return
+	}
+	written, err := p.store.UpdateObjectIfGeneration(ctx, bucket, claim.Object, data, "application/json", claim.Generation)
+	switch {
+	case err != nil:
+		log.Printf("Warning: Failed to mark event claim %s done: %v", claim.Object, err)
+	case written == 0:
+		log.Printf("Warning: Event claim %s was taken over before the document was done.", claim.Object)
+	}
+}
@@ -0,0 +1,108 @@
+package pdftospeech
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClaimEvent(t *testing.T) {
+	const input = "pdf-input/book.pdf"
+	tests := []struct {
+		name         string
+		existing     any // The claim already there, if any.
+		wantClaim    bool
+		wantExisting bool
+		wantHolder   string // The event holding the claim afterwards.
+	}{
+		{
+			name:       "unclaimed",
+			wantClaim:  true,
+			wantHolder: "second",
+		},
+		{
+			name:         "held by another delivery",
+			existing:     eventClaimRecord{EventID: "first", ClaimedAt: time.Now().UTC()},
+			wantExisting: true,
+			wantHolder:   "first",
+		},
+		{
+			name:         "done",
+			existing:     eventClaimRecord{EventID: "first", ClaimedAt: time.Now().Add(-3 * time.Hour).UTC(), Done: true},
+			wantExisting: true,
+			wantHolder:   "first",
+		},
+		{
+			name:       "taken over once stale",
+			existing:   eventClaimRecord{EventID: "first", ClaimedAt: time.Now().Add(-staleEventClaimAge - time.Minute).UTC()},
+			wantClaim:  true,
+			wantHolder: "second",
+		},
+		{
+			name:       "invalid claim replaced",
+			existing:   "not a claim",
+			wantClaim:  true,
+			wantHolder: "second",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			e := StorageObjectData{Bucket: testBucket, Name: input, Generation: "1", eventID: "second"}
+			if tt.existing != nil {
+				putJSON(t, p, eventClaimName(input, "1"), tt.existing)
+			}
+			claim, existing, err := p.claimEvent(context.Background(), e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (claim != nil) != tt.wantClaim || (existing != nil) != tt.wantExisting {
+				t.Fatalf("claim %v, existing %v; want claim: %v, existing: %v", claim, existing, tt.wantClaim, tt.wantExisting)
+			}
+			var stored eventClaimRecord
+			getJSON(t, p, eventClaimName(input, "1"), &stored)
+			if stored.EventID != tt.wantHolder {
+				t.Errorf("claim held by event %q, want %q", stored.EventID, tt.wantHolder)
+			}
+		})
+	}
+}
+
+func TestFinishEvent(t *testing.T) {
+	const input = "pdf-input/book.pdf"
+	tests := []struct {
+		name     string
+		failed   bool
+		takeOver bool // Whether another delivery took the claim over first.
+		wantDone bool
+		wantGone bool
+	}{
+		{name: "done", wantDone: true},
+		{name: "failed is released", failed: true, wantGone: true},
+		{name: "taken over is left alone", takeOver: true},
+		{name: "taken over and failed is left alone", failed: true, takeOver: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			ctx := context.Background()
+			claim, _, err := p.claimEvent(ctx, StorageObjectData{Bucket: testBucket, Name: input, Generation: "1", eventID: "first"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.takeOver {
+				putJSON(t, p, claim.Object, eventClaimRecord{EventID: "second", ClaimedAt: time.Now().UTC()})
+			}
+			p.finishEvent(ctx, testBucket, claim, tt.failed)
+
+			var stored eventClaimRecord
+			generation := getJSON(t, p, claim.Object, &stored)
+			if gone := generation == 0; gone != tt.wantGone {
+				t.Fatalf("claim deleted: %v, want %v", gone, tt.wantGone)
+			}
+			if !tt.wantGone && stored.Done != tt.wantDone {
+				t.Errorf("claim done: %v, want %v", stored.Done, tt.wantDone)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// failedPrefix holds an error report for every document that failed, named
+// after its input, e.g. "failed/series/book.pdf.json".
+const failedPrefix = "failed/"
+
+// Processing stages, reported with a failure.
+const (
+	stageConfiguration = "configuration"
+	stageDownload      = "download"
+	stageExtraction    = "extraction"
+	stagePreparation   = "preparation"
+	stageSynthesis     = "synthesis"
+	stageDelivery      = "delivery"
+)
+
+// failureReport is the machine-readable error report of a failed document, for
+// users who can't read the function's logs.
+type failureReport struct {
+	Input      string `json:"input"`
+	Generation string `json:"generation,omitempty"`
+	Stage      string `json:"stage"`
+	Error      string `json:"error"`
+	// Pages lists the pages whose text couldn't be extracted, if any.
+	Pages []int `json:"pages,omitempty"`
+	// Retryable reports whether the error looks transient (quota, server
+	// errors, timeouts), so uploading the PDF again is likely to succeed.
+	Retryable bool      `json:"retryable"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// failureObjectName returns where the report for an input is written, named
+// after its path below its input folder at inputPrefix.
+func failureObjectName(inputPrefix, inputName string) string {
+	return failedPrefix + strings.TrimPrefix(inputName, inputPrefix) + ".json"
+}
+
+// permanentError is a failure that retrying can't fix, such as a file that isn't
+// a PDF or invalid settings, whatever errors it wraps.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// permanent marks err as a permanent failure.
+func permanent(err error) error {
+	return &permanentError{err}
+}
+
+// transientError is a failure that goes away on its own though no API reported
+// it as transient, such as a document another invocation is still working on.
+type transientError struct{ err error }
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// transient marks err as a transient failure.
+func transient(err error) error {
+	return &transientError{err}
+}
+
+// isRetryableFailure reports whether a failure is likely to go away on its own:
+// one marked transient, a transient error of the TTS API or the store, a
+// timeout, or a synthesis stopped by the instance's shutdown. Failures marked
+// permanent, and any others, aren't.
+func isRetryableFailure(err error) bool {
+	var permanentErr *permanentError
+	if errors.As(err, &permanentErr) {
+		return false
+	}
+	var transientErr *transientError
+	if errors.As(err, &transientErr) {
+		return true
+	}
+	return tts.IsRetryable(err) || storage.IsRetryable(err) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, tts.ErrStopped)
+}
+
+// retryableOnly returns err when the event that failed with it is worth
+// delivering again, and nil for a permanent failure, which a redelivery would
+// only repeat: it's logged, and its error report in failed/ is where it's dealt
+// with.
+func retryableOnly(name string, err error) error {
+	if err == nil || isRetryableFailure(err) {
+		return err
+	}
+	log.Printf("Error: Processing %s failed permanently: %v. Not retrying; see its report in %s.", name, err, failedPrefix)
+	return nil
+}
+
+// writeFailureReport writes the report of a failed document to failed/ in the
+// bucket. It still runs once ctx is done, e.g. when the failure was a timeout;
+// a failure to write the report is only logged.
+func (p *Pipeline) writeFailureReport(ctx context.Context, cfg *Config, bucket, inputName string, report failureReport) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	folder, _ := cfg.inputFolder(inputName)
+	name := failureObjectName(folder, inputName)
+	report.FailedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = p.store.UploadFile(ctx, bucket, name, data, "application/json")
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to write the error report for %s: %v", inputName, err)
+		return
+	}
+	log.Printf("Wrote error report for %s to %s (stage %s, retryable %t).", inputName, name, report.Stage, report.Retryable)
+}
@@ -0,0 +1,92 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+)
+
+// folderConfigName is the object in a folder of the input folder holding the
+// per-document settings of everything uploaded to that folder, e.g.
+// "pdf-input/team-a/_config.json".
+const folderConfigName = "_config.json"
+
+// folderSettings returns the settings that apply to the input object in the
+// bucket: those of the _config.json objects in its folder and the folders above
+// it up to the input folder at inputPrefix, a deeper folder's taking precedence,
+// overridden in turn by the object's own custom metadata. It returns the
+// object's metadata as is when no folder has a _config.json.
+func (p *Pipeline) folderSettings(ctx context.Context, bucket, inputPrefix, objectName string, metadata map[string]string) (map[string]string, error) {
+	rel, ok := strings.CutPrefix(objectName, inputPrefix)
+	if !ok {
+		return metadata, nil
+	}
+	folders := []string{inputPrefix}
+	if dir := path.Dir(rel); dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			folders = append(folders, inputPrefix+strings.Join(parts[:i+1], "/")+"/")
+		}
+	}
+
+	merged := map[string]string{}
+	found := false
+	for _, folder := range folders {
+		settings, exists, err := p.readFolderConfig(ctx, bucket, folder+folderConfigName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		log.Printf("Applying %d setting(s) from gs://%s/%s%s to %s.", len(settings), bucket, folder, folderConfigName, objectName)
+		for key, value := range settings {
+			merged[key] = value
+		}
+		found = true
+	}
+	if !found {
+		return metadata, nil
+	}
+	for key, value := range metadata {
+		if value != "" {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// readFolderConfig reads and parses a _config.json object: a JSON object of
+// per-document settings keyed by their metadata names, e.g.
+// {"tts-voice": "en-GB-Neural2-B", "tts-speaking-rate": 1.1}. Strings, numbers
+// and booleans are accepted as values. A missing object yields false, without
+// an error.
+func (p *Pipeline) readFolderConfig(ctx context.Context, bucket, objectName string) (map[string]string, bool, error) {
+	if _, exists, err := p.store.ObjectMetadata(ctx, bucket, objectName); err != nil || !exists {
+		return nil, false, err
+	}
+	data, err := p.store.ReadObject(ctx, bucket, objectName)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read folder configuration %s: %w", objectName, err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, false, fmt.Errorf("invalid folder configuration %s: %w", objectName, err)
+	}
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if !strings.HasPrefix(key, "tts-") {
+			return nil, false, fmt.Errorf("invalid folder configuration %s: %q isn't a per-document setting (they start with tts-)", objectName, key)
+		}
+		switch v := value.(type) {
+		case string, float64, bool:
+			settings[key] = fmt.Sprint(v)
+		default:
+			return nil, false, fmt.Errorf("invalid folder configuration %s: %s must be a string, number or boolean", objectName, key)
+		}
+	}
+	return settings, true, nil
+}
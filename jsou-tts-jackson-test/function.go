@@ -2,14 +2,21 @@ package pdftospeech
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"MODULE_NAME/jsou-tts/internal/chunker"
+	"MODULE_NAME/jsou-tts/internal/dialogue"
+	"MODULE_NAME/jsou-tts/internal/jobtrack"
+	"MODULE_NAME/jsou-tts/internal/langdetect"
 	"MODULE_NAME/jsou-tts/internal/pdf-to-text/pdfprocessor"
+	"MODULE_NAME/jsou-tts/internal/ssml"
 	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/textnorm"
 	"MODULE_NAME/jsou-tts/internal/tts"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
 	v2 "github.com/cloudevents/sdk-go/v2"
@@ -17,12 +24,37 @@ import (
 
 // StorageObjectData is the payload of a GCS event.
 type StorageObjectData struct {
-	Bucket      string `json:"bucket"`
-	Name        string `json:"name"`
-	ContentType string `json:"contentType"`
+	Bucket      string            `json:"bucket"`
+	Name        string            `json:"name"`
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata"`
+	Generation  string            `json:"generation"`
+	MD5Hash     string            `json:"md5Hash"`
+
+	// job is the on-demand job the object is processed for, if any.
+	job *onDemandJob
+	// extracted is the text the extraction stage of a staged pipeline handed off, if any.
+	extracted *extractedDocument
+	// retryAttempt counts the retries of the object queued by scheduleRetry.
+	retryAttempt int
+	// eventID is the ID of the CloudEvent that delivered the object, if any.
+	eventID string
+	// audiobook marks the joined text of an audiobook, whose manifest's invocation schedules
+	// its retries.
+	audiobook bool
+}
+
+// jobID returns the ID of the on-demand job the object is processed for, or "" if there's none
+// or it has no ID, as with Pipeline.Process.
+func (e StorageObjectData) jobID() string {
+	if e.job == nil {
+		return ""
+	}
+	return e.job.ID
 }
 
-// internal/storage has its own client now, so no global Storage Client is needed.
+// The Storage and Text-to-Speech clients are created by functionPipeline on the first invocation,
+// not here, so a transient failure doesn't crash the instance on a cold start.
 
 func init() {
 	// Register the Cloud Function entry point directly to the handler that expects StorageObjectData.
@@ -31,81 +63,1056 @@ func init() {
 		if err := e.DataAs(&eventData); err != nil {
 			return fmt.Errorf("failed to parse event data: %w", err)
 		}
-		return processPDFToSpeechHandler(ctx, eventData)
+		eventData.eventID = e.ID()
+		pipeline, err := functionPipeline()
+		if err != nil {
+			return err
+		}
+		// A permanent failure isn't returned, so Eventarc doesn't retry the event in vain.
+		return retryableOnly(eventData.Name, pipeline.handle(ctx, eventData))
+	})
+
+	// Finalizer for long audio operations started with ASYNC_LONG_AUDIO=true. Trigger it
+	// periodically, e.g. from Cloud Scheduler through a Pub/Sub topic; the event payload is ignored.
+	functions.CloudEvent("FinalizePendingSyntheses", func(ctx context.Context, e v2.Event) error {
+		p, err := functionPipeline()
+		if err != nil {
+			return err
+		}
+		cfg := p.cfg
+		if cfg.BaseBucket == "" {
+			return fmt.Errorf("BASE_GCS_BUCKET must be set for FinalizePendingSyntheses")
+		}
+		return p.finalizePendingSyntheses(ctx, cfg, cfg.BaseBucket)
+	})
+
+	// Sweeper for intermediate objects left under tmp/ by jobs that crashed or timed out. Trigger
+	// it periodically like FinalizePendingSyntheses, e.g. daily; the event payload is ignored.
+	functions.CloudEvent("SweepIntermediateObjects", func(ctx context.Context, e v2.Event) error {
+		p, err := functionPipeline()
+		if err != nil {
+			return err
+		}
+		cfg := p.cfg
+		if cfg.BaseBucket == "" {
+			return fmt.Errorf("BASE_GCS_BUCKET must be set for SweepIntermediateObjects")
+		}
+		outputBucket, _ := cfg.outputLocation(cfg.BaseBucket)
+		return p.sweepIntermediates(ctx, cfg.BaseBucket, outputBucket, cfg.TmpMaxAge)
+	})
+
+	// Sweeper for PDFs whose events were dropped or whose invocations were lost, and for stuck
+	// jobs. Trigger it periodically like FinalizePendingSyntheses, e.g. hourly; the event payload
+	// is ignored.
+	functions.CloudEvent("SweepMissedInputs", func(ctx context.Context, e v2.Event) error {
+		p, err := functionPipeline()
+		if err != nil {
+			return err
+		}
+		cfg := p.cfg
+		if cfg.BaseBucket == "" {
+			return fmt.Errorf("BASE_GCS_BUCKET must be set for SweepMissedInputs")
+		}
+		if err := p.checkOnDemandJobs(); err != nil {
+			return fmt.Errorf("%w for SweepMissedInputs", err)
+		}
+		return p.sweepMissedInputs(ctx, cfg)
+	})
+
+	// Intake from the Google Drive folder DRIVE_FOLDER_ID. Trigger it periodically like
+	// FinalizePendingSyntheses, e.g. every few minutes; the event payload is ignored.
+	functions.CloudEvent("SyncDriveFolder", func(ctx context.Context, e v2.Event) error {
+		p, err := functionPipeline()
+		if err != nil {
+			return err
+		}
+		cfg := p.cfg
+		if cfg.BaseBucket == "" || cfg.DriveFolderID == "" {
+			return fmt.Errorf("BASE_GCS_BUCKET and DRIVE_FOLDER_ID must be set for SyncDriveFolder")
+		}
+		return p.syncIntake(ctx, cfg, driveSource{cfg: cfg, client: p.driveClient})
 	})
+
+	// Synthesis stage of a staged pipeline, triggered by the messages the extraction stage
+	// publishes to EXTRACTED_TEXT_TOPIC.
+	functions.CloudEvent("SynthesizeExtractedText", synthesizeExtractedText)
+
+	// Chapter synthesis of books fanned out to CHAPTER_TOPIC; FinalizePendingSyntheses joins them.
+	functions.CloudEvent("SynthesizeChapter", synthesizeChapter)
+
+	// Cloud Storage object deletion events, to delete the outputs of PDFs removed from the bucket
+	// when DELETE_OUTPUTS_WITH_INPUT is set.
+	functions.CloudEvent("CleanUpDeletedInput", cleanUpDeletedInput)
+
+	// Processing requests published to a Pub/Sub topic as {bucket, object, options}, to process a PDF
+	// already in the bucket programmatically or replay one without uploading it again.
+	functions.CloudEvent("ProcessPubSubRequest", processPubSubRequest)
+
+	// HTTP endpoint that queues a job for a PDF anywhere in storage (POST /process) and reports its status.
+	functions.HTTP("ProcessOnDemand", processOnDemand)
+
+	// On-demand jobs of ProcessOnDemand, the Jobs API and the sweeper, published to JOBS_TOPIC as
+	// they're queued.
+	functions.CloudEvent("RunOnDemandJob", runOnDemandJob)
+
+	// HTTP endpoint that queues every PDF in the input folder whose output is missing or stale.
+	functions.HTTP("ReprocessInputs", reprocessInputs)
+
+	// HTTP endpoint Cloud Tasks calls to retry a document after a transient failure (RETRY_QUEUE).
+	functions.HTTP("RetryDocument", retryDocument)
+
+	// HTTP endpoint that synthesizes a short sample, to audition a voice before using it for a book.
+	functions.HTTP("PreviewVoice", previewVoice)
+
+	// HTTP endpoint for S3 object creation events, when the pipeline runs against S3 (STORAGE_BACKEND=s3).
+	functions.HTTP("ProcessS3Event", processS3Event)
+
+	// HTTP endpoint for Event Grid BlobCreated events, when the pipeline runs against Azure Blob Storage (STORAGE_BACKEND=azure).
+	functions.HTTP("ProcessAzureBlobEvent", processAzureBlobEvent)
+
+	// HTTP endpoint that checks the configuration, clients and bucket access, to validate a deployment.
+	functions.HTTP("Healthz", healthz)
+
+	// REST API for running the pipeline as a service: create, follow and cancel on-demand jobs.
+	functions.HTTP("JobsAPI", jobsAPI)
+
+	// Webhook of the Dropbox app, which syncs the Dropbox folder DROPBOX_FOLDER when files change.
+	functions.HTTP("DropboxWebhook", dropboxWebhook)
 }
 
 // processPDFToSpeechHandler is the Cloud Function's event handler.
 // It's triggered by Cloud Storage object finalization events, with the payload
 // directly unmarshaled into the StorageObjectData struct by the functions-framework.
-func processPDFToSpeechHandler(ctx context.Context, e StorageObjectData) error {
+func (p *Pipeline) processPDFToSpeechHandler(ctx context.Context, cfg *Config, e StorageObjectData) (err error) {
 	log.Printf("Received event for file: %s in bucket: %s with content type: %s", e.Name, e.Bucket, e.ContentType)
 
-	// Ensure the file is a PDF and from the correct input prefix
-	if !strings.HasSuffix(strings.ToLower(e.Name), ".pdf") {
+	// When the instance is told to shut down, chunks stop being started and waits are cut short,
+	// so the invocation returns a transient failure, with its checkpoint saved, before it's killed.
+	ctx, done, err := startWork(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	// PDFs uploaded to the Jobs API are only run by their jobs, which RunOnDemandJob runs.
+	if strings.HasPrefix(e.Name, jobUploadsPrefix) && e.job == nil {
+		return nil
+	}
+
+	// Ensure the file is a PDF, by its extension or content type, or an audiobook manifest, and
+	// from the correct input prefix
+	if !isPDFInput(e.Name, e.ContentType) && !isAudiobookManifest(e.Name) {
 		log.Printf("Skipping non-PDF file: %s. Content type: %s", e.Name, e.ContentType)
 		return nil // Not an error, just skipping
 	}
-	if !strings.HasPrefix(e.Name, "pdf-input/") {
-		log.Printf("Skipping PDF file not in 'pdf-input/' folder: %s", e.Name)
+	// Inputs are the PDFs in the INPUT_PREFIX folders, by default pdf-input/, or matching
+	// INPUT_PATTERNS; on-demand jobs may name any PDF.
+	inputFolderPrefix, isInput := cfg.inputFolder(e.Name)
+	if !isInput && e.job == nil {
+		log.Printf("Skipping PDF file not selected by INPUT_PREFIX or INPUT_PATTERNS: %s", e.Name)
 		return nil
 	}
 
-	// Define folder prefixes
-	const inputFolderPrefix = "pdf-input/"
-	const outputFolderPrefix = "mp3-output/"
+	// Events are delivered at least once. A duplicate delivery for this version of the PDF is
+	// skipped rather than paying for the synthesis twice; while the first delivery is still at
+	// work, it fails so the platform redelivers it later, in case the first one crashed. Retries
+	// queued by scheduleRetry, on-demand jobs and the synthesis stage of a staged pipeline are
+	// deliberate repeats and aren't checked. DEDUPLICATE_EVENTS=false turns this off.
+	var claim *eventClaim
+	if cfg.DeduplicateEvents && e.Generation != "" && e.job == nil && e.extracted == nil && e.retryAttempt == 0 {
+		var existing *eventClaimRecord
+		claim, existing, err = p.claimEvent(ctx, e)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.Done {
+			log.Printf("Skipping duplicate delivery of %s (generation %s): already processed by event %s.", e.Name, e.Generation, existing.EventID)
+			return nil
+		}
+		if existing != nil {
+			return transient(fmt.Errorf("%s (generation %s) is already being processed by event %s since %s", e.Name, e.Generation, existing.EventID, existing.ClaimedAt.Format(time.RFC3339)))
+		}
+	}
 
-	// Extract the base file name (e.g., "document.pdf" from "pdf-input/document.pdf").
-	baseFileName := filepath.Base(e.Name)
-	// Construct the full output object name with the output folder prefix and .mp3 extension.
-	outputAudioObjectName := outputFolderPrefix + strings.TrimSuffix(baseFileName, filepath.Ext(baseFileName)) + ".mp3"
-	outputGCSURI := fmt.Sprintf("gs://%s/%s", e.Bucket, outputAudioObjectName)
+	// A transient failure is retried later through Cloud Tasks (RETRY_QUEUE), with backoff, beyond
+	// the event delivery's retry window. Once the retry is queued, the invocation succeeds. This
+	// runs last, after the failure has been reported.
+	original := e
+	defer func() {
+		if err != nil && isRetryableFailure(err) && e.job == nil && !e.audiobook && p.scheduleRetry(ctx, cfg, original, e.retryAttempt+1) {
+			err = nil
+		}
+	}()
+	// The claim is settled before a retry is scheduled, so a failure always releases it.
+	defer func() { p.finishEvent(ctx, e.Bucket, claim, err != nil) }()
+
+	// An audiobook manifest (.audiobook.json) lists PDFs to read, in order, as one book: their
+	// text is extracted here and then synthesized like a single document's. The manifest's
+	// event is claimed and its retries are scheduled above, for the extraction and synthesis
+	// alike.
+	if isAudiobookManifest(e.Name) && e.extracted == nil {
+		return p.processAudiobook(ctx, cfg, e)
+	}
 
-	// Get Project Number and Location from environment variables.
-	projectNumber := os.Getenv("PROJECT_NUMBER")
-	location := os.Getenv("GCP_LOCATION")
+	// A failed document gets an error report in failed/, naming the stage it failed in, for
+	// users without access to the logs.
+	stage := stageConfiguration
+	var failedPages []int
+	defer func() {
+		if err != nil {
+			p.writeFailureReport(ctx, cfg, e.Bucket, e.Name, failureReport{
+				Input:      fmt.Sprintf("gs://%s/%s", e.Bucket, e.Name),
+				Generation: e.Generation,
+				Stage:      stage,
+				Error:      err.Error(),
+				Pages:      failedPages,
+				Retryable:  isRetryableFailure(err),
+			})
+		}
+	}()
 
-	if projectNumber == "" || location == "" {
-		return fmt.Errorf("environment variables PROJECT_NUMBER and GCP_LOCATION must be set in the Cloud Function configuration")
+	// With JOBS_COLLECTION set, the document's progress is recorded in Firestore as it goes:
+	// queued now, then extracting, synthesizing and finalizing, and finally done, failed or
+	// skipped, with the reason in skipped. Long audio handed off to FinalizePendingSyntheses
+	// is left synthesizing for the finalizer to complete.
+	track := func(state jobtrack.State) {
+		p.trackJob(ctx, e.Bucket, e.Name, e.Generation, e.jobID(), jobtrack.Record{State: state})
+	}
+	// The pages extracted and chunks synthesized are recorded along the way, so a multi-hour
+	// conversion can be followed.
+	progress := p.progressReporter(ctx, e.Bucket, e.Name, e.Generation, e.jobID())
+	var outputGCSURI, skipped string
+	var stats *jobManifest
+	handedOff := false
+	// The synthesis stage of a staged pipeline carries on the job the extraction stage queued.
+	if e.extracted == nil {
+		track(jobtrack.Queued)
 	}
+	defer func() {
+		input := fmt.Sprintf("gs://%s/%s", e.Bucket, e.Name)
+		switch {
+		case err != nil:
+			p.trackJob(ctx, e.Bucket, e.Name, e.Generation, e.jobID(), jobtrack.Record{State: jobtrack.Failed, Output: outputGCSURI, Stage: stage, Error: err.Error(), Retryable: isRetryableFailure(err)})
+			payload := webhookPayload{Event: webhookFailed, Input: input, Generation: e.Generation, Output: outputGCSURI, Stage: stage, Error: err.Error(), Retryable: isRetryableFailure(err)}
+			p.notifyCompletion(ctx, cfg, e.Metadata["tts-callback-url"], e.Metadata["tts-notify-email"], payload)
+			p.notify(ctx, payload)
+		case handedOff:
+			// FinalizePendingSyntheses, or the synthesis stage of a staged pipeline, records how
+			// the document ends.
+		case skipped != "":
+			p.trackJob(ctx, e.Bucket, e.Name, e.Generation, e.jobID(), jobtrack.Record{State: jobtrack.Skipped, Output: outputGCSURI, Error: skipped})
+		default:
+			p.trackJob(ctx, e.Bucket, e.Name, e.Generation, e.jobID(), jobtrack.Record{State: jobtrack.Done, Output: outputGCSURI})
+			payload := webhookPayload{Event: webhookSucceeded, Input: input, Generation: e.Generation, Output: outputGCSURI, Stats: stats}
+			p.notifyCompletion(ctx, cfg, e.Metadata["tts-callback-url"], e.Metadata["tts-notify-email"], payload)
+			p.notify(ctx, payload)
+		}
+	}()
 
-	// Get TTS Voice Name from environment variable.
-	ttsVoiceName := os.Getenv("TTS_VOICE_NAME")
-	if ttsVoiceName == "" {
-		log.Printf("TTS_VOICE_NAME environment variable not set. Using default 'en-US-Wavenet-D'.")
-		ttsVoiceName = "en-US-Wavenet-D" // A common, generally available Wavenet voice
+	// Settings in a _config.json of the input's folder apply to the document unless its own
+	// metadata overrides them, giving each team's folder its own voice and format.
+	if isInput {
+		e.Metadata, err = p.folderSettings(ctx, e.Bucket, inputFolderPrefix, e.Name, e.Metadata)
+		if err != nil {
+			return err
+		}
 	}
 
-	log.Printf("Processing PDF: %s in bucket: %s", e.Name, e.Bucket)
-	log.Printf("Target output: %s", outputGCSURI)
-	log.Printf("Using Project Number: %s, Location: %s, Voice: %s", projectNumber, location, ttsVoiceName)
+	// Get where the audio goes: OUTPUT_BUCKET and OUTPUT_PREFIX, by default mp3-output/ in the trigger bucket.
+	outputBucket, outputFolderPrefix := cfg.outputLocation(e.Bucket)
 
-	// 1. Download the PDF file from the input bucket to a temporary path.
-	// The call to storage.DownloadFileToTemp is correct here.
-	tempPDFPath, cleanupTempFile, err := storage.DownloadFileToTemp(ctx, e.Bucket, e.Name)
+	// The output audio encoding (AUDIO_ENCODING, or tts-audio-encoding metadata) decides the
+	// output file extension.
+	audioFormat := cfg.AudioFormat
+	if raw := e.Metadata["tts-audio-encoding"]; raw != "" {
+		audioFormat, err = tts.ParseAudioFormat(raw)
+		if err != nil {
+			return fmt.Errorf("invalid tts-audio-encoding for %s: %w", e.Name, err)
+		}
+	}
+
+	// Get speaking rate, pitch, volume gain and sample rate, overridable per object via custom metadata.
+	audioSettings, err := audioSettingsFor(cfg, audioFormat, e.Metadata)
+	if err != nil {
+		return fmt.Errorf("invalid audio settings for %s: %w", e.Name, err)
+	}
+
+	// The output name template, e.g. "{dir}/{basename}/{voice}", is rendered once the voice is known.
+	outputTemplate := cfg.OutputTemplate
+	receivedAt := time.Now().UTC()
+
+	// Get Project Number and Location from the configuration.
+	projectNumber := cfg.ProjectNumber
+	location := cfg.ttsLocation()
+
+	// Get the TTS provider, Google Cloud Text-to-Speech unless TTS_PROVIDER says otherwise.
+	synth, err := tts.NewSynthesizer(ctx, cfg.Provider, p.providerConfig(cfg))
 	if err != nil {
-		return fmt.Errorf("failed to download PDF %s: %w", e.Name, err)
+		return fmt.Errorf("invalid TTS_PROVIDER: %w", err)
+	}
+
+	// Only Google needs the project; other providers, like a local Piper, run without it.
+	if synth.Name() == tts.ProviderGoogle && (projectNumber == "" || location == "") {
+		return fmt.Errorf("PROJECT_NUMBER and GCP_LOCATION (or TTS_REGION) must be set in the Cloud Function configuration")
 	}
-	defer cleanupTempFile() // Ensure temp file is cleaned up after processing
 
-	// 2. Extract text from the temporary PDF file.
-	extractedText, err := pdfprocessor.ExtractTextFromPDFFilePath(tempPDFPath)
+	// Get the optional voice gender. It's only used when no voice is named: Google then picks
+	// a voice of that gender for the document's language.
+	voiceGender, err := tts.ParseGender(lookupSetting(e.Metadata, "tts-voice-gender", cfg.VoiceGender))
 	if err != nil {
-		return fmt.Errorf("failed to extract text from PDF %s: %w", e.Name, err)
+		return fmt.Errorf("invalid TTS_VOICE_GENDER for %s: %w", e.Name, err)
+	}
+
+	// Get TTS Voice Name from the object's tts-voice metadata, falling back to TTS_VOICE_NAME.
+	ttsVoiceName := lookupSetting(e.Metadata, "tts-voice", cfg.VoiceName)
+	switch {
+	case ttsVoiceName == "" && voiceGender != "" && synth.Name() == tts.ProviderGoogle:
+		log.Printf("No voice named. Letting the API pick a %s voice for %s.", strings.ToLower(voiceGender), e.Name)
+	case ttsVoiceName == "":
+		log.Printf("TTS_VOICE_NAME not set. Using default 'en-US-Wavenet-D'.")
+		ttsVoiceName = "en-US-Wavenet-D" // A common, generally available Wavenet voice
+	case voiceGender != "":
+		log.Printf("Warning: Voice %s is named explicitly. Ignoring voice gender %s.", ttsVoiceName, voiceGender)
+		voiceGender = ""
+	}
+	if e.Metadata["tts-voice"] != "" {
+		log.Printf("Using voice %s from object metadata of %s.", ttsVoiceName, e.Name)
 	}
 
+	// Get the per-language default voices, used when the document's language doesn't match the voice.
+	voiceMap := cfg.VoiceMap
+
+	log.Printf("Processing PDF: %s in bucket: %s", e.Name, e.Bucket)
+	log.Printf("Using Provider: %s, Project Number: %s, Location: %s, Voice: %s, Encoding: %s", synth.Name(), projectNumber, location, ttsVoiceName, audioFormat)
+	log.Printf("Audio settings: rate=%.2f pitch=%.2f gain=%.2fdB sampleRate=%d",
+		audioSettings.SpeakingRate, audioSettings.Pitch, audioSettings.VolumeGainDb, audioSettings.SampleRateHertz)
+
+	// With CHECKPOINTS, how far the document has got is saved as it goes, so the retry of an
+	// invocation that timed out or crashed resumes it: the text isn't extracted again, chunks
+	// already synthesized are reused and long audio operations already started are waited for.
+	var cp *checkpoint
+	if cfg.Checkpoints && e.Generation != "" {
+		cp = p.loadCheckpoint(ctx, e.Bucket, e.Name, e.Generation)
+		defer func() {
+			if err == nil {
+				p.deleteCheckpoint(ctx, e.Bucket, e.Name)
+			}
+		}()
+	}
+
+	// 1.-2. In the synthesis stage of a staged pipeline (EXTRACTED_TEXT_TOPIC), the text comes
+	// from the extraction stage instead of the PDF, and on a resumed attempt from the checkpoint.
+	extracted := e.extracted
+	if extracted == nil && cp != nil {
+		extracted = cp.Extraction
+	}
+	var extraction pdfprocessor.Extraction
+	var extractionTime time.Duration
+	if extracted != nil {
+		extraction = extracted.extraction()
+		extractionTime = time.Duration(extracted.ExtractionSeconds * float64(time.Second))
+		receivedAt = extracted.ReceivedAt
+	} else {
+		// 1. Open the PDF in the input bucket for reading in place. Extraction fetches the ranges it
+		// needs from GCS instead of copying the whole file to /tmp, which counts against memory.
+		stage = stageDownload
+		track(jobtrack.Extracting)
+		pdfReader, err := p.store.OpenReaderAt(ctx, e.Bucket, e.Name)
+		if err != nil {
+			return fmt.Errorf("failed to open PDF %s: %w", e.Name, err)
+		}
+		// Refuse a mistakenly uploaded multi-gigabyte file before any of it is read.
+		if cfg.MaxInputBytes > 0 && pdfReader.Size() > cfg.MaxInputBytes {
+			return permanent(fmt.Errorf("PDF %s is %d bytes, over the MAX_INPUT_BYTES limit of %d", e.Name, pdfReader.Size(), cfg.MaxInputBytes))
+		}
+		// A file named .pdf that holds something else, e.g. an HTML error page saved by a
+		// downloader, fails here rather than as a cryptic parse error.
+		if isPDF, err := pdfprocessor.IsPDF(pdfReader, pdfReader.Size()); err != nil {
+			return fmt.Errorf("failed to read PDF %s: %w", e.Name, err)
+		} else if !isPDF {
+			return permanent(fmt.Errorf("%s isn't a PDF: it has no %%PDF- header (content type %q)", e.Name, e.ContentType))
+		}
+
+		// 2. Extract text from the PDF. Pages that fail are skipped, and listed in the error report
+		// if the document fails later.
+		stage = stageExtraction
+		// Only the pages in tts-pages metadata, e.g. "5-120" to skip front matter, are read.
+		pages, err := pdfprocessor.ParsePageRanges(e.Metadata["tts-pages"])
+		if err != nil {
+			return permanent(fmt.Errorf("invalid tts-pages for %s: %w", e.Name, err))
+		}
+		if pages != nil {
+			log.Printf("Reading pages %s of %s.", pages, e.Name)
+		}
+		extractionStart := time.Now()
+		extraction, err = p.extract(ctx, pdfReader, pdfReader.Size(), e.Name, pdfprocessor.ExtractOptions{
+			Pages:   pages,
+			Workers: cfg.ExtractionConcurrency,
+			Progress: func(done, total int) {
+				progress(jobtrack.Progress{PagesExtracted: done, Pages: total})
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to extract text from PDF %s: %w", e.Name, err)
+		}
+		extractionTime = time.Since(extractionStart)
+	}
+	extractedText := extraction.Text
+	failedPages = extraction.FailedPages
+
+	if strings.TrimSpace(extractedText) == "" && len(failedPages) > 0 {
+		return permanent(fmt.Errorf("failed to extract text from PDF %s: no page could be read", e.Name))
+	}
 	if strings.TrimSpace(extractedText) == "" {
 		log.Printf("No text extracted from PDF: %s. Skipping TTS.", e.Name)
+		skipped = "no text extracted"
 		return nil
 	}
 	log.Printf("Text extracted from PDF. Length: %d characters.", len(extractedText))
+	document := extractedDocument{
+		Text:              extractedText,
+		Pages:             extraction.Pages,
+		FailedPages:       failedPages,
+		ExtractionSeconds: extractionTime.Seconds(),
+		ReceivedAt:        receivedAt,
+	}
+	if cp != nil && cp.Extraction == nil {
+		cp.Extraction = &document
+		p.saveCheckpoint(ctx, e.Bucket, e.Name, cp)
+	}
+
+	// In a staged pipeline, the extraction stage ends here: the text is written to tts-text/ and
+	// SynthesizeExtractedText is told over Pub/Sub to synthesize it, so each stage can run with
+	// its own memory, timeout and concurrency.
+	if cfg.ExtractedTextTopic != "" && e.extracted == nil {
+		if err := p.handOffText(ctx, e, document); err != nil {
+			return err
+		}
+		handedOff = true
+		return nil
+	}
+	stage = stagePreparation
 
-	// 3. Synthesize long audio using the TTS API, directly to GCS.
-	err = tts.SynthesizeLongAudio(ctx, extractedText, projectNumber, location, outputGCSURI, ttsVoiceName)
+	// Derive the language code from the voice name, falling back to detecting the document language.
+	voice := tts.ParseVoice(ttsVoiceName)
+	voice.Gender = voiceGender
+	detectedLanguage, detected := langdetect.Detect(extractedText)
+	// A language given in tts-language metadata, e.g. for a folder of French documents, replaces detection.
+	if language := e.Metadata["tts-language"]; language != "" {
+		detectedLanguage, detected = language, true
+	}
+	switch {
+	case voice.LanguageCode == "" && detected:
+		log.Printf("Voice %s has no language prefix. Using detected document language %s.", voice.Name, detectedLanguage)
+		voice.LanguageCode = detectedLanguage
+	case voice.LanguageCode == "":
+		log.Printf("Voice %s has no language prefix and the document language is unknown. Using %s.", voice.Name, tts.DefaultLanguageCode)
+		voice.LanguageCode = tts.DefaultLanguageCode
+	case detected && langdetect.Language(detectedLanguage) != langdetect.Language(voice.LanguageCode):
+		mapped, ok := voiceMap.Lookup(detectedLanguage)
+		if !ok || e.Metadata["tts-voice"] != "" {
+			log.Printf("Warning: Document %s looks like %s but voice %s speaks %s.", e.Name, detectedLanguage, voice.Name, voice.LanguageCode)
+			break
+		}
+		log.Printf("Document %s looks like %s. Using default voice %s for that language instead of %s.", e.Name, detectedLanguage, mapped, voice.Name)
+		voice = tts.ParseVoice(mapped)
+		if voice.LanguageCode == "" {
+			voice.LanguageCode = detectedLanguage
+		}
+	}
+
+	// A trained Custom Voice model or an instant custom (cloned) voice, if configured, replaces
+	// the named voice, e.g. for an organization's own narrator.
+	voice, err = p.customVoice(ctx, cfg, voice)
 	if err != nil {
-		return fmt.Errorf("failed to synthesize speech for %s: %w", e.Name, err)
+		return err
+	}
+	if voice.Custom() {
+		if synth.Name() != tts.ProviderGoogle {
+			return fmt.Errorf("custom voices are only supported with the %s provider", tts.ProviderGoogle)
+		}
+		if voice.CloningKey != "" {
+			log.Printf("Using an instant custom voice for %s.", e.Name)
+		} else {
+			log.Printf("Using Custom Voice model %s for %s.", voice.CustomModel, e.Name)
+		}
+	}
+
+	// Check that the voice exists in this region, falling back to a compatible voice for the
+	// same language rather than failing mid-synthesis. VALIDATE_VOICE=false skips the check.
+	if cfg.ValidateVoice {
+		resolved, substituted, err := tts.ResolveVoice(ctx, synth, voice)
+		switch {
+		case err != nil:
+			log.Printf("Warning: Could not validate voice %s: %v. Using it as configured.", voice.Name, err)
+		case substituted:
+			log.Printf("Warning: Voice %s is not available from %s. Substituting %s for %s.", voice.Name, synth.Name(), resolved.Name, e.Name)
+			voice = resolved
+		}
+	}
+
+	// Gemini voices take style instructions ("Read this like a news anchor.") alongside the text.
+	if voice.Model != "" {
+		voice.Prompt = lookupSetting(e.Metadata, "tts-prompt", cfg.GeminiPrompt)
 	}
 
+	// Expand abbreviations ("Dept." -> "Department") so they're spoken in full rather than spelled out.
+	if cfg.ExpandAbbreviations {
+		abbreviations, err := p.abbreviationsFor(ctx, e.Bucket, cfg.AbbreviationsObject, voice.LanguageCode)
+		if err != nil {
+			return err
+		}
+		extractedText = abbreviations.Expand(extractedText)
+	}
+
+	// Adapt the request to what the voice family supports (e.g., Journey voices take no SSML or pitch).
+	capabilities := synth.Capabilities(voice)
+	audioSettings = capabilities.Adapt(audioSettings)
+	if !longAudioWritable(synth, p.store) {
+		capabilities.LongAudio = false
+	}
+
+	// 3. Build SSML with pauses between paragraphs and headings so the narration isn't one continuous run-on.
+	// The chunker splits it at sentence boundaries into documents that fit the synthesis request limits.
+	ssmlOptions := ssml.DefaultOptions
+	ssmlOptions.LanguageCode = voice.LanguageCode
+	ssmlOptions.SayAs = cfg.SayAs // Speak dates, currencies and long numbers correctly.
+	if cfg.LexiconObject != "" {
+		ssmlOptions.Lexicon, err = p.loadLexicon(ctx, e.Bucket, cfg.LexiconObject)
+		if err != nil {
+			return err
+		}
+	}
+	inputs := buildInputs(extractedText, ssmlOptions, capabilities.SSML, capabilities.ChunkBytes())
+	log.Printf("Built input in %d chunk(s) of up to %d bytes (SSML: %t).", len(inputs), capabilities.ChunkBytes(), capabilities.SSML)
+	if err := validateInputs(inputs, capabilities.ChunkBytes()); err != nil {
+		return fmt.Errorf("generated input for %s would be rejected: %w", e.Name, err)
+	}
+
+	// 4. Synthesize the audio. Short documents use the synchronous API and are uploaded
+	// via internal/storage. Longer ones either use Long Audio Synthesis, which writes
+	// directly to GCS, or are synthesized chunk by chunk in parallel and concatenated.
+	mode, err := synthesisModeFor(cfg.SynthesisMode, len(inputs), audioSettings.Format, capabilities)
+	if err != nil {
+		return err
+	}
+	log.Printf("Using %s synthesis for %s.", mode, e.Name)
+
+	// Scripts, interviews and plays can give each "SPEAKER:" their own voice. The speaker turns
+	// are synthesized chunk by chunk, since a long audio operation only takes one voice.
+	var dialogueTurns []dialogue.Segment
+	var speakerVoiceMap map[string]tts.Voice
+	if dialogueEnabled(cfg, e.Metadata) {
+		dialogueTurns = dialogue.Parse(extractedText)
+		if speakers := dialogue.Speakers(dialogueTurns, minSpeakerTurns); len(speakers) >= minDialogueSpeakers {
+			speakerVoiceMap = speakerVoices(cfg, speakers, voice)
+			if mode != modeStreaming {
+				mode = modeChunked
+			}
+			log.Printf("Dialogue mode: %d speakers. Using %s synthesis.", len(speakers), mode)
+		} else {
+			log.Printf("Dialogue mode is on but %s has fewer than %d speakers. Narrating with a single voice.", e.Name, minDialogueSpeakers)
+		}
+	}
+
+	// Long Audio Synthesis only writes some formats; fall back before naming the output, so
+	// redeliveries look for the output under the name it's actually written to.
+	if mode == modeLongAudio && !capabilities.SupportsLongAudioFormat(audioSettings.Format) {
+		log.Printf("Warning: Long Audio Synthesis doesn't support %s. Falling back to %s for %s.", audioSettings.Format, tts.FormatLinear16, e.Name)
+		audioSettings.Format = tts.FormatLinear16
+	}
+
+	// Construct the full output object name from the template under the output folder prefix,
+	// with the encoding's extension.
+	outputAudioObjectName := outputTemplate.objectName(outputFolderPrefix, inputFolderPrefix, e.Name, voice, receivedAt, audioSettings.Format)
+	outputGCSURI = fmt.Sprintf("gs://%s/%s", outputBucket, outputAudioObjectName)
+	defer func() {
+		if e.job != nil && err == nil {
+			e.job.Output = outputGCSURI
+		}
+	}()
+	log.Printf("Target output: %s", outputGCSURI)
+
+	// Skip documents whose output was already made from this version of the PDF, e.g. on a
+	// redelivered event. Upload with x-goog-meta-tts-force: true to synthesize it again.
+	if !forceReprocess(e.Metadata) {
+		upToDate, err := p.outputUpToDate(ctx, outputBucket, outputAudioObjectName, e)
+		if err != nil {
+			return fmt.Errorf("failed to check the existing output of %s: %w", e.Name, err)
+		}
+		if upToDate {
+			log.Printf("Output %s is already up to date with %s (generation %s). Skipping.", outputGCSURI, e.Name, e.Generation)
+			skipped = "output already up to date"
+			// A retry after a failed delivery only has the delivery left to do.
+			stage = stageDelivery
+			if err := p.deliverOutput(ctx, cfg, outputGCSURI); err != nil {
+				return err
+			}
+			p.archiveInput(ctx, cfg, e.Bucket, e.Name, outputGCSURI)
+			return nil
+		}
+	}
+
+	// A re-uploaded PDF is synthesized again; the audio of its previous version is kept under a
+	// versioned name rather than overwritten or left looking current. ARCHIVE_PREVIOUS_OUTPUTS=false
+	// overwrites it.
+	if cfg.ArchivePreviousOutputs {
+		p.archivePreviousOutput(ctx, cfg, e, outputGCSURI)
+	}
+
+	// Identical content synthesized before with the same voice and settings, e.g. the same book
+	// uploaded under another name, is copied from the earlier output instead of paying for it
+	// again. DEDUPLICATE=false turns the registry off; tts-force also bypasses it.
+	var dedupKey string
+	if cfg.Deduplicate {
+		dedupKey, err = contentKey(synth.Name(), voice, speakerVoiceMap, audioSettings, inputs)
+		if err != nil {
+			return err
+		}
+		if !forceReprocess(e.Metadata) && !dryRunEnabled(cfg, e.Metadata) {
+			reused, err := p.reuseSynthesizedAudio(ctx, e.Bucket, dedupKey, outputGCSURI)
+			if err != nil {
+				log.Printf("Warning: Could not reuse earlier audio for %s: %v. Synthesizing it.", e.Name, err)
+			}
+			if reused {
+				p.setOutputHeaders(ctx, outputGCSURI, outputHeaders(cfg, e.Name, outputAudioObjectName, voice.LanguageCode))
+				p.markOutputSource(ctx, outputGCSURI, sourceMetadata(cfg, e))
+				stage = stageDelivery
+				if err := p.deliverOutput(ctx, cfg, outputGCSURI); err != nil {
+					return err
+				}
+				p.archiveInput(ctx, cfg, e.Bucket, e.Name, outputGCSURI)
+				log.Printf("Successfully processed %s from identical content. Output: %s", e.Name, outputGCSURI)
+				return nil
+			}
+		}
+	}
+
+	// Estimate the cost from the character count and voice tier and check it against the budgets.
+	// Documents uploaded with x-goog-meta-tts-budget-override: true may exceed them.
+	estimate := estimateCost(inputs, capabilities)
+	log.Printf("Cost estimate for %s: %d characters with %s voices, about $%.2f.", e.Name, estimate.Characters, capabilities.Family, estimate.USD)
+	documentBudget, monthlyBudget := cfg.MaxCostPerDocument, cfg.MonthlyCostBudget
+
+	// A dry run stops here: it writes the planned requests and the cost estimate to a report
+	// next to the output instead of synthesizing, to check large batches cheaply.
+	if dryRunEnabled(cfg, e.Metadata) {
+		planned := inputs
+		if mode == modeLongAudio {
+			planned = buildInputs(extractedText, ssmlOptions, capabilities.SSML, capabilities.LongAudioBytes())
+		}
+		report := dryRunReport{
+			Input:             fmt.Sprintf("gs://%s/%s", e.Bucket, e.Name),
+			Output:            outputGCSURI,
+			Provider:          synth.Name(),
+			Voice:             voice.String(),
+			LanguageCode:      voice.LanguageCode,
+			AudioFormat:       audioSettings.Format.String(),
+			Mode:              mode,
+			Cost:              estimate,
+			DocumentBudgetUSD: documentBudget,
+			MonthlyBudgetUSD:  monthlyBudget,
+			OverDocumentLimit: documentBudget > 0 && estimate.USD > documentBudget,
+		}
+		skipped = "dry run"
+		return p.writeDryRunReport(ctx, outputBucket, dryRunObjectName(outputAudioObjectName), report, planned)
+	}
+
+	override := budgetOverride(e.Metadata)
+	if documentBudget > 0 && estimate.USD > documentBudget {
+		if !override {
+			log.Printf("Refusing to synthesize %s: estimated cost $%.2f exceeds the per-document budget of $%.2f. Re-upload it with x-goog-meta-tts-budget-override: true to proceed.", e.Name, estimate.USD, documentBudget)
+			skipped = "over the per-document budget"
+			return nil
+		}
+		log.Printf("Warning: Estimated cost $%.2f of %s exceeds the per-document budget of $%.2f. Proceeding because of the budget override.", estimate.USD, e.Name, documentBudget)
+	}
+	releaseBudget, err := p.reserveBudget(ctx, e.Bucket, estimate, monthlyBudget, override)
+	if errors.Is(err, errOverBudget) {
+		log.Printf("Refusing to synthesize %s: %v. Re-upload it with x-goog-meta-tts-budget-override: true to proceed.", e.Name, err)
+		skipped = "over the monthly budget"
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check the budget for %s: %w", e.Name, err)
+	}
+	defer func() {
+		if err != nil {
+			releaseBudget() // Failed documents don't count against the month.
+		}
+	}()
+
+	// Throttle against the shared TTS quota: wait for one of the synthesis slots shared by all
+	// instances, kept in BASE_GCS_BUCKET whichever bucket the input is in. The synthesizer
+	// limits this job's requests to its share of TTS_QPS.
+	slot, err := p.acquireSynthesisSlot(ctx, cfg.BaseBucket, fmt.Sprintf("gs://%s/%s", e.Bucket, e.Name), cfg.MaxConcurrentJobs)
+	if err != nil {
+		return fmt.Errorf("failed to start synthesis for %s: %w", e.Name, err)
+	}
+	// A long audio operation handed off to FinalizePendingSyntheses keeps its slot, and clears
+	// slot so it isn't released here.
+	defer func() { p.releaseSynthesisSlot(ctx, slot) }()
+
+	// buildSegments renders the document, or its dialogue turns, into synthesis segments.
+	buildSegments := func(opts ssml.Options) []tts.Segment {
+		if speakerVoiceMap != nil {
+			return dialogueSegments(synth, dialogueTurns, speakerVoiceMap, voice, opts)
+		}
+		var segments []tts.Segment
+		for _, input := range buildInputs(extractedText, opts, capabilities.SSML, capabilities.ChunkBytes()) {
+			segments = append(segments, tts.Segment{Input: input, Voice: voice})
+		}
+		return segments
+	}
+
+	// The manifest written next to the audio describes the job for downstream systems.
+	manifest := jobManifest{
+		Input:           fmt.Sprintf("gs://%s/%s", e.Bucket, e.Name),
+		InputGeneration: e.Generation,
+		Output:          outputGCSURI,
+		Pages:           extraction.Pages,
+		FailedPages:     extraction.FailedPages,
+		Characters:      utf8.RuneCountInString(extractedText),
+		Chunks:          len(inputs),
+		Provider:        synth.Name(),
+		Voice:           voice.String(),
+		LanguageCode:    voice.LanguageCode,
+		Encoding:        audioSettings.Format.String(),
+		Mode:            string(mode),
+		Timings:         manifestTimings{ReceivedAt: receivedAt, ExtractionSeconds: extractionTime.Seconds()},
+		PipelineVersion: cfg.pipelineVersion(),
+	}
+
+	// A checkpointed synthesis is only resumed with the same output, voice, settings and text;
+	// anything an attempt with others left in the output's tmp/ folder is removed.
+	if cp != nil {
+		key := dedupKey
+		if key == "" {
+			if key, err = contentKey(synth.Name(), voice, speakerVoiceMap, audioSettings, inputs); err != nil {
+				return err
+			}
+		}
+		if cp.Output != outputGCSURI || cp.ContentKey != key {
+			if cp.Output != "" {
+				p.cleanupIntermediates(ctx, outputGCSURI)
+			}
+			cp.Output, cp.ContentKey, cp.Operation, cp.Parts = outputGCSURI, key, "", nil
+			p.saveCheckpoint(ctx, e.Bucket, e.Name, cp)
+		}
+	}
+
+	stage = stageSynthesis
+	track(jobtrack.Synthesizing)
+	ctx = tts.WithProgress(ctx, synthesisProgress(progress))
+	synthesisStart := time.Now()
+
+	// With CHAPTER_TOPIC set, a book with chapters is fanned out: each chapter is synthesized by
+	// its own SynthesizeChapter invocation, all in parallel, and FinalizePendingSyntheses joins
+	// them into the audiobook once all are done. Dialogue, streaming and timepoints need the
+	// whole document in one invocation.
+	_, canCompose := p.store.(storage.Composer)
+	if cfg.ChapterTopic != "" && canCompose && speakerVoiceMap == nil && mode != modeStreaming && !timepointsEnabled(cfg, e.Metadata) {
+		if chapters := ssml.Chapters(extractedText); len(chapters) > 1 {
+			pending := pendingSynthesis{Bucket: e.Bucket, InputObject: e.Name, OutputURI: outputGCSURI, StartedAt: time.Now().UTC(), Provider: synth.Name(), Slot: slot, Source: sourceMetadata(cfg, e), ContentKey: dedupKey, Manifest: &manifest, Job: e.jobID()}
+			pending.Callback, pending.NotifyEmail = e.Metadata["tts-callback-url"], e.Metadata["tts-notify-email"]
+			pending.Headers = outputHeaders(cfg, e.Name, outputAudioObjectName, voice.LanguageCode)
+			pending.Format = audioSettings.Format.String()
+			manifest.Mode = string(modeChapters)
+			tasks := make([]chapterTask, len(chapters))
+			for i, chapter := range chapters {
+				uri, err := chapterURI(outputGCSURI, i, audioSettings.Format)
+				if err != nil {
+					return err
+				}
+				pending.Parts = append(pending.Parts, synthesisPart{OutputURI: uri})
+				tasks[i] = chapterTask{Input: manifest.Input, Chapter: i + 1, Chapters: len(chapters), OutputURI: uri, Provider: synth.Name(), ClonedVoice: voice.CloningKey != "", Settings: audioSettings}
+				for _, input := range buildInputs(chapter, ssmlOptions, capabilities.SSML, capabilities.ChunkBytes()) {
+					tasks[i].Segments = append(tasks[i].Segments, tts.Segment{Input: input, Voice: voice})
+				}
+			}
+			if err := p.savePending(ctx, outputAudioObjectName, pending); err != nil {
+				return err
+			}
+			if err := p.publishChapters(ctx, tasks); err != nil {
+				p.store.DeleteObject(ctx, e.Bucket, pendingObjectName(outputAudioObjectName))
+				return err
+			}
+			slot, handedOff = nil, true
+			log.Printf("Fanned out %d chapters of %s. Output will appear at %s.", len(chapters), e.Name, outputGCSURI)
+			return nil
+		}
+	}
+
+	switch mode {
+	case modeStreaming:
+		workers := cfg.ChunkConcurrency
+		if timepointsEnabled(cfg, e.Metadata) {
+			log.Printf("Warning: Streaming synthesis doesn't write timepoints. No timepoints file will be written for %s.", e.Name)
+		}
+		// Publish each part as soon as it and the parts before it are done, so listening can
+		// start while the rest of the document is synthesized.
+		stream := newStreamingOutput(p.store, outputBucket, outputAudioObjectName, audioSettings.Format)
+		log.Printf("Streaming %s: parts will be listed in gs://%s/%s as they're ready.", e.Name, outputBucket, stream.playlistObject)
+		parts, err := tts.SynthesizeSegmentsInOrder(ctx, synth, buildSegments(ssmlOptions), audioSettings, workers, func(i int, audio []byte) error {
+			return stream.add(ctx, i, audio)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to synthesize speech for %s: %w", e.Name, err)
+		}
+		audio, err := tts.ConcatAudio(audioSettings.Format, parts)
+		if err != nil {
+			return fmt.Errorf("failed to concatenate audio for %s: %w", e.Name, err)
+		}
+		if duration, err := tts.AudioDuration(audioSettings.Format, audio); err == nil {
+			manifest.DurationSeconds = duration.Seconds()
+		}
+		if err := p.store.UploadFile(ctx, outputBucket, outputAudioObjectName, audio, audioSettings.Format.ContentType, outputHeaders(cfg, e.Name, outputAudioObjectName, voice.LanguageCode)); err != nil {
+			return fmt.Errorf("failed to upload audio for %s: %w", e.Name, err)
+		}
+	case modeChunked:
+		workers := cfg.ChunkConcurrency
+		var parts [][]byte
+		var marks *ssml.Marks
+		var timepoints []tts.Timepoint
+		if timepointsEnabled(cfg, e.Metadata) && synth.Name() != tts.ProviderGoogle {
+			log.Printf("Warning: Timepoints are only supported with the %s provider. No timepoints file will be written for %s.", tts.ProviderGoogle, e.Name)
+		}
+		if composer, ok := p.store.(storage.Composer); ok && !(timepointsEnabled(cfg, e.Metadata) && synth.Name() == tts.ProviderGoogle) {
+			// Upload each chunk as soon as it's synthesized and join them server-side, so a
+			// multi-hour book is never held in memory. Timepoints still need the audio here.
+			duration, err := tts.ComposeSegments(ctx, synth, composer, buildSegments(ssmlOptions), audioSettings, workers, outputBucket, tmpObjectPrefix(outputAudioObjectName), outputAudioObjectName, outputHeaders(cfg, e.Name, outputAudioObjectName, voice.LanguageCode), cp != nil)
+			if err != nil {
+				return fmt.Errorf("failed to synthesize speech for %s: %w", e.Name, err)
+			}
+			manifest.DurationSeconds = duration.Seconds()
+			break
+		}
+		if google, ok := synth.(tts.Google); ok && timepointsEnabled(cfg, e.Metadata) {
+			// Tag every sentence with a <mark> and request timepoints for a read-along index.
+			marks = &ssml.Marks{}
+			markedOptions := ssmlOptions
+			markedOptions.Marks = marks
+			parts, timepoints, err = google.Client.SynthesizeSegmentsWithTimepoints(ctx, buildSegments(markedOptions), audioSettings, workers)
+		} else {
+			parts, err = tts.SynthesizeSegments(ctx, synth, buildSegments(ssmlOptions), audioSettings, workers)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to synthesize speech for %s: %w", e.Name, err)
+		}
+		audio, err := tts.ConcatAudio(audioSettings.Format, parts)
+		if err != nil {
+			return fmt.Errorf("failed to concatenate audio for %s: %w", e.Name, err)
+		}
+		if duration, err := tts.AudioDuration(audioSettings.Format, audio); err == nil {
+			manifest.DurationSeconds = duration.Seconds()
+		}
+		if err := p.store.UploadFile(ctx, outputBucket, outputAudioObjectName, audio, audioSettings.Format.ContentType, outputHeaders(cfg, e.Name, outputAudioObjectName, voice.LanguageCode)); err != nil {
+			return fmt.Errorf("failed to upload audio for %s: %w", e.Name, err)
+		}
+		if marks != nil {
+			if err := p.writeTimepoints(ctx, outputBucket, timepointsObjectName(outputAudioObjectName), marks, timepoints); err != nil {
+				return fmt.Errorf("failed to write timepoints for %s: %w", e.Name, err)
+			}
+		}
+	case modeLongAudio:
+		longInputs := buildInputs(extractedText, ssmlOptions, capabilities.SSML, capabilities.LongAudioBytes())
+		if err := validateInputs(longInputs, capabilities.LongAudioBytes()); err != nil {
+			return fmt.Errorf("generated input for %s would be rejected: %w", e.Name, err)
+		}
+		if err := p.checkLongAudioEncryption(ctx, outputBucket, cfg.KMSKeyName); err != nil {
+			return err
+		}
+		manifest.Chunks = len(longInputs)
+		if timepointsEnabled(cfg, e.Metadata) {
+			log.Printf("Warning: Long Audio Synthesis doesn't return timepoints. No timepoints file will be written for %s.", e.Name)
+		}
+		maxWait := cfg.MaxSynthesisWait
+		pending := pendingSynthesis{Bucket: e.Bucket, InputObject: e.Name, OutputURI: outputGCSURI, StartedAt: time.Now().UTC(), Provider: synth.Name(), Slot: slot, Source: sourceMetadata(cfg, e), ContentKey: dedupKey, Manifest: &manifest, Job: e.jobID()}
+		pending.Callback, pending.NotifyEmail = e.Metadata["tts-callback-url"], e.Metadata["tts-notify-email"]
+		pending.Headers = outputHeaders(cfg, e.Name, outputAudioObjectName, voice.LanguageCode)
+		// Operations an interrupted attempt already started are waited for again rather than
+		// started anew; each one started is checkpointed right away.
+		if cp != nil {
+			pending.Operation, pending.Parts = cp.Operation, cp.Parts
+			if pending.Operation != "" || len(pending.Parts) > 0 {
+				log.Printf("Resuming the long audio synthesis of %s started by an earlier attempt.", e.Name)
+			}
+		}
+		if len(longInputs) == 1 {
+			if pending.Operation == "" {
+				pending.Operation, err = synth.SynthesizeToGCS(ctx, longInputs[0], outputGCSURI, voice, audioSettings)
+				if err != nil {
+					return fmt.Errorf("failed to synthesize speech for %s: %w", e.Name, err)
+				}
+				if cp != nil {
+					cp.Operation = pending.Operation
+					p.saveCheckpoint(ctx, e.Bucket, e.Name, cp)
+				}
+			}
+		} else {
+			// Documents over the long audio input limit are split across several operations,
+			// each writing a part that's joined into the output once all of them are done.
+			log.Printf("Document %s exceeds the long audio input limit of %d bytes. Splitting it into %d operations.", e.Name, capabilities.LongAudioBytes(), len(longInputs))
+			pending.Format = audioSettings.Format.String()
+			for i := len(pending.Parts); i < len(longInputs); i++ {
+				uri, err := partURI(outputGCSURI, i, audioSettings.Format)
+				if err != nil {
+					return err
+				}
+				operation, err := synth.SynthesizeToGCS(ctx, longInputs[i], uri, voice, audioSettings)
+				if err != nil {
+					return fmt.Errorf("failed to synthesize part %d of %d for %s (%d already started and will be left running): %w", i+1, len(longInputs), e.Name, i, err)
+				}
+				pending.Parts = append(pending.Parts, synthesisPart{Operation: operation, OutputURI: uri})
+				if cp != nil {
+					cp.Parts = pending.Parts
+					p.saveCheckpoint(ctx, e.Bucket, e.Name, cp)
+				}
+			}
+		}
+		if cfg.AsyncLongAudio {
+			// Return right after starting; FinalizePendingSyntheses follows the operation from here,
+			// so long books don't run into the function timeout while polling.
+			if err := p.savePending(ctx, outputAudioObjectName, pending); err != nil {
+				return err
+			}
+			slot, handedOff = nil, true
+			log.Printf("Started long audio synthesis for %s. Output will appear at %s.", e.Name, outputGCSURI)
+			return nil
+		}
+
+		log.Printf("Long Audio Synthesis started for %s. Waiting for completion...", e.Name)
+		waitCtx, cancel := untilShutdown(ctx)
+		if maxWait > 0 {
+			var cancelWait context.CancelFunc
+			waitCtx, cancelWait = context.WithTimeout(waitCtx, maxWait)
+			defer cancelWait()
+		}
+		err = waitForSynthesis(waitCtx, synth, &pending)
+		cancel()
+		if err != nil && errors.Is(context.Cause(waitCtx), errShuttingDown) {
+			// The operation keeps running, and is in the checkpoint: the redelivered event waits
+			// for it again rather than starting it over.
+			log.Printf("Stopped waiting for the long audio synthesis of %s: the instance is shutting down.", e.Name)
+			return transient(fmt.Errorf("stopped waiting for the synthesis of %s: %w", e.Name, errShuttingDown))
+		}
+		if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			// Only our own deadline expired: hand the still-running operation to the finalizer
+			// and exit cleanly rather than letting the platform kill the invocation.
+			log.Printf("Long audio synthesis for %s is still running after %v. Handing it off to FinalizePendingSyntheses.", e.Name, maxWait)
+			if err := p.savePending(ctx, outputAudioObjectName, pending); err != nil {
+				return err
+			}
+			slot, handedOff = nil, true
+			return nil
+		}
+		if err != nil {
+			// A failed operation can't be resumed; the next attempt starts the synthesis over.
+			// One cut short by the invocation's own timeout still can.
+			if cp != nil && ctx.Err() == nil {
+				cp.Operation, cp.Parts = "", nil
+				p.saveCheckpoint(ctx, e.Bucket, e.Name, cp)
+			}
+			return fmt.Errorf("failed to synthesize speech for %s: %w", e.Name, err)
+		}
+		if len(pending.Parts) > 0 {
+			if err := p.joinParts(ctx, pending); err != nil {
+				return err
+			}
+		}
+		p.setOutputHeaders(ctx, outputGCSURI, pending.Headers)
+	}
+
+	track(jobtrack.Finalizing)
+	p.markOutputSource(ctx, outputGCSURI, sourceMetadata(cfg, e))
+	if dedupKey != "" {
+		p.registerSynthesizedAudio(ctx, e.Bucket, dedupKey, e.Name, outputGCSURI)
+	}
+	manifest = p.writeManifest(ctx, cfg, manifest, synthesisStart)
+	stats = &manifest
+	p.cleanupIntermediates(ctx, outputGCSURI)
+
+	// Push the audio and manifest to the distribution partner's SFTP server, if configured.
+	// A failure fails the document, and its retry finds the output up to date and only
+	// repeats the delivery.
+	stage = stageDelivery
+	if err := p.deliverOutput(ctx, cfg, outputGCSURI); err != nil {
+		return err
+	}
+	p.archiveInput(ctx, cfg, e.Bucket, e.Name, outputGCSURI)
 	log.Printf("Successfully processed %s. Output: %s", e.Name, outputGCSURI)
 	return nil
 }
+
+// validateInputs checks every input against the request size limit and SSML
+// inputs against the SSML the APIs accept, so problems surface as errors naming
+// the chunk and the offending markup rather than as an InvalidArgument from the API.
+func validateInputs(inputs []tts.Input, maxBytes int) error {
+	for i, input := range inputs {
+		var err error
+		if input.SSML != "" {
+			err = ssml.Validate(input.SSML, maxBytes)
+		} else if input.Len() > maxBytes {
+			err = fmt.Errorf("%d bytes of text exceed the %d byte limit", input.Len(), maxBytes)
+		}
+		if err != nil {
+			return fmt.Errorf("chunk %d/%d: %w", i+1, len(inputs), err)
+		}
+	}
+	return nil
+}
+
+// buildInputs splits text into synthesis inputs of at most maxBytes each: SSML
+// documents rendered with opts, or sanitized plain text for voices without SSML support.
+func buildInputs(text string, opts ssml.Options, useSSML bool, maxBytes int) []tts.Input {
+	var inputs []tts.Input
+	if useSSML {
+		for _, doc := range ssml.BuildChunks(text, opts, maxBytes) {
+			inputs = append(inputs, tts.SSMLInput(doc))
+		}
+		return inputs
+	}
+	for _, chunk := range chunker.Split(ssml.Sanitize(text), maxBytes) {
+		inputs = append(inputs, tts.TextInput(chunk))
+	}
+	return inputs
+}
+
+// loadLexicon reads and parses the pronunciation lexicon stored at objectName in the bucket.
+func (p *Pipeline) loadLexicon(ctx context.Context, bucketName, objectName string) (*ssml.Lexicon, error) {
+	data, err := p.store.ReadObject(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lexicon %s: %w", objectName, err)
+	}
+	lexicon, err := ssml.ParseLexicon(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lexicon %s: %w", objectName, err)
+	}
+	log.Printf("Loaded pronunciation lexicon gs://%s/%s with %d entries.", bucketName, objectName, lexicon.Len())
+	return lexicon, nil
+}
+
+// abbreviationsFor returns the abbreviation dictionary for a document. The built-in
+// list is English only; objectName, from ABBREVIATIONS_OBJECT, names an optional JSON
+// object in the bucket whose entries are added on top (an empty expansion removes a
+// built-in entry).
+func (p *Pipeline) abbreviationsFor(ctx context.Context, bucketName, objectName, languageCode string) (textnorm.Abbreviations, error) {
+	abbreviations := textnorm.Abbreviations{}
+	if langdetect.Language(languageCode) == "en" {
+		abbreviations = textnorm.DefaultAbbreviations
+	}
+
+	if objectName == "" {
+		return abbreviations, nil
+	}
+	data, err := p.store.ReadObject(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read abbreviations %s: %w", objectName, err)
+	}
+	overrides, err := textnorm.ParseAbbreviations(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse abbreviations %s: %w", objectName, err)
+	}
+	log.Printf("Loaded %d abbreviation overrides from gs://%s/%s.", len(overrides), bucketName, objectName)
+	return abbreviations.Merge(overrides), nil
+}
@@ -0,0 +1,272 @@
+package pdftospeech
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"MODULE_NAME/jsou-tts/jobspb"
+)
+
+// Page sizes of ListJobs.
+const (
+	defaultJobsPageSize = 50
+	maxJobsPageSize     = 200
+)
+
+// jobWatchInterval is how often WatchJob checks a job for changes.
+const jobWatchInterval = 5 * time.Second
+
+// jobStatuses maps the states of job records to the statuses of the Jobs service.
+var jobStatuses = map[string]jobspb.JobStatus{
+	jobQueued:     jobspb.JobStatus_JOB_STATUS_QUEUED,
+	jobRunning:    jobspb.JobStatus_JOB_STATUS_RUNNING,
+	jobDone:       jobspb.JobStatus_JOB_STATUS_DONE,
+	jobFailed:     jobspb.JobStatus_JOB_STATUS_FAILED,
+	jobCancelling: jobspb.JobStatus_JOB_STATUS_CANCELLING,
+	jobCancelled:  jobspb.JobStatus_JOB_STATUS_CANCELLED,
+}
+
+// NewServiceHandler returns a handler serving the gRPC Jobs service of jobspb
+// and the REST Jobs API of the JobsAPI entry point on one port, for running the
+// pipeline as a service, e.g. on Cloud Run, whose single port can't separate
+// them. Requests are told apart by their application/grpc content type. gRPC
+// needs HTTP/2, which Cloud Run forwards unencrypted when the service is
+// deployed with --use-http2: serve the handler with an http.Server whose
+// Protocols allow unencrypted HTTP/2, as cmd/jobsserver does. The
+// configuration and clients are loaded as for the function's entry points.
+func NewServiceHandler() (http.Handler, error) {
+	p, err := functionPipeline()
+	if err != nil {
+		return nil, err
+	}
+	if p.cfg.BaseBucket == "" {
+		return nil, fmt.Errorf("BASE_GCS_BUCKET must be set for the Jobs service")
+	}
+	if p.jobsAPIKey == "" {
+		return nil, fmt.Errorf("JOBS_API_KEY_SECRET must be set for the Jobs service")
+	}
+	if err := p.checkOnDemandJobs(); err != nil {
+		return nil, fmt.Errorf("%w for the Jobs service", err)
+	}
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(int(uploadLimit(p.cfg)+maxEventBytes)),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if !p.authorizedKey(grpcAPIKey(ctx)) {
+				return nil, status.Error(codes.Unauthenticated, errWrongAPIKey.Error())
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if !p.authorizedKey(grpcAPIKey(stream.Context())) {
+				return status.Error(codes.Unauthenticated, errWrongAPIKey.Error())
+			}
+			return handler(srv, stream)
+		}),
+	)
+	jobspb.RegisterJobsServer(server, &jobsService{cfg: p.cfg, pipeline: p})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			server.ServeHTTP(w, r)
+			return
+		}
+		jobsAPI(w, r)
+	}), nil
+}
+
+// grpcAPIKey returns the Jobs API key sent in the x-api-key metadata of a call.
+func grpcAPIKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get(strings.ToLower(jobsAPIKeyHeader)); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// jobsService implements the gRPC Jobs service over the on-demand jobs of the
+// Jobs API.
+type jobsService struct {
+	jobspb.UnimplementedJobsServer
+	cfg      *Config
+	pipeline *Pipeline
+}
+
+// SubmitJob implements jobspb.JobsServer.
+func (s *jobsService) SubmitJob(ctx context.Context, req *jobspb.SubmitJobRequest) (*jobspb.Job, error) {
+	var job onDemandJob
+	var httpStatus int
+	var err error
+	switch source := req.GetSource().(type) {
+	case *jobspb.SubmitJobRequest_Uri:
+		job, httpStatus, err = s.pipeline.queueJob(ctx, s.cfg.BaseBucket, jobRequest{URI: source.Uri, Options: req.GetOptions()})
+	case *jobspb.SubmitJobRequest_Pdf:
+		if limit := uploadLimit(s.cfg); int64(len(source.Pdf)) > limit {
+			return nil, status.Errorf(codes.InvalidArgument, "the PDF is over the limit of %d bytes", limit)
+		}
+		job, httpStatus, err = s.pipeline.queueUploadedJob(ctx, s.cfg, req.GetName(), bytes.NewReader(source.Pdf), req.GetOptions())
+	default:
+		return nil, status.Error(codes.InvalidArgument, "set uri or pdf")
+	}
+	if err != nil {
+		code := codes.Internal
+		switch httpStatus {
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+			code = codes.InvalidArgument
+		case http.StatusNotFound:
+			code = codes.NotFound
+		case http.StatusForbidden:
+			code = codes.PermissionDenied
+		default:
+			log.Printf("Error: %v", err)
+		}
+		return nil, status.Error(code, err.Error())
+	}
+	log.Printf("Queued job %s for %s.", job.ID, job.Input)
+	return jobProto(jobStatus{onDemandJob: job}), nil
+}
+
+// GetJob implements jobspb.JobsServer.
+func (s *jobsService) GetJob(ctx context.Context, req *jobspb.GetJobRequest) (*jobspb.Job, error) {
+	job, err := s.pipeline.loadJob(ctx, req.GetId())
+	if err != nil {
+		return nil, jobStatusError(err)
+	}
+	return jobProto(s.pipeline.describeJob(ctx, s.cfg, job)), nil
+}
+
+// ListJobs implements jobspb.JobsServer. Jobs are listed newest first, and a
+// page token is the ID of the last job of the previous page. Progress isn't
+// included; GetJob and WatchJob report it.
+func (s *jobsService) ListJobs(ctx context.Context, req *jobspb.ListJobsRequest) (*jobspb.ListJobsResponse, error) {
+	size := int(req.GetPageSize())
+	if size <= 0 {
+		size = defaultJobsPageSize
+	}
+	size = min(size, maxJobsPageSize)
+	after := req.GetPageToken()
+	if after != "" && !jobIDPattern.MatchString(after) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page token %q", after)
+	}
+	var withStatus string
+	if req.GetStatus() != jobspb.JobStatus_JOB_STATUS_UNSPECIFIED {
+		for name, st := range jobStatuses {
+			if st == req.GetStatus() {
+				withStatus = name
+			}
+		}
+		if withStatus == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid status %v", req.GetStatus())
+		}
+	}
+
+	// One job more than the page tells whether there's a next one.
+	jobs, err := s.pipeline.jobs.ListJobs(ctx, withStatus, after, size+1)
+	if errors.Is(err, errNoJob) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page token %q: its job no longer exists", after)
+	}
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &jobspb.ListJobsResponse{}
+	if len(jobs) > size {
+		jobs = jobs[:size]
+		resp.NextPageToken = jobs[size-1].ID
+	}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, jobProto(jobStatus{onDemandJob: job}))
+	}
+	return resp, nil
+}
+
+// WatchJob implements jobspb.JobsServer. The job is checked every
+// jobWatchInterval and sent when anything about it changed, until it's done,
+// failed or cancelled, or the client goes away.
+func (s *jobsService) WatchJob(req *jobspb.WatchJobRequest, stream grpc.ServerStreamingServer[jobspb.Job]) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(jobWatchInterval)
+	defer ticker.Stop()
+	var last *jobspb.Job
+	for {
+		job, err := s.pipeline.loadJob(ctx, req.GetId())
+		if err != nil {
+			return jobStatusError(err)
+		}
+		current := jobProto(s.pipeline.describeJob(ctx, s.cfg, job))
+		if !proto.Equal(current, last) {
+			if err := stream.Send(current); err != nil {
+				return err
+			}
+			last = current
+		}
+		switch job.Status {
+		case jobDone, jobFailed, jobCancelled:
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CancelJob implements jobspb.JobsServer.
+func (s *jobsService) CancelJob(ctx context.Context, req *jobspb.CancelJobRequest) (*jobspb.Job, error) {
+	job, err := s.pipeline.cancelJob(ctx, req.GetId())
+	if err != nil {
+		return nil, jobStatusError(err)
+	}
+	return jobProto(jobStatus{onDemandJob: job}), nil
+}
+
+// jobProto converts a job to its message.
+func jobProto(s jobStatus) *jobspb.Job {
+	job := &jobspb.Job{
+		Id:         s.ID,
+		Input:      s.Input,
+		Options:    s.Options,
+		Status:     jobStatuses[s.Status],
+		Output:     s.Output,
+		Error:      s.Error,
+		CreateTime: timestamppb.New(s.CreatedAt),
+		UpdateTime: timestamppb.New(s.UpdatedAt),
+		Stage:      s.Stage,
+		OutputUrl:  s.OutputURL,
+	}
+	if p := s.Progress; p != nil {
+		job.Progress = &jobspb.Progress{
+			PagesExtracted:    int32(p.PagesExtracted),
+			Pages:             int32(p.Pages),
+			ChunksSynthesized: int32(p.ChunksSynthesized),
+			Chunks:            int32(p.Chunks),
+			Percent:           p.Percent,
+		}
+	}
+	return job
+}
+
+// jobStatusError converts the error of a job operation to a gRPC status.
+func jobStatusError(err error) error {
+	switch {
+	case errors.Is(err, errNoJob):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errJobEnded):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		log.Printf("Error: %v", err)
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,58 @@
+package pdftospeech
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"MODULE_NAME/jsou-tts/jobspb"
+)
+
+func TestListJobs(t *testing.T) {
+	p := newTestPipeline(t)
+	ctx := context.Background()
+	created := time.Now().Add(-time.Hour).UTC()
+	var want []string
+	for i := range 5 {
+		job := onDemandJob{ID: fmt.Sprintf("%032x", i), Input: "gs://library/pdf-input/book.pdf", Status: jobDone, CreatedAt: created.Add(time.Duration(i) * time.Minute)}
+		if i == 2 {
+			job.Status = jobFailed
+		}
+		job.UpdatedAt = job.CreatedAt
+		if err := p.jobs.CreateJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+		want = append([]string{job.ID}, want...)
+	}
+	s := &jobsService{cfg: p.cfg, pipeline: p}
+
+	var got []string
+	token := ""
+	for page := 0; page == 0 || token != ""; page++ {
+		resp, err := s.ListJobs(ctx, &jobspb.ListJobsRequest{PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, job := range resp.GetJobs() {
+			got = append(got, job.GetId())
+		}
+		token = resp.GetNextPageToken()
+		// Updating a listed job doesn't move it between pages.
+		if _, err := p.jobs.UpdateJob(ctx, want[0], func(job *onDemandJob) (bool, error) { return true, nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+
+	resp, err := s.ListJobs(ctx, &jobspb.ListJobsRequest{Status: jobspb.JobStatus_JOB_STATUS_FAILED})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetJobs()) != 1 || resp.GetJobs()[0].GetId() != fmt.Sprintf("%032x", 2) || resp.GetNextPageToken() != "" {
+		t.Errorf("listed %v for the failed jobs, want only job 2", resp.GetJobs())
+	}
+}
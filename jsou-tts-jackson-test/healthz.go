@@ -0,0 +1,109 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// healthCheckObject is the object whose metadata health checks read to verify
+// bucket access. It needn't exist: a missing object still proves the bucket can
+// be reached with the function's credentials.
+const healthCheckObject = "tts-healthz"
+
+// healthCheck is the outcome of one check of a health report.
+type healthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// healthReport is the response of the Healthz entry point.
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+// healthz serves the Healthz entry point. It verifies that the configuration
+// loads and the clients are created, that the settings the pipeline can't run
+// without are set, and that the input and output buckets can be reached, so a
+// deployment can be validated before real events are routed to it. It responds
+// 200 with the checks when all pass, and 503 otherwise.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var report healthReport
+	check := func(name string, err error) {
+		c := healthCheck{Name: name, OK: err == nil}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		report.Checks = append(report.Checks, c)
+	}
+
+	p, err := functionPipeline()
+	check("clients", err)
+	if err == nil {
+		cfg := p.cfg
+		check("config", p.healthConfigError(cfg))
+		if cfg.BaseBucket != "" {
+			check("input bucket", p.bucketAccessError(ctx, cfg.BaseBucket))
+			if outputBucket, _ := cfg.outputLocation(cfg.BaseBucket); outputBucket != cfg.BaseBucket {
+				check("output bucket", p.bucketAccessError(ctx, outputBucket))
+			}
+		}
+	}
+
+	status := http.StatusOK
+	report.Status = "ok"
+	for _, c := range report.Checks {
+		if !c.OK {
+			status = http.StatusServiceUnavailable
+			report.Status = "unavailable"
+			log.Printf("Warning: Health check %s failed: %s", c.Name, c.Error)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// healthConfigError returns an error naming the required settings that are
+// missing: BASE_GCS_BUCKET, and PROJECT_NUMBER and GCP_LOCATION (or TTS_REGION)
+// with Google Text-to-Speech, whose client must also exist.
+func (p *Pipeline) healthConfigError(cfg *Config) error {
+	var missing []string
+	if cfg.BaseBucket == "" {
+		missing = append(missing, "BASE_GCS_BUCKET")
+	}
+	if cfg.usesGoogleTTS() {
+		if cfg.ProjectNumber == "" {
+			missing = append(missing, "PROJECT_NUMBER")
+		}
+		if cfg.ttsLocation() == "" {
+			missing = append(missing, "GCP_LOCATION")
+		}
+		if p.ttsClient == nil {
+			return fmt.Errorf("the Text-to-Speech client wasn't created")
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s not set", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// bucketAccessError returns an error if the bucket can't be read with the
+// function's credentials.
+func (p *Pipeline) bucketAccessError(ctx context.Context, bucket string) error {
+	if _, _, err := p.store.ObjectMetadata(ctx, bucket, healthCheckObject); err != nil {
+		return fmt.Errorf("failed to access %s: %w", bucket, err)
+	}
+	return nil
+}
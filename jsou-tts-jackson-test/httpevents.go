@@ -0,0 +1,70 @@
+package pdftospeech
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// maxEventBytes caps the body of an event posted to an HTTP entry point.
+const maxEventBytes = 1 << 20
+
+// readEventBody reads the event posted to an HTTP entry point. It responds and
+// returns false if the request isn't a POST or its body can't be read.
+func readEventBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST an event", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read the event: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// processEventObjects runs the handler for each object of an event posted to an
+// HTTP entry point, in turn. A failure responds with 500 so the sender retries
+// the event.
+func processEventObjects(w http.ResponseWriter, r *http.Request, objects []StorageObjectData) {
+	p, err := functionPipeline()
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, "service unavailable, try again", http.StatusServiceUnavailable)
+		return
+	}
+	cfg := p.cfg
+
+	var errs []error
+	for _, e := range objects {
+		if err := p.processStoredObject(r.Context(), cfg, e); err != nil {
+			log.Printf("Error: Processing %s in bucket %s failed: %v", e.Name, e.Bucket, err)
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// processStoredObject runs the handler for an object from an event that doesn't
+// carry the object's metadata, after reading it from the store. Only failures
+// worth retrying the event for are returned.
+func (p *Pipeline) processStoredObject(ctx context.Context, cfg *Config, e StorageObjectData) error {
+	metadata, exists, err := p.store.ObjectMetadata(ctx, e.Bucket, e.Name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		log.Printf("Warning: %s no longer exists in bucket %s. Skipping.", e.Name, e.Bucket)
+		return nil
+	}
+	e.Metadata = metadata
+	return retryableOnly(e.Name, p.processPDFToSpeechHandler(ctx, cfg, e))
+}
@@ -0,0 +1,85 @@
+package pdftospeech
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// Output metadata keys recording which version of the input an output was made
+// from, so a redelivered event or a rewrite of the same PDF doesn't run the
+// synthesis again.
+const (
+	sourceGenerationKey = "source-generation"
+	sourceMD5Key        = "source-md5"
+)
+
+// defaultPropagatedMetadata lists the input metadata keys copied to the output
+// when PROPAGATE_METADATA isn't set.
+const defaultPropagatedMetadata = "owner,request-id,label-*"
+
+// sourceMetadata returns the output metadata identifying the input of e, along
+// with the input's metadata that is propagated to the output.
+func sourceMetadata(cfg *Config, e StorageObjectData) map[string]string {
+	metadata := propagatedMetadata(cfg.PropagateMetadata, e.Metadata)
+	metadata[sourceGenerationKey] = e.Generation
+	metadata[sourceMD5Key] = e.MD5Hash
+	// The file of a PDF copied from a folder intake is where its audio goes
+	// back to.
+	if id := e.Metadata[intakeFileKey]; id != "" {
+		metadata[intakeSourceKey] = e.Metadata[intakeSourceKey]
+		metadata[intakeFileKey] = id
+	}
+	return metadata
+}
+
+// propagatedMetadata selects the input metadata copied to the output, so
+// tracking systems can correlate them: the comma-separated keys in patterns,
+// from PROPAGATE_METADATA, where a trailing "*" matches a prefix (e.g.
+// "label-*"). Set it to "-" to copy nothing.
+func propagatedMetadata(patterns string, input map[string]string) map[string]string {
+	selected := map[string]string{}
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		for key, value := range input {
+			if key == pattern || wildcard && strings.HasPrefix(key, prefix) {
+				selected[key] = value
+			}
+		}
+	}
+	return selected
+}
+
+// forceReprocess reports whether the document was uploaded with
+// x-goog-meta-tts-force: true, which skips the up-to-date check.
+func forceReprocess(metadata map[string]string) bool {
+	return strings.EqualFold(metadata["tts-force"], "true")
+}
+
+// outputUpToDate reports whether the output object exists and was made from the
+// same input: the same generation, or the same content if the PDF was uploaded again.
+func (p *Pipeline) outputUpToDate(ctx context.Context, bucket, object string, e StorageObjectData) (bool, error) {
+	metadata, exists, err := p.store.ObjectMetadata(ctx, bucket, object)
+	if err != nil || !exists {
+		return false, err
+	}
+	if e.Generation != "" && metadata[sourceGenerationKey] == e.Generation {
+		return true, nil
+	}
+	return e.MD5Hash != "" && metadata[sourceMD5Key] == e.MD5Hash, nil
+}
+
+// markOutputSource records the input an output was made from. A failure is only
+// logged: the output is fine, it just won't be recognized as up to date.
+func (p *Pipeline) markOutputSource(ctx context.Context, outputURI string, source map[string]string) {
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err == nil {
+		err = p.store.UpdateObjectMetadata(ctx, bucket, object, source)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to record the source of %s: %v", outputURI, err)
+	}
+}
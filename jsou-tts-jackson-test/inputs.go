@@ -0,0 +1,142 @@
+package pdftospeech
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// inputRule selects objects to process: those below a folder, or, with a
+// pattern, those of the folder whose whole name matches it. folder is what the
+// paths kept in processed/ and failed/ and {dir} in output names are relative
+// to, and where folder settings are looked up from.
+type inputRule struct {
+	folder  string
+	pattern *regexp.Regexp
+}
+
+// parseInputRules parses INPUT_PREFIX, a comma-separated list of folders, and
+// INPUT_PATTERNS, a comma-separated list of glob patterns, or regular
+// expressions prefixed with "re:", matched against the whole object name. In a
+// glob, "*" and "?" match within a path segment and "**" any number of them,
+// e.g. "uploads/**/audio-requests/*.pdf". Without either, the input folder is
+// pdf-input/; a folder of "/" is the whole bucket.
+func parseInputRules(prefixes, patterns string) ([]inputRule, error) {
+	var rules []inputRule
+	for _, prefix := range strings.Split(prefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix == "" {
+			continue
+		}
+		folder := strings.Trim(prefix, "/")
+		if folder != "" {
+			folder += "/"
+		}
+		rules = append(rules, inputRule{folder: folder})
+	}
+	for _, pattern := range strings.Split(patterns, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		expr, isRegexp := strings.CutPrefix(pattern, "re:")
+		if !isRegexp {
+			expr = globExpression(pattern)
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid input pattern %q: %w", pattern, err)
+		}
+		// The folder is the literal part of the pattern up to its last "/". It's
+		// taken from the unanchored expression, whose literal prefix regexp
+		// finds more often.
+		literal, _ := regexp.MustCompile("(?:" + expr + ")").LiteralPrefix()
+		folder := ""
+		if i := strings.LastIndex(literal, "/"); i >= 0 {
+			folder = literal[:i+1]
+		}
+		rules = append(rules, inputRule{folder: folder, pattern: re})
+	}
+	if len(rules) == 0 {
+		rules = []inputRule{{folder: defaultInputPrefix}}
+	}
+	for _, rule := range rules {
+		if strings.HasPrefix(rule.folder, processedPrefix) {
+			return nil, fmt.Errorf("input folder %q is in %s, where finished inputs are moved", rule.folder, processedPrefix)
+		}
+	}
+	return rules, nil
+}
+
+// globExpression translates a glob pattern into a regular expression.
+func globExpression(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// inputFolder returns the folder of the first input rule that selects the
+// object, and false if none does. Inputs moved to processed/ are never
+// selected, even when the whole bucket is an input folder.
+func (c *Config) inputFolder(objectName string) (string, bool) {
+	if strings.HasPrefix(objectName, processedPrefix) {
+		return "", false
+	}
+	for _, rule := range c.InputRules {
+		if !strings.HasPrefix(objectName, rule.folder) {
+			continue
+		}
+		if rule.pattern == nil || rule.pattern.MatchString(objectName) {
+			return rule.folder, true
+		}
+	}
+	return "", false
+}
+
+// isInput reports whether the object is selected by an input rule.
+func (c *Config) isInput(objectName string) bool {
+	_, ok := c.inputFolder(objectName)
+	return ok
+}
+
+// inputFolders returns the folders of the input rules, leaving out those
+// within another, so listing them all lists every input once.
+func (c *Config) inputFolders() []string {
+	var folders []string
+	for _, rule := range c.InputRules {
+		folders = append(folders, rule.folder)
+	}
+	slices.Sort(folders)
+	folders = slices.Compact(folders)
+	var outer []string
+	for _, folder := range folders {
+		if len(outer) == 0 || !strings.HasPrefix(folder, outer[len(outer)-1]) {
+			outer = append(outer, folder)
+		}
+	}
+	return outer
+}
+
+// inputName returns the name a finished input had before it was moved to
+// processed/ as processedName: the one recorded in its metadata, or else its
+// path below processed/ in the first input folder.
+func (c *Config) inputName(processedName string, metadata map[string]string) string {
+	if name := metadata[inputKey]; name != "" {
+		return name
+	}
+	return c.InputRules[0].folder + strings.TrimPrefix(processedName, processedPrefix)
+}
@@ -0,0 +1,230 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// Metadata keys linking objects to the files of a folder intake.
+const (
+	// intakeSourceKey names the intake an input was copied from, e.g. "drive";
+	// outputs carry it over from their input, like intakeFileKey.
+	intakeSourceKey = "tts-intake-source"
+	// intakeFileKey records the file an input was copied from.
+	intakeFileKey = "tts-intake-file"
+	// intakeVersionKey records the version of the file an input was copied
+	// from, so an unchanged file isn't copied again.
+	intakeVersionKey = "tts-intake-version"
+	// intakeOutputKey records the file an output was uploaded as.
+	intakeOutputKey = "tts-intake-output"
+)
+
+// intakePrefix holds the state of the folder intakes in BASE_GCS_BUCKET: where
+// each has got to in its changes feed, e.g. "tts-intake/drive.json", and files
+// being copied, under incoming/.
+const intakePrefix = "tts-intake/"
+
+// intakeFile is a PDF added to, or changed in, a watched folder.
+type intakeFile struct {
+	ID   string
+	Name string
+	// Version changes with every new version of the file.
+	Version string
+	Size    int64
+}
+
+// intakeSource is a folder in a file-sharing service whose PDFs are converted,
+// with their audio uploaded back to it.
+type intakeSource interface {
+	// name identifies the source in object names and metadata, e.g. "drive".
+	name() string
+	// folder identifies the watched folder; the feed starts over when it
+	// changes.
+	folder() string
+	// start returns a cursor of the changes feed as it is now.
+	start(ctx context.Context) (string, error)
+	// changes returns the PDFs added to or changed in the folder since cursor,
+	// and the cursor to pass next time.
+	changes(ctx context.Context, cursor string) ([]intakeFile, string, error)
+	// download opens the content of a file.
+	download(ctx context.Context, id string) (io.ReadCloser, error)
+	// upload creates a file named name in the output folder with the content
+	// read from r, and returns its ID.
+	upload(ctx context.Context, name, contentType string, r io.Reader) (string, error)
+}
+
+// intakeState is where the sync of a folder has got to in its changes feed.
+type intakeState struct {
+	Folder    string    `json:"folder"`
+	Cursor    string    `json:"cursor"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// intakeSources returns the folder intakes that are set up.
+func (p *Pipeline) intakeSources(cfg *Config) []intakeSource {
+	var sources []intakeSource
+	if p.driveClient != nil {
+		sources = append(sources, driveSource{cfg: cfg, client: p.driveClient})
+	}
+	if p.dropboxClient != nil {
+		sources = append(sources, dropboxSource{cfg: cfg, client: p.dropboxClient})
+	}
+	return sources
+}
+
+// syncIntake copies the PDFs added to, or changed in, the folder of src since
+// the last run into the input folder, under a folder named after the source,
+// where they're processed like uploads. The first run, and the first after the
+// folder changes, only records where the changes feed is, so files already in
+// the folder are left alone. Files that can't be copied are logged and left
+// for the next version; a failure to read or save the feed's state is
+// returned, so the run is retried.
+func (p *Pipeline) syncIntake(ctx context.Context, cfg *Config, src intakeSource) error {
+	stateName := intakePrefix + src.name() + ".json"
+	data, generation, err := p.store.ReadObjectGeneration(ctx, cfg.BaseBucket, stateName)
+	if err != nil {
+		return fmt.Errorf("failed to read the %s sync state: %w", src.name(), err)
+	}
+	var state intakeState
+	if data != nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("invalid %s sync state %s: %w", src.name(), stateName, err)
+		}
+	}
+
+	var next string
+	if state.Cursor == "" || state.Folder != src.folder() {
+		if next, err = src.start(ctx); err != nil {
+			return err
+		}
+		log.Printf("Watching %s folder %s from now on. Files already in it aren't converted.", src.name(), src.folder())
+	} else {
+		files, cursor, err := src.changes(ctx, state.Cursor)
+		if err != nil {
+			return err
+		}
+		next = cursor
+		copied := 0
+		for _, file := range files {
+			ok, err := p.copyIntakeFile(ctx, cfg, src, file)
+			if err != nil {
+				log.Printf("Error: Failed to copy %s (%s file %s): %v", file.Name, src.name(), file.ID, err)
+				continue
+			}
+			if ok {
+				copied++
+			}
+		}
+		log.Printf("Copied %d new or changed PDF(s) from %s folder %s.", copied, src.name(), src.folder())
+	}
+
+	state = intakeState{Folder: src.folder(), Cursor: next, UpdatedAt: time.Now().UTC()}
+	data, err = json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	written, err := p.store.UpdateObjectIfGeneration(ctx, cfg.BaseBucket, stateName, data, "application/json", generation)
+	if err != nil {
+		return fmt.Errorf("failed to save the %s sync state: %w", src.name(), err)
+	}
+	if written == 0 {
+		log.Printf("Warning: Another run synced %s folder %s at the same time. Keeping its state.", src.name(), src.folder())
+	}
+	return nil
+}
+
+// intakeInputName returns the input a file is copied to: its name, with ".pdf"
+// if it lacks it, under a folder named after the source in the first input
+// folder, e.g. "pdf-input/drive/book.pdf".
+func intakeInputName(cfg *Config, src intakeSource, file intakeFile) string {
+	name := strings.ReplaceAll(file.Name, "/", "_")
+	if !strings.EqualFold(path.Ext(name), ".pdf") {
+		name += ".pdf"
+	}
+	return cfg.InputRules[0].folder + src.name() + "/" + name
+}
+
+// copyIntakeFile copies a PDF from the folder of src to its input name, unless
+// that version of it was copied already, and reports whether it did. The file
+// is downloaded under tts-intake/incoming/ first and then moved into place with
+// its intake metadata, so the upload trigger sees the metadata.
+func (p *Pipeline) copyIntakeFile(ctx context.Context, cfg *Config, src intakeSource, file intakeFile) (bool, error) {
+	if cfg.MaxInputBytes > 0 && file.Size > cfg.MaxInputBytes {
+		return false, fmt.Errorf("it is %d bytes, over the MAX_INPUT_BYTES limit of %d", file.Size, cfg.MaxInputBytes)
+	}
+	inputName := intakeInputName(cfg, src, file)
+	folder, ok := cfg.inputFolder(inputName)
+	if !ok {
+		return false, fmt.Errorf("%s isn't selected by INPUT_PREFIX or INPUT_PATTERNS", inputName)
+	}
+	for _, name := range []string{inputName, processedObjectName(folder, inputName)} {
+		metadata, exists, err := p.store.ObjectMetadata(ctx, cfg.BaseBucket, name)
+		if err != nil {
+			return false, err
+		}
+		if exists && metadata[intakeSourceKey] == src.name() && metadata[intakeFileKey] == file.ID && metadata[intakeVersionKey] == file.Version {
+			return false, nil
+		}
+	}
+
+	body, err := src.download(ctx, file.ID)
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+	// The staged copy has no .pdf extension or PDF content type, so it isn't
+	// taken for an input even when the whole bucket is an input folder. IDs may
+	// hold characters like ":", so the name is escaped.
+	staged := intakePrefix + "incoming/" + src.name() + "-" + strings.NewReplacer("/", "_", ":", "_").Replace(file.ID)
+	if err := p.store.UploadReader(ctx, cfg.BaseBucket, staged, body, "application/octet-stream"); err != nil {
+		return false, err
+	}
+	metadata := map[string]string{intakeSourceKey: src.name(), intakeFileKey: file.ID, intakeVersionKey: file.Version}
+	if err := p.store.MoveObject(ctx, cfg.BaseBucket, staged, inputName, metadata); err != nil {
+		p.store.DeleteObject(ctx, cfg.BaseBucket, staged)
+		return false, err
+	}
+	log.Printf("Copied %s from %s to gs://%s/%s.", file.Name, src.name(), cfg.BaseBucket, inputName)
+	return true, nil
+}
+
+// deliverToIntake uploads the audio of an input copied from a folder intake
+// back to that intake's output folder, and records the uploaded file in the
+// audio's metadata, so a retry doesn't upload it twice. Other outputs, and
+// outputs of intakes that are no longer set up, are left alone.
+func (p *Pipeline) deliverToIntake(ctx context.Context, c *Config, bucket, object string, metadata map[string]string) error {
+	if metadata[intakeFileKey] == "" || metadata[intakeOutputKey] != "" {
+		return nil
+	}
+	var src intakeSource
+	for _, s := range p.intakeSources(c) {
+		if s.name() == metadata[intakeSourceKey] {
+			src = s
+		}
+	}
+	if src == nil {
+		return nil
+	}
+	rc, _, err := p.store.OpenObject(ctx, bucket, object)
+	if err != nil {
+		return fmt.Errorf("failed to read %s for %s: %w", object, src.name(), err)
+	}
+	defer rc.Close()
+	contentType := mime.TypeByExtension(path.Ext(object))
+	id, err := src.upload(ctx, path.Base(object), contentType, rc)
+	if err != nil {
+		return err
+	}
+	if err := p.store.UpdateObjectMetadata(ctx, bucket, object, map[string]string{intakeOutputKey: id}); err != nil {
+		log.Printf("Warning: Uploaded gs://%s/%s to %s but failed to record it: %v", bucket, object, src.name(), err)
+	}
+	log.Printf("Uploaded gs://%s/%s to %s.", bucket, object, src.name())
+	return nil
+}
@@ -0,0 +1,95 @@
+package pdftospeech
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// tmpPrefix holds the intermediate objects of a job, such as long audio parts
+// and synthesized chunks being composed, under the name of the output they're
+// for, e.g. "tmp/mp3-output/book.wav/part-001.wav". They live in the output
+// bucket, since they're joined into the output server-side, and are deleted
+// once the output and its manifest are written.
+const tmpPrefix = "tmp/"
+
+// defaultTmpMaxAge is how old intermediate objects must be before the sweeper
+// deletes them when TMP_MAX_AGE isn't set. It should exceed the longest a job
+// runs, as the sweeper can't tell a slow job from an abandoned one unless it's
+// a pending long audio operation.
+const defaultTmpMaxAge = 48 * time.Hour
+
+// tmpObjectPrefix returns where the intermediate objects of the output object
+// are kept, e.g. "tmp/mp3-output/book.mp3/".
+func tmpObjectPrefix(outputObject string) string {
+	return tmpPrefix + outputObject + "/"
+}
+
+// cleanupIntermediates deletes what's left of the intermediate objects of the
+// output at outputURI, such as the parts of an earlier, failed attempt. Failures
+// are only logged; the sweeper gets what's left.
+func (p *Pipeline) cleanupIntermediates(ctx context.Context, outputURI string) {
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		log.Printf("Warning: Failed to clean up intermediate objects of %s: %v", outputURI, err)
+		return
+	}
+	objects, err := p.store.ListObjectsWithPrefix(ctx, bucket, tmpObjectPrefix(object))
+	if err != nil {
+		log.Printf("Warning: Failed to clean up intermediate objects of %s: %v", outputURI, err)
+		return
+	}
+	for _, obj := range objects {
+		if err := p.store.DeleteObject(ctx, bucket, obj.Name); err != nil {
+			log.Printf("Warning: Failed to delete intermediate object %s: %v", obj.Name, err)
+		}
+	}
+	if len(objects) > 0 {
+		log.Printf("Removed %d leftover intermediate object(s) of %s.", len(objects), outputURI)
+	}
+}
+
+// sweepIntermediates deletes the intermediate objects in outputBucket that are
+// older than maxAge, left behind by jobs that crashed or timed out. Objects of
+// outputs with a pending long audio operation in inputBucket are kept, since
+// their parts are still to be joined. It returns an error only if the objects
+// can't be listed.
+func (p *Pipeline) sweepIntermediates(ctx context.Context, inputBucket, outputBucket string, maxAge time.Duration) error {
+	objects, err := p.store.ListObjectsWithPrefix(ctx, outputBucket, tmpPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list intermediate objects: %w", err)
+	}
+	cutoff := time.Now().Add(-maxAge)
+	pending := map[string]bool{} // Pending state by output object, looked up once each.
+	var removed int
+	for _, obj := range objects {
+		if obj.Created.After(cutoff) {
+			continue
+		}
+		output := path.Dir(strings.TrimPrefix(obj.Name, tmpPrefix))
+		isPending, checked := pending[output]
+		if !checked {
+			_, exists, err := p.store.ObjectMetadata(ctx, inputBucket, pendingObjectName(output))
+			if err != nil {
+				log.Printf("Warning: Failed to check for a pending operation of %s: %v. Keeping its intermediate objects.", output, err)
+				exists = true
+			}
+			pending[output], isPending = exists, exists
+		}
+		if isPending {
+			continue
+		}
+		if err := p.store.DeleteObject(ctx, outputBucket, obj.Name); err != nil {
+			log.Printf("Warning: Failed to delete intermediate object %s: %v", obj.Name, err)
+			continue
+		}
+		removed++
+	}
+	log.Printf("Swept %d of %d intermediate object(s) in %s older than %v.", removed, len(objects), outputBucket, maxAge)
+	return nil
+}
@@ -0,0 +1,259 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ConcatMP3 joins MP3 streams. MP3 is a sequence of self-contained frames, so
+// streams can be appended as-is; only ID3v2 tags after the first part are dropped
+// so players don't stop at them.
+func ConcatMP3(parts [][]byte) []byte {
+	var out bytes.Buffer
+	for i, part := range parts {
+		if i > 0 {
+			part = StripID3v2(part)
+		}
+		out.Write(part)
+	}
+	return out.Bytes()
+}
+
+// StripID3v2 removes a leading ID3v2 tag from an MP3 stream, so it can follow
+// another stream.
+func StripID3v2(b []byte) []byte {
+	end := id3v2Size(b)
+	if end > len(b) {
+		return b
+	}
+	return b[end:]
+}
+
+// SkipID3v2 discards a leading ID3v2 tag from a streamed MP3, so streams can be
+// joined like ConcatMP3 does without reading them into memory.
+func SkipID3v2(r *bufio.Reader) error {
+	head, err := r.Peek(10)
+	if err != nil {
+		return nil // Too short to hold a tag.
+	}
+	_, err = r.Discard(id3v2Size(head))
+	return err
+}
+
+// id3v2Size returns the length of the ID3v2 tag at the start of b, or 0 if
+// there is none. Only the 10-byte tag header needs to be present.
+func id3v2Size(b []byte) int {
+	if len(b) < 10 || string(b[:3]) != "ID3" {
+		return 0
+	}
+	// The tag size is a 28-bit "syncsafe" integer: 7 bits per byte.
+	size := int(b[6])<<21 | int(b[7])<<14 | int(b[8])<<7 | int(b[9])
+	end := 10 + size
+	if b[5]&0x10 != 0 {
+		end += 10 // Footer present.
+	}
+	return end
+}
+
+// ConcatOgg joins Ogg streams by chaining them, which the Ogg specification
+// allows: each part keeps its own logical bitstream and they play back to back.
+func ConcatOgg(parts [][]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// ConcatWAV joins WAV (RIFF/PCM) files into a single WAV file. All parts must
+// share the same format (channels, sample rate, bit depth).
+func ConcatWAV(parts [][]byte) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("no WAV parts to concatenate")
+	}
+
+	var format []byte
+	var data bytes.Buffer
+	for i, part := range parts {
+		fmtChunk, dataChunk, err := ParseWAV(part)
+		if err != nil {
+			return nil, fmt.Errorf("WAV part %d: %w", i, err)
+		}
+		if format == nil {
+			format = fmtChunk
+		} else if !bytes.Equal(format, fmtChunk) {
+			return nil, fmt.Errorf("WAV part %d has a different format than part 0", i)
+		}
+		data.Write(dataChunk)
+	}
+
+	return append(wavHeader(format, int64(data.Len())), data.Bytes()...), nil
+}
+
+// wavHeader returns the header of a WAV file with the given "fmt " chunk payload,
+// up to and including the header of a data chunk of dataLen bytes.
+func wavHeader(format []byte, dataLen int64) []byte {
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(4+8+int64(len(format))+8+dataLen))
+	out.WriteString("WAVE")
+	out.WriteString("fmt ")
+	binary.Write(&out, binary.LittleEndian, uint32(len(format)))
+	out.Write(format)
+	out.WriteString("data")
+	binary.Write(&out, binary.LittleEndian, uint32(dataLen))
+	return out.Bytes()
+}
+
+// WAVFileHeader returns the header of a WAV file whose "fmt " chunk payload is
+// fmtChunk, up to and including the header of a data chunk of dataLen bytes,
+// for assembling a file from the PCM data of several parts.
+func WAVFileHeader(fmtChunk []byte, dataLen int64) ([]byte, error) {
+	if dataLen > maxWAVDataBytes {
+		return nil, fmt.Errorf("%d bytes of audio exceed the 4 GiB WAV size limit", dataLen)
+	}
+	return wavHeader(fmtChunk, dataLen), nil
+}
+
+// maxWAVDataBytes is the largest data chunk a WAV file's 32-bit sizes can describe.
+const maxWAVDataBytes = math.MaxUint32 - 4 - 8 - 16 - 8
+
+// ReadWAVHeader reads a streamed WAV file of size bytes up to the start of its
+// PCM data and returns the "fmt " chunk payload and the number of data bytes
+// that follow. Like ConcatWAV, it trusts the file size over a placeholder data size.
+func ReadWAVHeader(r io.Reader, size int64) (fmtChunk []byte, dataLen int64, err error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil || string(riff[:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, 0, errors.New("not a RIFF/WAVE file")
+	}
+	pos := int64(len(riff))
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, 0, fmt.Errorf("missing data chunk: %w", err)
+		}
+		pos += 8
+		id := string(chunk[:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if id == "data" {
+			if fmtChunk == nil {
+				return nil, 0, errors.New("missing fmt chunk")
+			}
+			return fmtChunk, min(chunkSize, size-pos), nil
+		}
+		body := make([]byte, chunkSize+chunkSize%2) // Chunks are padded to an even size.
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, 0, fmt.Errorf("truncated %q chunk: %w", id, err)
+		}
+		pos += int64(len(body))
+		if id == "fmt " {
+			fmtChunk = body[:chunkSize]
+		}
+	}
+}
+
+// ConcatWAVStreams writes the WAV files opened by open, in order, to w as a
+// single WAV file without holding them in memory. Each part is opened twice:
+// once to size the output header and once to copy its data. All parts must
+// share the same format.
+func ConcatWAVStreams(w io.Writer, parts int, open func(i int) (io.ReadCloser, int64, error)) error {
+	if parts == 0 {
+		return errors.New("no WAV parts to concatenate")
+	}
+
+	var format []byte
+	var total int64
+	for i := range parts {
+		rc, size, err := open(i)
+		if err != nil {
+			return err
+		}
+		fmtChunk, dataLen, err := ReadWAVHeader(rc, size)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("WAV part %d: %w", i, err)
+		}
+		if format == nil {
+			format = fmtChunk
+		} else if !bytes.Equal(format, fmtChunk) {
+			return fmt.Errorf("WAV part %d has a different format than part 0", i)
+		}
+		total += dataLen
+	}
+	if total > maxWAVDataBytes {
+		return fmt.Errorf("%d bytes of audio exceed the 4 GiB WAV size limit", total)
+	}
+
+	if _, err := w.Write(wavHeader(format, total)); err != nil {
+		return err
+	}
+	for i := range parts {
+		if err := copyWAVData(w, i, open); err != nil {
+			return fmt.Errorf("WAV part %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// copyWAVData copies the PCM data of one part to w.
+func copyWAVData(w io.Writer, i int, open func(i int) (io.ReadCloser, int64, error)) error {
+	rc, size, err := open(i)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, dataLen, err := ReadWAVHeader(rc, size)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, rc, dataLen)
+	return err
+}
+
+// WAVHeader returns the header of a WAV file holding dataLen bytes of 16-bit
+// mono PCM at sampleRate, for providers that return raw PCM. The PCM data follows it.
+func WAVHeader(dataLen int, sampleRate int) []byte {
+	const channels, bitsPerSample = 1, 16
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(4+8+16+8+dataLen))
+	out.WriteString("WAVE")
+	out.WriteString("fmt ")
+	binary.Write(&out, binary.LittleEndian, uint32(16))
+	binary.Write(&out, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&out, binary.LittleEndian, uint16(channels))
+	binary.Write(&out, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&out, binary.LittleEndian, uint32(sampleRate*channels*bitsPerSample/8))
+	binary.Write(&out, binary.LittleEndian, uint16(channels*bitsPerSample/8))
+	binary.Write(&out, binary.LittleEndian, uint16(bitsPerSample))
+	out.WriteString("data")
+	binary.Write(&out, binary.LittleEndian, uint32(dataLen))
+	return out.Bytes()
+}
+
+// ParseWAV returns the payloads of the "fmt " and "data" chunks of a WAV file.
+func ParseWAV(b []byte) (fmtChunk, dataChunk []byte, err error) {
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, nil, errors.New("not a RIFF/WAVE file")
+	}
+	for pos := 12; pos+8 <= len(b); {
+		id := string(b[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(b[pos+4 : pos+8]))
+		body := b[pos+8:]
+		if size > len(body) {
+			size = len(body) // Streamed WAVs may carry a placeholder size; take what's there.
+		}
+		switch id {
+		case "fmt ":
+			fmtChunk = body[:size]
+		case "data":
+			dataChunk = body[:size]
+		}
+		pos += 8 + size + size%2 // Chunks are padded to an even size.
+	}
+	if fmtChunk == nil || dataChunk == nil {
+		return nil, nil, errors.New("missing fmt or data chunk")
+	}
+	return fmtChunk, dataChunk, nil
+}
@@ -0,0 +1,105 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// id3Tag returns an ID3v2 tag with size bytes of frames.
+func id3Tag(size int) []byte {
+	tag := []byte{'I', 'D', '3', 4, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(tag, make([]byte, size)...)
+}
+
+func TestConcatMP3(t *testing.T) {
+	first := append(id3Tag(200), "frames1"...)
+	second := append(id3Tag(300), "frames2"...)
+	want := append(append([]byte{}, first...), "frames2"...)
+	if got := ConcatMP3([][]byte{first, second}); !bytes.Equal(got, want) {
+		t.Errorf("ConcatMP3() = %q, want the first part whole and the second without its tag", got)
+	}
+}
+
+func TestStripID3v2(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{"tag", append(id3Tag(20), "frames"...), []byte("frames")},
+		{"no tag", []byte("frames"), []byte("frames")},
+		{"truncated tag kept", id3Tag(20)[:15], id3Tag(20)[:15]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripID3v2(tt.in); !bytes.Equal(got, tt.want) {
+				t.Errorf("StripID3v2() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcatWAV(t *testing.T) {
+	first := append(WAVHeader(4, 16000), 1, 2, 3, 4)
+	second := append(WAVHeader(2, 16000), 5, 6)
+	got, err := ConcatWAV([][]byte{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(WAVHeader(6, 16000), 1, 2, 3, 4, 5, 6); !bytes.Equal(got, want) {
+		t.Errorf("ConcatWAV() = %x, want %x", got, want)
+	}
+}
+
+func TestConcatWAVErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts [][]byte
+	}{
+		{"no parts", nil},
+		{"not a WAV file", [][]byte{[]byte("not audio")}},
+		{"different sample rates", [][]byte{append(WAVHeader(2, 16000), 1, 2), append(WAVHeader(2, 24000), 3, 4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ConcatWAV(tt.parts); err == nil {
+				t.Error("ConcatWAV() succeeded")
+			}
+		})
+	}
+}
+
+func TestParseWAVPlaceholderSize(t *testing.T) {
+	// Streamed WAVs may claim a data chunk larger than what follows.
+	b := append(WAVHeader(1000, 16000), 1, 2, 3, 4)
+	_, data, err := ParseWAV(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{1, 2, 3, 4}) {
+		t.Errorf("ParseWAV() data = %v, want what follows the header", data)
+	}
+}
+
+func TestConcatWAVStreams(t *testing.T) {
+	parts := [][]byte{
+		append(WAVHeader(4, 16000), 1, 2, 3, 4),
+		append(WAVHeader(0xFFFFFFF, 16000), 5, 6), // A streamed part with a placeholder size.
+	}
+	opened := 0
+	open := func(i int) (io.ReadCloser, int64, error) {
+		opened++
+		return io.NopCloser(bytes.NewReader(parts[i])), int64(len(parts[i])), nil
+	}
+	var got bytes.Buffer
+	if err := ConcatWAVStreams(&got, len(parts), open); err != nil {
+		t.Fatal(err)
+	}
+	if want := append(WAVHeader(6, 16000), 1, 2, 3, 4, 5, 6); !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("ConcatWAVStreams() wrote %x, want %x", got.Bytes(), want)
+	}
+	if opened != 2*len(parts) {
+		t.Errorf("parts opened %d times, want %d", opened, 2*len(parts))
+	}
+}
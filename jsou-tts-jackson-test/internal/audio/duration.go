@@ -0,0 +1,128 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WAVDuration returns the playing time of a PCM WAV file.
+func WAVDuration(b []byte) (time.Duration, error) {
+	fmtChunk, dataChunk, err := ParseWAV(b)
+	if err != nil {
+		return 0, err
+	}
+	if len(fmtChunk) < 16 {
+		return 0, errors.New("fmt chunk too short")
+	}
+	byteRate := binary.LittleEndian.Uint32(fmtChunk[8:12])
+	if byteRate == 0 {
+		return 0, errors.New("WAV byte rate is zero")
+	}
+	return time.Duration(float64(len(dataChunk)) / float64(byteRate) * float64(time.Second)), nil
+}
+
+// MPEG audio Layer III tables, indexed by the header's bitrate and sample rate fields.
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3SampleRate = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+// MP3Duration returns the playing time of an MP3 stream by walking its Layer III frames.
+func MP3Duration(b []byte) (time.Duration, error) {
+	b = StripID3v2(b)
+	var seconds float64
+	frames := 0
+	for pos := 0; pos+4 <= len(b); {
+		h := b[pos : pos+4]
+		if h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+			pos++ // Not a frame sync; skip junk between frames.
+			continue
+		}
+		version := int(h[1]>>3) & 3
+		layer := int(h[1]>>1) & 3
+		bitrateIndex := int(h[2] >> 4)
+		rateIndex := int(h[2]>>2) & 3
+		rates, ok := mp3SampleRate[version]
+		if !ok || layer != 1 || rateIndex == 3 || bitrateIndex == 0 || bitrateIndex == 15 {
+			pos++
+			continue
+		}
+		padding := int(h[2]>>1) & 1
+		sampleRate := rates[rateIndex]
+
+		var frameLen, samples int
+		if version == 3 {
+			frameLen = 144*mp3BitratesV1[bitrateIndex]*1000/sampleRate + padding
+			samples = 1152
+		} else {
+			frameLen = 72*mp3BitratesV2[bitrateIndex]*1000/sampleRate + padding
+			samples = 576
+		}
+		seconds += float64(samples) / float64(sampleRate)
+		frames++
+		pos += frameLen
+	}
+	if frames == 0 {
+		return 0, errors.New("no MP3 frames found")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// OggOpusDuration returns the playing time of an Ogg Opus file, summing all
+// chained logical streams. Opus granule positions always count 48kHz samples.
+func OggOpusDuration(b []byte) (time.Duration, error) {
+	granules := make(map[uint32]int64)
+	preSkip := make(map[uint32]int64)
+	var order []uint32
+
+	for pos := 0; pos+27 <= len(b); {
+		if string(b[pos:pos+4]) != "OggS" {
+			return 0, fmt.Errorf("invalid Ogg page at offset %d", pos)
+		}
+		granule := int64(binary.LittleEndian.Uint64(b[pos+6 : pos+14]))
+		serial := binary.LittleEndian.Uint32(b[pos+14 : pos+18])
+		segments := int(b[pos+26])
+		if pos+27+segments > len(b) {
+			return 0, errors.New("truncated Ogg page header")
+		}
+		bodyLen := 0
+		for _, l := range b[pos+27 : pos+27+segments] {
+			bodyLen += int(l)
+		}
+		body := b[pos+27+segments:]
+		if bodyLen > len(body) {
+			return 0, errors.New("truncated Ogg page")
+		}
+		body = body[:bodyLen]
+
+		if _, seen := granules[serial]; !seen {
+			order = append(order, serial)
+			granules[serial] = 0
+			if len(body) >= 12 && string(body[:8]) == "OpusHead" {
+				preSkip[serial] = int64(binary.LittleEndian.Uint16(body[10:12]))
+			}
+		}
+		if granule > granules[serial] {
+			granules[serial] = granule
+		}
+		pos += 27 + segments + bodyLen
+	}
+	if len(order) == 0 {
+		return 0, errors.New("no Ogg pages found")
+	}
+
+	var samples int64
+	for _, serial := range order {
+		if n := granules[serial] - preSkip[serial]; n > 0 {
+			samples += n
+		}
+	}
+	return time.Duration(float64(samples) / 48000 * float64(time.Second)), nil
+}
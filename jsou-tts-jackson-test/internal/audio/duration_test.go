@@ -0,0 +1,100 @@
+package audio
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// mp3Frames returns n MPEG-1 Layer III frames at 128 kbit/s and 44.1 kHz,
+// after an ID3v2 tag.
+func mp3Frames(n int) []byte {
+	b := id3Tag(100)
+	for range n {
+		frame := make([]byte, 417) // 144 * 128000 / 44100
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+		b = append(b, frame...)
+	}
+	return b
+}
+
+// oggPage returns an Ogg page of the logical stream serial.
+func oggPage(serial uint32, granule int64, body []byte) []byte {
+	page := make([]byte, 27, 28+len(body))
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint64(page[6:14], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:18], serial)
+	page[26] = 1
+	page = append(page, byte(len(body)))
+	return append(page, body...)
+}
+
+// opusStream returns the pages of an Ogg Opus stream with the given pre-skip
+// whose last granule position is granule.
+func opusStream(serial uint32, preSkip uint16, granule int64) []byte {
+	head := []byte("OpusHead\x01\x01\x00\x00")
+	binary.LittleEndian.PutUint16(head[10:12], preSkip)
+	b := oggPage(serial, 0, head)
+	b = append(b, oggPage(serial, 0, []byte("OpusTags"))...)
+	b = append(b, oggPage(serial, granule/2, []byte("audio"))...)
+	return append(b, oggPage(serial, granule, []byte("audio"))...)
+}
+
+func TestWAVDuration(t *testing.T) {
+	got, err := WAVDuration(append(WAVHeader(32000, 16000), make([]byte, 32000)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Second; got != want {
+		t.Errorf("WAVDuration() = %v, want %v", got, want)
+	}
+}
+
+func TestMP3Duration(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "frames", in: mp3Frames(100), want: 100 * 1152 * time.Second / 44100},
+		{name: "junk between frames skipped", in: append(append(mp3Frames(50), "junk"...), mp3Frames(50)[110:]...), want: 100 * 1152 * time.Second / 44100},
+		{name: "no frames", in: []byte("not audio"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MP3Duration(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MP3Duration() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if d := got - tt.want; d < -time.Millisecond || d > time.Millisecond {
+				t.Errorf("MP3Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOggOpusDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "one stream", in: opusStream(1, 312, 48000+312), want: time.Second},
+		{name: "chained streams", in: append(opusStream(1, 312, 48000+312), opusStream(2, 312, 24000+312)...), want: 1500 * time.Millisecond},
+		{name: "not Ogg", in: []byte("not an Ogg file at all, just text"), wantErr: true},
+		{name: "truncated page", in: opusStream(1, 312, 48000+312)[:35], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OggOpusDuration(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OggOpusDuration() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("OggOpusDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package chunker
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Split splits text into chunks of at most maxBytes bytes. Chunks end at
+// sentence boundaries where possible, fall back to word boundaries for
+// sentences longer than maxBytes, and only cut inside a word (at a rune
+// boundary) when a single word is longer than maxBytes.
+// Chunks are trimmed of surrounding whitespace; empty chunks are dropped.
+func Split(text string, maxBytes int) []string {
+	return SplitFunc(text, maxBytes, func(s string) int { return len(s) })
+}
+
+// SplitFunc is like Split but measures pieces of text with size instead of
+// their byte length, e.g. to account for escaping that happens later.
+// size must be additive: size(a+b) == size(a)+size(b).
+func SplitFunc(text string, maxSize int, size func(string) int) []string {
+	var chunks []string
+	var cur strings.Builder
+	curSize := 0
+
+	flush := func() {
+		if c := strings.TrimSpace(cur.String()); c != "" {
+			chunks = append(chunks, c)
+		}
+		cur.Reset()
+		curSize = 0
+	}
+	add := func(piece string, n int) {
+		if curSize+n > maxSize {
+			flush()
+		}
+		cur.WriteString(piece)
+		curSize += n
+	}
+
+	for _, sentence := range Sentences(text) {
+		if n := size(sentence); n <= maxSize {
+			add(sentence, n)
+			continue
+		}
+		for _, word := range words(sentence) {
+			if n := size(word); n <= maxSize {
+				add(word, n)
+				continue
+			}
+			for _, part := range splitRunes(word, maxSize, size) {
+				add(part, size(part))
+			}
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// sentenceTerminators end a sentence when followed by whitespace.
+const sentenceTerminators = ".!?…"
+
+// closers may follow a terminator and still belong to the sentence, e.g. `."` or `!)`.
+const closers = `"')]}”’»`
+
+// abbreviations are common words ending in a period that don't end a sentence.
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "st": true,
+	"vs": true, "etc": true, "e.g": true, "i.e": true, "no": true, "fig": true,
+	"vol": true, "p": true, "pp": true, "cf": true, "approx": true, "inc": true,
+	"a.m": true, "p.m": true,
+}
+
+// Sentences splits text into sentences. Each sentence keeps its trailing
+// whitespace so that joining the result reproduces text exactly.
+func Sentences(text string) []string {
+	var sentences []string
+	start := 0
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if !strings.ContainsRune(sentenceTerminators, r) {
+			continue
+		}
+
+		end := i
+		for end < len(text) {
+			c, n := utf8.DecodeRuneInString(text[end:])
+			if !strings.ContainsRune(closers, c) {
+				break
+			}
+			end += n
+		}
+		ws := end
+		for ws < len(text) {
+			c, n := utf8.DecodeRuneInString(text[ws:])
+			if !unicode.IsSpace(c) {
+				break
+			}
+			ws += n
+		}
+		if ws == end && end < len(text) {
+			continue // "3.14", "e.g.x": not followed by whitespace.
+		}
+		if r == '.' && isAbbreviation(text[start:i-size]) {
+			continue
+		}
+
+		sentences = append(sentences, text[start:ws])
+		start, i = ws, ws
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
+
+// isAbbreviation reports whether the word right before a period is an initial
+// ("J.") or a known abbreviation ("Dr.").
+func isAbbreviation(before string) bool {
+	word := before[strings.LastIndexFunc(before, unicode.IsSpace)+1:]
+	word = strings.TrimLeft(word, `"'([{“‘«`)
+	if utf8.RuneCountInString(word) == 1 {
+		r, _ := utf8.DecodeRuneInString(word)
+		return unicode.IsUpper(r)
+	}
+	return abbreviations[strings.ToLower(word)]
+}
+
+// words splits text after each run of whitespace, keeping the whitespace with
+// the preceding word so joining the result reproduces text.
+func words(text string) []string {
+	var out []string
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if inSpace && !space {
+			out = append(out, text[start:i])
+			start = i
+		}
+		inSpace = space
+	}
+	if start < len(text) {
+		out = append(out, text[start:])
+	}
+	return out
+}
+
+// splitRunes cuts a single oversized word into pieces of at most maxSize,
+// never splitting a multi-byte rune. Every piece holds at least one rune.
+// Since size is additive, the piece being built is measured one rune at a time.
+func splitRunes(word string, maxSize int, size func(string) int) []string {
+	var parts []string
+	start, cur := 0, 0
+	for i := 0; i < len(word); {
+		_, n := utf8.DecodeRuneInString(word[i:])
+		r := size(word[i : i+n])
+		if i > start && cur+r > maxSize {
+			parts = append(parts, word[start:i])
+			start, cur = i, 0
+		}
+		cur += r
+		i += n
+	}
+	return append(parts, word[start:])
+}
@@ -0,0 +1,154 @@
+package chunker
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "terminators",
+			text: "One. Two! Three? Four… Five",
+			want: []string{"One. ", "Two! ", "Three? ", "Four… ", "Five"},
+		},
+		{
+			name: "closers stay with the sentence",
+			text: `He said "stop." (Then left.) Done.`,
+			want: []string{`He said "stop." `, "(Then left.) ", "Done."},
+		},
+		{
+			name: "abbreviations and initials",
+			text: "Dr. Smith met Mrs. Jones, e.g. at 3 p.m. on St. Mary's. J. R. R. Tolkien wrote. Next.",
+			want: []string{"Dr. Smith met Mrs. Jones, e.g. at 3 p.m. on St. Mary's. ", "J. R. R. Tolkien wrote. ", "Next."},
+		},
+		{
+			name: "no whitespace after the period",
+			text: "Pi is 3.14 and e.g.x stays. Next.",
+			want: []string{"Pi is 3.14 and e.g.x stays. ", "Next."},
+		},
+		{
+			name: "trailing whitespace",
+			text: "One.  \n\nTwo.\n",
+			want: []string{"One.  \n\n", "Two.\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sentences(tt.text)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Sentences(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if joined := strings.Join(got, ""); joined != tt.text {
+				t.Errorf("joined sentences = %q, want %q", joined, tt.text)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxBytes int
+		want     []string
+	}{
+		{
+			name:     "sentences fit together",
+			text:     "One. Two. Three.",
+			maxBytes: 100,
+			want:     []string{"One. Two. Three."},
+		},
+		{
+			name:     "cut at sentence boundaries",
+			text:     "First sentence. Second sentence. Third.",
+			maxBytes: 20,
+			want:     []string{"First sentence.", "Second sentence.", "Third."},
+		},
+		{
+			name:     "long sentence cut at words",
+			text:     "alpha beta gamma delta epsilon.",
+			maxBytes: 12,
+			want:     []string{"alpha beta", "gamma delta", "epsilon."},
+		},
+		{
+			name:     "oversize word cut inside",
+			text:     "abcdefghij klm",
+			maxBytes: 4,
+			want:     []string{"abcd", "efgh", "ij", "klm"},
+		},
+		{
+			name:     "empty chunks dropped",
+			text:     "   \n\t ",
+			maxBytes: 10,
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.text, tt.maxBytes); !slices.Equal(got, tt.want) {
+				t.Errorf("Split(%q, %d) = %q, want %q", tt.text, tt.maxBytes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitMultibyteRunes(t *testing.T) {
+	word := strings.Repeat("é日😀", 20)
+	for maxBytes := 4; maxBytes <= 13; maxBytes++ {
+		chunks := Split(word, maxBytes)
+		if joined := strings.Join(chunks, ""); joined != word {
+			t.Fatalf("Split(_, %d) lost text: got %q", maxBytes, joined)
+		}
+		for _, c := range chunks {
+			if !utf8.ValidString(c) {
+				t.Errorf("Split(_, %d) cut a rune: %q", maxBytes, c)
+			}
+			if len(c) > maxBytes {
+				t.Errorf("Split(_, %d) chunk %q is %d bytes", maxBytes, c, len(c))
+			}
+		}
+	}
+}
+
+func TestSplitRuneLargerThanMax(t *testing.T) {
+	// A rune bigger than the limit still makes progress, one rune per chunk.
+	got := Split("😀😀", 2)
+	if want := []string{"😀", "😀"}; !slices.Equal(got, want) {
+		t.Errorf("Split = %q, want %q", got, want)
+	}
+}
+
+func TestSplitFuncSize(t *testing.T) {
+	// Ampersands count five times, as they will once escaped to &amp;.
+	size := func(s string) int { return len(s) + 4*strings.Count(s, "&") }
+	got := SplitFunc("a&b&c&d", 10, size)
+	for _, c := range got {
+		if size(c) > 10 {
+			t.Errorf("chunk %q has size %d, over 10", c, size(c))
+		}
+	}
+	if joined := strings.Join(got, ""); joined != "a&b&c&d" {
+		t.Errorf("joined chunks = %q", joined)
+	}
+}
+
+func TestSplitLongWordIsLinear(t *testing.T) {
+	// Text extracted from PDFs can glue whole paragraphs into one "word".
+	word := strings.Repeat("ab日", 80_000/5)
+	start := time.Now()
+	chunks := Split(word, 5000)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("splitting an %d-byte word took %v", len(word), elapsed)
+	}
+	if joined := strings.Join(chunks, ""); joined != word {
+		t.Error("splitting a long word lost text")
+	}
+}
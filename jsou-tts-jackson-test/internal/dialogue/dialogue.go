@@ -0,0 +1,91 @@
+package dialogue
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Segment is a run of text spoken by one speaker. Speaker is empty for
+// narration (text outside any "SPEAKER:" line).
+type Segment struct {
+	Speaker string
+	Text    string
+}
+
+// speakerLine matches "HAMLET: To be..." or "Dr Smith: Thank you." — a name of up to
+// four capitalized words followed by a colon and the spoken text.
+var speakerLine = regexp.MustCompile(`^\s*(\p{Lu}[\p{L}.'-]*(?:\s+\p{Lu}[\p{L}.'-]*){0,3}):\s+(\S.*)$`)
+
+// Parse splits a script, interview transcript or play into segments. A line of
+// the form "NAME: text" starts a new speaker turn; following non-blank lines
+// continue that turn; a blank line ends it and anything after is narration
+// until the next speaker line. Consecutive segments by the same speaker are merged.
+func Parse(text string) []Segment {
+	var segments []Segment
+	var cur *Segment
+
+	emit := func(speaker, line string) {
+		if cur != nil && cur.Speaker == speaker {
+			cur.Text += "\n" + line
+			return
+		}
+		segments = append(segments, Segment{Speaker: speaker, Text: line})
+		cur = &segments[len(segments)-1]
+	}
+
+	turnOpen := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if m := speakerLine.FindStringSubmatch(line); m != nil {
+			emit(normalizeSpeaker(m[1]), m[2])
+			turnOpen = true
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			turnOpen = false
+			if cur != nil {
+				cur.Text += "\n"
+			}
+			continue
+		}
+		if turnOpen {
+			emit(cur.Speaker, line)
+		} else {
+			emit("", line)
+		}
+	}
+
+	for i := range segments {
+		segments[i].Text = strings.TrimSpace(segments[i].Text)
+	}
+	return segments
+}
+
+// Speakers returns the distinct speakers of segments in order of first
+// appearance, counting only speakers with at least minTurns turns. Requiring
+// more than one turn filters out prose lines such as "Note: ...".
+func Speakers(segments []Segment, minTurns int) []string {
+	turns := make(map[string]int)
+	var order []string
+	for _, s := range segments {
+		if s.Speaker == "" {
+			continue
+		}
+		if turns[s.Speaker] == 0 {
+			order = append(order, s.Speaker)
+		}
+		turns[s.Speaker]++
+	}
+
+	var speakers []string
+	for _, name := range order {
+		if turns[name] >= minTurns {
+			speakers = append(speakers, name)
+		}
+	}
+	return speakers
+}
+
+// normalizeSpeaker makes speaker names comparable: "Dr Smith" and "DR SMITH" are the same speaker.
+func normalizeSpeaker(name string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,81 @@
+package dialogue
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Segment
+	}{
+		{
+			name: "narration only",
+			text: "It was a dark night.\nThe rain fell.",
+			want: []Segment{{Text: "It was a dark night.\nThe rain fell."}},
+		},
+		{
+			name: "turns",
+			text: "HAMLET: To be, or not to be.\nOPHELIA: Good my lord.",
+			want: []Segment{{Speaker: "HAMLET", Text: "To be, or not to be."}, {Speaker: "OPHELIA", Text: "Good my lord."}},
+		},
+		{
+			name: "turn continued on the next line",
+			text: "HAMLET: To be,\nor not to be.",
+			want: []Segment{{Speaker: "HAMLET", Text: "To be,\nor not to be."}},
+		},
+		{
+			name: "blank line ends a turn",
+			text: "HAMLET: To be.\n\nExit Hamlet.",
+			want: []Segment{{Speaker: "HAMLET", Text: "To be."}, {Text: "Exit Hamlet."}},
+		},
+		{
+			name: "speaker names normalized and merged",
+			text: "Dr Smith: Thank you.\nDR  SMITH: Next.",
+			want: []Segment{{Speaker: "DR SMITH", Text: "Thank you.\nNext."}},
+		},
+		{
+			name: "Windows line endings",
+			text: "HAMLET: To be.\r\nOPHELIA: My lord.",
+			want: []Segment{{Speaker: "HAMLET", Text: "To be."}, {Speaker: "OPHELIA", Text: "My lord."}},
+		},
+		{
+			name: "lower case label is narration",
+			text: "note: this is prose.",
+			want: []Segment{{Text: "note: this is prose."}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpeakers(t *testing.T) {
+	segments := []Segment{
+		{Speaker: "NOTE", Text: "Read slowly."},
+		{Speaker: "HAMLET", Text: "To be."},
+		{Text: "Pause."},
+		{Speaker: "OPHELIA", Text: "My lord."},
+		{Speaker: "HAMLET", Text: "Or not."},
+		{Speaker: "OPHELIA", Text: "Indeed."},
+	}
+	tests := []struct {
+		minTurns int
+		want     []string
+	}{
+		{minTurns: 1, want: []string{"NOTE", "HAMLET", "OPHELIA"}},
+		{minTurns: 2, want: []string{"HAMLET", "OPHELIA"}},
+		{minTurns: 3, want: nil},
+	}
+	for _, tt := range tests {
+		if got := Speakers(segments, tt.minTurns); !slices.Equal(got, tt.want) {
+			t.Errorf("Speakers(%d) = %q, want %q", tt.minTurns, got, tt.want)
+		}
+	}
+}
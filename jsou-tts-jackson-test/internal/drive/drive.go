@@ -0,0 +1,121 @@
+// Package drive reads files from Google Drive through the changes feed and
+// uploads files to it, for users who keep their documents in Drive rather
+// than Cloud Storage.
+package drive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// fileFields are the fields of a file the client reads.
+const fileFields = "id, name, mimeType, parents, trashed, md5Checksum, size"
+
+// File is a file in Drive.
+type File struct {
+	ID       string
+	Name     string
+	MimeType string
+	Parents  []string
+	Trashed  bool
+	// MD5 is the checksum of the file's content, which changes with every
+	// new version of it.
+	MD5  string
+	Size int64
+}
+
+// InFolder reports whether the file is directly in the folder.
+func (f File) InFolder(folderID string) bool {
+	return slices.Contains(f.Parents, folderID)
+}
+
+// Client accesses Drive with the application default credentials, e.g. the
+// function's service account, which sees the folders shared with it.
+type Client struct {
+	service *drive.Service
+}
+
+// New creates a Client.
+func New(ctx context.Context) (*Client, error) {
+	service, err := drive.NewService(ctx, option.WithScopes(drive.DriveScope))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Drive client: %w", err)
+	}
+	return &Client{service: service}, nil
+}
+
+// StartPageToken returns the token of the changes feed as it is now, from
+// which Changes lists the changes that follow.
+func (c *Client) StartPageToken(ctx context.Context) (string, error) {
+	token, err := c.service.Changes.GetStartPageToken().SupportsAllDrives(true).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the Drive changes start token: %w", err)
+	}
+	return token.StartPageToken, nil
+}
+
+// Changes returns the files added or changed since pageToken, including those
+// in shared drives, and the token to pass next time. Removed files are left
+// out; trashed ones are returned with Trashed set.
+func (c *Client) Changes(ctx context.Context, pageToken string) ([]File, string, error) {
+	var files []File
+	for {
+		list, err := c.service.Changes.List(pageToken).
+			SupportsAllDrives(true).
+			IncludeItemsFromAllDrives(true).
+			IncludeRemoved(false).
+			Fields("nextPageToken, newStartPageToken, changes(fileId, removed, file(" + fileFields + "))").
+			Context(ctx).Do()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list Drive changes: %w", err)
+		}
+		for _, change := range list.Changes {
+			if change.Removed || change.File == nil {
+				continue
+			}
+			files = append(files, newFile(change.File))
+		}
+		if list.NewStartPageToken != "" {
+			return files, list.NewStartPageToken, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// Download opens the content of a file.
+func (c *Client) Download(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	resp, err := c.service.Files.Get(fileID).SupportsAllDrives(true).Context(ctx).Download()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download Drive file %s: %w", fileID, err)
+	}
+	return resp.Body, nil
+}
+
+// Upload creates a file named name in the folder with the content read from r,
+// and returns its ID.
+func (c *Client) Upload(ctx context.Context, folderID, name, contentType string, r io.Reader) (string, error) {
+	file := &drive.File{Name: name, MimeType: contentType, Parents: []string{folderID}}
+	created, err := c.service.Files.Create(file).Media(r).SupportsAllDrives(true).Fields("id").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s to Drive folder %s: %w", name, folderID, err)
+	}
+	return created.Id, nil
+}
+
+// newFile converts a file of the Drive API.
+func newFile(f *drive.File) File {
+	return File{
+		ID:       f.Id,
+		Name:     f.Name,
+		MimeType: f.MimeType,
+		Parents:  f.Parents,
+		Trashed:  f.Trashed,
+		MD5:      f.Md5Checksum,
+		Size:     f.Size,
+	}
+}
@@ -0,0 +1,309 @@
+// Package dropbox lists, downloads and uploads files in a Dropbox folder over
+// the Dropbox HTTP API, for users who share their documents through Dropbox.
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dropbox API endpoints.
+const (
+	apiURL     = "https://api.dropboxapi.com/2/"
+	contentURL = "https://content.dropboxapi.com/2/"
+	tokenURL   = "https://api.dropboxapi.com/oauth2/token"
+)
+
+// uploadChunkBytes is the size of the parts of an upload session. Dropbox
+// takes at most 150 MB per request, and parts must be multiples of 4 MB.
+const uploadChunkBytes = 32 << 20
+
+// responseHeaderTimeout is how long a request waits for Dropbox to start
+// responding once it has been sent.
+const responseHeaderTimeout = time.Minute
+
+// httpClient sends the API requests. A transfer as a whole is bounded by its
+// context rather than a client timeout, since audio files can be large, but a
+// server that doesn't answer fails the request after responseHeaderTimeout.
+var httpClient = &http.Client{Transport: newTransport()}
+
+// newTransport returns the default transport with responseHeaderTimeout.
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = responseHeaderTimeout
+	return t
+}
+
+// Credentials are those of a Dropbox app with offline access: its key and
+// secret, and the refresh token of the account whose folder it reads.
+type Credentials struct {
+	AppKey       string `json:"app_key"`
+	AppSecret    string `json:"app_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// File is a file in Dropbox.
+type File struct {
+	ID   string
+	Name string
+	Path string
+	// Rev identifies the file's version.
+	Rev  string
+	Size int64
+}
+
+// Client accesses Dropbox with an app's credentials, refreshing its access
+// token as it expires.
+type Client struct {
+	creds Credentials
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+// New returns a Client for the credentials.
+func New(creds Credentials) (*Client, error) {
+	if creds.AppKey == "" || creds.AppSecret == "" || creds.RefreshToken == "" {
+		return nil, errors.New("Dropbox credentials need app_key, app_secret and refresh_token")
+	}
+	return &Client{creds: creds}, nil
+}
+
+// VerifySignature reports whether signature, the X-Dropbox-Signature header of
+// a webhook notification, is the HMAC-SHA256 of its body keyed with the app
+// secret.
+func (c *Client) VerifySignature(body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(c.creds.AppSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
+
+// LatestCursor returns a cursor of the folder as it is now, from which Changes
+// lists the changes that follow.
+func (c *Client) LatestCursor(ctx context.Context, folder string) (string, error) {
+	var resp struct {
+		Cursor string `json:"cursor"`
+	}
+	if err := c.call(ctx, "files/list_folder/get_latest_cursor", map[string]any{"path": folder}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Cursor, nil
+}
+
+// Changes returns the files added or changed in the folder since cursor, and
+// the cursor to pass next time. Deleted files and folders are left out.
+func (c *Client) Changes(ctx context.Context, cursor string) ([]File, string, error) {
+	var files []File
+	for {
+		var resp struct {
+			Entries []struct {
+				Tag  string `json:".tag"`
+				ID   string `json:"id"`
+				Name string `json:"name"`
+				Path string `json:"path_display"`
+				Rev  string `json:"rev"`
+				Size int64  `json:"size"`
+			} `json:"entries"`
+			Cursor  string `json:"cursor"`
+			HasMore bool   `json:"has_more"`
+		}
+		if err := c.call(ctx, "files/list_folder/continue", map[string]any{"cursor": cursor}, &resp); err != nil {
+			return nil, "", err
+		}
+		for _, entry := range resp.Entries {
+			if entry.Tag == "file" {
+				files = append(files, File{ID: entry.ID, Name: entry.Name, Path: entry.Path, Rev: entry.Rev, Size: entry.Size})
+			}
+		}
+		cursor = resp.Cursor
+		if !resp.HasMore {
+			return files, cursor, nil
+		}
+	}
+}
+
+// Download opens the content of a file, given by its ID or path.
+func (c *Client) Download(ctx context.Context, file string) (io.ReadCloser, error) {
+	resp, err := c.content(ctx, "files/download", map[string]any{"path": file}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s from Dropbox: %w", file, err)
+	}
+	return resp.Body, nil
+}
+
+// Upload writes the content read from r to a new file at path, renamed if a
+// file is there already, and returns its ID. The content is sent in parts of
+// an upload session, so files of any size can be uploaded.
+func (c *Client) Upload(ctx context.Context, path string, r io.Reader) (string, error) {
+	buf := make([]byte, uploadChunkBytes)
+	var sessionID string
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		part := bytes.NewReader(buf[:n])
+		switch {
+		case sessionID == "":
+			var resp struct {
+				SessionID string `json:"session_id"`
+			}
+			if err := c.contentJSON(ctx, "files/upload_session/start", map[string]any{"close": last}, part, &resp); err != nil {
+				return "", fmt.Errorf("failed to upload %s to Dropbox: %w", path, err)
+			}
+			sessionID = resp.SessionID
+		case !last:
+			arg := map[string]any{"cursor": map[string]any{"session_id": sessionID, "offset": offset}}
+			if err := c.contentJSON(ctx, "files/upload_session/append_v2", arg, part, nil); err != nil {
+				return "", fmt.Errorf("failed to upload %s to Dropbox: %w", path, err)
+			}
+		}
+		offset += int64(n)
+		if !last {
+			continue
+		}
+
+		// The last part, if it didn't start the session, goes with the commit.
+		if offset == int64(n) {
+			part = bytes.NewReader(nil)
+		}
+		arg := map[string]any{
+			"cursor": map[string]any{"session_id": sessionID, "offset": offset - int64(part.Len())},
+			"commit": map[string]any{"path": path, "mode": "add", "autorename": true},
+		}
+		var resp struct {
+			ID string `json:"id"`
+		}
+		if err := c.contentJSON(ctx, "files/upload_session/finish", arg, part, &resp); err != nil {
+			return "", fmt.Errorf("failed to upload %s to Dropbox: %w", path, err)
+		}
+		return resp.ID, nil
+	}
+}
+
+// call sends an RPC request with a JSON argument and decodes the JSON result
+// into result.
+func (c *Client) call(ctx context.Context, endpoint string, arg, result any) error {
+	body, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("Dropbox %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// contentJSON sends a content request and decodes its JSON result, if result
+// isn't nil.
+func (c *Client) contentJSON(ctx context.Context, endpoint string, arg any, content io.Reader, result any) error {
+	resp, err := c.content(ctx, endpoint, arg, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// content sends a content request, whose argument goes in the Dropbox-API-Arg
+// header and whose body is the file's content, and returns the response.
+func (c *Client) content(ctx context.Context, endpoint string, arg any, content io.Reader) (*http.Response, error) {
+	header, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, contentURL+endpoint, content)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Dropbox-API-Arg", string(header))
+	if content != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return c.do(req)
+}
+
+// do sends an authorized request and returns the response, or an error with
+// the body of a failed one.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	token, err := c.token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return resp, nil
+}
+
+// token returns an access token, refreshing it a minute before it expires.
+func (c *Client) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Before(c.expiry) {
+		return c.accessToken, nil
+	}
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.creds.RefreshToken},
+		"client_id":     {c.creds.AppKey},
+		"client_secret": {c.creds.AppSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh the Dropbox access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("failed to refresh the Dropbox access token: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid Dropbox token response: %w", err)
+	}
+	c.accessToken = result.AccessToken
+	c.expiry = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return c.accessToken, nil
+}
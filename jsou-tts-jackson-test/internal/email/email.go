@@ -0,0 +1,125 @@
+// Package email sends plain-text notification emails through SendGrid or
+// Amazon SES.
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+// Providers.
+const (
+	ProviderSendGrid = "sendgrid"
+	ProviderSES      = "ses"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+}
+
+// Sender sends emails.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// sendGridURL is SendGrid's v3 mail send endpoint.
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// httpClient sends SendGrid requests.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// SendGrid sends emails with the SendGrid v3 API.
+type SendGrid struct {
+	apiKey string
+}
+
+// NewSendGrid returns a Sender using a SendGrid API key.
+func NewSendGrid(apiKey string) *SendGrid {
+	return &SendGrid{apiKey: apiKey}
+}
+
+// Send implements Sender.
+func (s *SendGrid) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(map[string]any{
+		"personalizations": []map[string]any{{"to": []map[string]string{{"email": m.To}}}},
+		"from":             map[string]string{"email": m.From},
+		"subject":          m.Subject,
+		"content":          []map[string]string{{"type": "text/plain", "value": m.Text}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email with SendGrid: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 500))
+		return fmt.Errorf("SendGrid returned %s: %s", resp.Status, detail)
+	}
+	return nil
+}
+
+// SES sends emails with Amazon SES.
+type SES struct {
+	client *sesv2.Client
+}
+
+// SESCredentials are the AWS access key SES requests are signed with, as
+// stored in Secret Manager.
+type SESCredentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// NewSES returns a Sender using SES in region with the given access key.
+func NewSES(ctx context.Context, region string, creds SESCredentials) (*SES, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(region),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return &SES{client: sesv2.NewFromConfig(awsCfg)}, nil
+}
+
+// Send implements Sender.
+func (s *SES) Send(ctx context.Context, m Message) error {
+	_, err := s.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(m.From),
+		Destination:      &types.Destination{ToAddresses: []string{m.To}},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String(m.Subject)},
+				Body:    &types.Body{Text: &types.Content{Data: aws.String(m.Text)}},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send email with SES: %w", err)
+	}
+	return nil
+}
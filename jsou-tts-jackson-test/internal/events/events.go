@@ -0,0 +1,82 @@
+// Package events publishes messages and CloudEvents to a Pub/Sub topic, so
+// downstream systems can react to finished documents without watching the
+// bucket, and the stages of the pipeline can hand work to each other.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	v2 "github.com/cloudevents/sdk-go/v2"
+)
+
+// structuredContentType marks a message holding a whole CloudEvent as JSON,
+// per the CloudEvents Pub/Sub protocol binding.
+const structuredContentType = "application/cloudevents+json"
+
+// Publisher publishes CloudEvents in structured mode to a Pub/Sub topic.
+type Publisher struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+	source string
+}
+
+// NewPublisher returns a Publisher for topic, a full topic name such as
+// "projects/my-project/topics/pdf-to-speech-events". source is the events'
+// source attribute.
+func NewPublisher(ctx context.Context, topic, source string) (*Publisher, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" || parts[1] == "" || parts[3] == "" {
+		return nil, fmt.Errorf("invalid Pub/Sub topic %q (want projects/PROJECT/topics/TOPIC)", topic)
+	}
+	client, err := pubsub.NewClient(ctx, parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pub/Sub client: %w", err)
+	}
+	return &Publisher{client: client, topic: client.Topic(parts[3]), source: source}, nil
+}
+
+// Close stops the publisher and closes its Pub/Sub client.
+func (p *Publisher) Close() error {
+	p.topic.Stop()
+	return p.client.Close()
+}
+
+// Publish publishes an event of the given type about subject, e.g. an object
+// name, with data encoded as JSON, and waits until Pub/Sub has accepted it.
+func (p *Publisher) Publish(ctx context.Context, eventType, subject string, data any) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	event := v2.NewEvent()
+	event.SetID(hex.EncodeToString(id))
+	event.SetSource(p.source)
+	event.SetType(eventType)
+	event.SetSubject(subject)
+	event.SetTime(time.Now().UTC())
+	if err := event.SetData(v2.ApplicationJSON, data); err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	body, err := event.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	if err := p.PublishData(ctx, body, map[string]string{"content-type": structuredContentType}); err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// PublishData publishes a message with the given data and attributes, and
+// waits until Pub/Sub has accepted it.
+func (p *Publisher) PublishData(ctx context.Context, data []byte, attributes map[string]string) error {
+	result := p.topic.Publish(ctx, &pubsub.Message{Data: data, Attributes: attributes})
+	_, err := result.Get(ctx)
+	return err
+}
@@ -0,0 +1,358 @@
+// Package jobtrack records the state of each processed document in a Firestore
+// collection, so jobs can be queried ("everything that failed today") without
+// searching the logs. The same collection keeps the records of on-demand jobs.
+package jobtrack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// State is the stage a job has reached.
+type State string
+
+// Job states, in the order a document normally goes through them. Skipped is
+// for documents the handler chose not to synthesize, e.g. one whose output is
+// already up to date or that's over budget.
+const (
+	Queued       State = "queued"
+	Extracting   State = "extracting"
+	Synthesizing State = "synthesizing"
+	Finalizing   State = "finalizing"
+	Done         State = "done"
+	Failed       State = "failed"
+	Skipped      State = "skipped"
+)
+
+// Record is an update to a job document. Empty fields leave the document's
+// values as they are.
+type Record struct {
+	Input      string // gs:// URI of the PDF.
+	Generation string // Generation of the PDF the job processes.
+	State      State
+	Output     string // gs:// URI of the audio.
+	Stage      string // Stage a failed job failed in.
+	Error      string // Why the job failed, or the reason it was skipped.
+	Retryable  bool   // Whether a failed job may succeed when retried.
+	// Job is the ID of the on-demand job processing the input, whose document
+	// is updated rather than the one of the input's version.
+	Job string
+}
+
+// Tracker writes job documents to a Firestore collection.
+type Tracker struct {
+	client     *firestore.Client
+	collection string
+}
+
+// New creates a Tracker writing to collection in the given Firestore database
+// ("" for the default database) of the project the function runs in.
+func New(ctx context.Context, database, collection string) (*Tracker, error) {
+	if database == "" {
+		database = firestore.DefaultDatabaseID
+	}
+	client, err := firestore.NewClientWithDatabase(ctx, firestore.DetectProjectID, database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	return &Tracker{client: client, collection: collection}, nil
+}
+
+// Close closes the Firestore client.
+func (t *Tracker) Close() error {
+	return t.client.Close()
+}
+
+// ID returns the ID of the job document for a version of an input: a hash of
+// its URI and generation, since URIs contain slashes Firestore doesn't allow in
+// IDs.
+func ID(input, generation string) string {
+	sum := sha256.Sum256([]byte(input + "#" + generation))
+	return hex.EncodeToString(sum[:16])
+}
+
+// DocID returns the ID of the document tracking a version of an input: the
+// document of the on-demand job processing it if job isn't "", or else the
+// input's own.
+func DocID(input, generation, job string) string {
+	if job != "" {
+		return job
+	}
+	return ID(input, generation)
+}
+
+// Record merges r into the job's document, creating it if needed. Each state's
+// first-reached time is kept in the document's timestamps, next to created_at
+// and updated_at. Queuing a job again, e.g. on a retry, clears an earlier error.
+func (t *Tracker) Record(ctx context.Context, r Record) error {
+	doc := map[string]any{
+		"input":      r.Input,
+		"generation": r.Generation,
+		"state":      string(r.State),
+		"updated_at": firestore.ServerTimestamp,
+		"timestamps": map[string]any{string(r.State): firestore.ServerTimestamp},
+	}
+	switch r.State {
+	case Queued:
+		doc["error"] = firestore.Delete
+		doc["stage"] = firestore.Delete
+		doc["retryable"] = firestore.Delete
+	case Failed:
+		doc["stage"] = r.Stage
+		doc["retryable"] = r.Retryable
+	}
+	if r.Output != "" {
+		doc["output"] = r.Output
+	}
+	if r.Error != "" {
+		doc["error"] = r.Error
+	}
+
+	ref := t.client.Collection(t.collection).Doc(DocID(r.Input, r.Generation, r.Job))
+	if r.State == Queued {
+		// created_at is set once; a retry keeps the original time.
+		_, err := ref.Create(ctx, map[string]any{"created_at": firestore.ServerTimestamp})
+		if err != nil && status.Code(err) != codes.AlreadyExists {
+			return fmt.Errorf("failed to create job document %s: %w", ref.ID, err)
+		}
+	}
+	if _, err := ref.Set(ctx, doc, firestore.MergeAll); err != nil {
+		return fmt.Errorf("failed to update job document %s: %w", ref.ID, err)
+	}
+	return nil
+}
+
+// Progress is how far a job has got. Zero fields leave the document's values as
+// they are, so extraction and synthesis progress can be recorded separately.
+type Progress struct {
+	PagesExtracted    int     `firestore:"pages_extracted" json:"pages_extracted,omitempty"`
+	Pages             int     `firestore:"pages" json:"pages,omitempty"`
+	ChunksSynthesized int     `firestore:"chunks_synthesized" json:"chunks_synthesized,omitempty"`
+	Chunks            int     `firestore:"chunks" json:"chunks,omitempty"`
+	Percent           float64 `firestore:"percent" json:"percent,omitempty"` // Of the synthesis, from 0 to 100.
+}
+
+// RecordProgress merges p into the progress field of the document tracking a
+// version of an input, as Record does for states. In the document of an
+// on-demand job, it also updates the job's updated_at, so a job that's making
+// progress isn't taken for a stuck one.
+func (t *Tracker) RecordProgress(ctx context.Context, input, generation, job string, p Progress) error {
+	progress := map[string]any{}
+	for key, value := range map[string]int{
+		"pages_extracted":    p.PagesExtracted,
+		"pages":              p.Pages,
+		"chunks_synthesized": p.ChunksSynthesized,
+		"chunks":             p.Chunks,
+	} {
+		if value > 0 {
+			progress[key] = value
+		}
+	}
+	if p.Percent > 0 {
+		progress["percent"] = p.Percent
+	}
+	doc := map[string]any{"progress": progress, "updated_at": firestore.ServerTimestamp}
+	if job != "" {
+		doc["job"] = map[string]any{"updated_at": time.Now().UTC()}
+	}
+	ref := t.client.Collection(t.collection).Doc(DocID(input, generation, job))
+	if _, err := ref.Set(ctx, doc, firestore.MergeAll); err != nil {
+		return fmt.Errorf("failed to update progress of job document %s: %w", ref.ID, err)
+	}
+	return nil
+}
+
+// Job is a job document as read back.
+type Job struct {
+	State    State    `firestore:"state"`
+	Output   string   `firestore:"output"`
+	Stage    string   `firestore:"stage"`
+	Error    string   `firestore:"error"`
+	Progress Progress `firestore:"progress"`
+}
+
+// Get returns the job document id, or nil if there's none.
+func (t *Tracker) Get(ctx context.Context, id string) (*Job, error) {
+	ref := t.client.Collection(t.collection).Doc(id)
+	snap, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job document %s: %w", ref.ID, err)
+	}
+	var job Job
+	if err := snap.DataTo(&job); err != nil {
+		return nil, fmt.Errorf("invalid job document %s: %w", ref.ID, err)
+	}
+	return &job, nil
+}
+
+// OnDemandJob is the record of an on-demand job, kept in the job field of the
+// document whose ID is the job's, next to the state and progress tracked while
+// it runs.
+type OnDemandJob struct {
+	ID      string            `firestore:"id" json:"id"`
+	Input   string            `firestore:"input" json:"input"` // gs:// URI of the PDF or audiobook manifest.
+	Options map[string]string `firestore:"options,omitempty" json:"options,omitempty"`
+	Status  string            `firestore:"status" json:"status"`
+	// ContentType is the input's content type when it was queued by the
+	// sweeper, which tells a PDF named without ".pdf" apart.
+	ContentType string `firestore:"content_type,omitempty" json:"content_type,omitempty"`
+	// Output is where the audio is written, once the job got that far. Long
+	// audio handed to FinalizePendingSyntheses appears there when it's finished.
+	Output string `firestore:"output,omitempty" json:"output,omitempty"`
+	Error  string `firestore:"error,omitempty" json:"error,omitempty"`
+	// Requeues counts the times SweepMissedInputs queued the job again after
+	// the invocation running it was lost.
+	Requeues  int       `firestore:"requeues,omitempty" json:"requeues,omitempty"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+	UpdatedAt time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// ErrNoJob is the error for an on-demand job that has no record.
+var ErrNoJob = errors.New("no such job")
+
+// jobDocument is a document as read for the record of an on-demand job.
+type jobDocument struct {
+	Job *OnDemandJob `firestore:"job"`
+}
+
+// onDemandJob returns the record of an on-demand job in snap. It fails with
+// ErrNoJob if the document tracks an input rather than an on-demand job.
+func onDemandJob(snap *firestore.DocumentSnapshot) (*OnDemandJob, error) {
+	var doc jobDocument
+	if err := snap.DataTo(&doc); err != nil {
+		return nil, fmt.Errorf("invalid job document %s: %w", snap.Ref.ID, err)
+	}
+	if doc.Job == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoJob, snap.Ref.ID)
+	}
+	return doc.Job, nil
+}
+
+// CreateJob creates the document of an on-demand job with its record.
+func (t *Tracker) CreateJob(ctx context.Context, job OnDemandJob) error {
+	ref := t.client.Collection(t.collection).Doc(job.ID)
+	if _, err := ref.Create(ctx, map[string]any{"job": job}); err != nil {
+		return fmt.Errorf("failed to create job document %s: %w", ref.ID, err)
+	}
+	return nil
+}
+
+// GetJob returns the record of the on-demand job id. It fails with ErrNoJob if
+// there's none.
+func (t *Tracker) GetJob(ctx context.Context, id string) (*OnDemandJob, error) {
+	ref := t.client.Collection(t.collection).Doc(id)
+	snap, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNoJob, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job document %s: %w", ref.ID, err)
+	}
+	return onDemandJob(snap)
+}
+
+// UpdateJob calls update with the record of the on-demand job id, and saves the
+// changes it made, with the time in updated_at, unless it returns false or an
+// error. Both happen in a transaction, so update may be called again if the
+// record changed in the meantime. It returns the record as it is in the end,
+// and fails with ErrNoJob if there's none, or with the error of update.
+func (t *Tracker) UpdateJob(ctx context.Context, id string, update func(*OnDemandJob) (bool, error)) (*OnDemandJob, error) {
+	ref := t.client.Collection(t.collection).Doc(id)
+	var job *OnDemandJob
+	err := t.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("%w: %s", ErrNoJob, id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read job document %s: %w", ref.ID, err)
+		}
+		if job, err = onDemandJob(snap); err != nil {
+			return err
+		}
+		changed, err := update(job)
+		if err != nil || !changed {
+			return err
+		}
+		job.UpdatedAt = time.Now().UTC()
+		return tx.Update(ref, []firestore.Update{{Path: "job", Value: job}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ListJobs returns up to limit on-demand jobs, newest first, and only those
+// with the given status unless it's "". after is the ID of the job the previous
+// page ended with, or "" for the first page; it fails with ErrNoJob if that job
+// no longer exists. Filtering by status needs a composite index of job.status
+// and job.created_at, descending.
+func (t *Tracker) ListJobs(ctx context.Context, withStatus, after string, limit int) ([]OnDemandJob, error) {
+	// Ordering by job.created_at leaves out the documents of inputs, which
+	// don't have it.
+	q := t.client.Collection(t.collection).OrderBy("job.created_at", firestore.Desc)
+	if withStatus != "" {
+		q = q.Where("job.status", "==", withStatus)
+	}
+	if after != "" {
+		ref := t.client.Collection(t.collection).Doc(after)
+		snap, err := ref.Get(ctx)
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("%w: %s", ErrNoJob, after)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job document %s: %w", ref.ID, err)
+		}
+		q = q.StartAfter(snap)
+	}
+	return t.queryJobs(ctx, q.Limit(limit))
+}
+
+// LatestJob returns the on-demand job for the input at a gs:// URI created
+// last, or nil if there's none. It needs a composite index of job.input and
+// job.created_at, descending.
+func (t *Tracker) LatestJob(ctx context.Context, input string) (*OnDemandJob, error) {
+	q := t.client.Collection(t.collection).Where("job.input", "==", input).OrderBy("job.created_at", firestore.Desc).Limit(1)
+	jobs, err := t.queryJobs(ctx, q)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// JobsUpdatedBefore returns the on-demand jobs with one of the given statuses
+// whose records were last updated before cutoff. It needs a composite index of
+// job.status and job.updated_at.
+func (t *Tracker) JobsUpdatedBefore(ctx context.Context, statuses []string, cutoff time.Time) ([]OnDemandJob, error) {
+	q := t.client.Collection(t.collection).Where("job.status", "in", statuses).Where("job.updated_at", "<", cutoff)
+	return t.queryJobs(ctx, q)
+}
+
+// queryJobs returns the records of the on-demand jobs q finds.
+func (t *Tracker) queryJobs(ctx context.Context, q firestore.Query) ([]OnDemandJob, error) {
+	snaps, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs in %s: %w", t.collection, err)
+	}
+	jobs := make([]OnDemandJob, 0, len(snaps))
+	for _, snap := range snaps {
+		job, err := onDemandJob(snap)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, nil
+}
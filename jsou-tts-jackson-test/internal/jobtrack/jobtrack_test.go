@@ -0,0 +1,234 @@
+package jobtrack
+
+// The tests of Tracker run against the Firestore emulator, and are skipped
+// unless FIRESTORE_EMULATOR_HOST is set:
+//
+//	gcloud emulators firestore start --host-port=localhost:8080
+//	FIRESTORE_EMULATOR_HOST=localhost:8080 go test ./internal/jobtrack
+//
+// Each test works in a fresh collection.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// emulatorTracker returns a Tracker writing to a fresh collection of the
+// emulator, or skips the test without one.
+func emulatorTracker(t *testing.T) *Tracker {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST isn't set")
+	}
+	client, err := firestore.NewClient(context.Background(), "jobtrack-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	collection := fmt.Sprintf("%s-%d", strings.ReplaceAll(t.Name(), "/", "-"), time.Now().UnixNano())
+	return &Tracker{client: client, collection: collection}
+}
+
+func TestDocID(t *testing.T) {
+	tests := []struct {
+		name              string
+		input, generation string
+		job               string
+		want              string
+	}{
+		{name: "input", input: "gs://library/book.pdf", generation: "1", want: ID("gs://library/book.pdf", "1")},
+		{name: "on-demand job", input: "gs://library/book.pdf", generation: "1", job: "job-1", want: "job-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DocID(tt.input, tt.generation, tt.job); got != tt.want {
+				t.Errorf("DocID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if ID("gs://library/book.pdf", "1") == ID("gs://library/book.pdf", "2") {
+		t.Error("two generations of an input share a document")
+	}
+	if id := ID("gs://library/book.pdf", "1"); strings.Contains(id, "/") || len(id) != 32 {
+		t.Errorf("ID() = %q, want 32 hex digits", id)
+	}
+}
+
+func TestEmulatorRecord(t *testing.T) {
+	tr := emulatorTracker(t)
+	ctx := context.Background()
+	const input, generation = "gs://library/book.pdf", "1"
+	id := ID(input, generation)
+
+	if job, err := tr.Get(ctx, id); err != nil || job != nil {
+		t.Fatalf("Get() of a missing document = %v, %v; want nil", job, err)
+	}
+	steps := []struct {
+		record Record
+		want   Job
+	}{
+		{
+			record: Record{Input: input, Generation: generation, State: Queued},
+			want:   Job{State: Queued},
+		},
+		{
+			record: Record{Input: input, Generation: generation, State: Failed, Stage: "synthesis", Error: "quota exceeded", Retryable: true},
+			want:   Job{State: Failed, Stage: "synthesis", Error: "quota exceeded"},
+		},
+		{
+			record: Record{Input: input, Generation: generation, State: Queued},
+			want:   Job{State: Queued},
+		},
+		{
+			record: Record{Input: input, Generation: generation, State: Done, Output: "gs://library/mp3-output/book.mp3"},
+			want:   Job{State: Done, Output: "gs://library/mp3-output/book.mp3"},
+		},
+	}
+	for _, step := range steps {
+		if err := tr.Record(ctx, step.record); err != nil {
+			t.Fatal(err)
+		}
+		got, err := tr.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != step.want {
+			t.Errorf("after recording %s, document is %+v, want %+v", step.record.State, *got, step.want)
+		}
+	}
+}
+
+func TestEmulatorRecordProgress(t *testing.T) {
+	tr := emulatorTracker(t)
+	ctx := context.Background()
+	const input, generation = "gs://library/book.pdf", "1"
+	if err := tr.RecordProgress(ctx, input, generation, "", Progress{PagesExtracted: 3, Pages: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.RecordProgress(ctx, input, generation, "", Progress{ChunksSynthesized: 2, Chunks: 5, Percent: 40}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.Get(ctx, ID(input, generation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Progress{PagesExtracted: 3, Pages: 10, ChunksSynthesized: 2, Chunks: 5, Percent: 40}); got.Progress != want {
+		t.Errorf("progress %+v, want %+v", got.Progress, want)
+	}
+}
+
+func TestEmulatorRecordProgressOfJob(t *testing.T) {
+	tr := emulatorTracker(t)
+	ctx := context.Background()
+	created := time.Now().Add(-time.Hour).UTC()
+	job := OnDemandJob{ID: "job-1", Input: "gs://library/book.pdf", Status: "running", CreatedAt: created, UpdatedAt: created}
+	if err := tr.CreateJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.RecordProgress(ctx, job.Input, "1", job.ID, Progress{Percent: 10}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.UpdatedAt.After(created) || got.Status != job.Status {
+		t.Errorf("job %s updated at %v after progress, want after %v", got.Status, got.UpdatedAt, created)
+	}
+}
+
+func TestEmulatorJobs(t *testing.T) {
+	tr := emulatorTracker(t)
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+	for i, status := range []string{"done", "queued", "done"} {
+		created := start.Add(time.Duration(i) * time.Minute)
+		job := OnDemandJob{ID: fmt.Sprintf("job-%d", i), Input: "gs://library/book.pdf", Status: status, CreatedAt: created, UpdatedAt: created}
+		if err := tr.CreateJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Record(ctx, Record{Input: "gs://library/other.pdf", Generation: "1", State: Queued}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tr.GetJob(ctx, "missing"); !errors.Is(err, ErrNoJob) {
+		t.Errorf("GetJob() of a missing job: %v, want %v", err, ErrNoJob)
+	}
+	if _, err := tr.GetJob(ctx, ID("gs://library/other.pdf", "1")); !errors.Is(err, ErrNoJob) {
+		t.Errorf("GetJob() of an input's document: %v, want %v", err, ErrNoJob)
+	}
+
+	tests := []struct {
+		name       string
+		withStatus string
+		after      string
+		limit      int
+		want       []string
+		wantErr    error
+	}{
+		{name: "newest first", limit: 10, want: []string{"job-2", "job-1", "job-0"}},
+		{name: "first page", limit: 2, want: []string{"job-2", "job-1"}},
+		{name: "next page", after: "job-1", limit: 2, want: []string{"job-0"}},
+		{name: "with a status", withStatus: "done", limit: 10, want: []string{"job-2", "job-0"}},
+		{name: "after a missing job", after: "missing", limit: 10, wantErr: ErrNoJob},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs, err := tr.ListJobs(ctx, tt.withStatus, tt.after, tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListJobs() error = %v, want %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, job := range jobs {
+				got = append(got, job.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListJobs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmulatorUpdateJob(t *testing.T) {
+	tr := emulatorTracker(t)
+	ctx := context.Background()
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+	if err := tr.CreateJob(ctx, OnDemandJob{ID: "job-1", Input: "gs://library/book.pdf", Status: "queued", CreatedAt: created, UpdatedAt: created}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := tr.UpdateJob(ctx, "job-1", func(job *OnDemandJob) (bool, error) { return false, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !job.UpdatedAt.Equal(created) {
+		t.Errorf("unchanged job updated at %v, want %v", job.UpdatedAt, created)
+	}
+
+	if _, err := tr.UpdateJob(ctx, "job-1", func(job *OnDemandJob) (bool, error) {
+		job.Status = "running"
+		return true, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.GetJob(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "running" || !got.UpdatedAt.After(created) {
+		t.Errorf("job %s updated at %v, want running and updated after %v", got.Status, got.UpdatedAt, created)
+	}
+
+	if _, err := tr.UpdateJob(ctx, "missing", func(job *OnDemandJob) (bool, error) { return true, nil }); !errors.Is(err, ErrNoJob) {
+		t.Errorf("UpdateJob() of a missing job: %v, want %v", err, ErrNoJob)
+	}
+}
@@ -0,0 +1,73 @@
+package langdetect
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sampleWords caps how many words of a document are inspected. The opening
+// pages are plenty to tell the supported languages apart.
+const sampleWords = 5000
+
+// minMatches is the minimum number of stopword hits required before a
+// language is reported. Below this the sample is too small or too unusual.
+const minMatches = 10
+
+// stopwords lists very frequent function words per language, keyed by the
+// BCP-47 language code that is passed to the TTS API.
+var stopwords = map[string][]string{
+	"en-US": {"the", "and", "of", "to", "is", "in", "that", "it", "with", "for", "was", "are", "this", "be", "have", "not", "which", "from"},
+	"de-DE": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "ein", "eine", "sich", "auf", "für", "auch", "dem", "wird", "ich", "sie"},
+	"fr-FR": {"le", "la", "les", "et", "des", "est", "une", "dans", "que", "pour", "pas", "qui", "sur", "du", "au", "avec", "sont", "ce"},
+	"es-ES": {"el", "la", "los", "las", "y", "que", "del", "en", "una", "por", "con", "para", "es", "se", "no", "como", "pero", "su"},
+	"it-IT": {"il", "di", "che", "e", "la", "per", "un", "una", "sono", "con", "non", "gli", "della", "del", "nel", "questo", "anche", "le"},
+	"pt-BR": {"o", "a", "os", "as", "de", "que", "do", "da", "em", "um", "uma", "para", "com", "não", "por", "mais", "são", "se"},
+	"nl-NL": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "met", "voor", "ook", "maar", "er", "wordt", "die"},
+}
+
+var lookup = buildLookup()
+
+func buildLookup() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}
+
+// Detect guesses the language of text by counting stopword occurrences.
+// It returns a BCP-47 code such as "de-DE" and true, or "" and false when
+// the text doesn't contain enough evidence for any supported language.
+func Detect(text string) (string, bool) {
+	scores := make(map[string]int)
+	words := 0
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for _, lang := range lookup[strings.ToLower(field)] {
+			scores[lang]++
+		}
+		words++
+		if words >= sampleWords {
+			break
+		}
+	}
+
+	best, bestScore := "", 0
+	for lang, score := range scores {
+		// Ties are broken alphabetically so results are deterministic.
+		if score > bestScore || (score == bestScore && lang < best) {
+			best, bestScore = lang, score
+		}
+	}
+	if bestScore < minMatches {
+		return "", false
+	}
+	return best, true
+}
+
+// Language returns the primary language subtag of a BCP-47 code, e.g. "de" for "de-DE".
+func Language(code string) string {
+	lang, _, _ := strings.Cut(code, "-")
+	return strings.ToLower(lang)
+}
@@ -0,0 +1,66 @@
+package langdetect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "English",
+			text:   strings.Repeat("The cat sat on the mat and it was happy with the food that was in the bowl. ", 3),
+			want:   "en-US",
+			wantOK: true,
+		},
+		{
+			name:   "German",
+			text:   strings.Repeat("Der Hund und die Katze sind nicht auf dem Tisch, denn sie wird mit ihr spielen. ", 3),
+			want:   "de-DE",
+			wantOK: true,
+		},
+		{
+			name:   "French",
+			text:   strings.Repeat("Le chat est dans la maison avec les enfants qui sont sur le lit pour dormir. ", 3),
+			want:   "fr-FR",
+			wantOK: true,
+		},
+		{
+			name: "too little text",
+			text: "The cat.",
+		},
+		{
+			name: "no stopwords",
+			text: strings.Repeat("Lorem ipsum dolor sit amet consectetur adipiscing elit. ", 10),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Detect(tt.text)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Detect() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		code, want string
+	}{
+		{"de-DE", "de"},
+		{"EN-us", "en"},
+		{"fr", "fr"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Language(tt.code); got != tt.want {
+			t.Errorf("Language(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
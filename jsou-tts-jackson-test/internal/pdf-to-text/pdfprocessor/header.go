@@ -0,0 +1,23 @@
+package pdfprocessor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// headerWindow is how far into a file the "%PDF-" header is looked for. The
+// format puts it first, but readers accept it within the first kilobyte, after
+// junk some generators prepend.
+const headerWindow = 1024
+
+// IsPDF reports whether the file of size bytes read through r starts with a PDF
+// header, so a file named .pdf that holds something else is caught before it's
+// parsed.
+func IsPDF(r io.ReaderAt, size int64) (bool, error) {
+	head := make([]byte, min(size, headerWindow))
+	if _, err := r.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return bytes.Contains(head, []byte("%PDF-")), nil
+}
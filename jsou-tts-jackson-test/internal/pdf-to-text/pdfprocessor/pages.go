@@ -0,0 +1,75 @@
+package pdfprocessor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageRange is an inclusive range of 1-based page numbers.
+type PageRange struct {
+	First, Last int
+}
+
+// PageRanges selects the pages of a document to extract. A nil PageRanges
+// selects every page.
+type PageRanges []PageRange
+
+// ParsePageRanges parses a comma-separated list of pages and page ranges, such
+// as "1-3,7,10-", where a range without an end runs to the last page. An empty
+// string yields nil, selecting every page.
+func ParsePageRanges(s string) (PageRanges, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var ranges PageRanges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		r := PageRange{}
+		var err error
+		if r.First, err = strconv.Atoi(strings.TrimSpace(first)); err != nil || r.First < 1 {
+			return nil, fmt.Errorf("invalid page range %q: pages are numbered from 1", part)
+		}
+		r.Last = r.First
+		if isRange {
+			r.Last = 0 // Open-ended: up to the last page.
+			if last = strings.TrimSpace(last); last != "" {
+				if r.Last, err = strconv.Atoi(last); err != nil || r.Last < r.First {
+					return nil, fmt.Errorf("invalid page range %q", part)
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// Contains reports whether page is selected.
+func (p PageRanges) Contains(page int) bool {
+	if p == nil {
+		return true
+	}
+	for _, r := range p {
+		if page >= r.First && (r.Last == 0 || page <= r.Last) {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the ranges as ParsePageRanges accepts them.
+func (p PageRanges) String() string {
+	parts := make([]string, len(p))
+	for i, r := range p {
+		switch {
+		case r.Last == 0:
+			parts[i] = fmt.Sprintf("%d-", r.First)
+		case r.Last == r.First:
+			parts[i] = strconv.Itoa(r.First)
+		default:
+			parts[i] = fmt.Sprintf("%d-%d", r.First, r.Last)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -2,35 +2,114 @@ package pdfprocessor
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"strings"
+	"sync"
 
 	"github.com/dslipak/pdf"
+	"golang.org/x/sync/errgroup"
 )
 
 // ExtractTextFromFilePath takes the file path to a PDF document and extracts
 // all readable text from it. It returns the concatenated text and any error encountered.
 func ExtractTextFromPDFFilePath(filePath string) (string, error) {
+	extraction, err := ExtractPages(filePath)
+	return extraction.Text, err
+}
+
+// Extraction is the result of ExtractPages.
+type Extraction struct {
+	Text        string
+	Pages       int   // Number of pages in the document.
+	FailedPages []int // 1-based numbers of the pages whose text couldn't be extracted.
+}
+
+// ExtractPages is like ExtractTextFromPDFFilePath but also reports the page
+// count and the pages whose text couldn't be extracted, which are skipped rather
+// than failing the whole document.
+func ExtractPages(filePath string) (Extraction, error) {
 	pdfReader, err := pdf.Open(filePath) // Open the PDF directly from the file path
 	if err != nil {
-		return "", fmt.Errorf("failed to open PDF file %s for extraction: %w", filePath, err)
+		return Extraction{}, fmt.Errorf("failed to open PDF file %s for extraction: %w", filePath, err)
 	}
+	return extractPages(pdfReader, filePath, ExtractOptions{}), nil
+}
+
+// ExtractOptions tune ExtractPagesFromReader.
+type ExtractOptions struct {
+	// Pages are the pages to extract; nil extracts all of them.
+	Pages PageRanges
+	// Progress, if set, is called after each extracted page with the number
+	// of pages done and the number selected, one call at a time.
+	Progress func(done, total int)
+	// Workers is the number of pages extracted at the same time; 0 or 1
+	// extracts them one after the other. More workers are faster on large
+	// documents, but hold more pages in memory at once.
+	Workers int
+}
 
+// ExtractPagesFromReader is like ExtractPages for a PDF of size bytes read
+// through r, e.g. straight from Cloud Storage. name identifies it in logs.
+func ExtractPagesFromReader(r io.ReaderAt, size int64, name string, opts ExtractOptions) (Extraction, error) {
+	pdfReader, err := pdf.NewReader(r, size)
+	if err != nil {
+		return Extraction{}, fmt.Errorf("failed to open PDF %s for extraction: %w", name, err)
+	}
+	return extractPages(pdfReader, name, opts), nil
+}
+
+// extractPages extracts the text of the selected pages of an opened PDF.
+func extractPages(pdfReader *pdf.Reader, name string, opts ExtractOptions) Extraction {
 	var extractedText strings.Builder
-	numPages := pdfReader.NumPage()
-	if numPages == 0 {
-		return "", nil // No pages, no text
+	extraction := Extraction{Pages: pdfReader.NumPage()}
+	if extraction.Pages == 0 {
+		return extraction // No pages, no text
 	}
 
-	for i := 1; i <= numPages; i++ {
-		page := pdfReader.Page(i)
-		text, err := page.GetPlainText(nil) // nil for fonts to use default text extraction
-		if err != nil {
-			log.Printf("Warning: Failed to extract text from page %d of %s: %v", i, filePath, err)
-			continue // Continue with other pages even if one fails
+	var selected []int
+	for i := 1; i <= extraction.Pages; i++ {
+		if opts.Pages.Contains(i) {
+			selected = append(selected, i)
 		}
-		extractedText.WriteString(text)
 	}
+	workers := max(opts.Workers, 1)
 
-	return extractedText.String(), nil
+	// Pages are extracted by up to workers goroutines, and joined in page order.
+	texts := make([]string, len(selected))
+	failed := make([]bool, len(selected))
+	var mu sync.Mutex
+	done := 0
+	var g errgroup.Group
+	g.SetLimit(workers)
+	for n, i := range selected {
+		g.Go(func() error {
+			page := pdfReader.Page(i)
+			text, err := page.GetPlainText(nil) // nil for fonts to use default text extraction
+			if err != nil {
+				log.Printf("Warning: Failed to extract text from page %d of %s: %v", i, name, err)
+				failed[n] = true // Continue with other pages even if one fails
+			} else {
+				texts[n] = text
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if opts.Progress != nil {
+				opts.Progress(done, len(selected))
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	for n, i := range selected {
+		if failed[n] {
+			extraction.FailedPages = append(extraction.FailedPages, i)
+			continue
+		}
+		extractedText.WriteString(texts[n])
+	}
+	extraction.Text = extractedText.String()
+	return extraction
 }
@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// Reader reads the payloads of secrets.
+type Reader interface {
+	// Access returns the payload of a secret version, e.g.
+	// "projects/my-project/secrets/azure-speech-key/versions/latest".
+	Access(ctx context.Context, name string) (string, error)
+}
+
+// Client reads secrets from Secret Manager. It creates its Secret Manager
+// client when it first reads a secret, so a machine that needs no secrets
+// doesn't need credentials for it either. The zero value is ready to use; call
+// Close when done with it.
+type Client struct {
+	once   sync.Once
+	client *secretmanager.Client
+	err    error
+}
+
+// Access implements Reader. A name without a version
+// ("projects/my-project/secrets/azure-speech-key") reads the latest one.
+func (c *Client) Access(ctx context.Context, name string) (string, error) {
+	c.once.Do(func() {
+		// The client outlives the request, so it's created without its context.
+		client, err := secretmanager.NewClient(context.Background())
+		if err != nil {
+			c.err = fmt.Errorf("failed to create Secret Manager client: %w", err)
+			return
+		}
+		c.client = client
+	})
+	if c.err != nil {
+		return "", c.err
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	resp, err := c.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret %s: %w", name, err)
+	}
+	return strings.TrimSpace(string(resp.GetPayload().GetData())), nil
+}
+
+// Close closes the Secret Manager client, if one was created.
+func (c *Client) Close() error {
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Close()
+}
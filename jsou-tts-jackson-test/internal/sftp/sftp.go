@@ -0,0 +1,389 @@
+// Package sftp uploads files to an SFTP server, for delivering finished audio
+// to distribution partners. It implements the part of SFTP version 3 an upload
+// needs (open, write, close, rename, remove and mkdir) over golang.org/x/crypto/ssh.
+package sftp
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP packet types.
+const (
+	fxpInit    = 1
+	fxpVersion = 2
+	fxpOpen    = 3
+	fxpClose   = 4
+	fxpWrite   = 6
+	fxpRemove  = 13
+	fxpMkdir   = 14
+	fxpRename  = 18
+	fxpStatus  = 101
+	fxpHandle  = 102
+)
+
+// Open flags and status codes.
+const (
+	fxfWrite = 0x02
+	fxfCreat = 0x08
+	fxfTrunc = 0x10
+
+	fxOK = 0
+)
+
+// Writes are sent in chunks of writeChunkSize, with up to maxPendingWrites
+// awaiting their status, so a high-latency link doesn't wait for every chunk.
+const (
+	writeChunkSize   = 32 << 10
+	maxPendingWrites = 16
+)
+
+// Config identifies an SFTP server and how to log in to it.
+type Config struct {
+	Addr string // host:port
+	User string
+	// PrivateKey is a PEM private key; Password is used if it's empty.
+	PrivateKey []byte
+	Password   string
+	// HostKey is the server's public key in authorized_keys format
+	// ("ssh-ed25519 AAAA..."). The connection fails if the server presents another.
+	HostKey string
+}
+
+// StatusError is an SFTP request the server answered with a non-OK status.
+type StatusError struct {
+	Op      string
+	Code    uint32
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sftp %s: status %d: %s", e.Op, e.Code, e.Message)
+}
+
+// Client is an SFTP session. It isn't safe for concurrent use.
+type Client struct {
+	ssh     *ssh.Client
+	session *ssh.Session
+	w       io.WriteCloser
+	r       *bufio.Reader
+	nextID  uint32
+}
+
+// Dial connects and logs in to the server in cfg and starts an SFTP session.
+func Dial(ctx context.Context, cfg Config) (*Client, error) {
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SFTP host key: %w", err)
+	}
+	var auth ssh.AuthMethod
+	if len(cfg.PrivateKey) > 0 {
+		signer, err := ssh.ParsePrivateKey(cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SFTP private key: %w", err)
+		}
+		auth = ssh.PublicKeys(signer)
+	} else {
+		auth = ssh.Password(cfg.Password)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SFTP server %s: %w", cfg.Addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, cfg.Addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to log in to SFTP server %s: %w", cfg.Addr, err)
+	}
+	c := &Client{ssh: ssh.NewClient(sshConn, chans, reqs)}
+	if err := c.start(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to start SFTP on %s: %w", cfg.Addr, err)
+	}
+	return c, nil
+}
+
+// start opens the sftp subsystem and negotiates version 3.
+func (c *Client) start() error {
+	var err error
+	if c.session, err = c.ssh.NewSession(); err != nil {
+		return err
+	}
+	if c.w, err = c.session.StdinPipe(); err != nil {
+		return err
+	}
+	stdout, err := c.session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	c.r = bufio.NewReader(stdout)
+	if err := c.session.RequestSubsystem("sftp"); err != nil {
+		return err
+	}
+	if err := c.send(fxpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return err
+	}
+	typ, _, err := c.recv()
+	if err != nil {
+		return err
+	}
+	if typ != fxpVersion {
+		return fmt.Errorf("unexpected packet type %d instead of the server's version", typ)
+	}
+	return nil
+}
+
+// Close ends the session and the connection.
+func (c *Client) Close() error {
+	if c.session != nil {
+		c.session.Close()
+	}
+	return c.ssh.Close()
+}
+
+// Put uploads r to remotePath, creating missing parent directories. It's
+// written to remotePath.part and renamed into place when complete, so the
+// receiving side never picks up a partial file; an existing file is replaced.
+func (c *Client) Put(remotePath string, r io.Reader) (int64, error) {
+	c.mkdirAll(path.Dir(remotePath))
+	partPath := remotePath + ".part"
+	handle, err := c.open(partPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.write(handle, r)
+	if closeErr := c.call("close", fxpClose, appendString(nil, handle)); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.remove(partPath)
+		return n, err
+	}
+	// SFTP version 3 renames don't replace an existing file.
+	c.remove(remotePath)
+	if err := c.call("rename", fxpRename, appendString(appendString(nil, partPath), remotePath)); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// open opens path for writing, creating or truncating it, and returns its handle.
+func (c *Client) open(p string) (string, error) {
+	payload := appendString(nil, p)
+	payload = binary.BigEndian.AppendUint32(payload, fxfWrite|fxfCreat|fxfTrunc)
+	payload = binary.BigEndian.AppendUint32(payload, 0) // No attributes.
+	id, err := c.requestID(fxpOpen, payload)
+	if err != nil {
+		return "", err
+	}
+	typ, data, err := c.response(id)
+	if err != nil {
+		return "", err
+	}
+	if typ == fxpStatus {
+		return "", statusError("open "+p, data)
+	}
+	if typ != fxpHandle {
+		return "", fmt.Errorf("unexpected packet type %d in reply to open", typ)
+	}
+	handle, _, err := readString(data)
+	return handle, err
+}
+
+// write copies r to the file at handle, keeping up to maxPendingWrites chunks in flight.
+func (c *Client) write(handle string, r io.Reader) (int64, error) {
+	buf := make([]byte, writeChunkSize)
+	var offset int64
+	pending := 0
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			payload := appendString(nil, handle)
+			payload = binary.BigEndian.AppendUint64(payload, uint64(offset))
+			payload = appendString(payload, string(buf[:n]))
+			if _, err := c.requestID(fxpWrite, payload); err != nil {
+				return offset, err
+			}
+			offset += int64(n)
+			pending++
+		}
+		for pending == maxPendingWrites || pending > 0 && readErr != nil {
+			if err := c.status("write", 0); err != nil {
+				return offset, err
+			}
+			pending--
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return offset, nil
+		}
+		if readErr != nil {
+			return offset, readErr
+		}
+	}
+}
+
+// mkdirAll creates dir and its parents. Failures, mostly directories that
+// already exist, are ignored: SFTP version 3 doesn't tell them apart, and a
+// missing directory fails the upload anyway.
+func (c *Client) mkdirAll(dir string) {
+	if dir == "." || dir == "/" || dir == "" {
+		return
+	}
+	c.mkdirAll(path.Dir(dir))
+	payload := binary.BigEndian.AppendUint32(appendString(nil, dir), 0)
+	c.call("mkdir", fxpMkdir, payload)
+}
+
+// remove deletes a file, ignoring failures such as it not existing.
+func (c *Client) remove(p string) {
+	c.call("remove", fxpRemove, appendString(nil, p))
+}
+
+// call sends a request and waits for its status.
+func (c *Client) call(op string, typ byte, payload []byte) error {
+	id, err := c.requestID(typ, payload)
+	if err != nil {
+		return err
+	}
+	return c.status(op, id)
+}
+
+// requestID sends a request with a new ID.
+func (c *Client) requestID(typ byte, payload []byte) (uint32, error) {
+	c.nextID++
+	id := c.nextID
+	return id, c.send(typ, append(binary.BigEndian.AppendUint32(nil, id), payload...))
+}
+
+// status reads the status reply to a request and returns it as an error unless
+// it's OK. A zero id accepts a reply to any request, for pipelined writes.
+func (c *Client) status(op string, id uint32) error {
+	typ, data, err := c.response(id)
+	if err != nil {
+		return err
+	}
+	if typ != fxpStatus {
+		return fmt.Errorf("unexpected packet type %d in reply to %s", typ, op)
+	}
+	return statusError(op, data)
+}
+
+// response reads the next reply, which must be to request id unless id is 0,
+// and returns its type and the payload after the ID.
+func (c *Client) response(id uint32) (byte, []byte, error) {
+	typ, data, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 4 {
+		return 0, nil, fmt.Errorf("short SFTP reply of type %d", typ)
+	}
+	if got := binary.BigEndian.Uint32(data); id != 0 && got != id {
+		return 0, nil, fmt.Errorf("SFTP reply to request %d while waiting for %d", got, id)
+	}
+	return typ, data[4:], nil
+}
+
+// send writes a packet.
+func (c *Client) send(typ byte, payload []byte) error {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)))
+	packet = append(packet, typ)
+	_, err := c.w.Write(append(packet, payload...))
+	return err
+}
+
+// recv reads a packet and returns its type and payload.
+func (c *Client) recv() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("failed to read SFTP reply: %w", err)
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > 1<<20 {
+		return 0, nil, fmt.Errorf("invalid SFTP packet length %d", length)
+	}
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, nil, fmt.Errorf("failed to read SFTP reply: %w", err)
+	}
+	return header[4], data, nil
+}
+
+// statusError returns the error in a status payload (after the ID), or nil if it's OK.
+func statusError(op string, data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("short SFTP status reply to %s", op)
+	}
+	code := binary.BigEndian.Uint32(data)
+	if code == fxOK {
+		return nil
+	}
+	message, _, _ := readString(data[4:])
+	return &StatusError{Op: op, Code: code, Message: message}
+}
+
+// appendString appends an SFTP string: its length and bytes.
+func appendString(b []byte, s string) []byte {
+	return append(binary.BigEndian.AppendUint32(b, uint32(len(s))), s...)
+}
+
+// readString reads an SFTP string and returns it and the rest of data.
+func readString(data []byte) (string, []byte, error) {
+	if len(data) < 4 {
+		return "", nil, errors.New("short SFTP string")
+	}
+	n := binary.BigEndian.Uint32(data)
+	if uint32(len(data)-4) < n {
+		return "", nil, errors.New("short SFTP string")
+	}
+	return string(data[4 : 4+n]), data[4+n:], nil
+}
+
+// File is a file to upload: where it goes on the server and how to read it.
+type File struct {
+	RemotePath string
+	Open       func() (io.ReadCloser, error)
+}
+
+// Upload connects to the server in cfg and uploads files in order, so e.g. audio
+// arrives before the manifest announcing it.
+func Upload(ctx context.Context, cfg Config, files []File) error {
+	c, err := Dial(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for _, f := range files {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		n, err := c.Put(f.RemotePath, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("failed to upload %s to %s: %w", f.RemotePath, cfg.Addr, err)
+		}
+		log.Printf("Delivered %s (%d bytes) to %s@%s", f.RemotePath, n, cfg.User, cfg.Addr)
+	}
+	return nil
+}
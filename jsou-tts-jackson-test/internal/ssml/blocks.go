@@ -0,0 +1,148 @@
+package ssml
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// BlockKind classifies a block of extracted text.
+type BlockKind int
+
+const (
+	// Paragraph is ordinary body text.
+	Paragraph BlockKind = iota
+	// Heading is a short title line such as "1.2 Background" or "INTRODUCTION".
+	Heading
+	// Section is a heading that opens a major division, such as "Chapter 3" or "Part II".
+	Section
+)
+
+// Block is a paragraph or heading of a document.
+type Block struct {
+	Kind BlockKind
+	Text string
+}
+
+// maxHeadingLength is the longest line still considered a heading candidate.
+const maxHeadingLength = 80
+
+var (
+	sectionPattern  = regexp.MustCompile(`(?i)^(chapter|part|book|appendix|prologue|epilogue)\b`)
+	numberedHeading = regexp.MustCompile(`^\d+(\.\d+)*\.?\s+\p{Lu}`)
+)
+
+// Parse splits extracted text into paragraphs and headings. Blank lines separate
+// blocks; lines inside a block are joined with spaces, re-joining words that were
+// hyphenated across a line break.
+func Parse(text string) []Block {
+	var blocks []Block
+	var lines []string
+
+	flush := func() {
+		if len(lines) == 0 {
+			return
+		}
+		blocks = append(blocks, Block{Kind: Paragraph, Text: joinLines(lines)})
+		lines = lines[:0]
+	}
+
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			flush()
+			continue
+		}
+		if kind, ok := headingKind(line); ok {
+			flush()
+			blocks = append(blocks, Block{Kind: kind, Text: line})
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	return blocks
+}
+
+// headingKind reports whether a single line looks like a heading, and which kind.
+func headingKind(line string) (BlockKind, bool) {
+	if len(line) > maxHeadingLength || strings.ContainsAny(line[len(line)-1:], ".,;:!?") {
+		return Paragraph, false
+	}
+	if sectionPattern.MatchString(line) {
+		return Section, true
+	}
+	if numberedHeading.MatchString(line) || isUpperCase(line) {
+		return Heading, true
+	}
+	return Paragraph, false
+}
+
+// isUpperCase reports whether line has at least three letters and all of them are upper case.
+func isUpperCase(line string) bool {
+	letters := 0
+	for _, r := range line {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if !unicode.IsUpper(r) {
+			return false
+		}
+		letters++
+	}
+	return letters >= 3
+}
+
+// joinLines joins wrapped lines of a paragraph into a single line.
+func joinLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			if strings.HasSuffix(prev, "-") && len(prev) > 1 && unicode.IsLetter(rune(prev[len(prev)-2])) {
+				// "exam-" + "ple" -> "example": drop the hyphen written by the line wrap.
+				s := b.String()
+				b.Reset()
+				b.WriteString(s[:len(s)-1])
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// Chapters splits extracted text into chapters at the headings that open a
+// major division, such as "Chapter 3" or "Part II". Text before the first of
+// them, e.g. a title page or preface, goes with the first chapter, and
+// headings directly following each other, like "Part II" and "Chapter 4", open
+// the same chapter. Text without such headings is a single chapter.
+func Chapters(text string) []string {
+	var chapters []string
+	var current strings.Builder
+	inChapter, hasBody := false, false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			current.WriteString(line)
+			continue
+		}
+		if kind, ok := headingKind(trimmed); ok && kind == Section {
+			if inChapter && hasBody {
+				chapters = append(chapters, current.String())
+				current.Reset()
+			}
+			inChapter, hasBody = true, false
+			current.WriteString(line)
+			continue
+		}
+		hasBody = true
+		current.WriteString(line)
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		chapters = append(chapters, current.String())
+	}
+	return chapters
+}
@@ -0,0 +1,120 @@
+package ssml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LexiconEntry describes how a single word should be spoken. Exactly one of
+// IPA, XSampa or Sub must be set.
+type LexiconEntry struct {
+	IPA    string `json:"ipa,omitempty"`     // Pronunciation in the IPA alphabet, rendered as <phoneme>.
+	XSampa string `json:"x-sampa,omitempty"` // Pronunciation in X-SAMPA, rendered as <phoneme>.
+	Sub    string `json:"sub,omitempty"`     // Replacement text to speak instead, rendered as <sub>.
+}
+
+// Lexicon is a custom pronunciation dictionary. Words are matched exactly
+// first and then case-insensitively.
+type Lexicon struct {
+	exact  map[string]LexiconEntry
+	folded map[string]LexiconEntry
+}
+
+// ParseLexicon parses a JSON lexicon mapping words to entries, e.g.
+//
+//	{
+//	  "Nguyen": {"ipa": "wɪn"},
+//	  "JSOU":   {"sub": "Joint Special Operations University"}
+//	}
+func ParseLexicon(data []byte) (*Lexicon, error) {
+	var raw map[string]LexiconEntry
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid lexicon JSON: %w", err)
+	}
+
+	l := &Lexicon{exact: make(map[string]LexiconEntry), folded: make(map[string]LexiconEntry)}
+	for word, entry := range raw {
+		set := 0
+		for _, v := range []string{entry.IPA, entry.XSampa, entry.Sub} {
+			if v != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("lexicon entry %q must set exactly one of ipa, x-sampa or sub", word)
+		}
+		if strings.IndexFunc(word, isWordRune) != 0 || strings.ContainsFunc(word, unicode.IsSpace) {
+			return nil, fmt.Errorf("lexicon entry %q must be a single word", word)
+		}
+		l.exact[word] = entry
+		l.folded[strings.ToLower(word)] = entry
+	}
+	return l, nil
+}
+
+// Len returns the number of entries in the lexicon.
+func (l *Lexicon) Len() int {
+	return len(l.exact)
+}
+
+// lookup finds the entry for word, if any.
+func (l *Lexicon) lookup(word string) (LexiconEntry, bool) {
+	if e, ok := l.exact[word]; ok {
+		return e, true
+	}
+	e, ok := l.folded[strings.ToLower(word)]
+	return e, ok
+}
+
+// apply escapes text and wraps lexicon words in <phoneme> or <sub> elements.
+func (l *Lexicon) apply(text string) string {
+	var b strings.Builder
+	for len(text) > 0 {
+		n := wordLen(text)
+		if n == 0 {
+			_, size := utf8.DecodeRuneInString(text)
+			b.WriteString(Escape(text[:size]))
+			text = text[size:]
+			continue
+		}
+		word := text[:n]
+		text = text[n:]
+		entry, ok := l.lookup(word)
+		switch {
+		case !ok:
+			b.WriteString(Escape(word))
+		case entry.Sub != "":
+			fmt.Fprintf(&b, `<sub alias="%s">%s</sub>`, Escape(entry.Sub), Escape(word))
+		case entry.IPA != "":
+			fmt.Fprintf(&b, `<phoneme alphabet="ipa" ph="%s">%s</phoneme>`, Escape(entry.IPA), Escape(word))
+		default:
+			fmt.Fprintf(&b, `<phoneme alphabet="x-sampa" ph="%s">%s</phoneme>`, Escape(entry.XSampa), Escape(word))
+		}
+	}
+	return b.String()
+}
+
+// isWordRune reports whether r can start a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordLen returns the byte length of the word at the start of text: letters and
+// digits, plus apostrophes, hyphens and periods between them ("O'Neil", "e.g").
+func wordLen(text string) int {
+	end := 0
+	for i, r := range text {
+		switch {
+		case isWordRune(r):
+			end = i + utf8.RuneLen(r)
+		case end > 0 && end == i && strings.ContainsRune(`'’-.`, r):
+			// Joiner; only part of the word if a letter or digit follows.
+		default:
+			return end
+		}
+	}
+	return end
+}
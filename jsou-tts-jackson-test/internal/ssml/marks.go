@@ -0,0 +1,24 @@
+package ssml
+
+import "fmt"
+
+// Marks collects the sentences that BuildChunks tagged with <mark> elements, so
+// the timepoints returned by the API can be mapped back to text. The mark name of
+// Sentences[i] is MarkName(i).
+type Marks struct {
+	Sentences []string
+}
+
+// MarkName returns the name of the mark placed before the i-th sentence.
+func MarkName(i int) string {
+	return fmt.Sprintf("s%d", i)
+}
+
+// add records a sentence and returns its <mark> element.
+func (m *Marks) add(sentence string) string {
+	m.Sentences = append(m.Sentences, sentence)
+	return fmt.Sprintf(`<mark name="%s"/>`, MarkName(len(m.Sentences)-1))
+}
+
+// markOverhead is the most a sentence's <s> and <mark> elements add to its size.
+const markOverhead = len(`<s><mark name="s0000000"/></s>`)
@@ -0,0 +1,63 @@
+package ssml
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Sanitize cleans up raw PDF extraction output so it is safe to embed in an
+// SSML document. It drops invalid UTF-8, control and format characters and
+// code points that XML 1.0 forbids, turns page/form feeds into paragraph
+// breaks and normalizes exotic spaces to a plain space. Newlines and tabs are kept.
+func Sanitize(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			// Invalid byte sequence; drop it.
+		case r == '\n' || r == '\t':
+			b.WriteRune(r)
+		case r == '\r':
+			// Normalized away; "\r\n" keeps its '\n'.
+		case r == '\f' || r == '\v' || r == '\u2028' || r == '\u2029': // Page breaks and paragraph separators.
+			b.WriteString("\n\n")
+		case r == '\u00ad':
+			// Soft hyphen: only marks a possible break point.
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r), !isXMLChar(r):
+			// Control, zero-width/format and XML-invalid characters can't be spoken.
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isXMLChar reports whether r is allowed in an XML 1.0 document.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
+
+var escaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&apos;",
+)
+
+// Escape escapes the XML special characters in text so it can be used as
+// SSML character data or attribute values. text should already be sanitized.
+func Escape(text string) string {
+	return escaper.Replace(text)
+}
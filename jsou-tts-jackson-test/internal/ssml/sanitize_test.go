@@ -0,0 +1,74 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "Hello, world.", "Hello, world."},
+		{"special characters kept for Escape", `Tom & Jerry <"Fish" 'n' chips>`, `Tom & Jerry <"Fish" 'n' chips>`},
+		{"newlines and tabs kept", "a\nb\tc", "a\nb\tc"},
+		{"carriage returns dropped", "a\r\nb\rc", "a\nbc"},
+		{"control characters dropped", "a\x00b\x07c\x1bd\x7fe\u009bf", "abcdef"},
+		{"next line is a space", "a\u0085b", "a b"},
+		{"form feed is a paragraph break", "page one\fpage two", "page one\n\npage two"},
+		{"vertical tab and separators", "a\vb\u2028c\u2029d", "a\n\nb\n\nc\n\nd"},
+		{"invalid UTF-8 dropped", "caf\xc3 ok \xff\xfe end", "caf ok  end"},
+		{"truncated multibyte rune dropped", "日本\xe8\xaa", "日本"},
+		{"soft hyphen dropped", "hy\u00adphen", "hyphen"},
+		{"format characters dropped", "zero\u200bwidth\ufeff", "zerowidth"},
+		{"exotic spaces normalized", "a\u00a0b\u2003c", "a b c"},
+		{"noncharacters dropped", "a\ufffeb\uffffc", "abc"},
+		{"multibyte text kept", "Ünïcödé 日本語 😀", "Ünïcödé 日本語 😀"},
+		{"literal replacement character kept", "a\ufffdb", "a\ufffdb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Tom & Jerry", "Tom &amp; Jerry"},
+		{"a < b > c", "a &lt; b &gt; c"},
+		{`say "hi"`, "say &quot;hi&quot;"},
+		{"it's", "it&apos;s"},
+		{"&amp;", "&amp;amp;"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.in); got != tt.want {
+			t.Errorf("Escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizedTextIsValidXML(t *testing.T) {
+	raw := "Q&A <b>\"quoted\"</b> it's\x00\x01\f\xff\xfe\ufffe\u200b 日本 \r\n end"
+	doc := "<speak>" + Escape(Sanitize(raw)) + "</speak>"
+	d := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		_, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("%q isn't valid XML: %v", doc, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ssml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sayAsPattern finds numbers that are spoken wrongly or ambiguously as plain text.
+// Alternatives are tried in order, so currencies and dates win over bare numbers.
+var sayAsPattern = regexp.MustCompile(
+	`(?P<currency>[$€£¥]\s?\d{1,3}(?:,\d{3})+(?:\.\d{1,2})?\b|[$€£¥]\s?\d+(?:\.\d{1,2})?\b)` +
+		`|(?P<iso>\b\d{4}-\d{2}-\d{2}\b)` +
+		`|(?P<date>\b\d{1,2}/\d{1,2}/(?:\d{4}|\d{2})\b)` +
+		`|(?P<ordinal>\b\d+(?:st|nd|rd|th)\b)` +
+		`|(?P<cardinal>\b\d{1,3}(?:,\d{3})+(?:\.\d+)?\b|\b\d{5,}\b)`)
+
+// dateFormat returns the say-as date format for slash-separated dates, which are
+// month-first in US English and day-first almost everywhere else.
+func (o Options) dateFormat() string {
+	if o.LanguageCode == "" || strings.EqualFold(o.LanguageCode, "en-US") {
+		return "mdy"
+	}
+	return "dmy"
+}
+
+// applySayAs renders text, wrapping dates, currencies, ordinals and long numbers
+// in <say-as> elements. Everything else is rendered by plain.
+func (o Options) applySayAs(text string, plain func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range sayAsPattern.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(plain(text[last:m[0]]))
+		match := text[m[0]:m[1]]
+		for i, name := range sayAsPattern.SubexpNames() {
+			if name == "" || m[2*i] < 0 {
+				continue
+			}
+			b.WriteString(o.sayAs(name, match))
+			break
+		}
+		last = m[1]
+	}
+	b.WriteString(plain(text[last:]))
+	return b.String()
+}
+
+// sayAs renders a single match of the named sayAsPattern group.
+func (o Options) sayAs(kind, match string) string {
+	switch kind {
+	case "currency":
+		language := o.LanguageCode
+		if language == "" {
+			language = "en-US"
+		}
+		return fmt.Sprintf(`<say-as interpret-as="currency" language="%s">%s</say-as>`, Escape(language), Escape(match))
+	case "iso":
+		return fmt.Sprintf(`<say-as interpret-as="date" format="yyyymmdd">%s</say-as>`, Escape(match))
+	case "date":
+		return fmt.Sprintf(`<say-as interpret-as="date" format="%s">%s</say-as>`, o.dateFormat(), Escape(match))
+	case "ordinal":
+		return fmt.Sprintf(`<say-as interpret-as="ordinal">%s</say-as>`, strings.TrimRight(match, "stndrh"))
+	default:
+		return fmt.Sprintf(`<say-as interpret-as="cardinal">%s</say-as>`, Escape(match))
+	}
+}
@@ -0,0 +1,33 @@
+package ssml
+
+import "testing"
+
+func TestSayAs(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		in       string
+		want     string
+	}{
+		{"dollars", "en-US", "It costs $1,250.50 now.", `It costs <say-as interpret-as="currency" language="en-US">$1,250.50</say-as> now.`},
+		{"pounds", "en-GB", "Only £5 each.", `Only <say-as interpret-as="currency" language="en-GB">£5</say-as> each.`},
+		{"currency without a language", "", "Pay $20.", `Pay <say-as interpret-as="currency" language="en-US">$20</say-as>.`},
+		{"ISO date", "en-GB", "On 2024-03-05 it rained.", `On <say-as interpret-as="date" format="yyyymmdd">2024-03-05</say-as> it rained.`},
+		{"US date", "en-US", "Due 3/5/2024.", `Due <say-as interpret-as="date" format="mdy">3/5/2024</say-as>.`},
+		{"date without a language", "", "Due 3/5/24.", `Due <say-as interpret-as="date" format="mdy">3/5/24</say-as>.`},
+		{"British date", "en-GB", "Due 5/3/2024.", `Due <say-as interpret-as="date" format="dmy">5/3/2024</say-as>.`},
+		{"ordinal", "en-US", "The 21st century.", `The <say-as interpret-as="ordinal">21</say-as> century.`},
+		{"long number", "en-US", "About 1,000,000 people.", `About <say-as interpret-as="cardinal">1,000,000</say-as> people.`},
+		{"long number without separators", "en-US", "Code 123456.", `Code <say-as interpret-as="cardinal">123456</say-as>.`},
+		{"short number left alone", "en-US", "Page 42 & 43.", "Page 42 &amp; 43."},
+		{"year left alone", "en-US", "In 1999.", "In 1999."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := Options{SayAs: true, LanguageCode: tt.language}
+			if got := o.render(tt.in); got != tt.want {
+				t.Errorf("render(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
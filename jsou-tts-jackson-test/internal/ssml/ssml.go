@@ -0,0 +1,166 @@
+package ssml
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/chunker"
+)
+
+// Pauses configures the silence inserted after each kind of block.
+type Pauses struct {
+	Paragraph time.Duration
+	Heading   time.Duration
+	Section   time.Duration
+}
+
+// DefaultPauses gives a calm narration pace: a short beat between paragraphs,
+// a longer one after headings and a clear gap between chapters.
+var DefaultPauses = Pauses{
+	Paragraph: 500 * time.Millisecond,
+	Heading:   1 * time.Second,
+	Section:   2 * time.Second,
+}
+
+// after returns the pause that follows a block of the given kind.
+func (p Pauses) after(kind BlockKind) time.Duration {
+	switch kind {
+	case Section:
+		return p.Section
+	case Heading:
+		return p.Heading
+	default:
+		return p.Paragraph
+	}
+}
+
+// Options controls how text is rendered into SSML.
+type Options struct {
+	Pauses Pauses
+	// Lexicon, if set, replaces matching words with <phoneme> or <sub> elements.
+	Lexicon *Lexicon
+	// SayAs wraps dates, currencies, ordinals and long numbers in <say-as> elements.
+	SayAs bool
+	// LanguageCode is the document's BCP-47 language, used to interpret dates and currencies.
+	LanguageCode string
+	// Marks, if set, makes BuildChunks render every sentence as an <s> element
+	// preceded by a <mark> and record the sentences, for requesting timepoints.
+	Marks *Marks
+}
+
+// DefaultOptions renders with DefaultPauses and no lexicon.
+var DefaultOptions = Options{Pauses: DefaultPauses}
+
+const (
+	speakOpen  = "<speak>"
+	speakClose = "</speak>"
+)
+
+// Build converts extracted text into an SSML document with a <p> per block and
+// <break> elements between blocks, so the narration pauses naturally.
+// The text is sanitized first, so malformed extraction output can't produce invalid SSML.
+func Build(text string, opts Options) string {
+	return BuildBlocks(Parse(Sanitize(text)), opts)
+}
+
+// BuildBlocks renders already parsed blocks into an SSML document. Block text is
+// escaped but not sanitized.
+func BuildBlocks(blocks []Block, opts Options) string {
+	var b strings.Builder
+	b.WriteString(speakOpen)
+	for i, block := range blocks {
+		b.WriteString(breakBefore(blocks, i, opts.Pauses))
+		b.WriteString(opts.paragraph(block.Text))
+	}
+	b.WriteString(speakClose)
+	return b.String()
+}
+
+// BuildChunks is like Build but returns a sequence of SSML documents of at most
+// maxBytes each. Documents are split between blocks, and blocks too large for a
+// single document are split at sentence boundaries.
+func BuildChunks(text string, opts Options, maxBytes int) []string {
+	blocks := Parse(Sanitize(text))
+	envelope := len(speakOpen) + len(speakClose)
+
+	var docs []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			docs = append(docs, speakOpen+cur.String()+speakClose)
+			cur.Reset()
+		}
+	}
+
+	for i, block := range blocks {
+		pause := breakBefore(blocks, i, opts.Pauses)
+		budget := maxBytes - envelope - len(pause) - len(opts.paragraph(""))
+		if opts.Marks != nil {
+			budget = maxBytes - envelope - len(pause) - markOverhead
+		}
+		for j, part := range opts.split(block.Text, budget) {
+			fragment := opts.paragraph(part)
+			if opts.Marks != nil {
+				fragment = "<s>" + opts.Marks.add(part) + opts.render(part) + "</s>"
+			}
+			if j == 0 {
+				fragment = pause + fragment
+			}
+			if cur.Len()+len(fragment)+envelope > maxBytes {
+				flush()
+			}
+			cur.WriteString(fragment)
+		}
+	}
+	flush()
+
+	return docs
+}
+
+// split cuts block text into pieces of at most budget rendered bytes. With marks
+// enabled every sentence becomes its own piece so it can carry a mark.
+func (o Options) split(text string, budget int) []string {
+	if o.Marks == nil {
+		return chunker.SplitFunc(text, budget, o.renderedLen)
+	}
+	var pieces []string
+	for _, sentence := range chunker.Sentences(text) {
+		pieces = append(pieces, chunker.SplitFunc(sentence, budget, o.renderedLen)...)
+	}
+	return pieces
+}
+
+// breakBefore returns the <break> element that precedes blocks[i], if any.
+func breakBefore(blocks []Block, i int, pauses Pauses) string {
+	if i == 0 {
+		return ""
+	}
+	pause := pauses.after(blocks[i-1].Kind)
+	if pause <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(`<break time="%dms"/>`, pause.Milliseconds())
+}
+
+// paragraph renders text and wraps it in a <p> element.
+func (o Options) paragraph(text string) string {
+	return "<p>" + o.render(text) + "</p>"
+}
+
+// render escapes text and applies the inline transformations enabled in o.
+func (o Options) render(text string) string {
+	plain := Escape
+	if o.Lexicon != nil {
+		plain = o.Lexicon.apply
+	}
+	if o.SayAs {
+		return o.applySayAs(text, plain)
+	}
+	return plain(text)
+}
+
+// renderedLen returns the length of text once rendered.
+func (o Options) renderedLen(text string) int {
+	return len(o.render(text))
+}
@@ -0,0 +1,170 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ValidationError describes why an SSML document would be rejected, with enough
+// context to find the problem in the generated SSML.
+type ValidationError struct {
+	Offset  int64  // Byte offset in the document where the problem was found.
+	Context string // The document around Offset.
+	Reason  string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid SSML at byte %d: %s (near %q)", e.Offset, e.Reason, e.Context)
+}
+
+// allowedAttributes lists the elements the TTS APIs accept and, for each, the
+// attributes it may carry.
+var allowedAttributes = map[string][]string{
+	"speak":    {"lang"},
+	"p":        nil,
+	"s":        nil,
+	"break":    {"time", "strength"},
+	"say-as":   {"interpret-as", "format", "detail", "language"},
+	"phoneme":  {"alphabet", "ph"},
+	"sub":      {"alias"},
+	"mark":     {"name"},
+	"prosody":  {"rate", "pitch", "volume"},
+	"emphasis": {"level"},
+	"lang":     {"lang"},
+	"voice":    {"name", "gender", "variant", "language"},
+	"audio":    {"src", "clipBegin", "clipEnd", "speed", "repeatCount", "repeatDur", "soundLevel"},
+	"desc":     nil,
+	"par":      nil,
+	"seq":      nil,
+	"media":    {"xml:id", "begin", "end", "repeatCount", "repeatDur", "soundLevel", "fadeInDur", "fadeOutDur"},
+}
+
+// Attribute values the APIs check strictly.
+var (
+	breakTimePattern = regexp.MustCompile(`^\d+(\.\d+)?(ms|s)$`)
+	breakStrengths   = []string{"none", "x-weak", "weak", "medium", "strong", "x-strong"}
+	interpretAs      = []string{"currency", "telephone", "verbatim", "spell-out", "date", "characters", "cardinal", "ordinal", "fraction", "expletive", "bleep", "unit", "time"}
+	phonemeAlphabets = []string{"ipa", "x-sampa"}
+)
+
+// Validate checks an SSML document before it's sent: that it's well-formed XML
+// with a single <speak> root, uses only supported elements and attributes with
+// valid values, and is at most maxBytes long (0 skips the size check). The
+// error is a *ValidationError pointing at the first problem.
+func Validate(doc string, maxBytes int) error {
+	if maxBytes > 0 && len(doc) > maxBytes {
+		return &ValidationError{Offset: int64(maxBytes), Context: around(doc, int64(maxBytes)), Reason: fmt.Sprintf("document is %d bytes, over the %d byte limit", len(doc), maxBytes)}
+	}
+
+	d := xml.NewDecoder(strings.NewReader(doc))
+	depth := 0
+	roots := 0
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		fail := func(format string, args ...any) error {
+			return &ValidationError{Offset: offset, Context: around(doc, offset), Reason: fmt.Sprintf(format, args...)}
+		}
+		if err != nil {
+			return fail("malformed XML: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			if depth == 0 {
+				roots++
+				if name != "speak" || roots > 1 {
+					return fail("the document must be a single <speak> element, found <%s>", name)
+				}
+			}
+			if err := checkElement(t); err != nil {
+				return fail("%v", err)
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && strings.TrimSpace(string(t)) != "" {
+				return fail("text outside <speak>")
+			}
+		}
+	}
+	if roots == 0 {
+		return &ValidationError{Context: around(doc, 0), Reason: "no <speak> element"}
+	}
+	return nil
+}
+
+// checkElement checks an element's name and attributes.
+func checkElement(t xml.StartElement) error {
+	name := t.Name.Local
+	allowed, ok := allowedAttributes[name]
+	if !ok {
+		return fmt.Errorf("unsupported element <%s>", name)
+	}
+	attrs := map[string]string{}
+	for _, a := range t.Attr {
+		key := a.Name.Local
+		if a.Name.Space == "xml" || a.Name.Space == "http://www.w3.org/XML/1998/namespace" {
+			key = "xml:" + key
+		}
+		if a.Name.Space == "xmlns" || key == "xmlns" {
+			continue
+		}
+		if !slices.Contains(allowed, key) && !slices.Contains(allowed, a.Name.Local) {
+			return fmt.Errorf("unsupported attribute %s on <%s>", key, name)
+		}
+		attrs[a.Name.Local] = a.Value
+	}
+
+	switch name {
+	case "break":
+		if v, ok := attrs["time"]; ok && !breakTimePattern.MatchString(v) {
+			return fmt.Errorf(`<break time=%q> must be a duration such as "500ms" or "2s"`, v)
+		}
+		if v, ok := attrs["strength"]; ok && !slices.Contains(breakStrengths, v) {
+			return fmt.Errorf("<break strength=%q> must be one of %s", v, strings.Join(breakStrengths, ", "))
+		}
+	case "say-as":
+		v, ok := attrs["interpret-as"]
+		if !ok {
+			return errors.New("<say-as> needs an interpret-as attribute")
+		}
+		if !slices.Contains(interpretAs, v) {
+			return fmt.Errorf("<say-as interpret-as=%q> must be one of %s", v, strings.Join(interpretAs, ", "))
+		}
+	case "phoneme":
+		if !slices.Contains(phonemeAlphabets, attrs["alphabet"]) {
+			return fmt.Errorf("<phoneme alphabet=%q> must be ipa or x-sampa", attrs["alphabet"])
+		}
+		if attrs["ph"] == "" {
+			return errors.New("<phoneme> needs a ph attribute")
+		}
+	case "sub":
+		if attrs["alias"] == "" {
+			return errors.New("<sub> needs an alias attribute")
+		}
+	case "mark":
+		if attrs["name"] == "" {
+			return errors.New("<mark> needs a name attribute")
+		}
+	}
+	return nil
+}
+
+// around returns up to 40 bytes of doc on either side of offset.
+func around(doc string, offset int64) string {
+	const span = 40
+	start := max(0, int(offset)-span)
+	end := min(len(doc), int(offset)+span)
+	return doc[start:end]
+}
@@ -0,0 +1,635 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// azureBlobAPIVersion is the Blob service REST API version requests are made with.
+const azureBlobAPIVersion = "2023-11-03"
+
+// Server errors of idempotent Blob requests are retried up to
+// maxAzureBlobAttempts times, waiting azureBlobRetryDelay times the attempt.
+const (
+	maxAzureBlobAttempts = 3
+	azureBlobRetryDelay  = time.Second
+)
+
+// AzureBlob implements Storage with Azure Blob Storage over its REST API, for
+// deployments on Azure. Buckets are containers and objects are block blobs.
+// Requests are authorized with a SAS token, which needs the read, add, create,
+// write, delete and list permissions on the containers used. Azure has no
+// generations, so an object's generation is derived from its ETag (see
+// ETagGeneration) and conditional writes use If-Match and If-None-Match.
+//
+// Azure metadata names must be C# identifiers, so the hyphens of the pipeline's
+// metadata keys are stored as underscores: set x-ms-meta-tts_voice on a blob
+// for the tts-voice setting.
+type AzureBlob struct {
+	accountURL string // e.g. "https://myaccount.blob.core.windows.net"
+	account    string
+	sas        url.Values
+	// accountKey signs the read-only SAS URLs returned by SignedURL. Without it,
+	// SignedURL fails.
+	accountKey []byte
+	http       *http.Client
+}
+
+// NewAzureBlob returns an AzureBlob store for the storage account at
+// accountURL, authorized with sasToken (with or without the leading "?").
+// accountKey, the base64 account key, is only needed for SignedURL and may be "".
+func NewAzureBlob(accountURL, sasToken, accountKey string) (*AzureBlob, error) {
+	u, err := url.Parse(accountURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Azure storage account URL %q: want https://ACCOUNT.blob.core.windows.net", accountURL)
+	}
+	sas, err := url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(sasToken), "?"))
+	if err != nil || sas.Get("sig") == "" {
+		return nil, fmt.Errorf("invalid Azure SAS token: it needs at least a sig parameter")
+	}
+	var key []byte
+	if accountKey != "" {
+		if key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(accountKey)); err != nil {
+			return nil, fmt.Errorf("invalid Azure storage account key: %w", err)
+		}
+	}
+	account, _, _ := strings.Cut(u.Host, ".")
+	return &AzureBlob{
+		accountURL: "https://" + u.Host,
+		account:    account,
+		sas:        sas,
+		accountKey: key,
+		http:       &http.Client{},
+	}, nil
+}
+
+// blobError is a failed Blob service request.
+type blobError struct {
+	StatusCode int
+	Code       string // The x-ms-error-code header, e.g. "BlobAlreadyExists".
+}
+
+func (e *blobError) Error() string {
+	return fmt.Sprintf("HTTP %d %s", e.StatusCode, e.Code)
+}
+
+// blobStatus returns the HTTP status of a failed Blob request, or 0.
+func blobStatus(err error) int {
+	var blobErr *blobError
+	if errors.As(err, &blobErr) {
+		return blobErr.StatusCode
+	}
+	return 0
+}
+
+// blobURL returns the URL of a blob, or of a container if objectName is "",
+// with the SAS token and query appended.
+func (a *AzureBlob) blobURL(bucketName, objectName string, query url.Values) string {
+	u := a.accountURL + "/" + url.PathEscape(bucketName)
+	if objectName != "" {
+		u += "/" + (&url.URL{Path: objectName}).EscapedPath()
+	}
+	q := maps.Clone(a.sas)
+	maps.Copy(q, query)
+	return u + "?" + q.Encode()
+}
+
+// do sends a request to the Blob service and returns the response, or a
+// *blobError for a non-2xx status. Server errors are retried when the body can
+// be sent again. The caller closes the response body.
+func (a *AzureBlob) do(ctx context.Context, method, u string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if sr, ok := body.(*io.SectionReader); ok {
+		// Put Blob needs a Content-Length, which a section reader has; it can also be re-read.
+		req.ContentLength = sr.Size()
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(io.NewSectionReader(sr, 0, sr.Size())), nil }
+	}
+	maps.Copy(req.Header, header)
+	req.Header.Set("x-ms-version", azureBlobAPIVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+
+	for attempt := 1; ; attempt++ {
+		resp, err := a.http.Do(req)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			err = &blobError{StatusCode: resp.StatusCode, Code: resp.Header.Get("x-ms-error-code")}
+		}
+		retryable := blobStatus(err) >= 500 || blobStatus(err) == 0 && ctx.Err() == nil
+		if !retryable || attempt >= maxAzureBlobAttempts || req.Body != nil && req.GetBody == nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt) * azureBlobRetryDelay):
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// blobMetadataHeaders adds the x-ms-meta- headers for metadata to header.
+func blobMetadataHeaders(header http.Header, metadata map[string]string) {
+	for k, v := range metadata {
+		header.Set("x-ms-meta-"+strings.ReplaceAll(k, "-", "_"), v)
+	}
+}
+
+// blobMetadata returns the custom metadata in a response's headers.
+func blobMetadata(header http.Header) map[string]string {
+	metadata := map[string]string{}
+	for k := range header {
+		if name, ok := strings.CutPrefix(strings.ToLower(k), "x-ms-meta-"); ok {
+			metadata[strings.ReplaceAll(name, "_", "-")] = header.Get(k)
+		}
+	}
+	return metadata
+}
+
+// blobHeaders sets the Blob service headers for the non-empty fields of headers.
+func blobHeaders(header http.Header, headers ObjectHeaders) {
+	for name, v := range map[string]string{
+		"x-ms-blob-cache-control":       headers.CacheControl,
+		"x-ms-blob-content-disposition": headers.ContentDisposition,
+		"x-ms-blob-content-language":    headers.ContentLanguage,
+	} {
+		if v != "" {
+			header.Set(name, v)
+		}
+	}
+}
+
+// put writes a block blob from body, whose MD5 sum Azure checks, served with
+// headers and with the given conditions, and returns its generation.
+func (a *AzureBlob) put(ctx context.Context, bucketName, objectName string, body io.Reader, sum []byte, contentType string, headers ObjectHeaders, condition http.Header) (int64, error) {
+	header := http.Header{}
+	maps.Copy(header, condition)
+	header.Set("x-ms-blob-type", "BlockBlob")
+	header.Set("x-ms-blob-content-type", contentType)
+	blobHeaders(header, headers)
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum))
+	header.Set("x-ms-blob-content-md5", base64.StdEncoding.EncodeToString(sum))
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(bucketName, objectName, nil), header, body)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return ETagGeneration(resp.Header.Get("ETag")), nil
+}
+
+// putBytes writes content to a blob; see put.
+func (a *AzureBlob) putBytes(ctx context.Context, bucketName, objectName string, content []byte, contentType string, headers ObjectHeaders, condition http.Header) (int64, error) {
+	sum := md5.Sum(content)
+	return a.put(ctx, bucketName, objectName, bytes.NewReader(content), sum[:], contentType, headers, condition)
+}
+
+// lostBlobRace reports whether a conditional Blob request failed because the
+// blob was changed or already existed.
+func lostBlobRace(err error) bool {
+	status := blobStatus(err)
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
+
+// UploadFile implements Storage.
+func (a *AzureBlob) UploadFile(ctx context.Context, bucketName, objectName string, content []byte, contentType string, headers ...ObjectHeaders) error {
+	if _, err := a.putBytes(ctx, bucketName, objectName, content, contentType, uploadHeaders(headers), nil); err != nil {
+		return fmt.Errorf("failed to write Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	log.Printf("Uploaded to %s/%s/%s", a.accountURL, bucketName, objectName)
+	return nil
+}
+
+// UploadReader implements Storage. Put Blob needs the content's length and
+// MD5 up front, so the content is spooled to a temporary file first.
+func (a *AzureBlob) UploadReader(ctx context.Context, bucketName, objectName string, r io.Reader, contentType string) error {
+	f, err := os.CreateTemp("", "blob-upload-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	h := md5.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return fmt.Errorf("failed to spool Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	if _, err := a.put(ctx, bucketName, objectName, io.NewSectionReader(f, 0, size), h.Sum(nil), contentType, ObjectHeaders{}, nil); err != nil {
+		return fmt.Errorf("failed to write Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	log.Printf("Uploaded to %s/%s/%s", a.accountURL, bucketName, objectName)
+	return nil
+}
+
+// get reads a whole blob, checking its MD5 when it has one. It returns nil
+// content and false, without an error, if the blob doesn't exist.
+func (a *AzureBlob) get(ctx context.Context, bucketName, objectName string) ([]byte, http.Header, bool, error) {
+	resp, err := a.do(ctx, http.MethodGet, a.blobURL(bucketName, objectName, nil), nil, nil)
+	if blobStatus(err) == http.StatusNotFound {
+		return nil, nil, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to open Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to read Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	if want := resp.Header.Get("Content-MD5"); want != "" {
+		if got := md5.Sum(data); base64.StdEncoding.EncodeToString(got[:]) != want {
+			return nil, nil, false, fmt.Errorf("%w: Azure blob %s/%s has MD5 %s, got %x", ErrChecksumMismatch, bucketName, objectName, want, got)
+		}
+	}
+	return data, resp.Header, true, nil
+}
+
+// ReadObject implements Storage.
+func (a *AzureBlob) ReadObject(ctx context.Context, bucketName, objectName string) ([]byte, error) {
+	data, _, exists, err := a.get(ctx, bucketName, objectName)
+	if err == nil && !exists {
+		err = fmt.Errorf("Azure blob %s/%s doesn't exist", bucketName, objectName)
+	}
+	return data, err
+}
+
+// OpenObject implements Storage.
+func (a *AzureBlob) OpenObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, int64, error) {
+	resp, err := a.do(ctx, http.MethodGet, a.blobURL(bucketName, objectName, nil), nil, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// head returns the properties of a blob. It reports false, without an error,
+// if the blob doesn't exist.
+func (a *AzureBlob) head(ctx context.Context, bucketName, objectName string) (http.Header, bool, error) {
+	resp, err := a.do(ctx, http.MethodHead, a.blobURL(bucketName, objectName, nil), nil, nil)
+	if blobStatus(err) == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get properties of Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	resp.Body.Close()
+	return resp.Header, true, nil
+}
+
+// OpenReaderAt implements Storage with range reads, each made only if the blob
+// still has the ETag it had when opened.
+func (a *AzureBlob) OpenReaderAt(ctx context.Context, bucketName, objectName string) (*io.SectionReader, error) {
+	props, exists, err := a.head(ctx, bucketName, objectName)
+	if err == nil && !exists {
+		err = fmt.Errorf("Azure blob %s/%s doesn't exist", bucketName, objectName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseInt(props.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Azure blob %s/%s has no valid Content-Length: %w", bucketName, objectName, err)
+	}
+	etag := props.Get("ETag")
+	u := a.blobURL(bucketName, objectName, nil)
+	r := &objectReaderAt{
+		ctx:  ctx,
+		name: fmt.Sprintf("%s/%s/%s", a.accountURL, bucketName, objectName),
+		size: size,
+		readRange: func(ctx context.Context, offset, length int64, w io.Writer) (int64, error) {
+			header := http.Header{}
+			header.Set("x-ms-range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+			header.Set("If-Match", etag)
+			resp, err := a.do(ctx, http.MethodGet, u, header, nil)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read Azure blob %s/%s at %d: %w", bucketName, objectName, offset, err)
+			}
+			defer resp.Body.Close()
+			n, err := io.Copy(w, resp.Body)
+			if err == nil && n < length {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		},
+		blocks: map[int64][]byte{},
+	}
+	log.Printf("Opened %s (%d bytes) for reading in place.", r.name, r.size)
+	return io.NewSectionReader(r, 0, r.size), nil
+}
+
+// blobList is the response of List Blobs.
+type blobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			CreationTime  string `xml:"Creation-Time"`
+			ETag          string `xml:"Etag"`
+			ContentLength int64  `xml:"Content-Length"`
+			ContentType   string `xml:"Content-Type"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// ListObjectsWithPrefix implements Storage. Like a GCS listing without a
+// delimiter, it returns every blob whose name starts with prefix, however
+// deep, following continuation markers until the listing is complete.
+func (a *AzureBlob) ListObjectsWithPrefix(ctx context.Context, bucketName, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		resp, err := a.do(ctx, http.MethodGet, a.blobURL(bucketName, "", query), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error listing objects with prefix %s: %w", prefix, err)
+		}
+		var page blobList
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error listing objects with prefix %s: %w", prefix, err)
+		}
+		for _, b := range page.Blobs {
+			created, _ := time.Parse(http.TimeFormat, b.Properties.CreationTime)
+			objects = append(objects, ObjectInfo{
+				Name:        b.Name,
+				Size:        b.Properties.ContentLength,
+				Created:     created,
+				Generation:  ETagGeneration(b.Properties.ETag),
+				ContentType: b.Properties.ContentType,
+			})
+		}
+		if page.NextMarker == "" {
+			return objects, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+// ObjectMetadata implements Storage.
+func (a *AzureBlob) ObjectMetadata(ctx context.Context, bucketName, objectName string) (map[string]string, bool, error) {
+	props, exists, err := a.head(ctx, bucketName, objectName)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	return blobMetadata(props), true, nil
+}
+
+// UpdateObjectMetadata implements Storage. Set Blob Metadata replaces all of a
+// blob's metadata, so it's merged with the current metadata and only applied if
+// the blob is unchanged since it was read.
+func (a *AzureBlob) UpdateObjectMetadata(ctx context.Context, bucketName, objectName string, metadata map[string]string) error {
+	props, exists, err := a.head(ctx, bucketName, objectName)
+	if err == nil && !exists {
+		err = fmt.Errorf("Azure blob %s/%s doesn't exist", bucketName, objectName)
+	}
+	if err != nil {
+		return err
+	}
+	merged := blobMetadata(props)
+	maps.Copy(merged, metadata)
+	header := http.Header{}
+	header.Set("If-Match", props.Get("ETag"))
+	blobMetadataHeaders(header, merged)
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(bucketName, objectName, url.Values{"comp": {"metadata"}}), header, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update metadata of Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SetObjectHeaders implements Storage. Set Blob Properties clears the
+// properties it isn't given, so the current ones are sent along, and only
+// applied if the blob is unchanged since they were read.
+func (a *AzureBlob) SetObjectHeaders(ctx context.Context, bucketName, objectName string, headers ObjectHeaders) error {
+	if headers == (ObjectHeaders{}) {
+		return nil
+	}
+	props, exists, err := a.head(ctx, bucketName, objectName)
+	if err == nil && !exists {
+		err = fmt.Errorf("Azure blob %s/%s doesn't exist", bucketName, objectName)
+	}
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("If-Match", props.Get("ETag"))
+	for _, name := range []string{"Content-Type", "Content-MD5", "Content-Encoding", "Content-Language", "Content-Disposition", "Cache-Control"} {
+		if v := props.Get(name); v != "" {
+			header.Set("x-ms-blob-"+strings.ToLower(name), v)
+		}
+	}
+	blobHeaders(header, headers)
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(bucketName, objectName, url.Values{"comp": {"properties"}}), header, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update headers of Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// copy copies a blob whose ETag is etag within the account and waits for the
+// copy to finish. metadata replaces the copy's metadata unless it's nil.
+func (a *AzureBlob) copy(ctx context.Context, srcBucket, srcObject, etag, dstBucket, dstObject string, metadata map[string]string) error {
+	header := http.Header{}
+	header.Set("x-ms-copy-source", a.blobURL(srcBucket, srcObject, nil))
+	header.Set("x-ms-source-if-match", etag)
+	blobMetadataHeaders(header, metadata)
+	resp, err := a.do(ctx, http.MethodPut, a.blobURL(dstBucket, dstObject, nil), header, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	// Copies within an account usually finish at once; larger ones run in the background.
+	status := resp.Header.Get("x-ms-copy-status")
+	for status == "pending" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+		props, _, err := a.head(ctx, dstBucket, dstObject)
+		if err != nil {
+			return err
+		}
+		status = props.Get("x-ms-copy-status")
+	}
+	if status != "success" {
+		return fmt.Errorf("copy to Azure blob %s/%s ended with status %q", dstBucket, dstObject, status)
+	}
+	return nil
+}
+
+// CopyObject implements Storage.
+func (a *AzureBlob) CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) (bool, error) {
+	props, exists, err := a.head(ctx, srcBucket, srcObject)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := a.copy(ctx, srcBucket, srcObject, props.Get("ETag"), dstBucket, dstObject, nil); err != nil {
+		return false, fmt.Errorf("failed to copy Azure blob %s/%s to %s/%s: %w", srcBucket, srcObject, dstBucket, dstObject, err)
+	}
+	log.Printf("Copied %s/%s/%s to %s/%s", a.accountURL, srcBucket, srcObject, dstBucket, dstObject)
+	return true, nil
+}
+
+// MoveObject implements Storage. Only the copied version is deleted, so a blob
+// rewritten in the meantime stays in place.
+func (a *AzureBlob) MoveObject(ctx context.Context, bucketName, srcObject, dstObject string, metadata map[string]string) error {
+	props, exists, err := a.head(ctx, bucketName, srcObject)
+	if err == nil && !exists {
+		err = fmt.Errorf("Azure blob %s/%s doesn't exist", bucketName, srcObject)
+	}
+	if err != nil {
+		return err
+	}
+	merged := blobMetadata(props)
+	maps.Copy(merged, metadata)
+	if err := a.copy(ctx, bucketName, srcObject, props.Get("ETag"), bucketName, dstObject, merged); err != nil {
+		return fmt.Errorf("failed to copy Azure blob %s/%s to %s: %w", bucketName, srcObject, dstObject, err)
+	}
+	if _, err := a.deleteIfMatch(ctx, bucketName, srcObject, props.Get("ETag")); err != nil {
+		return err
+	}
+	log.Printf("Moved %s/%s/%s to %s", a.accountURL, bucketName, srcObject, dstObject)
+	return nil
+}
+
+// deleteIfMatch deletes a blob if it has the given ETag ("": any). It reports
+// whether it did.
+func (a *AzureBlob) deleteIfMatch(ctx context.Context, bucketName, objectName, etag string) (bool, error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+	resp, err := a.do(ctx, http.MethodDelete, a.blobURL(bucketName, objectName, nil), header, nil)
+	switch {
+	case err == nil:
+		resp.Body.Close()
+		return true, nil
+	case lostBlobRace(err), blobStatus(err) == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to delete Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+}
+
+// DeleteObject implements Storage.
+func (a *AzureBlob) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	if _, err := a.deleteIfMatch(ctx, bucketName, objectName, ""); err != nil {
+		return err
+	}
+	log.Printf("Deleted %s/%s/%s", a.accountURL, bucketName, objectName)
+	return nil
+}
+
+// CreateObjectIfAbsent implements Storage.
+func (a *AzureBlob) CreateObjectIfAbsent(ctx context.Context, bucketName, objectName string, content []byte, contentType string) (int64, error) {
+	generation, err := a.putBytes(ctx, bucketName, objectName, content, contentType, ObjectHeaders{}, http.Header{"If-None-Match": {"*"}})
+	if lostBlobRace(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	return generation, nil
+}
+
+// ReadObjectGeneration implements Storage.
+func (a *AzureBlob) ReadObjectGeneration(ctx context.Context, bucketName, objectName string) ([]byte, int64, error) {
+	data, header, exists, err := a.get(ctx, bucketName, objectName)
+	if err != nil || !exists {
+		return nil, 0, err
+	}
+	return data, ETagGeneration(header.Get("ETag")), nil
+}
+
+// UpdateObjectIfGeneration implements Storage.
+func (a *AzureBlob) UpdateObjectIfGeneration(ctx context.Context, bucketName, objectName string, content []byte, contentType string, generation int64) (int64, error) {
+	condition := http.Header{"If-None-Match": {"*"}}
+	if generation != 0 {
+		props, exists, err := a.head(ctx, bucketName, objectName)
+		if err != nil || !exists || ETagGeneration(props.Get("ETag")) != generation {
+			return 0, err
+		}
+		condition = http.Header{"If-Match": {props.Get("ETag")}}
+	}
+	written, err := a.putBytes(ctx, bucketName, objectName, content, contentType, ObjectHeaders{}, condition)
+	if lostBlobRace(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write Azure blob %s/%s: %w", bucketName, objectName, err)
+	}
+	return written, nil
+}
+
+// DeleteObjectGeneration implements Storage.
+func (a *AzureBlob) DeleteObjectGeneration(ctx context.Context, bucketName, objectName string, generation int64) (bool, error) {
+	props, exists, err := a.head(ctx, bucketName, objectName)
+	if err != nil || !exists || ETagGeneration(props.Get("ETag")) != generation {
+		return false, err
+	}
+	return a.deleteIfMatch(ctx, bucketName, objectName, props.Get("ETag"))
+}
+
+// SignedURL implements Storage with a read-only service SAS for the blob,
+// signed with the account key.
+func (a *AzureBlob) SignedURL(bucketName, objectName string, expiry time.Duration) (string, error) {
+	if a.accountKey == nil {
+		return "", fmt.Errorf("signing a URL for Azure blob %s/%s needs the storage account key", bucketName, objectName)
+	}
+	expires := time.Now().UTC().Add(expiry).Format(time.RFC3339)
+	resource := fmt.Sprintf("/blob/%s/%s/%s", a.account, bucketName, objectName)
+	// The string to sign of a service SAS: permissions, start, expiry, resource,
+	// identifier, IP, protocol, version, resource type, snapshot time,
+	// encryption scope and the five response header overrides.
+	toSign := strings.Join([]string{"r", "", expires, resource, "", "", "https", azureBlobAPIVersion, "b", "", "", "", "", "", "", ""}, "\n")
+	mac := hmac.New(sha256.New, a.accountKey)
+	mac.Write([]byte(toSign))
+	q := url.Values{
+		"sv":  {azureBlobAPIVersion},
+		"sr":  {"b"},
+		"sp":  {"r"},
+		"se":  {expires},
+		"spr": {"https"},
+		"sig": {base64.StdEncoding.EncodeToString(mac.Sum(nil))},
+	}
+	return a.accountURL + "/" + url.PathEscape(bucketName) + "/" + (&url.URL{Path: objectName}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+// BucketKMSKey implements Storage. Customer-managed keys of an Azure account
+// aren't visible through a SAS, so it reports none.
+func (a *AzureBlob) BucketKMSKey(ctx context.Context, bucketName string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"cloud.google.com/go/storage"
+)
+
+// ErrChecksumMismatch is returned, wrapped, when data read from or written to
+// GCS doesn't match the checksums GCS holds for the object.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// crc32cTable is the Castagnoli table GCS computes CRC32C checksums with.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksums computes the CRC32C and MD5 of everything written to it.
+type checksums struct {
+	crc32c hash.Hash32
+	md5    hash.Hash
+	w      io.Writer
+}
+
+func newChecksums() *checksums {
+	c := &checksums{crc32c: crc32.New(crc32cTable), md5: md5.New()}
+	c.w = io.MultiWriter(c.crc32c, c.md5)
+	return c
+}
+
+func (c *checksums) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// verify compares the checksums with those of the object. The MD5 is only
+// compared when the object has one; composite objects only have a CRC32C.
+func (c *checksums) verify(attrs *storage.ObjectAttrs) error {
+	if got := c.crc32c.Sum32(); got != attrs.CRC32C {
+		return fmt.Errorf("%w: gs://%s/%s has CRC32C %08x, got %08x", ErrChecksumMismatch, attrs.Bucket, attrs.Name, attrs.CRC32C, got)
+	}
+	if len(attrs.MD5) > 0 && !bytes.Equal(c.md5.Sum(nil), attrs.MD5) {
+		return fmt.Errorf("%w: gs://%s/%s has MD5 %x, got %x", ErrChecksumMismatch, attrs.Bucket, attrs.Name, attrs.MD5, c.md5.Sum(nil))
+	}
+	return nil
+}
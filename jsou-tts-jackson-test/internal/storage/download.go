@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Reads of object ranges are retried up to maxReadAttempts times on transient
+// errors, resuming after the last byte received, with a random wait of up to
+// initialReadBackoff, doubling with every attempt.
+const (
+	maxReadAttempts    = 5
+	initialReadBackoff = 500 * time.Millisecond
+)
+
+// copyRange copies length bytes of the given generation of obj starting at
+// offset to w, or everything from offset on if length is negative. A transient
+// error mid-read, such as a dropped connection halfway through a 500MB PDF,
+// resumes from the last byte written instead of starting over, and can't
+// continue into a different version of the object.
+func copyRange(ctx context.Context, obj *storage.ObjectHandle, generation, offset, length int64, w io.Writer) (int64, error) {
+	obj = obj.Generation(generation)
+	var written int64
+	backoff := initialReadBackoff
+	for attempt := 1; ; attempt++ {
+		remaining := int64(-1)
+		if length >= 0 {
+			remaining = length - written
+		}
+		n, err := copyOnce(ctx, obj, generation, offset+written, remaining, w)
+		written += n
+		if err == nil {
+			return written, nil
+		}
+		if !isTransientReadError(err) || attempt >= maxReadAttempts {
+			return written, fmt.Errorf("failed to read gs://%s/%s at %d after %d attempt(s): %w", obj.BucketName(), obj.ObjectName(), offset+written, attempt, err)
+		}
+
+		wait := time.Duration(rand.Int64N(int64(backoff) + 1))
+		log.Printf("Transient error reading gs://%s/%s at %d (attempt %d/%d): %v. Resuming in %v...", obj.BucketName(), obj.ObjectName(), offset+written, attempt, maxReadAttempts, err, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return written, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// copyOnce makes a single range read of obj, the given generation, into w.
+func copyOnce(ctx context.Context, obj *storage.ObjectHandle, generation, offset, length int64, w io.Writer) (int64, error) {
+	if length == 0 {
+		return 0, nil
+	}
+	rc, err := obj.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	if rc.Attrs.Generation != generation {
+		return 0, fmt.Errorf("gs://%s/%s served generation %d rather than %d", obj.BucketName(), obj.ObjectName(), rc.Attrs.Generation, generation)
+	}
+	n, err := io.Copy(w, rc)
+	if err == nil && length > 0 && n < length {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// isTransientReadError reports whether a failed read is worth resuming: a
+// connection cut short, or an error the storage client itself would retry.
+func isTransientReadError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || storage.ShouldRetry(err)
+}
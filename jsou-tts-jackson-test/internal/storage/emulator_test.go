@@ -0,0 +1,332 @@
+package storage
+
+// These tests run the Cloud Storage operations of Client against an emulator
+// such as fake-gcs-server, and are skipped unless STORAGE_EMULATOR_HOST is set:
+//
+//	docker run -d -p 4443:4443 fsouza/fake-gcs-server -scheme http
+//	STORAGE_EMULATOR_HOST=localhost:4443 go test ./internal/storage
+//
+// They work in the bucket STORAGE_TEST_BUCKET, by default storage-test, which
+// is created if it doesn't exist, below a fresh prefix for each test that is
+// removed afterwards.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// testContent is what the tests write: big enough for several blocks of a
+// ranged reader, and different at every offset.
+var testContent = bytes.Repeat([]byte("0123456789abcdefghijklmnopqrstuvwxyz"), 64<<10)
+
+// emulatorClient returns a client of the emulator, the test bucket and a fresh
+// prefix in it, or skips the test without an emulator.
+func emulatorClient(t *testing.T) (*Client, string, string) {
+	t.Helper()
+	host := os.Getenv("STORAGE_EMULATOR_HOST")
+	if host == "" {
+		t.Skip("STORAGE_EMULATOR_HOST isn't set")
+	}
+	bucket := os.Getenv("STORAGE_TEST_BUCKET")
+	if bucket == "" {
+		bucket = "storage-test"
+	}
+	ctx := context.Background()
+	if err := createTestBucket(ctx, bucket); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetEmulatorHost(host)
+	prefix := fmt.Sprintf("%s-%d/", t.Name(), time.Now().UnixNano())
+	t.Cleanup(func() {
+		defer client.Close()
+		objects, err := client.ListObjectsWithPrefix(ctx, bucket, prefix)
+		if err != nil {
+			t.Logf("failed to clean up %s: %v", prefix, err)
+			return
+		}
+		for _, obj := range objects {
+			client.DeleteObject(ctx, bucket, obj.Name)
+		}
+	})
+	return client, bucket, prefix
+}
+
+// createTestBucket creates a bucket unless it exists.
+func createTestBucket(ctx context.Context, bucket string) error {
+	client, err := gcs.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create storage client: %w", err)
+	}
+	defer client.Close()
+	err = client.Bucket(bucket).Create(ctx, "test", nil)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+	}
+	return nil
+}
+
+func TestEmulatorUploadAndRead(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	if err := c.UploadFile(ctx, bucket, prefix+"a.bin", testContent, "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ReadObject(ctx, bucket, prefix+"a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, testContent) {
+		t.Errorf("read %d bytes back, want the %d written", len(got), len(testContent))
+	}
+}
+
+func TestEmulatorStream(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	if err := c.UploadReader(ctx, bucket, prefix+"b.bin", bytes.NewReader(testContent), "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	rc, size, err := c.OpenObject(ctx, bucket, prefix+"b.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if size != int64(len(testContent)) {
+		t.Errorf("size %d, want %d", size, len(testContent))
+	}
+}
+
+func TestEmulatorRangedReads(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	if err := c.UploadFile(ctx, bucket, prefix+"a.bin", testContent, "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.OpenReaderAt(ctx, bucket, prefix+"a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int64{0, 1 << 20, int64(len(testContent)) - 10} {
+		got := make([]byte, 10)
+		if _, err := r.ReadAt(got, offset); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if want := testContent[offset : offset+10]; !bytes.Equal(got, want) {
+			t.Errorf("at %d read %q, want %q", offset, got, want)
+		}
+	}
+}
+
+func TestEmulatorRangeReader(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	if err := c.UploadFile(ctx, bucket, prefix+"a.bin", testContent, "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.OpenRangeReader(ctx, bucket, prefix+"a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, offset := range []int64{1 << 20, 0, int64(len(testContent)) - 10} {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, 10)
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatal(err)
+		}
+		if want := testContent[offset : offset+10]; !bytes.Equal(got, want) {
+			t.Errorf("at %d read %q, want %q", offset, got, want)
+		}
+	}
+	if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("read at the end: %d bytes, %v, want 0 bytes, %v", n, err, io.EOF)
+	}
+}
+
+func TestEmulatorList(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	for _, name := range []string{"b.bin", "a.bin"} {
+		if err := c.UploadFile(ctx, bucket, prefix+name, testContent, "application/octet-stream"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objects, err := c.ListObjectsWithPrefix(ctx, bucket, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, obj := range objects {
+		names = append(names, strings.TrimPrefix(obj.Name, prefix))
+		if obj.Size != int64(len(testContent)) || obj.Generation == 0 {
+			t.Errorf("%s has size %d and generation %d", obj.Name, obj.Size, obj.Generation)
+		}
+	}
+	if !slices.Equal(names, []string{"a.bin", "b.bin"}) {
+		t.Errorf("listed %q, want a.bin and b.bin", names)
+	}
+}
+
+func TestEmulatorMetadata(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	if err := c.UploadFile(ctx, bucket, prefix+"a.bin", testContent, "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateObjectMetadata(ctx, bucket, prefix+"a.bin", map[string]string{"tts-voice": "en-US-Wavenet-D"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateObjectMetadata(ctx, bucket, prefix+"a.bin", map[string]string{"tts-speaking-rate": "1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	metadata, exists, err := c.ObjectMetadata(ctx, bucket, prefix+"a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists || metadata["tts-voice"] != "en-US-Wavenet-D" || metadata["tts-speaking-rate"] != "1.1" {
+		t.Errorf("metadata %v, want both keys set", metadata)
+	}
+	if _, exists, err := c.ObjectMetadata(ctx, bucket, prefix+"missing.bin"); err != nil || exists {
+		t.Errorf("missing object reported as existing (%v)", err)
+	}
+}
+
+func TestEmulatorConditionalWrites(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	name := prefix + "record.json"
+	generation, err := c.CreateObjectIfAbsent(ctx, bucket, name, []byte(`{"n":1}`), "application/json")
+	if err != nil || generation == 0 {
+		t.Fatalf("first create got generation %d (%v)", generation, err)
+	}
+	if again, err := c.CreateObjectIfAbsent(ctx, bucket, name, []byte(`{"n":2}`), "application/json"); err != nil || again != 0 {
+		t.Errorf("second create got generation %d (%v), want 0", again, err)
+	}
+	data, current, err := c.ReadObjectGeneration(ctx, bucket, name)
+	if err != nil || current != generation || string(data) != `{"n":1}` {
+		t.Errorf("read %s at generation %d (%v)", data, current, err)
+	}
+	updated, err := c.UpdateObjectIfGeneration(ctx, bucket, name, []byte(`{"n":3}`), "application/json", generation)
+	if err != nil || updated == 0 || updated == generation {
+		t.Errorf("update at the current generation got generation %d (%v)", updated, err)
+	}
+	if _, current, err := c.ReadObjectGeneration(ctx, bucket, name); err != nil || current != updated {
+		t.Errorf("read generation %d after the update, want %d (%v)", current, updated, err)
+	}
+	if stale, err := c.UpdateObjectIfGeneration(ctx, bucket, name, []byte(`{"n":4}`), "application/json", generation); err != nil || stale != 0 {
+		t.Errorf("update at a stale generation got generation %d (%v)", stale, err)
+	}
+	if ok, err := c.DeleteObjectGeneration(ctx, bucket, name, generation); err != nil || ok {
+		t.Errorf("delete at a stale generation succeeded (%v)", err)
+	}
+}
+
+func TestEmulatorCopyMoveDelete(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	if err := c.UploadFile(ctx, bucket, prefix+"a.bin", testContent, "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateObjectMetadata(ctx, bucket, prefix+"a.bin", map[string]string{"tts-voice": "en-US-Wavenet-D"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.CopyObject(ctx, bucket, prefix+"a.bin", bucket, prefix+"copy.bin"); err != nil || !ok {
+		t.Fatalf("copy failed (%v)", err)
+	}
+	if ok, err := c.CopyObject(ctx, bucket, prefix+"missing.bin", bucket, prefix+"none.bin"); err != nil || ok {
+		t.Errorf("copy of a missing object reported success (%v)", err)
+	}
+	if err := c.MoveObject(ctx, bucket, prefix+"copy.bin", prefix+"moved.bin", map[string]string{"tts-archived": "true"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, err := c.ObjectMetadata(ctx, bucket, prefix+"copy.bin"); err != nil || exists {
+		t.Errorf("moved object still at its source (%v)", err)
+	}
+	metadata, _, err := c.ObjectMetadata(ctx, bucket, prefix+"moved.bin")
+	if err != nil || metadata["tts-archived"] != "true" || metadata["tts-voice"] != "en-US-Wavenet-D" {
+		t.Errorf("moved object has metadata %v (%v)", metadata, err)
+	}
+	if err := c.DeleteObject(ctx, bucket, prefix+"moved.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteObject(ctx, bucket, prefix+"moved.bin"); err != nil {
+		t.Errorf("deleting a missing object: %v", err)
+	}
+}
+
+func TestEmulatorCompose(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	// More sources than one compose request takes, to go through intermediate objects.
+	var sources []string
+	var want []byte
+	for i := range 40 {
+		name := fmt.Sprintf("%spart-%02d", prefix, i)
+		part := []byte(fmt.Sprintf("part %02d;", i))
+		if err := c.UploadFile(ctx, bucket, name, part, "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, name)
+		want = append(want, part...)
+	}
+	if err := c.ComposeObjects(ctx, bucket, sources, prefix+"composed.txt", "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ReadObject(ctx, bucket, prefix+"composed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("composed %q, want %q", got, want)
+	}
+}
+
+func TestEmulatorDownloadURL(t *testing.T) {
+	c, bucket, prefix := emulatorClient(t)
+	ctx := context.Background()
+	if err := c.UploadFile(ctx, bucket, prefix+"a.txt", []byte("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := c.SignedURL(bucket, prefix+"a.txt", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(got) != "hello" {
+		t.Errorf("GET %s: %s %q", u, resp.Status, got)
+	}
+}
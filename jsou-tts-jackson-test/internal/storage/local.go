@@ -0,0 +1,414 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// localMetadataDir holds the metadata of every object of a Local store, apart
+// from the objects so listing a bucket doesn't return it.
+const localMetadataDir = ".metadata"
+
+// Local implements Storage on the local filesystem, so the pipeline can run on
+// a development machine or in an air-gapped environment without Cloud Storage.
+// Object o of bucket b is the file <Root>/b/o; its content type, custom
+// metadata and generation are kept in <Root>/.metadata/b/o.json. Conditional
+// writes are atomic within one process only.
+type Local struct {
+	Root string
+
+	mu sync.Mutex // Serializes writes, so conditional ones are atomic.
+}
+
+// localMetadata is what a Local store records about an object besides its content.
+type localMetadata struct {
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Generation  int64             `json:"generation"`
+	ObjectHeaders
+}
+
+// NewLocal returns a Local store rooted at the directory root, creating it if needed.
+func NewLocal(root string) (*Local, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local storage directory %s: %w", root, err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage directory %s: %w", root, err)
+	}
+	log.Printf("Using local storage in %s", root)
+	return &Local{Root: root}, nil
+}
+
+// paths returns the files holding an object and its metadata. Names that would
+// escape the bucket directory are rejected.
+func (l *Local) paths(bucketName, objectName string) (file, meta string, err error) {
+	if !filepath.IsLocal(bucketName) || strings.ContainsRune(bucketName, '/') || bucketName == localMetadataDir {
+		return "", "", fmt.Errorf("invalid bucket name %q", bucketName)
+	}
+	if !filepath.IsLocal(filepath.FromSlash(objectName)) {
+		return "", "", fmt.Errorf("invalid object name %q", objectName)
+	}
+	rel := filepath.Join(bucketName, filepath.FromSlash(objectName))
+	return filepath.Join(l.Root, rel), filepath.Join(l.Root, localMetadataDir, rel+".json"), nil
+}
+
+// readMetadata reads an object's metadata. It reports false if the object doesn't exist.
+func (l *Local) readMetadata(bucketName, objectName string) (localMetadata, bool, error) {
+	file, meta, err := l.paths(bucketName, objectName)
+	if err != nil {
+		return localMetadata{}, false, err
+	}
+	if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+		return localMetadata{}, false, nil
+	} else if err != nil {
+		return localMetadata{}, false, err
+	}
+	var m localMetadata
+	data, err := os.ReadFile(meta)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, true, nil // A file put there by hand.
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		return m, true, fmt.Errorf("failed to read metadata of %s/%s: %w", bucketName, objectName, err)
+	}
+	return m, true, nil
+}
+
+// write replaces an object's content from r and records m with a new
+// generation, which it returns. The caller holds l.mu.
+func (l *Local) write(bucketName, objectName string, r io.Reader, m localMetadata) (int64, error) {
+	file, meta, err := l.paths(bucketName, objectName)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(file, r); err != nil {
+		return 0, fmt.Errorf("failed to write %s/%s: %w", bucketName, objectName, err)
+	}
+	m.Generation = time.Now().UnixNano()
+	data, err := json.Marshal(m)
+	if err == nil {
+		err = writeFileAtomic(meta, bytes.NewReader(data))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write metadata of %s/%s: %w", bucketName, objectName, err)
+	}
+	return m.Generation, nil
+}
+
+// writeFileAtomic writes r to a temporary file next to name and renames it into
+// place, so readers never see a partial file.
+func writeFileAtomic(name string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// remove deletes an object and its metadata. The caller holds l.mu.
+func (l *Local) remove(bucketName, objectName string) error {
+	file, meta, err := l.paths(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s/%s: %w", bucketName, objectName, err)
+	}
+	os.Remove(meta)
+	return nil
+}
+
+// UploadFile implements Storage. Headers are only recorded, as local files
+// aren't served over HTTP.
+func (l *Local) UploadFile(ctx context.Context, bucketName, objectName string, content []byte, contentType string, headers ...ObjectHeaders) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m := localMetadata{ContentType: contentType, ObjectHeaders: uploadHeaders(headers)}
+	if _, err := l.write(bucketName, objectName, bytes.NewReader(content), m); err != nil {
+		return err
+	}
+	log.Printf("Wrote %s/%s", bucketName, objectName)
+	return nil
+}
+
+// UploadReader implements Storage.
+func (l *Local) UploadReader(ctx context.Context, bucketName, objectName string, r io.Reader, contentType string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.write(bucketName, objectName, r, localMetadata{ContentType: contentType}); err != nil {
+		return err
+	}
+	log.Printf("Wrote %s/%s", bucketName, objectName)
+	return nil
+}
+
+// ReadObject implements Storage.
+func (l *Local) ReadObject(ctx context.Context, bucketName, objectName string) ([]byte, error) {
+	file, _, err := l.paths(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", bucketName, objectName, err)
+	}
+	return data, nil
+}
+
+// OpenObject implements Storage.
+func (l *Local) OpenObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, int64, error) {
+	file, _, err := l.paths(bucketName, objectName)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open %s/%s: %w", bucketName, objectName, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("failed to open %s/%s: %w", bucketName, objectName, err)
+	}
+	return f, info.Size(), nil
+}
+
+// OpenReaderAt implements Storage. The object is read into memory, so it stays
+// readable if it's replaced, like a generation of a GCS object.
+func (l *Local) OpenReaderAt(ctx context.Context, bucketName, objectName string) (*io.SectionReader, error) {
+	data, err := l.ReadObject(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))), nil
+}
+
+// ListObjectsWithPrefix implements Storage.
+func (l *Local) ListObjectsWithPrefix(ctx context.Context, bucketName, prefix string) ([]ObjectInfo, error) {
+	dir, _, err := l.paths(bucketName, ".")
+	if err != nil {
+		return nil, err
+	}
+	var objects []ObjectInfo
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // An empty bucket.
+		}
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		m, _, err := l.readMetadata(bucketName, name)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Name: name, Size: info.Size(), Created: info.ModTime(), Generation: m.Generation, ContentType: m.ContentType})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing objects with prefix %s: %w", prefix, err)
+	}
+	return objects, nil
+}
+
+// ObjectMetadata implements Storage.
+func (l *Local) ObjectMetadata(ctx context.Context, bucketName, objectName string) (map[string]string, bool, error) {
+	m, exists, err := l.readMetadata(bucketName, objectName)
+	return m.Metadata, exists, err
+}
+
+// UpdateObjectMetadata implements Storage.
+func (l *Local) UpdateObjectMetadata(ctx context.Context, bucketName, objectName string, metadata map[string]string) error {
+	return l.updateMetadata(bucketName, objectName, "metadata", func(m *localMetadata) {
+		if m.Metadata == nil {
+			m.Metadata = map[string]string{}
+		}
+		maps.Copy(m.Metadata, metadata)
+	})
+}
+
+// SetObjectHeaders implements Storage.
+func (l *Local) SetObjectHeaders(ctx context.Context, bucketName, objectName string, headers ObjectHeaders) error {
+	return l.updateMetadata(bucketName, objectName, "headers", func(m *localMetadata) {
+		m.ObjectHeaders = m.ObjectHeaders.merge(headers)
+	})
+}
+
+// updateMetadata applies update to the recorded metadata of an existing object.
+// what names the updated part in errors.
+func (l *Local) updateMetadata(bucketName, objectName, what string, update func(*localMetadata)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, exists, err := l.readMetadata(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("failed to update %s of %s/%s: %w", what, bucketName, objectName, fs.ErrNotExist)
+	}
+	update(&m)
+	_, meta, _ := l.paths(bucketName, objectName)
+	data, err := json.Marshal(m)
+	if err == nil {
+		err = writeFileAtomic(meta, bytes.NewReader(data))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update %s of %s/%s: %w", what, bucketName, objectName, err)
+	}
+	return nil
+}
+
+// CopyObject implements Storage.
+func (l *Local) CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.copy(srcBucket, srcObject, dstBucket, dstObject, nil)
+}
+
+// copy copies an object, adding metadata to its custom metadata. It reports
+// false if the source doesn't exist. The caller holds l.mu.
+func (l *Local) copy(srcBucket, srcObject, dstBucket, dstObject string, metadata map[string]string) (bool, error) {
+	m, exists, err := l.readMetadata(srcBucket, srcObject)
+	if err != nil || !exists {
+		return false, err
+	}
+	file, _, _ := l.paths(srcBucket, srcObject)
+	f, err := os.Open(file)
+	if err != nil {
+		return false, fmt.Errorf("failed to copy %s/%s: %w", srcBucket, srcObject, err)
+	}
+	defer f.Close()
+	m.Metadata = maps.Clone(m.Metadata)
+	if m.Metadata == nil && len(metadata) > 0 {
+		m.Metadata = map[string]string{}
+	}
+	maps.Copy(m.Metadata, metadata)
+	if _, err := l.write(dstBucket, dstObject, f, m); err != nil {
+		return false, err
+	}
+	log.Printf("Copied %s/%s to %s/%s", srcBucket, srcObject, dstBucket, dstObject)
+	return true, nil
+}
+
+// MoveObject implements Storage.
+func (l *Local) MoveObject(ctx context.Context, bucketName, srcObject, dstObject string, metadata map[string]string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	copied, err := l.copy(bucketName, srcObject, bucketName, dstObject, metadata)
+	if err != nil {
+		return err
+	}
+	if !copied {
+		return fmt.Errorf("failed to move %s/%s: %w", bucketName, srcObject, fs.ErrNotExist)
+	}
+	return l.remove(bucketName, srcObject)
+}
+
+// DeleteObject implements Storage.
+func (l *Local) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.remove(bucketName, objectName)
+}
+
+// CreateObjectIfAbsent implements Storage.
+func (l *Local) CreateObjectIfAbsent(ctx context.Context, bucketName, objectName string, content []byte, contentType string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, exists, err := l.readMetadata(bucketName, objectName); err != nil || exists {
+		return 0, err
+	}
+	return l.write(bucketName, objectName, bytes.NewReader(content), localMetadata{ContentType: contentType})
+}
+
+// ReadObjectGeneration implements Storage.
+func (l *Local) ReadObjectGeneration(ctx context.Context, bucketName, objectName string) ([]byte, int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, exists, err := l.readMetadata(bucketName, objectName)
+	if err != nil || !exists {
+		return nil, 0, err
+	}
+	data, err := l.ReadObject(ctx, bucketName, objectName)
+	return data, m.Generation, err
+}
+
+// UpdateObjectIfGeneration implements Storage.
+func (l *Local) UpdateObjectIfGeneration(ctx context.Context, bucketName, objectName string, content []byte, contentType string, generation int64) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, exists, err := l.readMetadata(bucketName, objectName)
+	if err != nil {
+		return 0, err
+	}
+	if exists != (generation != 0) || exists && m.Generation != generation {
+		return 0, nil
+	}
+	return l.write(bucketName, objectName, bytes.NewReader(content), localMetadata{ContentType: contentType})
+}
+
+// DeleteObjectGeneration implements Storage.
+func (l *Local) DeleteObjectGeneration(ctx context.Context, bucketName, objectName string, generation int64) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, exists, err := l.readMetadata(bucketName, objectName)
+	if err != nil || !exists || m.Generation != generation {
+		return false, err
+	}
+	return true, l.remove(bucketName, objectName)
+}
+
+// SignedURL implements Storage with a file:// URL, which doesn't expire.
+func (l *Local) SignedURL(bucketName, objectName string, expiry time.Duration) (string, error) {
+	file, _, err := l.paths(bucketName, objectName)
+	if err != nil {
+		return "", err
+	}
+	return "file://" + filepath.ToSlash(file), nil
+}
+
+// BucketKMSKey implements Storage. Local files aren't encrypted with a KMS key.
+func (l *Local) BucketKMSKey(ctx context.Context, bucketName string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+)
+
+// RangeReader is a Storage that can open an object for streaming from any
+// offset, without first reading it whole as OpenReaderAt does, e.g. to serve
+// HTTP range requests of a long audio file. Client implements it with Cloud
+// Storage range reads.
+type RangeReader interface {
+	Storage
+	OpenRangeReader(ctx context.Context, bucketName, objectName string) (io.ReadSeekCloser, error)
+}
+
+// OpenRangeReader implements RangeReader. Each read after a seek starts a GCS
+// range read from the new offset to the end of the object, which later reads
+// continue, so serving a range only fetches that range. Reads are of the
+// generation current when the object was opened. ctx bounds every read. The
+// caller must close the reader.
+func (c *Client) OpenRangeReader(ctx context.Context, bucketName, objectName string) (io.ReadSeekCloser, error) {
+	obj := c.bucket(bucketName).Object(objectName)
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attributes of GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	return &rangeReader{ctx: ctx, obj: obj.Generation(attrs.Generation), size: attrs.Size}, nil
+}
+
+// rangeReader implements io.ReadSeekCloser over range reads of one generation
+// of a GCS object.
+type rangeReader struct {
+	ctx    context.Context
+	obj    *storage.ObjectHandle
+	size   int64
+	offset int64
+	rc     *storage.Reader // Reading from offset on, or nil until the next Read.
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.rc == nil {
+		rc, err := r.obj.NewRangeReader(r.ctx, r.offset, -1)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read gs://%s/%s at %d: %w", r.obj.BucketName(), r.obj.ObjectName(), r.offset, err)
+		}
+		r.rc = rc
+	}
+	n, err := r.rc.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek of gs://%s/%s to negative offset %d", r.obj.BucketName(), r.obj.ObjectName(), offset)
+	}
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// Close ends the range read in progress, if any.
+func (r *rangeReader) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+	err := r.rc.Close()
+	r.rc = nil
+	return err
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Objects opened with OpenReaderAt are read in blocks of readBlockSize, keeping
+// up to maxCachedBlocks of them, since PDF parsers make many small reads around
+// the cross-reference table and the page objects.
+const (
+	readBlockSize   = 1 << 20
+	maxCachedBlocks = 16
+)
+
+// OpenReaderAt exposes a GCS object for random access without downloading it,
+// so a large PDF doesn't have to be copied to the function's memory-backed /tmp.
+// Reads fetch byte ranges of the generation that was current when the object was
+// opened, and fail if GCS serves another, so a rewrite in the meantime can't mix
+// two versions. GCS only checksums whole objects, so the generation is streamed
+// through its CRC32C and MD5 once when opened, recording the CRC32C of each
+// block and keeping its last blocks, where PDFs keep their cross-reference
+// table, cached. Blocks read again later are checked against their recorded
+// CRC32C. A mismatch fails with ErrChecksumMismatch. ctx bounds every read.
+func (c *Client) OpenReaderAt(ctx context.Context, bucketName, objectName string) (*io.SectionReader, error) {
+	obj := c.bucket(bucketName).Object(objectName)
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attributes of GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	r := &objectReaderAt{
+		ctx:  ctx,
+		name: fmt.Sprintf("gs://%s/%s", bucketName, objectName),
+		size: attrs.Size,
+		readRange: func(ctx context.Context, offset, length int64, w io.Writer) (int64, error) {
+			return copyRange(ctx, obj, attrs.Generation, offset, length, w)
+		},
+		blocks: map[int64][]byte{},
+	}
+	sums := newChecksums()
+	blocks := &blockWriter{r: r}
+	if _, err := r.readRange(ctx, 0, r.size, io.MultiWriter(blocks, sums)); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", r.name, err)
+	}
+	blocks.flush()
+	if err := sums.verify(attrs); err != nil {
+		return nil, err
+	}
+	r.crcs = blocks.crcs
+	log.Printf("Opened %s (%d bytes) for reading in place.", r.name, r.size)
+	return io.NewSectionReader(r, 0, r.size), nil
+}
+
+// objectReaderAt implements io.ReaderAt over range reads of an object.
+type objectReaderAt struct {
+	ctx  context.Context
+	name string
+	size int64
+	// readRange copies length bytes of one version of the object, starting at offset, to w.
+	readRange func(ctx context.Context, offset, length int64, w io.Writer) (int64, error)
+
+	mu     sync.Mutex // Guards blocks and order, not fetches.
+	blocks map[int64][]byte
+	order  []int64 // Cached block indexes, oldest first.
+
+	// fetches lets concurrent reads of an uncached block share one request.
+	fetches singleflight.Group
+
+	// crcs are the CRC32C of each block as verified when the object was opened,
+	// which blocks fetched again must match. Blocks aren't checked while it's nil.
+	crcs []uint32
+}
+
+func (r *objectReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("read of %s at negative offset %d", r.name, off)
+	}
+	n := 0
+	for n < len(p) && off < r.size {
+		index := off / readBlockSize
+		block, err := r.block(index)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], block[off-index*readBlockSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// block returns the block at index, fetching it if it isn't cached. Reads of
+// other blocks go ahead while it's fetched.
+func (r *objectReaderAt) block(index int64) ([]byte, error) {
+	if block, ok := r.cached(index); ok {
+		return block, nil
+	}
+	v, err, _ := r.fetches.Do(strconv.FormatInt(index, 10), func() (any, error) {
+		// A fetch that finished since the lookup above has cached the block.
+		if block, ok := r.cached(index); ok {
+			return block, nil
+		}
+		start := index * readBlockSize
+		var buf bytes.Buffer
+		if _, err := r.readRange(r.ctx, start, min(readBlockSize, r.size-start), &buf); err != nil {
+			return nil, err
+		}
+		if r.crcs != nil {
+			if got, want := crc32.Checksum(buf.Bytes(), crc32cTable), r.crcs[index]; got != want {
+				return nil, fmt.Errorf("%w: block %d of %s has CRC32C %08x, was %08x when opened", ErrChecksumMismatch, index, r.name, got, want)
+			}
+		}
+		r.cache(index, buf.Bytes())
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// cached returns the block at index if it's cached.
+func (r *objectReaderAt) cached(index int64) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	block, ok := r.blocks[index]
+	return block, ok
+}
+
+// cache adds the block at index, evicting the oldest block if the cache is full.
+func (r *objectReaderAt) cache(index int64, block []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.order) == maxCachedBlocks {
+		delete(r.blocks, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.blocks[index] = block
+	r.order = append(r.order, index)
+}
+
+// blockWriter caches what's written to it, from the start of the object, as
+// the blocks of r, and records the CRC32C of each block.
+type blockWriter struct {
+	r     *objectReaderAt
+	index int64
+	buf   []byte
+	crcs  []uint32
+}
+
+func (w *blockWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		take := min(len(p), readBlockSize-len(w.buf))
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+		if len(w.buf) == readBlockSize {
+			w.flush()
+		}
+	}
+	return n, nil
+}
+
+// flush caches the partial block written last, if any.
+func (w *blockWriter) flush() {
+	if len(w.buf) > 0 {
+		w.crcs = append(w.crcs, crc32.Checksum(w.buf, crc32cTable))
+		w.r.cache(w.index, w.buf)
+		w.index++
+		w.buf = nil
+	}
+}
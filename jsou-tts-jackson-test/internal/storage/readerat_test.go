@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeReaderAt returns an objectReaderAt over content that counts its range
+// reads, and holds each of them until release is closed.
+func fakeReaderAt(content []byte, release <-chan struct{}, reads *atomic.Int64) *objectReaderAt {
+	return &objectReaderAt{
+		ctx:  context.Background(),
+		name: "fake",
+		size: int64(len(content)),
+		readRange: func(ctx context.Context, offset, length int64, w io.Writer) (int64, error) {
+			reads.Add(1)
+			<-release
+			n, err := w.Write(content[offset : offset+length])
+			return int64(n), err
+		},
+		blocks: map[int64][]byte{},
+	}
+}
+
+func TestReaderAtSharesFetches(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), readBlockSize/5)
+	release := make(chan struct{})
+	var reads atomic.Int64
+	r := fakeReaderAt(content, release, &reads)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := make([]byte, 10)
+			if _, err := r.ReadAt(got, 100); err != nil {
+				t.Error(err)
+			}
+			if !bytes.Equal(got, content[100:110]) {
+				t.Errorf("read %q, want %q", got, content[100:110])
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if n := reads.Load(); n != 1 {
+		t.Errorf("%d range reads of one block, want 1", n)
+	}
+}
+
+func TestReaderAtFetchesBlocksConcurrently(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), readBlockSize/5)
+	release := make(chan struct{})
+	var reads atomic.Int64
+	r := fakeReaderAt(content, release, &reads)
+
+	var wg sync.WaitGroup
+	for _, off := range []int64{0, readBlockSize} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.ReadAt(make([]byte, 10), off); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// Both fetches start while neither has finished.
+	for reads.Load() < 2 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestBlockWriterCachesLastBlocks(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), (maxCachedBlocks+2)*readBlockSize/10+7)
+	release := make(chan struct{})
+	close(release)
+	var reads atomic.Int64
+	r := fakeReaderAt(content, release, &reads)
+
+	w := &blockWriter{r: r}
+	for chunk := range slices.Chunk(content, 4096) {
+		w.Write(chunk)
+	}
+	w.flush()
+	last := r.size - 100
+	got := make([]byte, 100)
+	if _, err := r.ReadAt(got, last); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content[last:]) {
+		t.Errorf("read %q, want %q", got, content[last:])
+	}
+	if n := reads.Load(); n != 0 {
+		t.Errorf("%d range reads of a cached block, want 0", n)
+	}
+	if _, err := r.ReadAt(got, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := reads.Load(); n != 1 {
+		t.Errorf("%d range reads of an evicted block, want 1", n)
+	}
+}
+
+func TestReaderAtChecksRefetchedBlocks(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), (maxCachedBlocks+1)*readBlockSize/10)
+	release := make(chan struct{})
+	close(release)
+	var reads atomic.Int64
+	r := fakeReaderAt(content, release, &reads)
+
+	w := &blockWriter{r: r}
+	w.Write(content)
+	w.flush()
+	r.crcs = w.crcs
+	got := make([]byte, 10)
+	if _, err := r.ReadAt(got, 0); err != nil {
+		t.Fatalf("read of an unchanged block: %v", err)
+	}
+
+	r.blocks = map[int64][]byte{}
+	r.order = nil
+	content[0] = 'x'
+	if _, err := r.ReadAt(got, 0); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("read of a changed block: %v, want %v", err, ErrChecksumMismatch)
+	}
+}
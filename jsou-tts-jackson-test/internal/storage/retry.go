@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"net/http"
+
+	"cloud.google.com/go/storage"
+)
+
+// IsRetryable reports whether err from a store is transient and worth
+// retrying: rate limiting (429), server errors (5xx), and for Cloud Storage
+// also the network errors its client retries itself.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, status := range []int{s3Status(err), blobStatus(err)} {
+		if status == http.StatusTooManyRequests || status >= 500 {
+			return true
+		}
+	}
+	return storage.ShouldRetry(err)
+}
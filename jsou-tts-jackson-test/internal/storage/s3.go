@@ -0,0 +1,463 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 implements Storage with Amazon S3, so the pipeline can run against AWS
+// buckets. S3 has no generations, so an object's generation is derived from its
+// ETag (see ETagGeneration) and conditional writes are made with If-Match and
+// If-None-Match on the ETag. Uploads send a CRC32C that S3 verifies, and full
+// downloads are checked against the object's stored checksum.
+type S3 struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	// kmsKeyID is the AWS KMS key every object written is encrypted with (SSE-KMS).
+	// Empty means the bucket's default encryption.
+	kmsKeyID string
+}
+
+// NewS3 creates an S3 store from the default AWS configuration (AWS_REGION,
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, or the instance's role).
+func NewS3(ctx context.Context) (*S3, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	client := s3.NewFromConfig(cfg)
+	return &S3{client: client, presign: s3.NewPresignClient(client)}, nil
+}
+
+// SetKMSKey makes every object written from now on encrypted with an AWS KMS
+// key, given by its ID or ARN. Call it before the store is shared.
+func (s *S3) SetKMSKey(id string) {
+	s.kmsKeyID = id
+}
+
+// s3Status returns the HTTP status of a failed S3 request, or 0.
+func s3Status(err error) int {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
+
+// lostRace reports whether a conditional S3 request failed because the object
+// was changed: a failed precondition, or a conflicting concurrent write.
+func lostRace(err error) bool {
+	status := s3Status(err)
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
+
+// head returns an object's attributes. It reports false, without an error, if
+// the object doesn't exist.
+func (s *S3) head(ctx context.Context, bucketName, objectName string) (*s3.HeadObjectOutput, bool, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucketName), Key: aws.String(objectName)})
+	if s3Status(err) == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get attributes of S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	return out, true, nil
+}
+
+// s3Headers returns the headers an S3 object is served with.
+func s3Headers(attrs *s3.HeadObjectOutput) ObjectHeaders {
+	return ObjectHeaders{
+		CacheControl:       aws.ToString(attrs.CacheControl),
+		ContentDisposition: aws.ToString(attrs.ContentDisposition),
+		ContentLanguage:    aws.ToString(attrs.ContentLanguage),
+	}
+}
+
+// optionalString returns nil for an empty string, so the header isn't sent.
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return aws.String(v)
+}
+
+// put writes an object served with headers, with the given conditions (nil for
+// none), and returns its generation.
+func (s *S3) put(ctx context.Context, bucketName, objectName string, body io.Reader, contentType string, headers ObjectHeaders, ifMatch, ifNoneMatch *string) (int64, error) {
+	in := &s3.PutObjectInput{
+		Bucket:             aws.String(bucketName),
+		Key:                aws.String(objectName),
+		Body:               body,
+		ContentType:        aws.String(contentType),
+		CacheControl:       optionalString(headers.CacheControl),
+		ContentDisposition: optionalString(headers.ContentDisposition),
+		ContentLanguage:    optionalString(headers.ContentLanguage),
+		ChecksumAlgorithm:  s3types.ChecksumAlgorithmCrc32c,
+		IfMatch:            ifMatch,
+		IfNoneMatch:        ifNoneMatch,
+	}
+	if s.kmsKeyID != "" {
+		in.ServerSideEncryption = s3types.ServerSideEncryptionAwsKms
+		in.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+	out, err := s.client.PutObject(ctx, in)
+	if err != nil {
+		return 0, err
+	}
+	return ETagGeneration(aws.ToString(out.ETag)), nil
+}
+
+// UploadFile implements Storage.
+func (s *S3) UploadFile(ctx context.Context, bucketName, objectName string, content []byte, contentType string, headers ...ObjectHeaders) error {
+	if _, err := s.put(ctx, bucketName, objectName, bytes.NewReader(content), contentType, uploadHeaders(headers), nil, nil); err != nil {
+		return fmt.Errorf("failed to write S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	log.Printf("Uploaded to s3://%s/%s", bucketName, objectName)
+	return nil
+}
+
+// UploadReader implements Storage. A single PutObject needs a body it can
+// rewind to sign and checksum, so the content is spooled to a temporary file first.
+func (s *S3) UploadReader(ctx context.Context, bucketName, objectName string, r io.Reader, contentType string) error {
+	f, err := os.CreateTemp("", "s3-upload-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to spool S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := s.put(ctx, bucketName, objectName, f, contentType, ObjectHeaders{}, nil, nil); err != nil {
+		return fmt.Errorf("failed to write S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	log.Printf("Uploaded to s3://%s/%s", bucketName, objectName)
+	return nil
+}
+
+// get opens an object, checking its checksum as it's read.
+func (s *S3) get(ctx context.Context, bucketName, objectName string) (*s3.GetObjectOutput, error) {
+	return s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(objectName),
+		ChecksumMode: s3types.ChecksumModeEnabled,
+	})
+}
+
+// ReadObject implements Storage.
+func (s *S3) ReadObject(ctx context.Context, bucketName, objectName string) ([]byte, error) {
+	data, _, err := s.ReadObjectGeneration(ctx, bucketName, objectName)
+	if err == nil && data == nil {
+		err = fmt.Errorf("S3 object %s/%s doesn't exist", bucketName, objectName)
+	}
+	return data, err
+}
+
+// OpenObject implements Storage.
+func (s *S3) OpenObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, int64, error) {
+	out, err := s.get(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	return out.Body, aws.ToInt64(out.ContentLength), nil
+}
+
+// OpenReaderAt implements Storage with range reads, each made only if the
+// object still has the ETag it had when opened.
+func (s *S3) OpenReaderAt(ctx context.Context, bucketName, objectName string) (*io.SectionReader, error) {
+	attrs, ok, err := s.head(ctx, bucketName, objectName)
+	if err == nil && !ok {
+		err = fmt.Errorf("S3 object %s/%s doesn't exist", bucketName, objectName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &objectReaderAt{
+		ctx:  ctx,
+		name: fmt.Sprintf("s3://%s/%s", bucketName, objectName),
+		size: aws.ToInt64(attrs.ContentLength),
+		readRange: func(ctx context.Context, offset, length int64, w io.Writer) (int64, error) {
+			out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+				Bucket:  aws.String(bucketName),
+				Key:     aws.String(objectName),
+				Range:   aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+				IfMatch: attrs.ETag,
+			})
+			if err != nil {
+				return 0, fmt.Errorf("failed to read s3://%s/%s at %d: %w", bucketName, objectName, offset, err)
+			}
+			defer out.Body.Close()
+			n, err := io.Copy(w, out.Body)
+			if err == nil && n < length {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		},
+		blocks: map[int64][]byte{},
+	}
+	log.Printf("Opened %s (%d bytes) for reading in place.", r.name, r.size)
+	return io.NewSectionReader(r, 0, r.size), nil
+}
+
+// ListObjectsWithPrefix implements Storage. S3 doesn't record when an object
+// was created, so Created is when it was last written.
+func (s *S3) ListObjectsWithPrefix(ctx context.Context, bucketName, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{Bucket: aws.String(bucketName), Prefix: aws.String(prefix)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing objects with prefix %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Name:       aws.ToString(obj.Key),
+				Size:       aws.ToInt64(obj.Size),
+				Created:    aws.ToTime(obj.LastModified),
+				Generation: ETagGeneration(aws.ToString(obj.ETag)),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// ObjectMetadata implements Storage.
+func (s *S3) ObjectMetadata(ctx context.Context, bucketName, objectName string) (map[string]string, bool, error) {
+	attrs, ok, err := s.head(ctx, bucketName, objectName)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return attrs.Metadata, true, nil
+}
+
+// copy copies the version of an object described by attrs, replacing its
+// metadata with metadata. Its headers are kept, except those set in headers.
+func (s *S3) copy(ctx context.Context, srcBucket, srcObject string, attrs *s3.HeadObjectOutput, dstBucket, dstObject string, metadata map[string]string, headers ObjectHeaders) error {
+	headers = s3Headers(attrs).merge(headers)
+	in := &s3.CopyObjectInput{
+		Bucket:             aws.String(dstBucket),
+		Key:                aws.String(dstObject),
+		CopySource:         aws.String(srcBucket + "/" + url.PathEscape(srcObject)),
+		CopySourceIfMatch:  attrs.ETag,
+		MetadataDirective:  s3types.MetadataDirectiveReplace,
+		Metadata:           metadata,
+		ContentType:        attrs.ContentType,
+		CacheControl:       optionalString(headers.CacheControl),
+		ContentDisposition: optionalString(headers.ContentDisposition),
+		ContentLanguage:    optionalString(headers.ContentLanguage),
+		ChecksumAlgorithm:  s3types.ChecksumAlgorithmCrc32c,
+	}
+	if s.kmsKeyID != "" {
+		in.ServerSideEncryption = s3types.ServerSideEncryptionAwsKms
+		in.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+	_, err := s.client.CopyObject(ctx, in)
+	return err
+}
+
+// UpdateObjectMetadata implements Storage. S3 metadata can't be changed in
+// place, so the object is copied onto itself with the merged metadata.
+func (s *S3) UpdateObjectMetadata(ctx context.Context, bucketName, objectName string, metadata map[string]string) error {
+	attrs, ok, err := s.head(ctx, bucketName, objectName)
+	if err == nil && !ok {
+		err = fmt.Errorf("S3 object %s/%s doesn't exist", bucketName, objectName)
+	}
+	if err != nil {
+		return err
+	}
+	merged := maps.Clone(attrs.Metadata)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, metadata)
+	if err := s.copy(ctx, bucketName, objectName, attrs, bucketName, objectName, merged, ObjectHeaders{}); err != nil {
+		return fmt.Errorf("failed to update metadata of S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	return nil
+}
+
+// SetObjectHeaders implements Storage. Like metadata, headers are set by
+// copying the object onto itself.
+func (s *S3) SetObjectHeaders(ctx context.Context, bucketName, objectName string, headers ObjectHeaders) error {
+	if headers == (ObjectHeaders{}) {
+		return nil
+	}
+	attrs, ok, err := s.head(ctx, bucketName, objectName)
+	if err == nil && !ok {
+		err = fmt.Errorf("S3 object %s/%s doesn't exist", bucketName, objectName)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.copy(ctx, bucketName, objectName, attrs, bucketName, objectName, attrs.Metadata, headers); err != nil {
+		return fmt.Errorf("failed to update headers of S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	return nil
+}
+
+// CopyObject implements Storage.
+func (s *S3) CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) (bool, error) {
+	attrs, ok, err := s.head(ctx, srcBucket, srcObject)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := s.copy(ctx, srcBucket, srcObject, attrs, dstBucket, dstObject, attrs.Metadata, ObjectHeaders{}); err != nil {
+		return false, fmt.Errorf("failed to copy s3://%s/%s to s3://%s/%s: %w", srcBucket, srcObject, dstBucket, dstObject, err)
+	}
+	log.Printf("Copied s3://%s/%s to s3://%s/%s", srcBucket, srcObject, dstBucket, dstObject)
+	return true, nil
+}
+
+// MoveObject implements Storage. Only the copied version is deleted, so an
+// object rewritten in the meantime stays in place.
+func (s *S3) MoveObject(ctx context.Context, bucketName, srcObject, dstObject string, metadata map[string]string) error {
+	attrs, ok, err := s.head(ctx, bucketName, srcObject)
+	if err == nil && !ok {
+		err = fmt.Errorf("S3 object %s/%s doesn't exist", bucketName, srcObject)
+	}
+	if err != nil {
+		return err
+	}
+	merged := maps.Clone(attrs.Metadata)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, metadata)
+	if err := s.copy(ctx, bucketName, srcObject, attrs, bucketName, dstObject, merged, ObjectHeaders{}); err != nil {
+		return fmt.Errorf("failed to copy s3://%s/%s to %s: %w", bucketName, srcObject, dstObject, err)
+	}
+	if _, err := s.deleteIfMatch(ctx, bucketName, srcObject, attrs.ETag); err != nil {
+		return err
+	}
+	log.Printf("Moved s3://%s/%s to s3://%s/%s", bucketName, srcObject, bucketName, dstObject)
+	return nil
+}
+
+// DeleteObject implements Storage.
+func (s *S3) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	if _, err := s.deleteIfMatch(ctx, bucketName, objectName, nil); err != nil {
+		return err
+	}
+	log.Printf("Deleted s3://%s/%s", bucketName, objectName)
+	return nil
+}
+
+// deleteIfMatch deletes an object if it has the given ETag (nil: any). It
+// reports whether it did.
+func (s *S3) deleteIfMatch(ctx context.Context, bucketName, objectName string, etag *string) (bool, error) {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucketName), Key: aws.String(objectName), IfMatch: etag})
+	switch {
+	case err == nil:
+		return true, nil
+	case lostRace(err), s3Status(err) == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to delete S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+}
+
+// CreateObjectIfAbsent implements Storage.
+func (s *S3) CreateObjectIfAbsent(ctx context.Context, bucketName, objectName string, content []byte, contentType string) (int64, error) {
+	generation, err := s.put(ctx, bucketName, objectName, bytes.NewReader(content), contentType, ObjectHeaders{}, nil, aws.String("*"))
+	if lostRace(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	return generation, nil
+}
+
+// ReadObjectGeneration implements Storage.
+func (s *S3) ReadObjectGeneration(ctx context.Context, bucketName, objectName string) ([]byte, int64, error) {
+	out, err := s.get(ctx, bucketName, objectName)
+	if s3Status(err) == http.StatusNotFound {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	return data, ETagGeneration(aws.ToString(out.ETag)), nil
+}
+
+// UpdateObjectIfGeneration implements Storage.
+func (s *S3) UpdateObjectIfGeneration(ctx context.Context, bucketName, objectName string, content []byte, contentType string, generation int64) (int64, error) {
+	var ifMatch, ifNoneMatch *string
+	if generation == 0 {
+		ifNoneMatch = aws.String("*")
+	} else {
+		attrs, ok, err := s.head(ctx, bucketName, objectName)
+		if err != nil || !ok || ETagGeneration(aws.ToString(attrs.ETag)) != generation {
+			return 0, err
+		}
+		ifMatch = attrs.ETag
+	}
+	written, err := s.put(ctx, bucketName, objectName, bytes.NewReader(content), contentType, ObjectHeaders{}, ifMatch, ifNoneMatch)
+	if lostRace(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	return written, nil
+}
+
+// DeleteObjectGeneration implements Storage.
+func (s *S3) DeleteObjectGeneration(ctx context.Context, bucketName, objectName string, generation int64) (bool, error) {
+	attrs, ok, err := s.head(ctx, bucketName, objectName)
+	if err != nil || !ok || ETagGeneration(aws.ToString(attrs.ETag)) != generation {
+		return false, err
+	}
+	return s.deleteIfMatch(ctx, bucketName, objectName, attrs.ETag)
+}
+
+// SignedURL implements Storage with a presigned GET URL. S3 caps presigned
+// URLs at 7 days, like GCS.
+func (s *S3) SignedURL(bucketName, objectName string, expiry time.Duration) (string, error) {
+	req, err := s.presign.PresignGetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String(bucketName), Key: aws.String(objectName)}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign a URL for S3 object %s/%s: %w", bucketName, objectName, err)
+	}
+	return req.URL, nil
+}
+
+// BucketKMSKey implements Storage. It returns the bucket's default SSE-KMS key,
+// or "" if the bucket doesn't default to one.
+func (s *S3) BucketKMSKey(ctx context.Context, bucketName string) (string, error) {
+	out, err := s.client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the encryption of bucket %s: %w", bucketName, err)
+	}
+	if out.ServerSideEncryptionConfiguration == nil {
+		return "", nil
+	}
+	for _, rule := range out.ServerSideEncryptionConfiguration.Rules {
+		if d := rule.ApplyServerSideEncryptionByDefault; d != nil && d.KMSMasterKeyID != nil {
+			return *d.KMSMasterKeyID, nil
+		}
+	}
+	return "", nil
+}
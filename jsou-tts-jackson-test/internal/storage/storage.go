@@ -2,73 +2,211 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"hash/fnv"
 	"io"
 	"log"
-	"os"
-	"path/filepath"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
-// Global Storage Client for reusability.
-var client *storage.Client
+// Storage is where the pipeline reads its inputs and writes its outputs and
+// records. Objects are addressed by bucket and name as in Cloud Storage, and
+// generations identify versions of an object for conditional writes. Client
+// implements it with Cloud Storage, S3 with Amazon S3 and Local with local
+// directories.
+type Storage interface {
+	UploadFile(ctx context.Context, bucketName, objectName string, content []byte, contentType string, headers ...ObjectHeaders) error
+	UploadReader(ctx context.Context, bucketName, objectName string, r io.Reader, contentType string) error
+	ReadObject(ctx context.Context, bucketName, objectName string) ([]byte, error)
+	OpenObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, int64, error)
+	OpenReaderAt(ctx context.Context, bucketName, objectName string) (*io.SectionReader, error)
+	ListObjectsWithPrefix(ctx context.Context, bucketName, prefix string) ([]ObjectInfo, error)
+	ObjectMetadata(ctx context.Context, bucketName, objectName string) (map[string]string, bool, error)
+	UpdateObjectMetadata(ctx context.Context, bucketName, objectName string, metadata map[string]string) error
+	SetObjectHeaders(ctx context.Context, bucketName, objectName string, headers ObjectHeaders) error
+	CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) (bool, error)
+	MoveObject(ctx context.Context, bucketName, srcObject, dstObject string, metadata map[string]string) error
+	DeleteObject(ctx context.Context, bucketName, objectName string) error
+	CreateObjectIfAbsent(ctx context.Context, bucketName, objectName string, content []byte, contentType string) (int64, error)
+	ReadObjectGeneration(ctx context.Context, bucketName, objectName string) ([]byte, int64, error)
+	UpdateObjectIfGeneration(ctx context.Context, bucketName, objectName string, content []byte, contentType string, generation int64) (int64, error)
+	DeleteObjectGeneration(ctx context.Context, bucketName, objectName string, generation int64) (bool, error)
+	SignedURL(bucketName, objectName string, expiry time.Duration) (string, error)
+	BucketKMSKey(ctx context.Context, bucketName string) (string, error)
+}
 
-func init() {
-	var err error
-	client, err = storage.NewClient(context.Background())
-	if err != nil {
-		log.Fatalf("Failed to create storage client in internal/storage: %v", err)
+// Composer is a Storage that can join objects into one server-side, without
+// downloading them. Client implements it with Cloud Storage compose.
+type Composer interface {
+	Storage
+	ComposeObjects(ctx context.Context, bucketName string, srcObjects []string, dstObject, contentType string, headers ...ObjectHeaders) error
+}
+
+// ObjectInfo describes an object returned by ListObjectsWithPrefix.
+type ObjectInfo struct {
+	Name        string
+	Size        int64
+	Created     time.Time
+	Generation  int64
+	ContentType string // Empty where the listing doesn't include it, as on S3.
+}
+
+// ObjectHeaders are the HTTP headers, besides Content-Type, that an object is
+// served with. Empty fields are left unset.
+type ObjectHeaders struct {
+	CacheControl       string `json:"cache_control,omitempty"`
+	ContentDisposition string `json:"content_disposition,omitempty"`
+	ContentLanguage    string `json:"content_language,omitempty"`
+}
+
+// merge returns h with the non-empty fields of other replacing its own.
+func (h ObjectHeaders) merge(other ObjectHeaders) ObjectHeaders {
+	if other.CacheControl != "" {
+		h.CacheControl = other.CacheControl
+	}
+	if other.ContentDisposition != "" {
+		h.ContentDisposition = other.ContentDisposition
 	}
+	if other.ContentLanguage != "" {
+		h.ContentLanguage = other.ContentLanguage
+	}
+	return h
 }
 
-// DownloadFileToTemp downloads a file from GCS to a temporary file on the local filesystem.
-// It returns the path to the temporary file and a function to clean it up.
-func DownloadFileToTemp(ctx context.Context, bucketName, objectName string) (string, func(), error) {
-	bucket := client.Bucket(bucketName)
-	obj := bucket.Object(objectName)
+// uploadHeaders returns the headers passed to UploadFile, if any.
+func uploadHeaders(headers []ObjectHeaders) ObjectHeaders {
+	var h ObjectHeaders
+	for _, other := range headers {
+		h = h.merge(other)
+	}
+	return h
+}
 
-	rc, err := obj.NewReader(ctx)
-	if err != nil {
-		return "", nil, fmt.Errorf("NewReader: %w", err)
+// ETagGeneration returns the generation reported for an object with the given
+// ETag by the stores whose service has no generations, S3 and Azure Blob. Two
+// versions with the same content can share an ETag, so they count as the same
+// generation; the pipeline only uses generations to detect changes, which
+// identical content isn't.
+func ETagGeneration(etag string) int64 {
+	h := fnv.New64a()
+	io.WriteString(h, strings.Trim(etag, `"`))
+	if g := int64(h.Sum64() & math.MaxInt64); g != 0 {
+		return g
 	}
+	return 1
+}
+
+// Client performs the pipeline's Cloud Storage operations. Create it with
+// NewClient; it's safe for concurrent use.
+type Client struct {
+	gcs *storage.Client
+	// kmsKeyName is the Cloud KMS key every object written by the client is
+	// encrypted with. Empty means the bucket's default encryption.
+	kmsKeyName string
+	// billingProject is the project requests are billed to. Empty means the
+	// bucket's own project, which requester-pays buckets refuse.
+	billingProject string
+	// emulatorURL is the base URL of a Cloud Storage emulator the client talks
+	// to instead of Cloud Storage, or empty.
+	emulatorURL string
+}
 
-	tempFile, err := os.CreateTemp("", filepath.Base(objectName)+"_*.tmp")
+// NewClient creates a Client with the default credentials. Unlike a client
+// created at package init, a failure, e.g. a transient metadata server error
+// on a cold start, is returned to the caller to retry.
+func NewClient(ctx context.Context, opts ...option.ClientOption) (*Client, error) {
+	gcs, err := storage.NewClient(ctx, opts...)
 	if err != nil {
-		rc.Close()
-		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
 	}
+	return &Client{gcs: gcs}, nil
+}
 
-	if _, err := io.Copy(tempFile, rc); err != nil {
-		rc.Close()
-		tempFile.Close()
-		os.Remove(tempFile.Name()) // Clean up partial download
-		return "", nil, fmt.Errorf("failed to copy object to temp file: %w", err)
+// Close closes the underlying Cloud Storage client.
+func (c *Client) Close() error {
+	return c.gcs.Close()
+}
+
+// SetKMSKey makes every object written from now on encrypted with a
+// customer-managed Cloud KMS key, given by its resource name
+// ("projects/.../locations/.../keyRings/.../cryptoKeys/..."). The storage
+// service agent needs the Encrypter/Decrypter role on the key. Call it before
+// the client is shared.
+func (c *Client) SetKMSKey(name string) {
+	c.kmsKeyName = name
+}
+
+// SetEmulatorHost points the client at a Cloud Storage emulator such as
+// fake-gcs-server, given as "host:port" or a URL as in STORAGE_EMULATOR_HOST.
+// The Cloud Storage library itself honors STORAGE_EMULATOR_HOST for requests;
+// this only makes SignedURL return plain download URLs from the emulator,
+// which can't sign. Call it before the client is shared.
+func (c *Client) SetEmulatorHost(host string) {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
 	}
+	c.emulatorURL = strings.TrimSuffix(host, "/")
+}
 
-	rc.Close()
-	tempFile.Close() // Close the file handle after writing
+// SetBillingProject bills every request from now on to the given project ID,
+// so objects in requester-pays buckets, e.g. of other teams, can be read and
+// written. The function's service account needs the serviceusage.services.use
+// permission in that project. Call it before the client is shared.
+func (c *Client) SetBillingProject(project string) {
+	c.billingProject = project
+}
 
-	cleanupFunc := func() {
-		if err := os.Remove(tempFile.Name()); err != nil && !os.IsNotExist(err) {
-			log.Printf("Error cleaning up temp file %s: %v", tempFile.Name(), err)
-		} else {
-			log.Printf("Cleaned up temp file %s", tempFile.Name())
-		}
+// bucket returns a handle for a bucket, billed to the billing project if set.
+func (c *Client) bucket(name string) *storage.BucketHandle {
+	b := c.gcs.Bucket(name)
+	if c.billingProject != "" {
+		b = b.UserProject(c.billingProject)
 	}
+	return b
+}
 
-	log.Printf("Downloaded gs://%s/%s to temp file: %s", bucketName, objectName, tempFile.Name())
-	return tempFile.Name(), cleanupFunc, nil
+// BucketKMSKey returns the default Cloud KMS key of a bucket, or "" if it uses
+// Google-managed encryption. Objects written by other services, such as Long
+// Audio Synthesis, are encrypted with it.
+func (c *Client) BucketKMSKey(ctx context.Context, bucketName string) (string, error) {
+	attrs, err := c.bucket(bucketName).Attrs(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get attributes of bucket %s: %w", bucketName, err)
+	}
+	if attrs.Encryption == nil {
+		return "", nil
+	}
+	return attrs.Encryption.DefaultKMSKeyName, nil
 }
 
-// UploadFile uploads content from a byte slice to a specified GCS object.
-func UploadFile(ctx context.Context, bucketName, objectName string, content []byte, contentType string) error {
-	bucket := client.Bucket(bucketName)
+// UploadFile uploads content from a byte slice to a specified GCS object,
+// served with the given headers if any. The content's CRC32C is sent along, so
+// GCS rejects an upload corrupted on the way.
+func (c *Client) UploadFile(ctx context.Context, bucketName, objectName string, content []byte, contentType string, headers ...ObjectHeaders) error {
+	bucket := c.bucket(bucketName)
 	obj := bucket.Object(objectName)
 
+	h := uploadHeaders(headers)
 	wc := obj.NewWriter(ctx)
 	wc.ContentType = contentType
+	wc.CacheControl = h.CacheControl
+	wc.ContentDisposition = h.ContentDisposition
+	wc.ContentLanguage = h.ContentLanguage
+	wc.KMSKeyName = c.kmsKeyName
+	wc.CRC32C = crc32.Checksum(content, crc32cTable)
+	wc.SendCRC32C = true
 
 	if _, err := wc.Write(content); err != nil {
 		wc.Close()
@@ -83,10 +221,53 @@ func UploadFile(ctx context.Context, bucketName, objectName string, content []by
 	return nil
 }
 
+// UploadReader streams content from r to a specified GCS object, for content
+// too large to hold in memory. Its checksum isn't known up front, so the
+// CRC32C of the streamed content is compared with the stored object's
+// afterwards, and a mismatching object is deleted. If reading r fails, the
+// upload is abandoned rather than committing what was read so far.
+func (c *Client) UploadReader(ctx context.Context, bucketName, objectName string, r io.Reader, contentType string) error {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wc := c.bucket(bucketName).Object(objectName).NewWriter(wctx)
+	wc.ContentType = contentType
+	wc.KMSKeyName = c.kmsKeyName
+
+	sums := newChecksums()
+	if _, err := io.Copy(wc, io.TeeReader(r, sums)); err != nil {
+		// Closing would finalize a truncated object; canceling the writer's context discards the upload.
+		cancel()
+		return fmt.Errorf("failed to write to GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("failed to close GCS writer for %s/%s: %w", bucketName, objectName, err)
+	}
+	if err := sums.verify(wc.Attrs()); err != nil {
+		c.DeleteObjectGeneration(ctx, bucketName, objectName, wc.Attrs().Generation)
+		return err
+	}
+
+	log.Printf("Uploaded to gs://%s/%s", bucketName, objectName)
+	return nil
+}
+
+// ParseGCSURI splits a "gs://bucket/object" URI into its bucket and object names.
+func ParseGCSURI(uri string) (bucketName, objectName string, err error) {
+	rest, ok := strings.CutPrefix(uri, "gs://")
+	if !ok {
+		return "", "", fmt.Errorf("invalid GCS URI %q: must start with gs://", uri)
+	}
+	bucketName, objectName, ok = strings.Cut(rest, "/")
+	if !ok || bucketName == "" || objectName == "" {
+		return "", "", fmt.Errorf("invalid GCS URI %q: want gs://bucket/object", uri)
+	}
+	return bucketName, objectName, nil
+}
+
 // ListObjectsWithPrefix lists objects in a bucket with a given prefix.
-func ListObjectsWithPrefix(ctx context.Context, bucketName, prefix string) ([]*storage.ObjectAttrs, error) {
-	var objects []*storage.ObjectAttrs
-	it := client.Bucket(bucketName).Objects(ctx, &storage.Query{Prefix: prefix})
+func (c *Client) ListObjectsWithPrefix(ctx context.Context, bucketName, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	it := c.bucket(bucketName).Objects(ctx, &storage.Query{Prefix: prefix})
 	for {
 		attrs, err := it.Next()
 		if err == iterator.Done {
@@ -95,7 +276,291 @@ func ListObjectsWithPrefix(ctx context.Context, bucketName, prefix string) ([]*s
 		if err != nil {
 			return nil, fmt.Errorf("error listing objects with prefix %s: %w", prefix, err)
 		}
-		objects = append(objects, attrs)
+		objects = append(objects, ObjectInfo{Name: attrs.Name, Size: attrs.Size, Created: attrs.Created, Generation: attrs.Generation, ContentType: attrs.ContentType})
 	}
 	return objects, nil
 }
+
+// ReadObject reads the full content of a GCS object into memory.
+// It's meant for small objects such as configuration files.
+func (c *Client) ReadObject(ctx context.Context, bucketName, objectName string) ([]byte, error) {
+	rc, err := c.bucket(bucketName).Object(objectName).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("NewReader for %s/%s: %w", bucketName, objectName, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	return data, nil
+}
+
+// OpenObject opens a GCS object for streaming, for objects too large to read
+// into memory. It also returns the object's size. The caller must close the reader.
+func (c *Client) OpenObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, int64, error) {
+	rc, err := c.bucket(bucketName).Object(objectName).NewReader(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("NewReader for %s/%s: %w", bucketName, objectName, err)
+	}
+	return rc, rc.Attrs.Size, nil
+}
+
+// ObjectMetadata returns the custom metadata of a GCS object. A missing object
+// yields nil metadata and false, without an error.
+func (c *Client) ObjectMetadata(ctx context.Context, bucketName, objectName string) (map[string]string, bool, error) {
+	attrs, err := c.bucket(bucketName).Object(objectName).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get attributes of GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	return attrs.Metadata, true, nil
+}
+
+// UpdateObjectMetadata sets custom metadata keys on an existing GCS object,
+// leaving its other keys as they are.
+func (c *Client) UpdateObjectMetadata(ctx context.Context, bucketName, objectName string, metadata map[string]string) error {
+	_, err := c.bucket(bucketName).Object(objectName).Update(ctx, storage.ObjectAttrsToUpdate{Metadata: metadata})
+	if err != nil {
+		return fmt.Errorf("failed to update metadata of GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	return nil
+}
+
+// SetObjectHeaders sets the non-empty headers on an existing GCS object,
+// leaving the others as they are.
+func (c *Client) SetObjectHeaders(ctx context.Context, bucketName, objectName string, headers ObjectHeaders) error {
+	var update storage.ObjectAttrsToUpdate
+	if headers.CacheControl != "" {
+		update.CacheControl = headers.CacheControl
+	}
+	if headers.ContentDisposition != "" {
+		update.ContentDisposition = headers.ContentDisposition
+	}
+	if headers.ContentLanguage != "" {
+		update.ContentLanguage = headers.ContentLanguage
+	}
+	if headers == (ObjectHeaders{}) {
+		return nil
+	}
+	if _, err := c.bucket(bucketName).Object(objectName).Update(ctx, update); err != nil {
+		return fmt.Errorf("failed to update headers of GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	return nil
+}
+
+// maxComposeSources is the most objects a single GCS compose request can join.
+const maxComposeSources = 32
+
+// ComposeObjects joins srcObjects, in order, into dstObject within a bucket,
+// served with the given headers if any. Beyond maxComposeSources objects, groups
+// of them are composed into intermediate objects next to the sources first,
+// which are deleted afterwards. The sources are left in place.
+func (c *Client) ComposeObjects(ctx context.Context, bucketName string, srcObjects []string, dstObject, contentType string, headers ...ObjectHeaders) error {
+	var intermediates []string
+	defer func() {
+		for _, name := range intermediates {
+			c.DeleteObject(ctx, bucketName, name)
+		}
+	}()
+	for level := 1; len(srcObjects) > maxComposeSources; level++ {
+		var next []string
+		for i := 0; i < len(srcObjects); i += maxComposeSources {
+			name := fmt.Sprintf("%s.compose-%d", srcObjects[i], level)
+			if err := c.compose(ctx, bucketName, srcObjects[i:min(i+maxComposeSources, len(srcObjects))], name, contentType, ObjectHeaders{}); err != nil {
+				return err
+			}
+			intermediates = append(intermediates, name)
+			next = append(next, name)
+		}
+		srcObjects = next
+	}
+	if err := c.compose(ctx, bucketName, srcObjects, dstObject, contentType, uploadHeaders(headers)); err != nil {
+		return err
+	}
+	log.Printf("Composed gs://%s/%s", bucketName, dstObject)
+	return nil
+}
+
+// compose joins at most maxComposeSources objects into dstObject.
+func (c *Client) compose(ctx context.Context, bucketName string, srcObjects []string, dstObject, contentType string, headers ObjectHeaders) error {
+	bucket := c.bucket(bucketName)
+	srcs := make([]*storage.ObjectHandle, len(srcObjects))
+	for i, name := range srcObjects {
+		srcs[i] = bucket.Object(name)
+	}
+	composer := bucket.Object(dstObject).ComposerFrom(srcs...)
+	composer.ContentType = contentType
+	composer.CacheControl = headers.CacheControl
+	composer.ContentDisposition = headers.ContentDisposition
+	composer.ContentLanguage = headers.ContentLanguage
+	composer.KMSKeyName = c.kmsKeyName
+	if _, err := composer.Run(ctx); err != nil {
+		return fmt.Errorf("failed to compose GCS object %s/%s from %d objects: %w", bucketName, dstObject, len(srcObjects), err)
+	}
+	return nil
+}
+
+// CopyObject copies a GCS object, possibly to another bucket. It reports false,
+// without an error, if the source doesn't exist.
+func (c *Client) CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) (bool, error) {
+	copier := c.bucket(dstBucket).Object(dstObject).CopierFrom(c.bucket(srcBucket).Object(srcObject))
+	copier.DestinationKMSKeyName = c.kmsKeyName
+	_, err := copier.Run(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to copy gs://%s/%s to gs://%s/%s: %w", srcBucket, srcObject, dstBucket, dstObject, err)
+	}
+	log.Printf("Copied gs://%s/%s to gs://%s/%s", srcBucket, srcObject, dstBucket, dstObject)
+	return true, nil
+}
+
+// DeleteObject deletes a GCS object. Deleting an object that doesn't exist is not an error.
+func (c *Client) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	err := c.bucket(bucketName).Object(objectName).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	log.Printf("Deleted gs://%s/%s", bucketName, objectName)
+	return nil
+}
+
+// CreateObjectIfAbsent writes content to a GCS object only if the object doesn't
+// exist yet and returns the generation it created. It returns 0, without an
+// error, if another writer got there first.
+func (c *Client) CreateObjectIfAbsent(ctx context.Context, bucketName, objectName string, content []byte, contentType string) (int64, error) {
+	obj := c.bucket(bucketName).Object(objectName).If(storage.Conditions{DoesNotExist: true})
+
+	wc := obj.NewWriter(ctx)
+	wc.ContentType = contentType
+	wc.KMSKeyName = c.kmsKeyName
+	wc.CRC32C = crc32.Checksum(content, crc32cTable)
+	wc.SendCRC32C = true
+	if _, err := wc.Write(content); err != nil {
+		wc.Close()
+		return 0, fmt.Errorf("failed to write to GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	if err := wc.Close(); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to close GCS writer for %s/%s: %w", bucketName, objectName, err)
+	}
+	return wc.Attrs().Generation, nil
+}
+
+// DeleteObjectGeneration deletes a specific generation of a GCS object, so an
+// object that was replaced in the meantime is left alone. It reports whether
+// that generation was deleted.
+func (c *Client) DeleteObjectGeneration(ctx context.Context, bucketName, objectName string, generation int64) (bool, error) {
+	obj := c.bucket(bucketName).Object(objectName).If(storage.Conditions{GenerationMatch: generation})
+	err := obj.Delete(ctx)
+	var apiErr *googleapi.Error
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, storage.ErrObjectNotExist), errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to delete GCS object %s/%s#%d: %w", bucketName, objectName, generation, err)
+	}
+}
+
+// MoveObject moves a GCS object within its bucket by copying and deleting it.
+// metadata is added to the object's custom metadata on the way. Only the copied
+// generation is deleted, so an object rewritten in the meantime stays in place.
+func (c *Client) MoveObject(ctx context.Context, bucketName, srcObject, dstObject string, metadata map[string]string) error {
+	src := c.bucket(bucketName).Object(srcObject)
+	attrs, err := src.Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get attributes of GCS object %s/%s: %w", bucketName, srcObject, err)
+	}
+	merged := make(map[string]string, len(attrs.Metadata)+len(metadata))
+	maps.Copy(merged, attrs.Metadata)
+	maps.Copy(merged, metadata)
+
+	copier := c.bucket(bucketName).Object(dstObject).CopierFrom(src.If(storage.Conditions{GenerationMatch: attrs.Generation}))
+	copier.ContentType = attrs.ContentType
+	copier.Metadata = merged
+	copier.DestinationKMSKeyName = c.kmsKeyName
+	if _, err := copier.Run(ctx); err != nil {
+		return fmt.Errorf("failed to copy gs://%s/%s to %s: %w", bucketName, srcObject, dstObject, err)
+	}
+	if _, err := c.DeleteObjectGeneration(ctx, bucketName, srcObject, attrs.Generation); err != nil {
+		return err
+	}
+	log.Printf("Moved gs://%s/%s to gs://%s/%s", bucketName, srcObject, bucketName, dstObject)
+	return nil
+}
+
+// SignedURL returns a V4 signed URL that allows downloading a GCS object without
+// access to the bucket until it expires. On Cloud Functions the URL is signed
+// through the IAM Credentials API, so the function's service account needs the
+// Service Account Token Creator role on itself.
+func (c *Client) SignedURL(bucketName, objectName string, expiry time.Duration) (string, error) {
+	if c.emulatorURL != "" {
+		return fmt.Sprintf("%s/download/storage/v1/b/%s/o/%s?alt=media", c.emulatorURL, bucketName, url.PathEscape(objectName)), nil
+	}
+	signed, err := c.bucket(bucketName).SignedURL(objectName, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  http.MethodGet,
+		Expires: time.Now().Add(expiry),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign a URL for GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	return signed, nil
+}
+
+// ReadObjectGeneration reads a small GCS object along with its generation, for a
+// later UpdateObjectIfGeneration. A missing object yields nil content and generation 0.
+func (c *Client) ReadObjectGeneration(ctx context.Context, bucketName, objectName string) ([]byte, int64, error) {
+	rc, err := c.bucket(bucketName).Object(objectName).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("NewReader for %s/%s: %w", bucketName, objectName, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	return data, rc.Attrs.Generation, nil
+}
+
+// UpdateObjectIfGeneration replaces a GCS object only if it is still at the given
+// generation (0: only if it doesn't exist) and returns the generation it wrote.
+// It returns 0, without an error, if the object was changed in the meantime, so
+// the caller can re-read and retry.
+func (c *Client) UpdateObjectIfGeneration(ctx context.Context, bucketName, objectName string, content []byte, contentType string, generation int64) (int64, error) {
+	cond := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		cond = storage.Conditions{DoesNotExist: true}
+	}
+	wc := c.bucket(bucketName).Object(objectName).If(cond).NewWriter(ctx)
+	wc.ContentType = contentType
+	wc.KMSKeyName = c.kmsKeyName
+	wc.CRC32C = crc32.Checksum(content, crc32cTable)
+	wc.SendCRC32C = true
+	if _, err := wc.Write(content); err != nil {
+		wc.Close()
+		return 0, fmt.Errorf("failed to write to GCS object %s/%s: %w", bucketName, objectName, err)
+	}
+	if err := wc.Close(); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to close GCS writer for %s/%s: %w", bucketName, objectName, err)
+	}
+	return wc.Attrs().Generation, nil
+}
@@ -0,0 +1,65 @@
+// Package tasks schedules HTTP requests with Cloud Tasks, for work that has to
+// happen later than an event's own retries reach.
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	"cloud.google.com/go/cloudtasks/apiv2/cloudtaskspb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Queue creates tasks that POST a JSON body to a URL.
+type Queue struct {
+	client         *cloudtasks.Client
+	queue          string
+	url            string
+	serviceAccount string
+}
+
+// New returns a Queue adding tasks to queue
+// ("projects/P/locations/L/queues/Q") that POST to url. With serviceAccount
+// set, requests carry an OIDC token of that account, so url can require
+// authentication.
+func New(ctx context.Context, queue, url, serviceAccount string) (*Queue, error) {
+	client, err := cloudtasks.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Tasks client: %w", err)
+	}
+	return &Queue{client: client, queue: queue, url: url, serviceAccount: serviceAccount}, nil
+}
+
+// Close closes the Cloud Tasks client.
+func (q *Queue) Close() error {
+	return q.client.Close()
+}
+
+// Enqueue adds a task that POSTs body at the given time, and returns the
+// task's name.
+func (q *Queue) Enqueue(ctx context.Context, body []byte, at time.Time) (string, error) {
+	httpRequest := &cloudtaskspb.HttpRequest{
+		HttpMethod: cloudtaskspb.HttpMethod_POST,
+		Url:        q.url,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       body,
+	}
+	if q.serviceAccount != "" {
+		httpRequest.AuthorizationHeader = &cloudtaskspb.HttpRequest_OidcToken{
+			OidcToken: &cloudtaskspb.OidcToken{ServiceAccountEmail: q.serviceAccount},
+		}
+	}
+	task, err := q.client.CreateTask(ctx, &cloudtaskspb.CreateTaskRequest{
+		Parent: q.queue,
+		Task: &cloudtaskspb.Task{
+			ScheduleTime: timestamppb.New(at),
+			MessageType:  &cloudtaskspb.Task_HttpRequest{HttpRequest: httpRequest},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create task in %s: %w", q.queue, err)
+	}
+	return task.GetName(), nil
+}
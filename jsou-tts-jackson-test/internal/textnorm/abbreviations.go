@@ -0,0 +1,150 @@
+package textnorm
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Abbreviations maps an abbreviation, as written in the text, to its spoken expansion.
+// Matching is case-sensitive.
+type Abbreviations map[string]string
+
+// DefaultAbbreviations is the built-in English expansion list. Ambiguous
+// abbreviations such as "St." (Saint/Street) or "No." are deliberately left out.
+var DefaultAbbreviations = Abbreviations{
+	"e.g.":    "for example",
+	"i.e.":    "that is",
+	"et al.":  "and others",
+	"etc.":    "et cetera",
+	"cf.":     "compare",
+	"ca.":     "circa",
+	"approx.": "approximately",
+	"vs.":     "versus",
+	"ibid.":   "in the same place",
+	"Mr.":     "Mister",
+	"Mrs.":    "Missus",
+	"Dr.":     "Doctor",
+	"Prof.":   "Professor",
+	"Dept.":   "Department",
+	"Govt.":   "Government",
+	"Intl.":   "International",
+	"Corp.":   "Corporation",
+	"Inc.":    "Incorporated",
+	"Ltd.":    "Limited",
+	"Gen.":    "General",
+	"Col.":    "Colonel",
+	"Lt.":     "Lieutenant",
+	"Maj.":    "Major",
+	"Capt.":   "Captain",
+	"Sgt.":    "Sergeant",
+	"Fig.":    "Figure",
+	"Vol.":    "Volume",
+	"Ch.":     "Chapter",
+	"Sec.":    "Section",
+	"Eq.":     "Equation",
+	"pp.":     "pages",
+	"Jan.":    "January",
+	"Feb.":    "February",
+	"Aug.":    "August",
+	"Sept.":   "September",
+	"Oct.":    "October",
+	"Nov.":    "November",
+	"Dec.":    "December",
+}
+
+// ParseAbbreviations parses a JSON object of abbreviation → expansion overrides.
+// An empty expansion removes that abbreviation when merged.
+func ParseAbbreviations(data []byte) (Abbreviations, error) {
+	var a Abbreviations
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("invalid abbreviations JSON: %w", err)
+	}
+	for abbr := range a {
+		if strings.TrimSpace(abbr) == "" {
+			return nil, fmt.Errorf("abbreviations must not contain an empty key")
+		}
+	}
+	return a, nil
+}
+
+// Merge returns a new dictionary with the entries of other added to or replacing
+// those of a. Entries of other with an empty expansion are removed.
+func (a Abbreviations) Merge(other Abbreviations) Abbreviations {
+	merged := make(Abbreviations, len(a)+len(other))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range other {
+		if v == "" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// Expand replaces every whole-word occurrence of an abbreviation in text with
+// its expansion. When an abbreviation's trailing period also ends the sentence
+// ("... and so on etc. The next"), the period is kept after the expansion.
+// Capitalized expansions are treated as titles ("Dr. Smith") and only keep the
+// period at the end of a paragraph.
+func (a Abbreviations) Expand(text string) string {
+	if len(a) == 0 {
+		return text
+	}
+	re := a.pattern()
+
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[2], m[3] // The abbreviation itself, without the leading boundary.
+		abbr := text[start:end]
+		if end < len(text) && !strings.HasSuffix(abbr, ".") {
+			if r, _ := utf8.DecodeRuneInString(text[end:]); unicode.IsLetter(r) || unicode.IsDigit(r) {
+				continue // Part of a longer word.
+			}
+		}
+		b.WriteString(text[last:start])
+		expansion := a[abbr]
+		b.WriteString(expansion)
+		first, _ := utf8.DecodeRuneInString(expansion)
+		if strings.HasSuffix(abbr, ".") && endsSentence(text[end:], !unicode.IsUpper(first)) {
+			b.WriteByte('.')
+		}
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// pattern compiles a regexp matching any abbreviation at the start of a word,
+// preferring the longest abbreviation when several match.
+func (a Abbreviations) pattern() *regexp.Regexp {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, regexp.QuoteMeta(k))
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	return regexp.MustCompile(`(?:^|[^\p{L}\p{N}.])(` + strings.Join(keys, "|") + `)`)
+}
+
+// endsSentence reports whether rest, the text following an abbreviation's
+// period, starts a new sentence: end of text, a blank line, or, if
+// capitalStarts is set, whitespace followed by an upper case letter.
+func endsSentence(rest string, capitalStarts bool) bool {
+	trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace)
+	if trimmed == "" || strings.Count(rest[:len(rest)-len(trimmed)], "\n") >= 2 {
+		return true
+	}
+	if !capitalStarts || len(trimmed) == len(rest) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(trimmed)
+	return unicode.IsUpper(r)
+}
@@ -0,0 +1,80 @@
+package textnorm
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name string
+		abbr Abbreviations
+		in   string
+		want string
+	}{
+		{"lower case expansion", DefaultAbbreviations, "Fruit, e.g. apples.", "Fruit, for example apples."},
+		{"title", DefaultAbbreviations, "Ask Dr. Smith.", "Ask Doctor Smith."},
+		{"title before a number", DefaultAbbreviations, "See Fig. 3 below.", "See Figure 3 below."},
+		{"period ending the sentence kept", DefaultAbbreviations, "Apples, pears, etc. Then plums.", "Apples, pears, et cetera. Then plums."},
+		{"period at the end of the text kept", DefaultAbbreviations, "Apples, pears, etc.", "Apples, pears, et cetera."},
+		{"title at the end of a paragraph", DefaultAbbreviations, "Thanks to the Dept.\n\nNext", "Thanks to the Department.\n\nNext"},
+		{"title before a capital keeps no period", DefaultAbbreviations, "Mrs. Jones and Mr. Brown", "Missus Jones and Mister Brown"},
+		{"case-sensitive", DefaultAbbreviations, "dr. Who", "dr. Who"},
+		{"inside a word", DefaultAbbreviations, "the xe.g. file", "the xe.g. file"},
+		{"longest abbreviation wins", Abbreviations{"U.S.": "United States", "U.S.A.": "United States of America"}, "the U.S.A. team", "the United States of America team"},
+		{"without a period", Abbreviations{"km": "kilometres"}, "5 km away", "5 kilometres away"},
+		{"without a period, part of a word", Abbreviations{"km": "kilometres"}, "a kmart", "a kmart"},
+		{"empty dictionary", nil, "e.g. this", "e.g. this"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.abbr.Expand(tt.in); got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAbbreviations(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Abbreviations
+		wantErr bool
+	}{
+		{"overrides", `{"Fig.": "figure", "e.g.": ""}`, Abbreviations{"Fig.": "figure", "e.g.": ""}, false},
+		{"empty", `{}`, Abbreviations{}, false},
+		{"not an object", `["e.g."]`, nil, true},
+		{"empty key", `{" ": "space"}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAbbreviations([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAbbreviations(%s) error = %v, want error: %v", tt.in, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseAbbreviations(%s) = %v, want %v", tt.in, got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("ParseAbbreviations(%s)[%q] = %q, want %q", tt.in, k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := Abbreviations{"Fig.": "Figure", "e.g.": "for example"}
+	merged := base.Merge(Abbreviations{"Fig.": "figure", "e.g.": "", "No.": "number"})
+	want := Abbreviations{"Fig.": "figure", "No.": "number"}
+	if len(merged) != len(want) {
+		t.Fatalf("Merge() = %v, want %v", merged, want)
+	}
+	for k, v := range want {
+		if merged[k] != v {
+			t.Errorf("Merge()[%q] = %q, want %q", k, merged[k], v)
+		}
+	}
+	if base["Fig."] != "Figure" || base["e.g."] != "for example" {
+		t.Errorf("Merge() changed its receiver to %v", base)
+	}
+}
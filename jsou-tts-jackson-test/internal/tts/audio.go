@@ -0,0 +1,70 @@
+package tts
+
+import (
+	"fmt"
+	"slices"
+
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// AudioSettings holds the tunable parameters of the synthesized audio.
+// Zero values leave the corresponding knob at the service default.
+type AudioSettings struct {
+	Format          AudioFormat
+	SpeakingRate    float64 // 0.25 to 4.0, 1.0 is normal speed.
+	Pitch           float64 // -20.0 to 20.0 semitones from the voice's natural pitch.
+	VolumeGainDb    float64 // -96.0 to 16.0 dB relative to the voice's natural volume.
+	SampleRateHertz int32   // Resamples the output when it differs from the voice's natural rate.
+	// EffectsProfileIDs optimizes the audio for the target playback device, applied in order.
+	EffectsProfileIDs []string
+}
+
+// EffectsProfiles lists the audio profiles supported by the TTS API.
+var EffectsProfiles = []string{
+	"wearable-class-device",
+	"handset-class-device",
+	"headphone-class-device",
+	"small-bluetooth-speaker-class-device",
+	"medium-bluetooth-speaker-class-device",
+	"large-home-entertainment-class-device",
+	"large-automotive-class-device",
+	"telephony-class-application",
+}
+
+// Validate checks that every knob is within the range accepted by the TTS API.
+func (s AudioSettings) Validate() error {
+	if s.SpeakingRate != 0 && (s.SpeakingRate < 0.25 || s.SpeakingRate > 4.0) {
+		return fmt.Errorf("speaking rate %.2f out of range [0.25, 4.0]", s.SpeakingRate)
+	}
+	if s.Pitch < -20.0 || s.Pitch > 20.0 {
+		return fmt.Errorf("pitch %.2f out of range [-20.0, 20.0]", s.Pitch)
+	}
+	if s.VolumeGainDb < -96.0 || s.VolumeGainDb > 16.0 {
+		return fmt.Errorf("volume gain %.2f dB out of range [-96.0, 16.0]", s.VolumeGainDb)
+	}
+	if s.SampleRateHertz < 0 {
+		return fmt.Errorf("sample rate %d must not be negative", s.SampleRateHertz)
+	}
+	for _, id := range s.EffectsProfileIDs {
+		if !slices.Contains(EffectsProfiles, id) {
+			return fmt.Errorf("unknown effects profile %q", id)
+		}
+	}
+	return nil
+}
+
+// audioConfig converts the settings into the API's AudioConfig.
+func (s AudioSettings) audioConfig() *texttospeechpb.AudioConfig {
+	cfg := &texttospeechpb.AudioConfig{
+		AudioEncoding:    s.Format.Encoding,
+		SpeakingRate:     s.SpeakingRate,
+		Pitch:            s.Pitch,
+		VolumeGainDb:     s.VolumeGainDb,
+		SampleRateHertz:  s.SampleRateHertz,
+		EffectsProfileId: s.EffectsProfileIDs,
+	}
+	if cfg.SampleRateHertz == 0 && s.Format.Encoding == texttospeechpb.AudioEncoding_LINEAR16 {
+		cfg.SampleRateHertz = 16000 // LINEAR16 often requires a sample rate. 16kHz is common.
+	}
+	return cfg
+}
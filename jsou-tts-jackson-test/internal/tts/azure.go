@@ -0,0 +1,345 @@
+package tts
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/ssml"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// ProviderAzure selects Azure AI Speech.
+const ProviderAzure = "azure"
+
+// azureBatchAPIVersion is the version of the batch synthesis REST API used for long audio.
+const azureBatchAPIVersion = "2024-04-01"
+
+// Azure implements Synthesizer with Azure AI Speech over its REST APIs. Voices
+// are Azure voice names such as "en-US-JennyNeural". Long inputs run as batch
+// synthesis jobs whose result is copied to GCS once the job has succeeded.
+type Azure struct {
+	Region string // Speech resource region, e.g. "westeurope".
+
+	key     string // Speech resource key, read from Secret Manager.
+	storage storage.Storage
+	limits  Limits
+}
+
+// newAzure reads the Speech resource key from the Secret Manager secret in cfg,
+// or takes it from cfg.AzureKey if it was read before.
+func newAzure(ctx context.Context, cfg ProviderConfig) (*Azure, error) {
+	if cfg.AzureRegion == "" || cfg.AzureKeySecret == "" {
+		return nil, fmt.Errorf("the azure provider needs AZURE_SPEECH_REGION and AZURE_SPEECH_KEY_SECRET")
+	}
+	key, err := cfg.AzureKey.get(ctx, cfg.Secrets, cfg.AzureKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Azure Speech key: %w", err)
+	}
+	return &Azure{Region: cfg.AzureRegion, key: key, storage: cfg.Storage, limits: cfg.Limits}, nil
+}
+
+// Name implements Synthesizer.
+func (a *Azure) Name() string { return ProviderAzure }
+
+// chunkSlots implements slotted.
+func (a *Azure) chunkSlots() ChunkSlots { return a.limits.ChunkSlots }
+
+// Capabilities implements Synthesizer. Azure takes the speaking rate and pitch
+// only as SSML prosody, so the AudioConfig knobs are dropped.
+func (a *Azure) Capabilities(voice Voice) Capabilities {
+	return Capabilities{
+		Family:           "Azure",
+		SSML:             true,
+		LongAudio:        true,
+		LongAudioFormats: []AudioFormat{FormatLinear16, FormatMP3, FormatOggOpus},
+	}
+}
+
+// SynthesizeChunk implements Synthesizer with the text-to-speech REST API.
+func (a *Azure) SynthesizeChunk(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, error) {
+	format, err := azureOutputFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", a.Region)
+	body := azureSSML(input, voice)
+
+	var audio []byte
+	err = a.limits.withRetry(ctx, "Azure synthesis", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Ocp-Apim-Subscription-Key", a.key)
+		req.Header.Set("Content-Type", "application/ssml+xml")
+		req.Header.Set("X-Microsoft-OutputFormat", format)
+		req.Header.Set("User-Agent", "pdf-to-speech")
+		audio, err = doRequest(req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize speech with Azure: %w", err)
+	}
+	return audio, nil
+}
+
+// azureBatch is the subset of a batch synthesis job used here.
+type azureBatch struct {
+	InputKind  string                `json:"inputKind,omitempty"`
+	Inputs     []azureBatchInput     `json:"inputs,omitempty"`
+	Properties *azureBatchProperties `json:"properties,omitempty"`
+	Status     string                `json:"status,omitempty"`
+	Outputs    *azureBatchOutputs    `json:"outputs,omitempty"`
+}
+
+type azureBatchInput struct {
+	Content string `json:"content"`
+}
+
+type azureBatchProperties struct {
+	OutputFormat      string `json:"outputFormat"`
+	ConcatenateResult bool   `json:"concatenateResult"`
+}
+
+type azureBatchOutputs struct {
+	Result string `json:"result"` // URL of a ZIP archive with the audio.
+}
+
+// SynthesizeToGCS implements Synthesizer with a batch synthesis job. The
+// returned operation name carries what CheckOperation needs to copy the result.
+func (a *Azure) SynthesizeToGCS(ctx context.Context, input Input, outputGCSURI string, voice Voice, settings AudioSettings) (string, error) {
+	if _, _, err := storage.ParseGCSURI(outputGCSURI); err != nil {
+		return "", err
+	}
+	format, err := azureOutputFormat(settings)
+	if err != nil {
+		return "", err
+	}
+
+	job := azureBatch{
+		InputKind:  "SSML",
+		Inputs:     []azureBatchInput{{Content: azureSSML(input, voice)}},
+		Properties: &azureBatchProperties{OutputFormat: format, ConcatenateResult: true},
+	}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode Azure batch synthesis: %w", err)
+	}
+
+	id := fmt.Sprintf("pdf-to-speech-%d", time.Now().UnixNano())
+	log.Printf("Starting Azure batch synthesis %s with voice %s and %s encoding...", id, voice.Name, settings.Format)
+	err = a.limits.withRetry(ctx, "Azure batch synthesis", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.batchURL(id), bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Ocp-Apim-Subscription-Key", a.key)
+		req.Header.Set("Content-Type", "application/json")
+		_, err = doRequest(req)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start Azure batch synthesis: %w", err)
+	}
+	return url.Values{"id": {id}, "output": {outputGCSURI}, "format": {settings.Format.String()}}.Encode(), nil
+}
+
+// CheckOperation implements Synthesizer. When the job has succeeded, it copies
+// the audio to the GCS output before reporting it done.
+func (a *Azure) CheckOperation(ctx context.Context, operation string) (bool, float64, error) {
+	op, err := url.ParseQuery(operation)
+	if err != nil || op.Get("id") == "" {
+		return true, 0, fmt.Errorf("invalid Azure operation %q", operation)
+	}
+
+	var job azureBatch
+	err = a.limits.withRetry(ctx, "Azure batch status", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.batchURL(op.Get("id")), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Ocp-Apim-Subscription-Key", a.key)
+		body, err := doRequest(req)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, &job)
+	})
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to get Azure batch synthesis %s: %w", op.Get("id"), err)
+	}
+
+	switch job.Status {
+	case "Failed":
+		return true, 0, fmt.Errorf("azure batch synthesis %s failed", op.Get("id"))
+	case "Succeeded":
+		if job.Outputs == nil || job.Outputs.Result == "" {
+			return true, 0, fmt.Errorf("azure batch synthesis %s succeeded without a result", op.Get("id"))
+		}
+		if err := a.copyToGCS(ctx, job.Outputs.Result, op); err != nil {
+			return false, 100, err
+		}
+		return true, 100, nil
+	default:
+		return false, 0, nil // NotStarted or Running; Azure doesn't report progress.
+	}
+}
+
+// copyToGCS downloads a finished job's result archive and uploads the audio file
+// in it to the GCS output of op.
+func (a *Azure) copyToGCS(ctx context.Context, resultURL string, op url.Values) error {
+	gcsBucket, gcsObject, err := storage.ParseGCSURI(op.Get("output"))
+	if err != nil {
+		return err
+	}
+	format, err := ParseAudioFormat(op.Get("format"))
+	if err != nil {
+		return err
+	}
+
+	var archive []byte
+	err = a.limits.withRetry(ctx, "Azure batch result", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, resultURL, nil) // A SAS URL; no key needed.
+		if err != nil {
+			return err
+		}
+		archive, err = doRequest(req)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download Azure batch result: %w", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return fmt.Errorf("failed to open Azure batch result: %w", err)
+	}
+	for _, f := range zr.File {
+		if path.Ext(f.Name) != format.Extension {
+			continue // summary.json and other metadata
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from the Azure batch result: %w", f.Name, err)
+		}
+		defer rc.Close()
+		if err := a.storage.UploadReader(ctx, gcsBucket, gcsObject, rc, format.ContentType); err != nil {
+			return fmt.Errorf("failed to copy Azure output to %s: %w", op.Get("output"), err)
+		}
+		return nil
+	}
+	return fmt.Errorf("azure batch result has no %s file", format.Extension)
+}
+
+// batchURL returns the URL of the batch synthesis job with the given ID.
+func (a *Azure) batchURL(id string) string {
+	return fmt.Sprintf("https://%s.api.cognitive.microsoft.com/texttospeech/batchsyntheses/%s?api-version=%s", a.Region, url.PathEscape(id), azureBatchAPIVersion)
+}
+
+// ListVoices implements Synthesizer.
+func (a *Azure) ListVoices(ctx context.Context, languageCode string) ([]VoiceInfo, error) {
+	endpoint := fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/voices/list", a.Region)
+	var list []struct {
+		ShortName       string
+		Gender          string
+		Locale          string
+		SampleRateHertz string
+	}
+	err := a.limits.withRetry(ctx, "Azure voices", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Ocp-Apim-Subscription-Key", a.key)
+		body, err := doRequest(req)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, &list)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Azure voices: %w", err)
+	}
+
+	var voices []VoiceInfo
+	for _, v := range list {
+		if languageCode != "" && !strings.EqualFold(v.Locale, languageCode) {
+			continue
+		}
+		rate, _ := strconv.Atoi(v.SampleRateHertz)
+		voices = append(voices, VoiceInfo{
+			Name:                   v.ShortName,
+			LanguageCodes:          []string{v.Locale},
+			Gender:                 strings.ToUpper(v.Gender),
+			NaturalSampleRateHertz: int32(rate),
+		})
+	}
+	return voices, nil
+}
+
+// azureSSML wraps the input in the <speak> and <voice> elements Azure requires.
+// SSML built by internal/ssml is re-rooted; plain text is escaped.
+func azureSSML(input Input, voice Voice) string {
+	content := ssml.Escape(input.Text)
+	if input.SSML != "" {
+		content = strings.TrimSuffix(strings.TrimPrefix(input.SSML, "<speak>"), "</speak>")
+	}
+	lang := voice.LanguageCode
+	if lang == "" {
+		lang = DefaultLanguageCode
+	}
+	return fmt.Sprintf(`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="%s"><voice name="%s">%s</voice></speak>`,
+		ssml.Escape(lang), ssml.Escape(voice.Name), content)
+}
+
+// azureOutputFormat maps the audio settings to an Azure output format name.
+func azureOutputFormat(settings AudioSettings) (string, error) {
+	rate := settings.SampleRateHertz
+	switch settings.Format.Encoding {
+	case texttospeechpb.AudioEncoding_LINEAR16:
+		switch rate {
+		case 0, 16000:
+			return "riff-16khz-16bit-mono-pcm", nil
+		case 8000, 22050, 24000, 44100, 48000:
+			return fmt.Sprintf("riff-%s-16bit-mono-pcm", azureRate(rate)), nil
+		}
+	case texttospeechpb.AudioEncoding_MP3:
+		switch rate {
+		case 0, 24000:
+			return "audio-24khz-96kbitrate-mono-mp3", nil
+		case 16000:
+			return "audio-16khz-128kbitrate-mono-mp3", nil
+		case 48000:
+			return "audio-48khz-96kbitrate-mono-mp3", nil
+		}
+	case texttospeechpb.AudioEncoding_OGG_OPUS:
+		switch rate {
+		case 0, 24000:
+			return "ogg-24khz-16bit-mono-opus", nil
+		case 16000, 48000:
+			return fmt.Sprintf("ogg-%s-16bit-mono-opus", azureRate(rate)), nil
+		}
+	default:
+		return "", fmt.Errorf("azure can't produce %s audio", settings.Format)
+	}
+	return "", fmt.Errorf("azure doesn't offer %s audio at %d Hz", settings.Format, rate)
+}
+
+// azureRate formats a sample rate the way Azure format names do, e.g. "24khz" or "22050hz".
+func azureRate(rate int32) string {
+	if rate%1000 == 0 {
+		return fmt.Sprintf("%dkhz", rate/1000)
+	}
+	return fmt.Sprintf("%dhz", rate)
+}
@@ -0,0 +1,134 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAzureSSML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input Input
+		voice Voice
+		want  string
+	}{
+		{
+			name:  "plain text escaped",
+			input: Input{Text: "Tom & Jerry"},
+			voice: Voice{Name: "en-GB-SoniaNeural", LanguageCode: "en-GB"},
+			want:  `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="en-GB"><voice name="en-GB-SoniaNeural">Tom &amp; Jerry</voice></speak>`,
+		},
+		{
+			name:  "SSML re-rooted",
+			input: Input{SSML: `<speak><p>Hello<break time="500ms"/></p></speak>`},
+			voice: Voice{Name: "de-DE-KatjaNeural", LanguageCode: "de-DE"},
+			want:  `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="de-DE"><voice name="de-DE-KatjaNeural"><p>Hello<break time="500ms"/></p></voice></speak>`,
+		},
+		{
+			name:  "default language",
+			input: Input{Text: "Hello"},
+			voice: Voice{Name: "en-US-JennyNeural"},
+			want:  `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="` + DefaultLanguageCode + `"><voice name="en-US-JennyNeural">Hello</voice></speak>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := azureSSML(tt.input, tt.voice); got != tt.want {
+				t.Errorf("azureSSML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAzureOutputFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings AudioSettings
+		want     string
+		wantErr  bool
+	}{
+		{name: "LINEAR16", settings: AudioSettings{Format: FormatLinear16}, want: "riff-16khz-16bit-mono-pcm"},
+		{name: "LINEAR16 at 22050 Hz", settings: AudioSettings{Format: FormatLinear16, SampleRateHertz: 22050}, want: "riff-22050hz-16bit-mono-pcm"},
+		{name: "LINEAR16 at 48000 Hz", settings: AudioSettings{Format: FormatLinear16, SampleRateHertz: 48000}, want: "riff-48khz-16bit-mono-pcm"},
+		{name: "MP3", settings: AudioSettings{Format: FormatMP3}, want: "audio-24khz-96kbitrate-mono-mp3"},
+		{name: "MP3 at 16000 Hz", settings: AudioSettings{Format: FormatMP3, SampleRateHertz: 16000}, want: "audio-16khz-128kbitrate-mono-mp3"},
+		{name: "MP3 at an unsupported rate", settings: AudioSettings{Format: FormatMP3, SampleRateHertz: 22050}, wantErr: true},
+		{name: "Opus", settings: AudioSettings{Format: FormatOggOpus}, want: "ogg-24khz-16bit-mono-opus"},
+		{name: "Opus at 48000 Hz", settings: AudioSettings{Format: FormatOggOpus, SampleRateHertz: 48000}, want: "ogg-48khz-16bit-mono-opus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := azureOutputFormat(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("azureOutputFormat() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("azureOutputFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// roundTripFunc implements http.RoundTripper with a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// recordRequests makes the REST providers send their requests to a recorder
+// for the rest of the test, which answers each with status and body.
+func recordRequests(t *testing.T, status int, body string) *[]*http.Request {
+	t.Helper()
+	var requests []*http.Request
+	client := httpClient
+	t.Cleanup(func() { httpClient = client })
+	httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r)
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+	})}
+	return &requests
+}
+
+// requestBody reads the body of a recorded request.
+func requestBody(t *testing.T, r *http.Request) string {
+	t.Helper()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestAzureSynthesizeChunk(t *testing.T) {
+	requests := recordRequests(t, http.StatusOK, "audio")
+	a := &Azure{Region: "westeurope", key: "key"}
+	data, err := a.SynthesizeChunk(context.Background(), Input{Text: "Hello"}, Voice{Name: "en-GB-SoniaNeural", LanguageCode: "en-GB"}, AudioSettings{Format: FormatMP3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "audio" {
+		t.Errorf("SynthesizeChunk() = %q, want the response body", data)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(*requests))
+	}
+	r := (*requests)[0]
+	if want := "https://westeurope.tts.speech.microsoft.com/cognitiveservices/v1"; r.URL.String() != want {
+		t.Errorf("request to %s, want %s", r.URL, want)
+	}
+	for header, want := range map[string]string{
+		"Ocp-Apim-Subscription-Key": "key",
+		"Content-Type":              "application/ssml+xml",
+		"X-Microsoft-OutputFormat":  "audio-24khz-96kbitrate-mono-mp3",
+	} {
+		if got := r.Header.Get(header); got != want {
+			t.Errorf("%s: %q, want %q", header, got, want)
+		}
+	}
+	want := `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="en-GB"><voice name="en-GB-SoniaNeural">Hello</voice></speak>`
+	if body := requestBody(t, r); body != want {
+		t.Errorf("request body %q, want %q", body, want)
+	}
+}
@@ -0,0 +1,148 @@
+package tts
+
+import (
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Capabilities describes what a voice family supports. Premium families have
+// restrictions that make some requests fail with InvalidArgument.
+type Capabilities struct {
+	Family       string
+	SSML         bool // Accepts SSML input.
+	LongAudio    bool // Can be used with Long Audio Synthesis.
+	SpeakingRate bool // Honors AudioConfig.SpeakingRate.
+	Pitch        bool // Honors AudioConfig.Pitch.
+
+	// Provider limits. Zero values mean Google's limits.
+	MaxInputBytes     int           // Largest SynthesizeChunk input; 0 means MaxStandardInputBytes.
+	MaxLongAudioBytes int           // Largest SynthesizeToGCS input; 0 means MaxLongAudioInputBytes.
+	LongAudioFormats  []AudioFormat // Formats SynthesizeToGCS can write; nil means LINEAR16 only.
+
+	// SSML restrictions of other providers. Zero values mean Google's SSML.
+	SayAsTypes      []string // interpret-as values <say-as> accepts; nil means any.
+	NoSayAsLanguage bool     // <say-as> rejects the language attribute.
+}
+
+// fullCapabilities applies to Standard, Wavenet, Neural2, News, Polyglot and
+// unknown families.
+var fullCapabilities = Capabilities{SSML: true, LongAudio: true, SpeakingRate: true, Pitch: true}
+
+// familyCapabilities lists families with restrictions, per the Cloud TTS voice documentation.
+var familyCapabilities = map[string]Capabilities{
+	"Studio":  {SSML: true, LongAudio: false, SpeakingRate: true, Pitch: false},
+	"Journey": {SSML: false, LongAudio: false, SpeakingRate: false, Pitch: false},
+	"Casual":  {SSML: true, LongAudio: false, SpeakingRate: true, Pitch: false},
+	// High-definition voices take plain text only. Chirp 3 HD supports pace control.
+	"Chirp3-HD": {SSML: false, LongAudio: false, SpeakingRate: true, Pitch: false},
+	"Chirp-HD":  {SSML: false, LongAudio: false, SpeakingRate: false, Pitch: false},
+}
+
+// geminiCapabilities applies to Gemini voices, which take plain text and a style
+// prompt of up to 4000 bytes each.
+var geminiCapabilities = Capabilities{Family: "Gemini", MaxInputBytes: 4000}
+
+// customCapabilities applies to trained Custom Voice models. They take SSML but
+// aren't documented for Long Audio Synthesis, so documents are chunked.
+var customCapabilities = Capabilities{Family: "Custom", SSML: true, SpeakingRate: true, Pitch: true}
+
+// instantCustomCapabilities applies to instant custom (cloned) voices, which are
+// built on Chirp 3 HD and share its restrictions.
+var instantCustomCapabilities = Capabilities{Family: "InstantCustom", SpeakingRate: true}
+
+// VoiceFamily returns the family part of a voice name, e.g. "Neural2" for
+// "en-US-Neural2-C", or "" if the name doesn't follow the usual pattern.
+func VoiceFamily(voiceName string) string {
+	parts := strings.Split(voiceName, "-")
+	if len(parts) < 4 {
+		return ""
+	}
+	return strings.Join(parts[2:len(parts)-1], "-")
+}
+
+// CapabilitiesFor returns the capabilities of the named voice.
+func CapabilitiesFor(voiceName string) Capabilities {
+	family := VoiceFamily(voiceName)
+	caps, ok := familyCapabilities[family]
+	if !ok {
+		caps = fullCapabilities
+	}
+	caps.Family = family
+	return caps
+}
+
+// ChunkBytes returns the largest input SynthesizeChunk accepts.
+func (c Capabilities) ChunkBytes() int {
+	if c.MaxInputBytes > 0 {
+		return c.MaxInputBytes
+	}
+	return MaxStandardInputBytes
+}
+
+// LongAudioBytes returns the largest input SynthesizeToGCS accepts.
+func (c Capabilities) LongAudioBytes() int {
+	if c.MaxLongAudioBytes > 0 {
+		return c.MaxLongAudioBytes
+	}
+	return MaxLongAudioInputBytes
+}
+
+// SupportsLongAudioFormat reports whether SynthesizeToGCS can write format.
+func (c Capabilities) SupportsLongAudioFormat(format AudioFormat) bool {
+	if c.LongAudioFormats == nil {
+		return SupportsLongAudio(format)
+	}
+	for _, f := range c.LongAudioFormats {
+		if f.Encoding == format.Encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// sayAsElement matches the <say-as> elements internal/ssml writes, which hold
+// escaped text only.
+var sayAsElement = regexp.MustCompile(`<say-as ([^>]*)>([^<]*)</say-as>`)
+
+// sayAsType and sayAsLanguage match attributes of a <say-as> element.
+var (
+	sayAsType     = regexp.MustCompile(`interpret-as="([^"]*)"`)
+	sayAsLanguage = regexp.MustCompile(` language="[^"]*"`)
+)
+
+// AdaptSSML returns ssml with the <say-as> markup the voice doesn't support
+// translated: elements of a type it doesn't accept are replaced by their text,
+// which it reads as written, e.g. "$1,250.50" as an amount of dollars, and a
+// language attribute it rejects is dropped.
+func (c Capabilities) AdaptSSML(ssml string) string {
+	if c.SayAsTypes == nil && !c.NoSayAsLanguage {
+		return ssml
+	}
+	return sayAsElement.ReplaceAllStringFunc(ssml, func(element string) string {
+		m := sayAsElement.FindStringSubmatch(element)
+		attrs, text := m[1], m[2]
+		if t := sayAsType.FindStringSubmatch(attrs); c.SayAsTypes != nil && (t == nil || !slices.Contains(c.SayAsTypes, t[1])) {
+			return text
+		}
+		if c.NoSayAsLanguage {
+			attrs = sayAsLanguage.ReplaceAllString(attrs, "")
+		}
+		return "<say-as " + attrs + ">" + text + "</say-as>"
+	})
+}
+
+// Adapt returns settings with the knobs the voice doesn't support reset to their
+// defaults, logging each adjustment so the substitution is visible.
+func (c Capabilities) Adapt(settings AudioSettings) AudioSettings {
+	if !c.SpeakingRate && settings.SpeakingRate != 0 {
+		log.Printf("Warning: %s voices don't support a speaking rate. Ignoring %.2f.", c.Family, settings.SpeakingRate)
+		settings.SpeakingRate = 0
+	}
+	if !c.Pitch && settings.Pitch != 0 {
+		log.Printf("Warning: %s voices don't support pitch adjustment. Ignoring %.2f.", c.Family, settings.Pitch)
+		settings.Pitch = 0
+	}
+	return settings
+}
@@ -0,0 +1,91 @@
+package tts
+
+import "testing"
+
+func TestAdaptSSML(t *testing.T) {
+	const ssml = `<speak>Pay <say-as interpret-as="currency" language="en-GB">£5</say-as> by <say-as interpret-as="date" format="dmy">5/3/2024</say-as>.</speak>`
+	tests := []struct {
+		name string
+		caps Capabilities
+		want string
+	}{
+		{
+			name: "Google SSML unchanged",
+			caps: fullCapabilities,
+			want: ssml,
+		},
+		{
+			name: "unsupported type read as written",
+			caps: Capabilities{SayAsTypes: []string{"date"}},
+			want: `<speak>Pay £5 by <say-as interpret-as="date" format="dmy">5/3/2024</say-as>.</speak>`,
+		},
+		{
+			name: "language attribute dropped",
+			caps: Capabilities{NoSayAsLanguage: true},
+			want: `<speak>Pay <say-as interpret-as="currency">£5</say-as> by <say-as interpret-as="date" format="dmy">5/3/2024</say-as>.</speak>`,
+		},
+		{
+			name: "no say-as supported",
+			caps: Capabilities{SayAsTypes: []string{}},
+			want: `<speak>Pay £5 by 5/3/2024.</speak>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.caps.AdaptSSML(ssml); got != tt.want {
+				t.Errorf("AdaptSSML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		voice      string
+		wantFamily string
+		wantSSML   bool
+		wantLong   bool
+		wantRate   bool
+		wantPitch  bool
+	}{
+		{voice: "en-US-Neural2-C", wantFamily: "Neural2", wantSSML: true, wantLong: true, wantRate: true, wantPitch: true},
+		{voice: "en-US-Studio-O", wantFamily: "Studio", wantSSML: true, wantRate: true},
+		{voice: "en-US-Journey-D", wantFamily: "Journey"},
+		{voice: "en-US-Chirp-HD-F", wantFamily: "Chirp-HD"},
+		{voice: "en-US-Chirp3-HD-Charon", wantFamily: "Chirp3-HD", wantRate: true},
+		{voice: "custom", wantFamily: "", wantSSML: true, wantLong: true, wantRate: true, wantPitch: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.voice, func(t *testing.T) {
+			got := CapabilitiesFor(tt.voice)
+			if got.Family != tt.wantFamily {
+				t.Errorf("Family = %q, want %q", got.Family, tt.wantFamily)
+			}
+			if got.SSML != tt.wantSSML || got.LongAudio != tt.wantLong || got.SpeakingRate != tt.wantRate || got.Pitch != tt.wantPitch {
+				t.Errorf("SSML, LongAudio, SpeakingRate, Pitch = %v, %v, %v, %v, want %v, %v, %v, %v",
+					got.SSML, got.LongAudio, got.SpeakingRate, got.Pitch, tt.wantSSML, tt.wantLong, tt.wantRate, tt.wantPitch)
+			}
+		})
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	settings := AudioSettings{SpeakingRate: 1.25, Pitch: -2}
+	tests := []struct {
+		name string
+		caps Capabilities
+		want AudioSettings
+	}{
+		{name: "all knobs supported", caps: fullCapabilities, want: settings},
+		{name: "no pitch", caps: familyCapabilities["Studio"], want: AudioSettings{SpeakingRate: 1.25}},
+		{name: "no knobs", caps: familyCapabilities["Journey"], want: AudioSettings{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.caps.Adapt(settings)
+			if got.SpeakingRate != tt.want.SpeakingRate || got.Pitch != tt.want.Pitch {
+				t.Errorf("Adapt() = rate %.2f, pitch %.2f, want rate %.2f, pitch %.2f", got.SpeakingRate, got.Pitch, tt.want.SpeakingRate, tt.want.Pitch)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"MODULE_NAME/jsou-tts/internal/audio"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultChunkConcurrency is the number of chunks synthesized at the same time
+// when no concurrency is configured.
+const DefaultChunkConcurrency = 4
+
+// Segment is one synthesis request of a multi-part document.
+type Segment struct {
+	Input Input
+	Voice Voice
+}
+
+// SynthesizeChunks synthesizes each input with s.SynthesizeChunk, running at
+// most workers requests at a time, and returns the audio of each chunk in input
+// order. The first failure cancels the remaining requests.
+func SynthesizeChunks(ctx context.Context, s Synthesizer, inputs []Input, voice Voice, settings AudioSettings, workers int) ([][]byte, error) {
+	segments := make([]Segment, len(inputs))
+	for i, input := range inputs {
+		segments[i] = Segment{Input: input, Voice: voice}
+	}
+	return SynthesizeSegments(ctx, s, segments, settings, workers)
+}
+
+// SynthesizeSegments is like SynthesizeChunks but lets every segment use its own voice,
+// e.g. for the speakers of a dialogue.
+func SynthesizeSegments(ctx context.Context, s Synthesizer, segments []Segment, settings AudioSettings, workers int) ([][]byte, error) {
+	if workers <= 0 {
+		workers = DefaultChunkConcurrency
+	}
+
+	results := make([][]byte, len(segments))
+	counter := &chunkCounter{total: len(segments)}
+	gate := newChunkGate(ctx)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+
+	for i, segment := range segments {
+		g.Go(func() error {
+			if !gate.open() {
+				return nil
+			}
+			release, err := acquireChunkSlot(ctx, s)
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			audio, err := s.SynthesizeChunk(ctx, segment.Input, segment.Voice, settings)
+			release()
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			results[i] = audio
+			log.Printf("Synthesized chunk %d/%d.", i+1, len(segments))
+			counter.chunkDone(ctx)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := gate.err(len(segments)); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SynthesizeSegmentsInOrder is like SynthesizeSegments but hands each segment's
+// audio to ready as soon as it and every segment before it are done, so the
+// start of a document can be published while the rest is still synthesizing.
+// ready is called in segment order, one call at a time; an error from it stops
+// the synthesis.
+func SynthesizeSegmentsInOrder(ctx context.Context, s Synthesizer, segments []Segment, settings AudioSettings, workers int, ready func(i int, audio []byte) error) ([][]byte, error) {
+	if workers <= 0 {
+		workers = DefaultChunkConcurrency
+	}
+
+	results := make([][]byte, len(segments))
+	done := make([]bool, len(segments))
+	var mu sync.Mutex
+	next := 0 // First segment not yet handed to ready.
+	counter := &chunkCounter{total: len(segments)}
+	gate := newChunkGate(ctx)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+
+	for i, segment := range segments {
+		g.Go(func() error {
+			if !gate.open() {
+				return nil
+			}
+			release, err := acquireChunkSlot(ctx, s)
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			audio, err := s.SynthesizeChunk(ctx, segment.Input, segment.Voice, settings)
+			release()
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			log.Printf("Synthesized chunk %d/%d.", i+1, len(segments))
+			counter.chunkDone(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[i], done[i] = audio, true
+			for ; next < len(done) && done[next]; next++ {
+				if err := ready(next, results[next]); err != nil {
+					return fmt.Errorf("chunk %d/%d: %w", next+1, len(segments), err)
+				}
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := gate.err(len(segments)); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ConcatAudio joins per-chunk audio produced in the given format into a single file.
+func ConcatAudio(format AudioFormat, parts [][]byte) ([]byte, error) {
+	switch format.Encoding {
+	case texttospeechpb.AudioEncoding_MP3:
+		return audio.ConcatMP3(parts), nil
+	case texttospeechpb.AudioEncoding_OGG_OPUS:
+		return audio.ConcatOgg(parts), nil
+	case texttospeechpb.AudioEncoding_LINEAR16:
+		return audio.ConcatWAV(parts)
+	default:
+		return nil, fmt.Errorf("concatenating %s audio is not supported", format)
+	}
+}
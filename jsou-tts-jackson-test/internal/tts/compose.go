@@ -0,0 +1,213 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/audio"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"golang.org/x/sync/errgroup"
+)
+
+// ComposeSegments synthesizes segments like SynthesizeSegments, but rather than
+// returning the audio it uploads each chunk to bucket under prefix as soon as
+// it's synthesized, and has the store join the chunks into outputObject
+// server-side. Only the chunks being synthesized are held in memory, however
+// long the document. The joined object is what ConcatAudio would produce: MP3
+// chunks after the first are stored without their ID3v2 tags, and WAV chunks
+// as bare PCM data behind a header for the whole file. The chunk objects are
+// deleted afterwards. It returns the length of the audio, or 0 if it couldn't
+// be measured.
+//
+// With resume, chunks left under prefix by an earlier call for the same
+// segments that didn't finish, e.g. because the function timed out, are reused
+// instead of synthesized again, and a failed call keeps its chunks for the
+// next one. The caller must clear prefix when the segments change.
+func ComposeSegments(ctx context.Context, s Synthesizer, store storage.Composer, segments []Segment, settings AudioSettings, workers int, bucket, prefix, outputObject string, headers storage.ObjectHeaders, resume bool) (time.Duration, error) {
+	format := settings.Format
+	switch format.Encoding {
+	case texttospeechpb.AudioEncoding_MP3, texttospeechpb.AudioEncoding_OGG_OPUS, texttospeechpb.AudioEncoding_LINEAR16:
+	default:
+		return 0, fmt.Errorf("concatenating %s audio is not supported", format)
+	}
+	if len(segments) == 0 {
+		return 0, errors.New("no chunks to compose")
+	}
+	if workers <= 0 {
+		workers = DefaultChunkConcurrency
+	}
+
+	objects := make([]string, len(segments))
+	for i := range segments {
+		objects[i] = fmt.Sprintf("%schunk-%04d%s", prefix, i+1, format.Extension)
+	}
+	// The "fmt " chunk of WAV chunks is kept next to them, since the chunks are stored as
+	// bare PCM and reusing them needs it.
+	formatObject := prefix + "format"
+	succeeded := false
+	defer func() {
+		if resume && !succeeded {
+			return // Kept for the next call to resume from.
+		}
+		for _, name := range append(objects, formatObject) {
+			store.DeleteObject(ctx, bucket, name)
+		}
+	}()
+
+	var mu sync.Mutex
+	var wavFormat []byte // "fmt " chunk shared by all WAV chunks.
+	var dataLen int64    // PCM bytes of all WAV chunks.
+
+	existing := map[string]bool{}
+	if resume {
+		listed, err := store.ListObjectsWithPrefix(ctx, bucket, prefix)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list the chunks of an earlier attempt: %w", err)
+		}
+		for _, obj := range listed {
+			existing[obj.Name] = true
+		}
+		if format.Encoding == texttospeechpb.AudioEncoding_LINEAR16 && len(existing) > 0 {
+			if wavFormat, err = store.ReadObject(ctx, bucket, formatObject); err != nil {
+				log.Printf("Warning: Can't reuse the chunks of an earlier attempt without their WAV format: %v", err)
+				existing, wavFormat = map[string]bool{}, nil
+			}
+		}
+		if n := len(existing); n > 0 {
+			log.Printf("Resuming from %d object(s) of an earlier attempt under gs://%s/%s.", n, bucket, prefix)
+		}
+	}
+	var duration time.Duration
+	measured := true
+	counter := &chunkCounter{total: len(segments)}
+	gate := newChunkGate(ctx)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+
+	for i, segment := range segments {
+		g.Go(func() error {
+			if existing[objects[i]] {
+				return reuseChunk(gctx, store, format, bucket, objects[i], wavFormat, func(length time.Duration, lengthErr error, pcmLen int64) {
+					log.Printf("Reused chunk %d/%d.", i+1, len(segments))
+					counter.chunkDone(ctx)
+					mu.Lock()
+					defer mu.Unlock()
+					duration += length
+					dataLen += pcmLen
+					measured = measured && lengthErr == nil
+				})
+			}
+			if !gate.open() {
+				return nil
+			}
+			release, err := acquireChunkSlot(gctx, s)
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			data, err := s.SynthesizeChunk(gctx, segment.Input, segment.Voice, settings)
+			release()
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			length, lengthErr := AudioDuration(format, data)
+
+			part := data
+			switch format.Encoding {
+			case texttospeechpb.AudioEncoding_MP3:
+				if i > 0 {
+					part = audio.StripID3v2(data)
+				}
+			case texttospeechpb.AudioEncoding_LINEAR16:
+				fmtChunk, pcm, err := audio.ParseWAV(data)
+				if err != nil {
+					return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+				}
+				mu.Lock()
+				first := wavFormat == nil
+				if first {
+					wavFormat = fmtChunk
+				} else if !bytes.Equal(wavFormat, fmtChunk) {
+					err = fmt.Errorf("chunk %d/%d has a different WAV format than the others", i+1, len(segments))
+				}
+				dataLen += int64(len(pcm))
+				mu.Unlock()
+				if err != nil {
+					return err
+				}
+				if first && resume {
+					if err := store.UploadFile(gctx, bucket, formatObject, fmtChunk, "application/octet-stream"); err != nil {
+						return fmt.Errorf("failed to keep the WAV format of the chunks: %w", err)
+					}
+				}
+				part = pcm
+			}
+			if err := store.UploadFile(gctx, bucket, objects[i], part, format.ContentType); err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			log.Printf("Synthesized chunk %d/%d.", i+1, len(segments))
+			counter.chunkDone(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			duration += length
+			measured = measured && lengthErr == nil
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+	if err := gate.err(len(segments)); err != nil {
+		return 0, err
+	}
+
+	sources := objects
+	if format.Encoding == texttospeechpb.AudioEncoding_LINEAR16 {
+		header, err := audio.WAVFileHeader(wavFormat, dataLen)
+		if err != nil {
+			return 0, err
+		}
+		headerObject := prefix + "header" + format.Extension
+		if err := store.UploadFile(ctx, bucket, headerObject, header, format.ContentType); err != nil {
+			return 0, err
+		}
+		defer store.DeleteObject(ctx, bucket, headerObject)
+		sources = append([]string{headerObject}, objects...)
+	}
+	if err := store.ComposeObjects(ctx, bucket, sources, outputObject, format.ContentType, headers); err != nil {
+		return 0, fmt.Errorf("failed to join %d chunks: %w", len(segments), err)
+	}
+	succeeded = true
+	if !measured {
+		return 0, nil
+	}
+	return duration, nil
+}
+
+// reuseChunk reads a chunk object uploaded by an earlier ComposeSegments call
+// and passes its length, and for WAV its number of PCM bytes, to done. WAV
+// chunks are bare PCM, measured against wavFormat.
+func reuseChunk(ctx context.Context, store storage.Storage, format AudioFormat, bucket, object string, wavFormat []byte, done func(length time.Duration, lengthErr error, pcmLen int64)) error {
+	data, err := store.ReadObject(ctx, bucket, object)
+	if err != nil {
+		return fmt.Errorf("failed to reuse chunk %s: %w", object, err)
+	}
+	if format.Encoding != texttospeechpb.AudioEncoding_LINEAR16 {
+		length, lengthErr := AudioDuration(format, data)
+		done(length, lengthErr, 0)
+		return nil
+	}
+	header, err := audio.WAVFileHeader(wavFormat, int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("failed to reuse chunk %s: %w", object, err)
+	}
+	length, lengthErr := AudioDuration(format, append(header, data...))
+	done(length, lengthErr, int64(len(data)))
+	return nil
+}
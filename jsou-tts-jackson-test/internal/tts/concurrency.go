@@ -0,0 +1,51 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+)
+
+// ChunkSlots bound the chunk requests in flight across every document whose
+// synthesizers share them, whatever the number of documents and their workers.
+// Set them as Limits.ChunkSlots; nil leaves the requests to each document's
+// workers.
+type ChunkSlots chan struct{}
+
+// NewChunkSlots returns slots for n chunk requests at the same time. Zero or
+// less returns nil, for no limit.
+func NewChunkSlots(n int) ChunkSlots {
+	if n <= 0 {
+		return nil
+	}
+	return make(ChunkSlots, n)
+}
+
+// slotted is implemented by the synthesizers of this package, which take a
+// slot of their Limits.ChunkSlots for each chunk request.
+type slotted interface {
+	chunkSlots() ChunkSlots
+}
+
+// acquireChunkSlot blocks until a chunk request of s may start or ctx is done.
+// The returned func ends the request.
+func acquireChunkSlot(ctx context.Context, s Synthesizer) (func(), error) {
+	var slots ChunkSlots
+	if s, ok := s.(slotted); ok {
+		slots = s.chunkSlots()
+	}
+	return slots.acquire(ctx)
+}
+
+// acquire blocks until one of the slots is free or ctx is done. The returned
+// func frees it.
+func (s ChunkSlots) acquire(ctx context.Context) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	select {
+	case s <- struct{}{}:
+		return func() { <-s }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a chunk request slot: %w", ctx.Err())
+	}
+}
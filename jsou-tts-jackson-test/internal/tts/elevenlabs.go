@@ -0,0 +1,202 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/audio"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// ProviderElevenLabs selects ElevenLabs.
+const ProviderElevenLabs = "elevenlabs"
+
+// DefaultElevenLabsModel is used when no model is configured.
+const DefaultElevenLabsModel = "eleven_multilingual_v2"
+
+const elevenLabsAPI = "https://api.elevenlabs.io/v1"
+
+// elevenLabsMaxChars is the per-request character limit of each model; unknown
+// models get the smallest one. Bytes are at least as many as characters.
+var elevenLabsMaxChars = map[string]int{
+	"eleven_multilingual_v2": 10000,
+	"eleven_flash_v2_5":      40000,
+	"eleven_turbo_v2_5":      40000,
+	"eleven_v3":              3000,
+}
+
+// ElevenLabs speed range; SPEAKING_RATE is clamped to it.
+const (
+	elevenLabsMinSpeed = 0.7
+	elevenLabsMaxSpeed = 1.2
+)
+
+// ElevenLabs implements Synthesizer with the ElevenLabs text-to-speech API.
+// Voices are ElevenLabs voice IDs. It takes plain text only and has no long
+// audio path, so documents are always synthesized chunk by chunk.
+type ElevenLabs struct {
+	Model string
+
+	key    string // API key, read from Secret Manager.
+	limits Limits
+}
+
+// newElevenLabs reads the API key from the Secret Manager secret in cfg.
+func newElevenLabs(ctx context.Context, cfg ProviderConfig) (*ElevenLabs, error) {
+	if cfg.ElevenLabsKeySecret == "" {
+		return nil, fmt.Errorf("the elevenlabs provider needs ELEVENLABS_API_KEY_SECRET")
+	}
+	key, err := cfg.Secrets.Access(ctx, cfg.ElevenLabsKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the ElevenLabs API key: %w", err)
+	}
+	model := cfg.ElevenLabsModel
+	if model == "" {
+		model = DefaultElevenLabsModel
+	}
+	return &ElevenLabs{Model: model, key: key, limits: cfg.Limits}, nil
+}
+
+// Name implements Synthesizer.
+func (e *ElevenLabs) Name() string { return ProviderElevenLabs }
+
+// chunkSlots implements slotted.
+func (e *ElevenLabs) chunkSlots() ChunkSlots { return e.limits.ChunkSlots }
+
+// Capabilities implements Synthesizer.
+func (e *ElevenLabs) Capabilities(voice Voice) Capabilities {
+	maxChars, ok := elevenLabsMaxChars[e.Model]
+	if !ok {
+		maxChars = elevenLabsMaxChars["eleven_v3"]
+	}
+	return Capabilities{
+		Family:        "ElevenLabs " + e.Model,
+		SpeakingRate:  true,
+		MaxInputBytes: maxChars,
+	}
+}
+
+// SynthesizeChunk implements Synthesizer with the streaming text-to-speech
+// endpoint, which starts returning audio before the whole chunk is rendered.
+func (e *ElevenLabs) SynthesizeChunk(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, error) {
+	if input.SSML != "" {
+		return nil, errors.New("ElevenLabs doesn't accept SSML input")
+	}
+	format, sampleRate, err := elevenLabsFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]any{"text": input.Text, "model_id": e.Model}
+	if settings.SpeakingRate != 0 {
+		speed := min(max(settings.SpeakingRate, elevenLabsMinSpeed), elevenLabsMaxSpeed)
+		if speed != settings.SpeakingRate {
+			log.Printf("Warning: ElevenLabs speed must be between %.1f and %.1f. Using %.2f instead of %.2f.", elevenLabsMinSpeed, elevenLabsMaxSpeed, speed, settings.SpeakingRate)
+		}
+		body["voice_settings"] = map[string]any{"speed": speed}
+	}
+	if voice.LanguageCode != "" && (e.Model == "eleven_flash_v2_5" || e.Model == "eleven_turbo_v2_5") {
+		language, _, _ := strings.Cut(voice.LanguageCode, "-") // These models take a bare language code.
+		body["language_code"] = language
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ElevenLabs request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/text-to-speech/%s/stream?output_format=%s", elevenLabsAPI, url.PathEscape(voice.Name), format)
+	var data []byte
+	err = e.limits.withRetry(ctx, "ElevenLabs synthesis", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("xi-api-key", e.key)
+		req.Header.Set("Content-Type", "application/json")
+		data, err = doRequest(req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize speech with ElevenLabs: %w", err)
+	}
+	if sampleRate != 0 {
+		data = append(audio.WAVHeader(len(data), sampleRate), data...)
+	}
+	return data, nil
+}
+
+// SynthesizeToGCS implements Synthesizer. ElevenLabs has no long audio API.
+func (e *ElevenLabs) SynthesizeToGCS(ctx context.Context, input Input, outputGCSURI string, voice Voice, settings AudioSettings) (string, error) {
+	return "", errors.New("ElevenLabs doesn't support long audio synthesis; use SYNTHESIS_MODE=chunked")
+}
+
+// CheckOperation implements Synthesizer. ElevenLabs has no long audio API.
+func (e *ElevenLabs) CheckOperation(ctx context.Context, operation string) (bool, float64, error) {
+	return true, 0, fmt.Errorf("ElevenLabs has no operation %q", operation)
+}
+
+// ListVoices implements Synthesizer. ElevenLabs voices aren't tied to a language,
+// so languageCode is ignored.
+func (e *ElevenLabs) ListVoices(ctx context.Context, languageCode string) ([]VoiceInfo, error) {
+	var resp struct {
+		Voices []struct {
+			VoiceID string            `json:"voice_id"`
+			Name    string            `json:"name"`
+			Labels  map[string]string `json:"labels"`
+		} `json:"voices"`
+	}
+	err := e.limits.withRetry(ctx, "ElevenLabs voices", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, elevenLabsAPI+"/voices", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("xi-api-key", e.key)
+		body, err := doRequest(req)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, &resp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ElevenLabs voices: %w", err)
+	}
+
+	voices := make([]VoiceInfo, 0, len(resp.Voices))
+	for _, v := range resp.Voices {
+		voices = append(voices, VoiceInfo{Name: v.VoiceID, Gender: strings.ToUpper(v.Labels["gender"])})
+	}
+	return voices, nil
+}
+
+// elevenLabsFormat maps the audio settings to an ElevenLabs output format. For
+// LINEAR16, which comes back as raw PCM, it also returns the sample rate for the
+// WAV header; it's 0 otherwise.
+func elevenLabsFormat(settings AudioSettings) (string, int, error) {
+	rate := int(settings.SampleRateHertz)
+	switch settings.Format.Encoding {
+	case texttospeechpb.AudioEncoding_MP3:
+		switch rate {
+		case 0, 44100:
+			return "mp3_44100_128", 0, nil
+		case 22050:
+			return "mp3_22050_32", 0, nil
+		}
+	case texttospeechpb.AudioEncoding_LINEAR16:
+		switch rate {
+		case 0:
+			return "pcm_16000", 16000, nil
+		case 16000, 22050, 24000, 44100:
+			return fmt.Sprintf("pcm_%d", rate), rate, nil
+		}
+	default:
+		return "", 0, fmt.Errorf("ElevenLabs can't produce %s audio; use MP3 or LINEAR16", settings.Format)
+	}
+	return "", 0, fmt.Errorf("ElevenLabs doesn't offer %s audio at %d Hz", settings.Format, rate)
+}
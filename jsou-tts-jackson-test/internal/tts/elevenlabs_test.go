@@ -0,0 +1,99 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestElevenLabsRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		voice    Voice
+		settings AudioSettings
+		wantURL  string
+		wantBody map[string]any
+	}{
+		{
+			name:     "MP3",
+			model:    DefaultElevenLabsModel,
+			voice:    Voice{Name: "voice-id", LanguageCode: "en-GB"},
+			settings: AudioSettings{Format: FormatMP3},
+			wantURL:  elevenLabsAPI + "/text-to-speech/voice-id/stream?output_format=mp3_44100_128",
+			wantBody: map[string]any{"text": "Hello", "model_id": DefaultElevenLabsModel},
+		},
+		{
+			name:     "speed clamped",
+			model:    DefaultElevenLabsModel,
+			voice:    Voice{Name: "voice-id"},
+			settings: AudioSettings{Format: FormatMP3, SampleRateHertz: 22050, SpeakingRate: 2},
+			wantURL:  elevenLabsAPI + "/text-to-speech/voice-id/stream?output_format=mp3_22050_32",
+			wantBody: map[string]any{"text": "Hello", "model_id": DefaultElevenLabsModel, "voice_settings": map[string]any{"speed": elevenLabsMaxSpeed}},
+		},
+		{
+			name:     "language of a flash model",
+			model:    "eleven_flash_v2_5",
+			voice:    Voice{Name: "voice-id", LanguageCode: "de-DE"},
+			settings: AudioSettings{Format: FormatLinear16, SampleRateHertz: 24000},
+			wantURL:  elevenLabsAPI + "/text-to-speech/voice-id/stream?output_format=pcm_24000",
+			wantBody: map[string]any{"text": "Hello", "model_id": "eleven_flash_v2_5", "language_code": "de"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := recordRequests(t, http.StatusOK, "audio")
+			e := &ElevenLabs{Model: tt.model, key: "key"}
+			if _, err := e.SynthesizeChunk(context.Background(), Input{Text: "Hello"}, tt.voice, tt.settings); err != nil {
+				t.Fatal(err)
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("%d requests, want 1", len(*requests))
+			}
+			r := (*requests)[0]
+			if r.URL.String() != tt.wantURL {
+				t.Errorf("request to %s, want %s", r.URL, tt.wantURL)
+			}
+			if got := r.Header.Get("xi-api-key"); got != "key" {
+				t.Errorf("xi-api-key: %q, want the API key", got)
+			}
+			var body map[string]any
+			if err := json.Unmarshal([]byte(requestBody(t, r)), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body, tt.wantBody) {
+				t.Errorf("request body %v, want %v", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestElevenLabsFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings AudioSettings
+		want     string
+		wantRate int
+		wantErr  bool
+	}{
+		{name: "MP3", settings: AudioSettings{Format: FormatMP3}, want: "mp3_44100_128"},
+		{name: "MP3 at 22050 Hz", settings: AudioSettings{Format: FormatMP3, SampleRateHertz: 22050}, want: "mp3_22050_32"},
+		{name: "MP3 at an unsupported rate", settings: AudioSettings{Format: FormatMP3, SampleRateHertz: 16000}, wantErr: true},
+		{name: "LINEAR16 as PCM", settings: AudioSettings{Format: FormatLinear16}, want: "pcm_16000", wantRate: 16000},
+		{name: "LINEAR16 at 44100 Hz", settings: AudioSettings{Format: FormatLinear16, SampleRateHertz: 44100}, want: "pcm_44100", wantRate: 44100},
+		{name: "unsupported format", settings: AudioSettings{Format: FormatOggOpus}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rate, err := elevenLabsFormat(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("elevenLabsFormat() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want || rate != tt.wantRate {
+				t.Errorf("elevenLabsFormat() = %q at %d Hz, want %q at %d Hz", got, rate, tt.want, tt.wantRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package tts
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// AudioFormat ties a TTS audio encoding to the file extension and content type
+// used for the generated output object.
+type AudioFormat struct {
+	Encoding    texttospeechpb.AudioEncoding
+	Extension   string
+	ContentType string
+}
+
+var (
+	// FormatLinear16 is uncompressed 16-bit PCM wrapped in a WAV header.
+	FormatLinear16 = AudioFormat{Encoding: texttospeechpb.AudioEncoding_LINEAR16, Extension: ".wav", ContentType: "audio/wav"}
+	// FormatMP3 is MP3 audio at 32kbps.
+	FormatMP3 = AudioFormat{Encoding: texttospeechpb.AudioEncoding_MP3, Extension: ".mp3", ContentType: "audio/mpeg"}
+	// FormatOggOpus is Opus encoded audio wrapped in an Ogg container.
+	FormatOggOpus = AudioFormat{Encoding: texttospeechpb.AudioEncoding_OGG_OPUS, Extension: ".ogg", ContentType: "audio/ogg"}
+)
+
+// DefaultAudioFormat is used when no AUDIO_ENCODING is configured. It matches
+// the only encoding the Long Audio Synthesis API writes to GCS.
+var DefaultAudioFormat = FormatLinear16
+
+// ParseAudioFormat maps an AUDIO_ENCODING value (case-insensitive) to an AudioFormat.
+// Accepted values are MP3, OGG_OPUS (or OPUS/OGG), and LINEAR16 (or WAV).
+// An empty string selects DefaultAudioFormat.
+func ParseAudioFormat(name string) (AudioFormat, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "":
+		return DefaultAudioFormat, nil
+	case "MP3":
+		return FormatMP3, nil
+	case "OGG_OPUS", "OPUS", "OGG":
+		return FormatOggOpus, nil
+	case "LINEAR16", "WAV":
+		return FormatLinear16, nil
+	default:
+		return AudioFormat{}, fmt.Errorf("unsupported audio encoding %q (want MP3, OGG_OPUS or LINEAR16)", name)
+	}
+}
+
+// String returns the encoding name, e.g. "MP3".
+func (f AudioFormat) String() string {
+	return f.Encoding.String()
+}
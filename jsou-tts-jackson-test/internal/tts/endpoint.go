@@ -0,0 +1,18 @@
+package tts
+
+import "net"
+
+// RegionalEndpoint returns the Text-to-Speech endpoint of a region, e.g.
+// "eu-texttospeech.googleapis.com:443" for "eu". Requests sent there are
+// processed in that region, for data residency requirements.
+func RegionalEndpoint(region string) string {
+	return region + "-texttospeech.googleapis.com:443"
+}
+
+// withDefaultPort adds the HTTPS port to an endpoint given as a bare host.
+func withDefaultPort(endpoint string) string {
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return net.JoinHostPort(endpoint, "443")
+	}
+	return endpoint
+}
@@ -0,0 +1,31 @@
+package tts
+
+import "cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+
+// Input is the content to synthesize, either plain text or an SSML document.
+type Input struct {
+	Text string
+	SSML string
+}
+
+// TextInput wraps plain text.
+func TextInput(text string) Input { return Input{Text: text} }
+
+// SSMLInput wraps an SSML document.
+func SSMLInput(ssml string) Input { return Input{SSML: ssml} }
+
+// Len returns the size of the input in bytes, which is what the API limits are measured in.
+func (in Input) Len() int {
+	if in.SSML != "" {
+		return len(in.SSML)
+	}
+	return len(in.Text)
+}
+
+// synthesisInput converts the input into the API's SynthesisInput.
+func (in Input) synthesisInput() *texttospeechpb.SynthesisInput {
+	if in.SSML != "" {
+		return &texttospeechpb.SynthesisInput{InputSource: &texttospeechpb.SynthesisInput_Ssml{Ssml: in.SSML}}
+	}
+	return &texttospeechpb.SynthesisInput{InputSource: &texttospeechpb.SynthesisInput_Text{Text: in.Text}}
+}
@@ -0,0 +1,155 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"MODULE_NAME/jsou-tts/internal/audio"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// ProviderOpenAI selects OpenAI text-to-speech.
+const ProviderOpenAI = "openai"
+
+// DefaultOpenAIModel is used when no model is configured.
+const DefaultOpenAIModel = "gpt-4o-mini-tts"
+
+const openAISpeechURL = "https://api.openai.com/v1/audio/speech"
+
+// openAIMaxInputChars is the character limit of the speech endpoint. Bytes are
+// at least as many as characters, so chunking by bytes stays within it.
+const openAIMaxInputChars = 4096
+
+// openAISampleRate is the fixed sample rate of OpenAI's PCM output.
+const openAISampleRate = 24000
+
+// openAIVoices are the built-in voices; the API has no endpoint to list them.
+var openAIVoices = []string{"alloy", "ash", "ballad", "coral", "echo", "fable", "nova", "onyx", "sage", "shimmer", "verse"}
+
+// OpenAI implements Synthesizer with the OpenAI speech endpoint. Voices are
+// OpenAI voice names such as "alloy". It takes plain text only and has no long
+// audio path, so documents are always synthesized chunk by chunk.
+type OpenAI struct {
+	Model string
+
+	key    string // API key, read from Secret Manager.
+	limits Limits
+}
+
+// newOpenAI reads the API key from the Secret Manager secret in cfg.
+func newOpenAI(ctx context.Context, cfg ProviderConfig) (*OpenAI, error) {
+	if cfg.OpenAIKeySecret == "" {
+		return nil, fmt.Errorf("the openai provider needs OPENAI_API_KEY_SECRET")
+	}
+	key, err := cfg.Secrets.Access(ctx, cfg.OpenAIKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the OpenAI API key: %w", err)
+	}
+	model := cfg.OpenAIModel
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAI{Model: model, key: key, limits: cfg.Limits}, nil
+}
+
+// Name implements Synthesizer.
+func (o *OpenAI) Name() string { return ProviderOpenAI }
+
+// chunkSlots implements slotted.
+func (o *OpenAI) chunkSlots() ChunkSlots { return o.limits.ChunkSlots }
+
+// Capabilities implements Synthesizer. The speed parameter has the same
+// 0.25–4.0 range as SPEAKING_RATE.
+func (o *OpenAI) Capabilities(voice Voice) Capabilities {
+	return Capabilities{
+		Family:        "OpenAI " + o.Model,
+		SpeakingRate:  true,
+		MaxInputBytes: openAIMaxInputChars,
+	}
+}
+
+// SynthesizeChunk implements Synthesizer.
+func (o *OpenAI) SynthesizeChunk(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, error) {
+	if input.SSML != "" {
+		return nil, errors.New("OpenAI doesn't accept SSML input")
+	}
+	format, err := openAIFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]any{
+		"model":           o.Model,
+		"input":           input.Text,
+		"voice":           voice.Name,
+		"response_format": format,
+	}
+	if settings.SpeakingRate != 0 {
+		body["speed"] = settings.SpeakingRate
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAI request: %w", err)
+	}
+
+	var data []byte
+	err = o.limits.withRetry(ctx, "OpenAI speech", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, openAISpeechURL, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+o.key)
+		req.Header.Set("Content-Type", "application/json")
+		data, err = doRequest(req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize speech with OpenAI: %w", err)
+	}
+	if format == "pcm" {
+		data = append(audio.WAVHeader(len(data), openAISampleRate), data...)
+	}
+	return data, nil
+}
+
+// SynthesizeToGCS implements Synthesizer. OpenAI has no long audio API.
+func (o *OpenAI) SynthesizeToGCS(ctx context.Context, input Input, outputGCSURI string, voice Voice, settings AudioSettings) (string, error) {
+	return "", errors.New("OpenAI doesn't support long audio synthesis; use SYNTHESIS_MODE=chunked")
+}
+
+// CheckOperation implements Synthesizer. OpenAI has no long audio API.
+func (o *OpenAI) CheckOperation(ctx context.Context, operation string) (bool, float64, error) {
+	return true, 0, fmt.Errorf("OpenAI has no operation %q", operation)
+}
+
+// ListVoices implements Synthesizer. OpenAI voices speak every supported
+// language, so languageCode is ignored.
+func (o *OpenAI) ListVoices(ctx context.Context, languageCode string) ([]VoiceInfo, error) {
+	voices := make([]VoiceInfo, len(openAIVoices))
+	for i, name := range openAIVoices {
+		voices[i] = VoiceInfo{Name: name, NaturalSampleRateHertz: openAISampleRate}
+	}
+	return voices, nil
+}
+
+// openAIFormat maps the audio settings to an OpenAI response format. LINEAR16 is
+// requested as raw PCM, which is always 24 kHz, and wrapped in a WAV header.
+func openAIFormat(settings AudioSettings) (string, error) {
+	switch settings.Format.Encoding {
+	case texttospeechpb.AudioEncoding_MP3:
+		return "mp3", nil
+	case texttospeechpb.AudioEncoding_OGG_OPUS:
+		return "opus", nil
+	case texttospeechpb.AudioEncoding_LINEAR16:
+		if settings.SampleRateHertz != 0 && settings.SampleRateHertz != openAISampleRate {
+			return "", fmt.Errorf("OpenAI produces LINEAR16 at %d Hz only, not %d", openAISampleRate, settings.SampleRateHertz)
+		}
+		return "pcm", nil
+	default:
+		return "", fmt.Errorf("OpenAI can't produce %s audio", settings.Format)
+	}
+}
@@ -0,0 +1,77 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAIRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings AudioSettings
+		wantBody map[string]any
+		wantWAV  bool
+	}{
+		{
+			name:     "MP3",
+			settings: AudioSettings{Format: FormatMP3},
+			wantBody: map[string]any{"model": "tts-1", "input": "Hello", "voice": "alloy", "response_format": "mp3"},
+		},
+		{
+			name:     "speed",
+			settings: AudioSettings{Format: FormatOggOpus, SpeakingRate: 1.5},
+			wantBody: map[string]any{"model": "tts-1", "input": "Hello", "voice": "alloy", "response_format": "opus", "speed": 1.5},
+		},
+		{
+			name:     "LINEAR16 as PCM in a WAV header",
+			settings: AudioSettings{Format: FormatLinear16},
+			wantBody: map[string]any{"model": "tts-1", "input": "Hello", "voice": "alloy", "response_format": "pcm"},
+			wantWAV:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := recordRequests(t, http.StatusOK, "audio")
+			o := &OpenAI{Model: "tts-1", key: "key"}
+			data, err := o.SynthesizeChunk(context.Background(), Input{Text: "Hello"}, Voice{Name: "alloy"}, tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wav := bytes.HasPrefix(data, []byte("RIFF")); wav != tt.wantWAV {
+				t.Errorf("audio in a WAV header: %v, want %v", wav, tt.wantWAV)
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("%d requests, want 1", len(*requests))
+			}
+			r := (*requests)[0]
+			if r.URL.String() != openAISpeechURL {
+				t.Errorf("request to %s, want %s", r.URL, openAISpeechURL)
+			}
+			if got := r.Header.Get("Authorization"); got != "Bearer key" {
+				t.Errorf("Authorization: %q, want the API key", got)
+			}
+			var body map[string]any
+			if err := json.Unmarshal([]byte(requestBody(t, r)), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body, tt.wantBody) {
+				t.Errorf("request body %v, want %v", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestOpenAIRejectsSSML(t *testing.T) {
+	requests := recordRequests(t, http.StatusOK, "audio")
+	o := &OpenAI{Model: "tts-1", key: "key"}
+	if _, err := o.SynthesizeChunk(context.Background(), Input{SSML: "<speak>Hello</speak>"}, Voice{Name: "alloy"}, AudioSettings{Format: FormatMP3}); err == nil {
+		t.Error("SynthesizeChunk() of SSML succeeded")
+	}
+	if len(*requests) != 0 {
+		t.Errorf("%d requests, want none", len(*requests))
+	}
+}
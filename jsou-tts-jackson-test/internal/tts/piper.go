@@ -0,0 +1,126 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// ProviderPiper selects Piper, a local neural TTS engine.
+const ProviderPiper = "piper"
+
+// DefaultPiperBinary is the Piper executable looked up in PATH when none is configured.
+const DefaultPiperBinary = "piper"
+
+// Piper implements Synthesizer by running the Piper command line tool locally,
+// so the pipeline can run on a laptop or in CI without cloud credentials. Voices
+// are Piper model names such as "en_US-lessac-medium", loaded from ModelDir.
+// Output is always WAV at the model's sample rate.
+type Piper struct {
+	Binary   string // Path to the piper executable.
+	ModelDir string // Directory with <voice>.onnx and <voice>.onnx.json files.
+	limits   Limits // Only the chunk slots apply; Piper runs locally.
+}
+
+// newPiper checks that the Piper executable can be found.
+func newPiper(cfg ProviderConfig) (*Piper, error) {
+	binary := cfg.PiperBinary
+	if binary == "" {
+		binary = DefaultPiperBinary
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, fmt.Errorf("piper executable %q not found: %w", binary, err)
+	}
+	if cfg.PiperModelDir == "" {
+		return nil, errors.New("the piper provider needs PIPER_MODEL_DIR")
+	}
+	return &Piper{Binary: binary, ModelDir: cfg.PiperModelDir, limits: cfg.Limits}, nil
+}
+
+// Name implements Synthesizer.
+func (p *Piper) Name() string { return ProviderPiper }
+
+// chunkSlots implements slotted.
+func (p *Piper) chunkSlots() ChunkSlots { return p.limits.ChunkSlots }
+
+// Capabilities implements Synthesizer. The speaking rate maps to Piper's length scale.
+func (p *Piper) Capabilities(voice Voice) Capabilities {
+	return Capabilities{Family: "Piper", SpeakingRate: true}
+}
+
+// SynthesizeChunk implements Synthesizer by running piper with the text on stdin.
+func (p *Piper) SynthesizeChunk(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, error) {
+	if input.SSML != "" {
+		return nil, errors.New("piper doesn't accept SSML input")
+	}
+	if settings.Format.Encoding != texttospeechpb.AudioEncoding_LINEAR16 {
+		return nil, fmt.Errorf("piper only produces LINEAR16 (WAV) audio, not %s", settings.Format)
+	}
+	if settings.SampleRateHertz != 0 {
+		log.Printf("Warning: Piper uses the sample rate of the voice model. Ignoring %d Hz.", settings.SampleRateHertz)
+	}
+
+	out, err := os.CreateTemp("", "piper_*.wav")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file for piper output: %w", err)
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	args := []string{"--model", filepath.Join(p.ModelDir, voice.Name+".onnx"), "--output_file", out.Name()}
+	if settings.SpeakingRate != 0 {
+		args = append(args, "--length_scale", strconv.FormatFloat(1/settings.SpeakingRate, 'f', 3, 64))
+	}
+	cmd := exec.CommandContext(ctx, p.Binary, args...)
+	cmd.Stdin = strings.NewReader(input.Text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("piper failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read piper output: %w", err)
+	}
+	return data, nil
+}
+
+// SynthesizeToGCS implements Synthesizer. Piper has no long audio path.
+func (p *Piper) SynthesizeToGCS(ctx context.Context, input Input, outputGCSURI string, voice Voice, settings AudioSettings) (string, error) {
+	return "", errors.New("piper doesn't support long audio synthesis; use SYNTHESIS_MODE=chunked")
+}
+
+// CheckOperation implements Synthesizer. Piper has no long audio path.
+func (p *Piper) CheckOperation(ctx context.Context, operation string) (bool, float64, error) {
+	return true, 0, fmt.Errorf("piper has no operation %q", operation)
+}
+
+// ListVoices implements Synthesizer, listing the models in ModelDir. Piper model
+// names start with the language, e.g. "en_US-lessac-medium" speaks en-US.
+func (p *Piper) ListVoices(ctx context.Context, languageCode string) ([]VoiceInfo, error) {
+	models, err := filepath.Glob(filepath.Join(p.ModelDir, "*.onnx"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list piper models: %w", err)
+	}
+	var voices []VoiceInfo
+	for _, model := range models {
+		name := strings.TrimSuffix(filepath.Base(model), ".onnx")
+		locale, _, _ := strings.Cut(name, "-")
+		locale = strings.ReplaceAll(locale, "_", "-")
+		if languageCode != "" && !strings.EqualFold(locale, languageCode) {
+			continue
+		}
+		voices = append(voices, VoiceInfo{Name: name, LanguageCodes: []string{locale}})
+	}
+	return voices, nil
+}
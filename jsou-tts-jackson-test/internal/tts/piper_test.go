@@ -0,0 +1,102 @@
+package tts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakePiper writes a script standing in for the piper executable, which
+// records its arguments and input in dir and writes "audio" as its output.
+func fakePiper(t *testing.T, dir string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake piper is a shell script")
+	}
+	script := `#!/bin/sh
+echo "$@" > "` + filepath.Join(dir, "args") + `"
+cat > "` + filepath.Join(dir, "input") + `"
+while [ $# -gt 0 ]; do
+	if [ "$1" = --output_file ]; then printf audio > "$2"; fi
+	shift
+done
+`
+	binary := filepath.Join(dir, "piper")
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return binary
+}
+
+func TestPiperCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings AudioSettings
+		wantArgs []string // With OUTPUT for the output file.
+	}{
+		{
+			name:     "default rate",
+			settings: AudioSettings{Format: FormatLinear16},
+			wantArgs: []string{"--model", "/models/en_US-lessac-medium.onnx", "--output_file", "OUTPUT"},
+		},
+		{
+			name:     "faster",
+			settings: AudioSettings{Format: FormatLinear16, SpeakingRate: 1.25},
+			wantArgs: []string{"--model", "/models/en_US-lessac-medium.onnx", "--output_file", "OUTPUT", "--length_scale", "0.800"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p := &Piper{Binary: fakePiper(t, dir), ModelDir: "/models"}
+			data, err := p.SynthesizeChunk(context.Background(), Input{Text: "Hello"}, Voice{Name: "en_US-lessac-medium"}, tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "audio" {
+				t.Errorf("SynthesizeChunk() = %q, want the output file", data)
+			}
+			args, err := os.ReadFile(filepath.Join(dir, "args"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Fields(string(args))
+			if i := slices.Index(got, "--output_file"); i >= 0 && i+1 < len(got) {
+				got[i+1] = "OUTPUT"
+			}
+			if !slices.Equal(got, tt.wantArgs) {
+				t.Errorf("piper run with %q, want %q", got, tt.wantArgs)
+			}
+			if input, _ := os.ReadFile(filepath.Join(dir, "input")); string(input) != "Hello" {
+				t.Errorf("piper read %q, want the text", input)
+			}
+		})
+	}
+}
+
+func TestPiperRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    Input
+		settings AudioSettings
+	}{
+		{name: "SSML", input: Input{SSML: "<speak>Hello</speak>"}, settings: AudioSettings{Format: FormatLinear16}},
+		{name: "MP3", input: Input{Text: "Hello"}, settings: AudioSettings{Format: FormatMP3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p := &Piper{Binary: fakePiper(t, dir), ModelDir: "/models"}
+			if _, err := p.SynthesizeChunk(context.Background(), tt.input, Voice{Name: "en_US-lessac-medium"}, tt.settings); err == nil {
+				t.Error("SynthesizeChunk() succeeded")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "args")); err == nil {
+				t.Error("piper was run")
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/audio"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/polly"
+	pollytypes "github.com/aws/aws-sdk-go-v2/service/polly/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ProviderPolly selects Amazon Polly.
+const ProviderPolly = "polly"
+
+// Polly limits, in characters of input. Bytes are at least as many, so
+// chunking by bytes stays within them.
+const (
+	pollyMaxInputBytes     = 3000
+	pollyMaxLongAudioBytes = 100000
+)
+
+// pollySayAsTypes are the <say-as> types Polly supports. It has no currency,
+// and no language attribute on say-as.
+var pollySayAsTypes = []string{"characters", "spell-out", "cardinal", "number", "ordinal", "digits", "fraction", "unit", "date", "time", "address", "expletive", "telephone"}
+
+// DefaultPollyEngine is used when no engine is configured.
+const DefaultPollyEngine = "neural"
+
+// Polly implements Synthesizer with Amazon Polly. Voices are Polly voice IDs such
+// as "Joanna". Long inputs run as speech synthesis tasks that write to an S3
+// bucket, and the result is copied to GCS once the task is done.
+//
+// AWS credentials and region come from the standard AWS environment
+// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION).
+type Polly struct {
+	Engine       string // "standard", "neural", "long-form" or "generative".
+	OutputBucket string // S3 bucket for speech synthesis tasks; long audio is unavailable without it.
+
+	polly   *polly.Client
+	s3      *s3.Client
+	storage storage.Storage
+	limits  Limits
+}
+
+// newPolly creates the Polly and S3 clients from the default AWS configuration.
+func newPolly(ctx context.Context, cfg ProviderConfig) (*Polly, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	engine := cfg.PollyEngine
+	if engine == "" {
+		engine = DefaultPollyEngine
+	}
+	return &Polly{
+		Engine:       engine,
+		OutputBucket: cfg.PollyOutputBucket,
+		polly:        polly.NewFromConfig(awsCfg),
+		s3:           s3.NewFromConfig(awsCfg),
+		storage:      cfg.Storage,
+		limits:       cfg.Limits,
+	}, nil
+}
+
+// Name implements Synthesizer.
+func (p *Polly) Name() string { return ProviderPolly }
+
+// chunkSlots implements slotted.
+func (p *Polly) chunkSlots() ChunkSlots { return p.limits.ChunkSlots }
+
+// Capabilities implements Synthesizer. Polly takes the speaking rate and pitch
+// only as SSML prosody, so the AudioConfig knobs are dropped, and supports
+// fewer <say-as> types than Google.
+func (p *Polly) Capabilities(voice Voice) Capabilities {
+	return Capabilities{
+		Family:            "Polly " + p.Engine,
+		SSML:              true,
+		LongAudio:         p.OutputBucket != "",
+		MaxInputBytes:     pollyMaxInputBytes,
+		MaxLongAudioBytes: pollyMaxLongAudioBytes,
+		LongAudioFormats:  []AudioFormat{FormatLinear16, FormatMP3},
+		SayAsTypes:        pollySayAsTypes,
+		NoSayAsLanguage:   true,
+	}
+}
+
+// SynthesizeChunk implements Synthesizer with Polly's SynthesizeSpeech.
+func (p *Polly) SynthesizeChunk(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, error) {
+	format, sampleRate, err := pollyFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+	text, textType := pollyText(input, p.Capabilities(voice))
+	req := &polly.SynthesizeSpeechInput{
+		Engine:       pollytypes.Engine(p.Engine),
+		OutputFormat: format,
+		SampleRate:   aws.String(strconv.Itoa(sampleRate)),
+		Text:         aws.String(text),
+		TextType:     textType,
+		VoiceId:      pollytypes.VoiceId(voice.Name),
+	}
+
+	// withRetry applies the TTS_QPS limit; the AWS SDK retries transient errors itself.
+	var resp *polly.SynthesizeSpeechOutput
+	err = p.limits.withRetry(ctx, "Polly SynthesizeSpeech", func(ctx context.Context) error {
+		var err error
+		resp, err = p.polly.SynthesizeSpeech(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize speech with Polly: %w", err)
+	}
+	defer resp.AudioStream.Close()
+	data, err := io.ReadAll(resp.AudioStream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Polly audio: %w", err)
+	}
+	if format == pollytypes.OutputFormatPcm {
+		data = append(audio.WAVHeader(len(data), sampleRate), data...)
+	}
+	return data, nil
+}
+
+// SynthesizeToGCS implements Synthesizer with a Polly speech synthesis task. The
+// returned operation name carries what CheckOperation needs to copy the result.
+func (p *Polly) SynthesizeToGCS(ctx context.Context, input Input, outputGCSURI string, voice Voice, settings AudioSettings) (string, error) {
+	if p.OutputBucket == "" {
+		return "", fmt.Errorf("long audio synthesis with Polly needs POLLY_OUTPUT_BUCKET")
+	}
+	if _, _, err := storage.ParseGCSURI(outputGCSURI); err != nil {
+		return "", err
+	}
+	format, sampleRate, err := pollyFormat(settings)
+	if err != nil {
+		return "", err
+	}
+	text, textType := pollyText(input, p.Capabilities(voice))
+	req := &polly.StartSpeechSynthesisTaskInput{
+		Engine:             pollytypes.Engine(p.Engine),
+		OutputFormat:       format,
+		OutputS3BucketName: aws.String(p.OutputBucket),
+		OutputS3KeyPrefix:  aws.String("pdf-to-speech/"),
+		SampleRate:         aws.String(strconv.Itoa(sampleRate)),
+		Text:               aws.String(text),
+		TextType:           textType,
+		VoiceId:            pollytypes.VoiceId(voice.Name),
+	}
+
+	log.Printf("Starting Polly speech synthesis task with voice %s (%s engine) and %s encoding...", voice.Name, p.Engine, settings.Format)
+	var resp *polly.StartSpeechSynthesisTaskOutput
+	err = p.limits.withRetry(ctx, "Polly StartSpeechSynthesisTask", func(ctx context.Context) error {
+		var err error
+		resp, err = p.polly.StartSpeechSynthesisTask(ctx, req)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start Polly speech synthesis task: %w", err)
+	}
+	operation := url.Values{
+		"task":   {aws.ToString(resp.SynthesisTask.TaskId)},
+		"output": {outputGCSURI},
+		"rate":   {strconv.Itoa(sampleRate)},
+		"pcm":    {strconv.FormatBool(format == pollytypes.OutputFormatPcm)},
+	}
+	return operation.Encode(), nil
+}
+
+// CheckOperation implements Synthesizer. When the task has completed, it copies
+// the audio from S3 to the GCS output before reporting it done.
+func (p *Polly) CheckOperation(ctx context.Context, operation string) (bool, float64, error) {
+	op, err := url.ParseQuery(operation)
+	if err != nil || op.Get("task") == "" {
+		return true, 0, fmt.Errorf("invalid Polly operation %q", operation)
+	}
+	var resp *polly.GetSpeechSynthesisTaskOutput
+	err = p.limits.withRetry(ctx, "Polly GetSpeechSynthesisTask", func(ctx context.Context) error {
+		var err error
+		resp, err = p.polly.GetSpeechSynthesisTask(ctx, &polly.GetSpeechSynthesisTaskInput{TaskId: aws.String(op.Get("task"))})
+		return err
+	})
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to get Polly task %s: %w", op.Get("task"), err)
+	}
+
+	task := resp.SynthesisTask
+	switch task.TaskStatus {
+	case pollytypes.TaskStatusFailed:
+		return true, 0, fmt.Errorf("polly task %s failed: %s", op.Get("task"), aws.ToString(task.TaskStatusReason))
+	case pollytypes.TaskStatusCompleted:
+		if err := p.copyToGCS(ctx, aws.ToString(task.OutputUri), op); err != nil {
+			return false, 100, err
+		}
+		return true, 100, nil
+	default:
+		return false, 0, nil // Polly doesn't report progress.
+	}
+}
+
+// copyToGCS copies a finished task's S3 output to the GCS output of op, adding
+// a WAV header to raw PCM.
+func (p *Polly) copyToGCS(ctx context.Context, outputURI string, op url.Values) error {
+	u, err := url.Parse(outputURI)
+	if err != nil {
+		return fmt.Errorf("invalid Polly output URI %q: %w", outputURI, err)
+	}
+	s3Bucket, s3Key, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if !ok {
+		return fmt.Errorf("unexpected Polly output URI %q", outputURI)
+	}
+	gcsBucket, gcsObject, err := storage.ParseGCSURI(op.Get("output"))
+	if err != nil {
+		return err
+	}
+
+	obj, err := p.s3.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s3Bucket), Key: aws.String(s3Key)})
+	if err != nil {
+		return fmt.Errorf("failed to read Polly output s3://%s/%s: %w", s3Bucket, s3Key, err)
+	}
+	defer obj.Body.Close()
+
+	var body io.Reader = obj.Body
+	contentType := FormatMP3.ContentType
+	if op.Get("pcm") == "true" {
+		rate, _ := strconv.Atoi(op.Get("rate"))
+		body = io.MultiReader(bytes.NewReader(audio.WAVHeader(int(aws.ToInt64(obj.ContentLength)), rate)), obj.Body)
+		contentType = FormatLinear16.ContentType
+	}
+	if err := p.storage.UploadReader(ctx, gcsBucket, gcsObject, body, contentType); err != nil {
+		return fmt.Errorf("failed to copy Polly output to %s: %w", op.Get("output"), err)
+	}
+	return nil
+}
+
+// ListVoices implements Synthesizer, listing the voices that support the configured engine.
+func (p *Polly) ListVoices(ctx context.Context, languageCode string) ([]VoiceInfo, error) {
+	req := &polly.DescribeVoicesInput{Engine: pollytypes.Engine(p.Engine)}
+	if languageCode != "" {
+		req.LanguageCode = pollytypes.LanguageCode(languageCode)
+	}
+
+	var voices []VoiceInfo
+	for {
+		resp, err := p.polly.DescribeVoices(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Polly voices: %w", err)
+		}
+		for _, v := range resp.Voices {
+			languages := []string{string(v.LanguageCode)}
+			for _, l := range v.AdditionalLanguageCodes {
+				languages = append(languages, string(l))
+			}
+			voices = append(voices, VoiceInfo{
+				Name:          string(v.Id),
+				LanguageCodes: languages,
+				Gender:        strings.ToUpper(string(v.Gender)),
+			})
+		}
+		if resp.NextToken == nil {
+			return voices, nil
+		}
+		req.NextToken = resp.NextToken
+	}
+}
+
+// pollyFormat maps the audio settings to a Polly output format and sample rate.
+// LINEAR16 is requested as raw PCM and wrapped in a WAV header afterwards.
+func pollyFormat(settings AudioSettings) (pollytypes.OutputFormat, int, error) {
+	rate := int(settings.SampleRateHertz)
+	switch settings.Format.Encoding {
+	case texttospeechpb.AudioEncoding_MP3:
+		if rate == 0 {
+			rate = 24000
+		}
+		return pollytypes.OutputFormatMp3, rate, nil
+	case texttospeechpb.AudioEncoding_LINEAR16:
+		if rate == 0 {
+			rate = 16000
+		}
+		if rate != 8000 && rate != 16000 {
+			return "", 0, fmt.Errorf("polly produces LINEAR16 at 8000 or 16000 Hz, not %d", rate)
+		}
+		return pollytypes.OutputFormatPcm, rate, nil
+	default:
+		return "", 0, fmt.Errorf("polly can't produce %s audio; use MP3 or LINEAR16", settings.Format)
+	}
+}
+
+// pollyText returns the input's text and its Polly text type, with SSML adapted
+// to what caps supports.
+func pollyText(input Input, caps Capabilities) (string, pollytypes.TextType) {
+	if input.SSML != "" {
+		return caps.AdaptSSML(input.SSML), pollytypes.TextTypeSsml
+	}
+	return input.Text, pollytypes.TextTypeText
+}
@@ -0,0 +1,72 @@
+package tts
+
+import (
+	"testing"
+
+	pollytypes "github.com/aws/aws-sdk-go-v2/service/polly/types"
+)
+
+func TestPollyText(t *testing.T) {
+	caps := (&Polly{Engine: DefaultPollyEngine}).Capabilities(Voice{Name: "Joanna"})
+	tests := []struct {
+		name     string
+		input    Input
+		want     string
+		wantType pollytypes.TextType
+	}{
+		{
+			name:     "plain text",
+			input:    Input{Text: "It costs $5."},
+			want:     "It costs $5.",
+			wantType: pollytypes.TextTypeText,
+		},
+		{
+			name:     "currency read as written",
+			input:    Input{SSML: `<speak>It costs <say-as interpret-as="currency" language="en-US">$1,250.50</say-as>.</speak>`},
+			want:     "<speak>It costs $1,250.50.</speak>",
+			wantType: pollytypes.TextTypeSsml,
+		},
+		{
+			name:     "supported types kept",
+			input:    Input{SSML: `<speak><say-as interpret-as="date" format="dmy">5/3/2024</say-as> <say-as interpret-as="ordinal">21</say-as></speak>`},
+			want:     `<speak><say-as interpret-as="date" format="dmy">5/3/2024</say-as> <say-as interpret-as="ordinal">21</say-as></speak>`,
+			wantType: pollytypes.TextTypeSsml,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotType := pollyText(tt.input, caps)
+			if got != tt.want || gotType != tt.wantType {
+				t.Errorf("pollyText() = %q (%s), want %q (%s)", got, gotType, tt.want, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestPollyFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings AudioSettings
+		want     pollytypes.OutputFormat
+		wantRate int
+		wantErr  bool
+	}{
+		{name: "MP3", settings: AudioSettings{Format: FormatMP3}, want: pollytypes.OutputFormatMp3, wantRate: 24000},
+		{name: "MP3 at a set rate", settings: AudioSettings{Format: FormatMP3, SampleRateHertz: 22050}, want: pollytypes.OutputFormatMp3, wantRate: 22050},
+		{name: "LINEAR16 as PCM", settings: AudioSettings{Format: FormatLinear16}, want: pollytypes.OutputFormatPcm, wantRate: 16000},
+		{name: "LINEAR16 at 8000 Hz", settings: AudioSettings{Format: FormatLinear16, SampleRateHertz: 8000}, want: pollytypes.OutputFormatPcm, wantRate: 8000},
+		{name: "LINEAR16 at an unsupported rate", settings: AudioSettings{Format: FormatLinear16, SampleRateHertz: 24000}, wantErr: true},
+		{name: "unsupported format", settings: AudioSettings{Format: FormatOggOpus}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rate, err := pollyFormat(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pollyFormat() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want || rate != tt.wantRate {
+				t.Errorf("pollyFormat() = %s at %d Hz, want %s at %d Hz", got, rate, tt.want, tt.wantRate)
+			}
+		})
+	}
+}
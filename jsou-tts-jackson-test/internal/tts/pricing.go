@@ -0,0 +1,69 @@
+package tts
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// pricePerMillionChars is the list price in USD per million characters of each
+// voice family, keyed by Capabilities.Family. Prices change; they're meant for
+// estimates and budget checks, not billing.
+var pricePerMillionChars = map[string]float64{
+	"Standard":  4,
+	"Wavenet":   4,
+	"Neural2":   16,
+	"News":      16,
+	"Polyglot":  16,
+	"Casual":    16,
+	"Journey":   30,
+	"Chirp-HD":  30,
+	"Chirp3-HD": 30,
+	"Studio":    160,
+	"Gemini":    30,
+
+	"Custom":        60,
+	"InstantCustom": 60,
+
+	"Polly standard":   4,
+	"Polly neural":     16,
+	"Polly long-form":  100,
+	"Polly generative": 30,
+
+	"Azure": 15,
+
+	"OpenAI tts-1":           15,
+	"OpenAI tts-1-hd":        30,
+	"OpenAI gpt-4o-mini-tts": 12,
+
+	"Piper": 0,
+}
+
+// defaultPricePerMillionChars is assumed for families without a known price, e.g.
+// ElevenLabs, whose pricing is per subscription. It errs on the expensive side.
+const defaultPricePerMillionChars = 160
+
+// PricePerMillionChars returns the estimated price in USD per million characters
+// for voices with the given capabilities.
+func PricePerMillionChars(caps Capabilities) float64 {
+	if price, ok := pricePerMillionChars[caps.Family]; ok {
+		return price
+	}
+	if caps.Family == "" || strings.HasPrefix(caps.Family, "Standard") {
+		return pricePerMillionChars["Standard"]
+	}
+	return defaultPricePerMillionChars
+}
+
+// BilledChars counts the characters of inputs the way providers bill them. SSML
+// markup is included, which slightly overestimates providers that don't bill it.
+func BilledChars(inputs []Input) int {
+	n := 0
+	for _, in := range inputs {
+		if in.SSML != "" {
+			n += utf8.RuneCountInString(in.SSML)
+		} else {
+			n += utf8.RuneCountInString(in.Text)
+		}
+	}
+	return n
+}
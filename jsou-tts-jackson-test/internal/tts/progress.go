@@ -0,0 +1,53 @@
+package tts
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Progress is how far the synthesis of a document has got: the chunks
+// synthesized so far, or for long audio the operation's percentage.
+type Progress struct {
+	ChunksDone int
+	Chunks     int
+	Percent    float64
+}
+
+// ProgressFunc is told of a document's progress. It may be called from several
+// goroutines at once.
+type ProgressFunc func(Progress)
+
+// progressKey is the context key of the ProgressFunc set by WithProgress.
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx that makes the syntheses run with it
+// report their progress to f: after every chunk of a chunked synthesis, and
+// after every poll of a long audio operation.
+func WithProgress(ctx context.Context, f ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+// ProgressFromContext returns the ProgressFunc set by WithProgress, or nil.
+func ProgressFromContext(ctx context.Context) ProgressFunc {
+	f, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return f
+}
+
+// reportProgress passes p to the context's ProgressFunc, if any.
+func reportProgress(ctx context.Context, p Progress) {
+	if f := ProgressFromContext(ctx); f != nil {
+		f(p)
+	}
+}
+
+// chunkCounter counts the synthesized chunks of a document for reportProgress.
+type chunkCounter struct {
+	done  atomic.Int64
+	total int
+}
+
+// chunkDone counts another synthesized chunk and reports the progress.
+func (c *chunkCounter) chunkDone(ctx context.Context) {
+	n := int(c.done.Add(1))
+	reportProgress(ctx, Progress{ChunksDone: n, Chunks: c.total, Percent: 100 * float64(n) / float64(c.total)})
+}
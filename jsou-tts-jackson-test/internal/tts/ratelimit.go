@@ -0,0 +1,31 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// NewRateLimiter returns a limiter allowing qps requests per second, to set as
+// Limits.Limiter of the synthesizers whose requests it should throttle
+// together, including retries and long audio polls. Zero or less returns nil,
+// for no limit.
+func NewRateLimiter(qps float64) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(qps), 1)
+}
+
+// waitForRateLimit blocks until the limiter of l allows another request or ctx
+// is done.
+func (l Limits) waitForRateLimit(ctx context.Context) error {
+	if l.Limiter == nil {
+		return nil
+	}
+	if err := l.Limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for TTS rate limit: %w", err)
+	}
+	return nil
+}
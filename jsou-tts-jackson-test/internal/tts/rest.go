@@ -0,0 +1,44 @@
+package tts
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpClient is shared by the providers that are called over REST.
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// HTTPError is a non-2xx response from a REST-based provider.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// doRequest sends req and returns the response body, or an *HTTPError for a
+// non-2xx status so withRetry can tell transient failures apart.
+func doRequest(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", req.URL.Host, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		const maxBody = 500 // Keep error messages readable.
+		if len(body) > maxBody {
+			body = body[:maxBody]
+		}
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
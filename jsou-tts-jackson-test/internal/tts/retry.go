@@ -0,0 +1,109 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy controls how TTS API calls are retried on transient errors.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first; 1 disables retries.
+	InitialBackoff time.Duration // Upper bound of the first wait.
+	MaxBackoff     time.Duration // Cap on the exponentially growing wait.
+}
+
+// DefaultRetryPolicy retries up to 5 attempts, waiting a random time up to 1s, 2s, 4s and 8s.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 32 * time.Second}
+
+// Limits are what a Synthesizer applies to each of its API calls. Set them in
+// ProviderConfig; the zero value retries with DefaultRetryPolicy.
+type Limits struct {
+	Retry RetryPolicy // DefaultRetryPolicy if MaxAttempts is zero.
+	// Limiter throttles the requests of the synthesizers sharing it; nil
+	// means unlimited. See NewRateLimiter.
+	Limiter *rate.Limiter
+	// ChunkSlots bound the chunk requests of the synthesizers sharing them;
+	// nil means unlimited. See NewChunkSlots.
+	ChunkSlots ChunkSlots
+}
+
+// retryPolicy returns the policy of l, with at least one attempt.
+func (l Limits) retryPolicy() RetryPolicy {
+	p := l.Retry
+	if p.MaxAttempts == 0 {
+		return DefaultRetryPolicy
+	}
+	p.MaxAttempts = max(p.MaxAttempts, 1)
+	return p
+}
+
+// IsRetryable reports whether err is a transient API error worth retrying:
+// quota exhaustion (429), server errors (5xx) and deadlines. The failure of a
+// finished long audio operation, an *OperationError, isn't.
+func IsRetryable(err error) bool {
+	var opErr *OperationError
+	if err == nil || errors.As(err, &opErr) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.ResourceExhausted, codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Aborted, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// withRetry calls fn until it succeeds, fails with a non-retryable error, the
+// policy's attempts are exhausted or ctx is done. Waits between attempts grow
+// exponentially with full jitter so parallel callers don't retry in lockstep.
+func (l Limits) withRetry(ctx context.Context, what string, fn func(ctx context.Context) error) error {
+	retryPolicy := l.retryPolicy()
+	backoff := retryPolicy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		if err := l.waitForRateLimit(ctx); err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+		err := fn(ctx)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt >= retryPolicy.MaxAttempts {
+			return fmt.Errorf("%s failed after %d attempts: %w", what, attempt, err)
+		}
+
+		wait := time.Duration(rand.Int64N(int64(backoff) + 1))
+		log.Printf("Transient error in %s (attempt %d/%d): %v. Retrying in %v...", what, attempt, retryPolicy.MaxAttempts, err, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w (last error: %v)", what, ctx.Err(), err)
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > retryPolicy.MaxBackoff {
+			backoff = retryPolicy.MaxBackoff
+		}
+	}
+}
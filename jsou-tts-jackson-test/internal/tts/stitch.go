@@ -0,0 +1,70 @@
+package tts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"MODULE_NAME/jsou-tts/internal/audio"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// ConcatAudioObjects joins the audio objects at partURIs, produced in the given
+// format, into a single object at outputURI. Like ConcatAudio, but the parts are
+// streamed from GCS instead of held in memory, since long audio output can run
+// to gigabytes.
+func ConcatAudioObjects(ctx context.Context, store storage.Storage, format AudioFormat, partURIs []string, outputURI string) error {
+	outputBucket, outputObject, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		return err
+	}
+	open := func(i int) (io.ReadCloser, int64, error) {
+		bucket, object, err := storage.ParseGCSURI(partURIs[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		return store.OpenObject(ctx, bucket, object)
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close() // Unblocks the writer if the upload fails.
+	go func() {
+		pw.CloseWithError(writeConcatenated(pw, format, len(partURIs), open))
+	}()
+	if err := store.UploadReader(ctx, outputBucket, outputObject, pr, format.ContentType); err != nil {
+		return fmt.Errorf("failed to write joined audio to %s: %w", outputURI, err)
+	}
+	return nil
+}
+
+// writeConcatenated writes the parts opened by open to w as one audio stream.
+func writeConcatenated(w io.Writer, format AudioFormat, parts int, open func(i int) (io.ReadCloser, int64, error)) error {
+	switch format.Encoding {
+	case texttospeechpb.AudioEncoding_LINEAR16:
+		return audio.ConcatWAVStreams(w, parts, open)
+	case texttospeechpb.AudioEncoding_MP3, texttospeechpb.AudioEncoding_OGG_OPUS:
+	default:
+		return fmt.Errorf("concatenating %s audio is not supported", format)
+	}
+
+	for i := range parts {
+		rc, _, err := open(i)
+		if err != nil {
+			return err
+		}
+		r := bufio.NewReader(rc)
+		if i > 0 && format.Encoding == texttospeechpb.AudioEncoding_MP3 {
+			err = audio.SkipID3v2(r)
+		}
+		if err == nil {
+			_, err = io.Copy(w, r)
+		}
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("audio part %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrStopped is returned by a chunked synthesis that stopped starting chunks
+// because the channel set by WithStop was closed. Chunks already under way are
+// finished first, so a resumed synthesis can reuse them.
+var ErrStopped = errors.New("synthesis stopped before every chunk was started")
+
+// stopKey is the context key of the channel set by WithStop.
+type stopKey struct{}
+
+// WithStop returns a copy of ctx that makes the chunked syntheses run with it
+// stop starting new chunks once stop is closed, and return ErrStopped when the
+// chunks under way are done. Unlike cancelling ctx, it doesn't abort requests
+// already sent.
+func WithStop(ctx context.Context, stop <-chan struct{}) context.Context {
+	return context.WithValue(ctx, stopKey{}, stop)
+}
+
+// chunkGate decides whether the chunks of a synthesis may still be started.
+type chunkGate struct {
+	stop    <-chan struct{}
+	skipped atomic.Int64
+}
+
+// newChunkGate returns the gate of a synthesis run with ctx.
+func newChunkGate(ctx context.Context) *chunkGate {
+	stop, _ := ctx.Value(stopKey{}).(<-chan struct{})
+	return &chunkGate{stop: stop}
+}
+
+// open reports whether another chunk may be started, counting it as skipped if
+// not.
+func (g *chunkGate) open() bool {
+	select {
+	case <-g.stop:
+		g.skipped.Add(1)
+		return false
+	default:
+		return true
+	}
+}
+
+// err returns ErrStopped, with the number of chunks skipped, if any were.
+func (g *chunkGate) err(total int) error {
+	if n := g.skipped.Load(); n > 0 {
+		return fmt.Errorf("%d of %d chunks not started: %w", n, total, ErrStopped)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/secrets"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// Synthesizer is a text-to-speech provider. The handler only talks to providers
+// through this interface, so another provider can be configured in place of Google.
+type Synthesizer interface {
+	// Name identifies the provider in logs and pending operation records, e.g. "google".
+	Name() string
+	// Capabilities reports what the provider supports for the given voice.
+	Capabilities(voice Voice) Capabilities
+	// SynthesizeChunk synthesizes one input of at most Capabilities.ChunkBytes and returns the audio.
+	SynthesizeChunk(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, error)
+	// SynthesizeToGCS starts synthesizing a long input straight to outputGCSURI and
+	// returns an operation name to follow with CheckOperation.
+	SynthesizeToGCS(ctx context.Context, input Input, outputGCSURI string, voice Voice, settings AudioSettings) (string, error)
+	// CheckOperation polls an operation started by SynthesizeToGCS once. A failed
+	// operation is returned as an error with done set to true.
+	CheckOperation(ctx context.Context, operation string) (done bool, progress float64, err error)
+	// ListVoices lists the voices available for languageCode, or all voices if it's empty.
+	ListVoices(ctx context.Context, languageCode string) ([]VoiceInfo, error)
+}
+
+// VoiceInfo describes a voice offered by a provider.
+type VoiceInfo struct {
+	Name                   string
+	LanguageCodes          []string
+	Gender                 string // "MALE", "FEMALE", "NEUTRAL", or "" if unknown.
+	NaturalSampleRateHertz int32
+}
+
+// ProviderGoogle is the default provider, Google Cloud Text-to-Speech.
+const ProviderGoogle = "google"
+
+// ProviderConfig holds the settings providers need to be created.
+type ProviderConfig struct {
+	Google        *Client // Google Cloud Text-to-Speech clients; required for the Google provider.
+	ProjectNumber string  // Google Cloud project number, for Long Audio Synthesis.
+	Location      string  // Google Cloud location, for Long Audio Synthesis.
+
+	Storage storage.Storage // Copies the long audio output of Polly and Azure to GCS.
+	Secrets secrets.Reader  // Reads the API keys of Azure, ElevenLabs and OpenAI.
+
+	Limits Limits // Applied to the provider's API calls.
+
+	PollyEngine       string // Polly engine; DefaultPollyEngine if empty.
+	PollyOutputBucket string // S3 bucket for Polly speech synthesis tasks.
+
+	AzureRegion    string // Azure Speech resource region.
+	AzureKeySecret string // Secret Manager secret holding the Azure Speech resource key.
+	// AzureKey keeps the Azure Speech resource key once it has been read, so
+	// the synthesizers created with it read the secret once. If it's nil, each
+	// reads the secret.
+	AzureKey *KeyCache
+
+	ElevenLabsModel     string // ElevenLabs model; DefaultElevenLabsModel if empty.
+	ElevenLabsKeySecret string // Secret Manager secret holding the ElevenLabs API key.
+
+	OpenAIModel     string // OpenAI speech model; DefaultOpenAIModel if empty.
+	OpenAIKeySecret string // Secret Manager secret holding the OpenAI API key.
+
+	PiperBinary   string // Piper executable; DefaultPiperBinary if empty.
+	PiperModelDir string // Directory with Piper voice models.
+}
+
+// KeyCache keeps a key read from a secret, for the synthesizers of a pipeline
+// to share. The zero value is ready to use.
+type KeyCache struct {
+	mu     sync.Mutex
+	secret string // The secret key was read from.
+	key    string
+}
+
+// get returns the payload of secret, reading it with r unless it was read
+// before. A failed read is tried again on the next call.
+func (c *KeyCache) get(ctx context.Context, r secrets.Reader, secret string) (string, error) {
+	if c == nil {
+		return r.Access(ctx, secret)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.key != "" && c.secret == secret {
+		return c.key, nil
+	}
+	key, err := r.Access(ctx, secret)
+	if err != nil {
+		return "", err
+	}
+	c.secret, c.key = secret, key
+	return key, nil
+}
+
+// NewSynthesizer returns the provider with the given name. An empty name selects Google.
+func NewSynthesizer(ctx context.Context, name string, cfg ProviderConfig) (Synthesizer, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ProviderGoogle:
+		if cfg.Google == nil {
+			return nil, fmt.Errorf("the %s provider needs a Text-to-Speech client", ProviderGoogle)
+		}
+		return Google{Client: cfg.Google.WithLimits(cfg.Limits), ProjectNumber: cfg.ProjectNumber, Location: cfg.Location}, nil
+	case ProviderPolly:
+		return newPolly(ctx, cfg)
+	case ProviderAzure:
+		return newAzure(ctx, cfg)
+	case ProviderElevenLabs:
+		return newElevenLabs(ctx, cfg)
+	case ProviderOpenAI:
+		return newOpenAI(ctx, cfg)
+	case ProviderPiper:
+		return newPiper(cfg)
+	default:
+		return nil, fmt.Errorf("unknown TTS provider %q", name)
+	}
+}
+
+// Google implements Synthesizer with Google Cloud Text-to-Speech.
+type Google struct {
+	Client        *Client
+	ProjectNumber string
+	Location      string
+}
+
+// Name implements Synthesizer.
+func (Google) Name() string { return ProviderGoogle }
+
+// chunkSlots implements slotted.
+func (g Google) chunkSlots() ChunkSlots { return g.Client.limits.ChunkSlots }
+
+// Capabilities implements Synthesizer.
+func (Google) Capabilities(voice Voice) Capabilities {
+	switch {
+	case voice.Model != "":
+		return geminiCapabilities
+	case voice.CloningKey != "":
+		return instantCustomCapabilities
+	case voice.CustomModel != "":
+		return customCapabilities
+	}
+	return CapabilitiesFor(voice.Name)
+}
+
+// SynthesizeChunk implements Synthesizer with the synchronous SynthesizeSpeech API.
+func (g Google) SynthesizeChunk(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, error) {
+	return g.Client.SynthesizeSpeech(ctx, input, voice, settings)
+}
+
+// SynthesizeToGCS implements Synthesizer with Long Audio Synthesis.
+func (g Google) SynthesizeToGCS(ctx context.Context, input Input, outputGCSURI string, voice Voice, settings AudioSettings) (string, error) {
+	return g.Client.StartLongAudio(ctx, input, g.ProjectNumber, g.Location, outputGCSURI, voice, settings)
+}
+
+// CheckOperation implements Synthesizer.
+func (g Google) CheckOperation(ctx context.Context, operation string) (bool, float64, error) {
+	return g.Client.CheckLongAudio(ctx, operation)
+}
+
+// ListVoices implements Synthesizer.
+func (g Google) ListVoices(ctx context.Context, languageCode string) ([]VoiceInfo, error) {
+	var resp *texttospeechpb.ListVoicesResponse
+	err := g.Client.limits.withRetry(ctx, "ListVoices", func(ctx context.Context) error {
+		var err error
+		resp, err = g.Client.speech.ListVoices(ctx, &texttospeechpb.ListVoicesRequest{LanguageCode: languageCode})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list voices: %w", err)
+	}
+
+	voices := make([]VoiceInfo, 0, len(resp.GetVoices()))
+	for _, v := range resp.GetVoices() {
+		voices = append(voices, VoiceInfo{
+			Name:                   v.GetName(),
+			LanguageCodes:          v.GetLanguageCodes(),
+			Gender:                 v.GetSsmlGender().String(),
+			NaturalSampleRateHertz: v.GetNaturalSampleRateHertz(),
+		})
+	}
+	return voices, nil
+}
+
+// Bounds of the wait between polls of a long audio operation. Polls start
+// frequent so short books finish promptly and back off for multi-hour ones.
+const (
+	initialPollInterval = 5 * time.Second
+	maxPollInterval     = 60 * time.Second
+)
+
+// WaitForOperation polls an operation started with s.SynthesizeToGCS, backing off
+// between polls, until it completes, fails, or ctx is done.
+func WaitForOperation(ctx context.Context, s Synthesizer, operation string) error {
+	interval := initialPollInterval
+	for {
+		done, progress, err := s.CheckOperation(ctx, operation)
+		if err != nil {
+			return err
+		}
+		if done {
+			log.Printf("%s synthesis operation %s completed successfully.", s.Name(), operation)
+			return nil
+		}
+		log.Printf("Operation %s is %.1f%% complete. Checking again in %v...", operation, progress, interval)
+		reportProgress(ctx, Progress{Percent: progress})
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for operation %s: %w", operation, ctx.Err())
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, maxPollInterval)
+	}
+}
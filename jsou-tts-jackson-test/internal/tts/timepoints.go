@@ -0,0 +1,169 @@
+package tts
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/audio"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"golang.org/x/sync/errgroup"
+	texttospeechbeta "google.golang.org/api/texttospeech/v1beta1"
+)
+
+// Timepoint is the time at which an SSML <mark> was reached in the audio.
+type Timepoint struct {
+	MarkName string
+	Offset   time.Duration
+}
+
+// SynthesizeSpeechWithTimepoints is like SynthesizeSpeech but also returns the
+// time offset of every <mark> in the SSML input.
+func (c *Client) SynthesizeSpeechWithTimepoints(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, []Timepoint, error) {
+	if input.Len() > MaxStandardInputBytes {
+		return nil, nil, fmt.Errorf("input of %d bytes exceeds the %d byte limit of standard synthesis", input.Len(), MaxStandardInputBytes)
+	}
+
+	req := texttospeechbeta.SynthesizeSpeechRequest{
+		Input:              betaInput(input),
+		Voice:              betaVoice(voice.params()),
+		AudioConfig:        betaAudioConfig(settings.audioConfig()),
+		EnableTimePointing: []string{"SSML_MARK"},
+	}
+
+	var resp *texttospeechbeta.SynthesizeSpeechResponse
+	err := c.limits.withRetry(ctx, "SynthesizeSpeech", func(ctx context.Context) error {
+		var err error
+		resp, err = c.beta.Text.Synthesize(&req).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to synthesize speech with timepoints: %w", err)
+	}
+	content, err := base64.StdEncoding.DecodeString(resp.AudioContent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode the synthesized audio: %w", err)
+	}
+
+	timepoints := make([]Timepoint, 0, len(resp.Timepoints))
+	for _, tp := range resp.Timepoints {
+		timepoints = append(timepoints, Timepoint{
+			MarkName: tp.MarkName,
+			Offset:   time.Duration(tp.TimeSeconds * float64(time.Second)),
+		})
+	}
+	return content, timepoints, nil
+}
+
+// SynthesizeSegmentsWithTimepoints is like SynthesizeSegments but requests
+// timepoints for every segment and returns them shifted onto the timeline of the
+// concatenated audio, using the duration of each preceding segment.
+func (c *Client) SynthesizeSegmentsWithTimepoints(ctx context.Context, segments []Segment, settings AudioSettings, workers int) ([][]byte, []Timepoint, error) {
+	if workers <= 0 {
+		workers = DefaultChunkConcurrency
+	}
+
+	results := make([][]byte, len(segments))
+	points := make([][]Timepoint, len(segments))
+	counter := &chunkCounter{total: len(segments)}
+	gate := newChunkGate(ctx)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+
+	for i, segment := range segments {
+		g.Go(func() error {
+			if !gate.open() {
+				return nil
+			}
+			release, err := c.limits.ChunkSlots.acquire(gctx)
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			audio, tps, err := c.SynthesizeSpeechWithTimepoints(gctx, segment.Input, segment.Voice, settings)
+			release()
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(segments), err)
+			}
+			results[i], points[i] = audio, tps
+			log.Printf("Synthesized chunk %d/%d with %d timepoints.", i+1, len(segments), len(tps))
+			counter.chunkDone(ctx)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	if err := gate.err(len(segments)); err != nil {
+		return nil, nil, err
+	}
+
+	var timeline []Timepoint
+	var offset time.Duration
+	for i, part := range results {
+		for _, tp := range points[i] {
+			timeline = append(timeline, Timepoint{MarkName: tp.MarkName, Offset: offset + tp.Offset})
+		}
+		d, err := AudioDuration(settings.Format, part)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to measure chunk %d/%d: %w", i+1, len(segments), err)
+		}
+		offset += d
+	}
+	return results, timeline, nil
+}
+
+// AudioDuration returns the playing time of audio encoded in format.
+func AudioDuration(format AudioFormat, data []byte) (time.Duration, error) {
+	switch format.Encoding {
+	case texttospeechpb.AudioEncoding_MP3:
+		return audio.MP3Duration(data)
+	case texttospeechpb.AudioEncoding_OGG_OPUS:
+		return audio.OggOpusDuration(data)
+	case texttospeechpb.AudioEncoding_LINEAR16:
+		return audio.WAVDuration(data)
+	default:
+		return 0, fmt.Errorf("measuring %s audio is not supported", format)
+	}
+}
+
+// betaInput converts an Input into the v1beta1 SynthesisInput.
+func betaInput(in Input) *texttospeechbeta.SynthesisInput {
+	if in.SSML != "" {
+		return &texttospeechbeta.SynthesisInput{Ssml: in.SSML}
+	}
+	return &texttospeechbeta.SynthesisInput{Text: in.Text}
+}
+
+// betaVoice converts v1 VoiceSelectionParams into their v1beta1 equivalent.
+// The REST API names enum values as the v1 enums do.
+func betaVoice(p *texttospeechpb.VoiceSelectionParams) *texttospeechbeta.VoiceSelectionParams {
+	beta := &texttospeechbeta.VoiceSelectionParams{
+		LanguageCode: p.LanguageCode,
+		Name:         p.Name,
+		SsmlGender:   p.SsmlGender.String(),
+	}
+	if c := p.GetCustomVoice(); c != nil {
+		beta.CustomVoice = &texttospeechbeta.CustomVoiceParams{
+			Model:         c.Model,
+			ReportedUsage: c.ReportedUsage.String(),
+		}
+	}
+	if c := p.GetVoiceClone(); c != nil {
+		beta.VoiceClone = &texttospeechbeta.VoiceCloneParams{VoiceCloningKey: c.VoiceCloningKey}
+	}
+	return beta
+}
+
+// betaAudioConfig converts a v1 AudioConfig into its v1beta1 equivalent.
+func betaAudioConfig(c *texttospeechpb.AudioConfig) *texttospeechbeta.AudioConfig {
+	return &texttospeechbeta.AudioConfig{
+		AudioEncoding:    c.AudioEncoding.String(),
+		SpeakingRate:     c.SpeakingRate,
+		Pitch:            c.Pitch,
+		VolumeGainDb:     c.VolumeGainDb,
+		SampleRateHertz:  int64(c.SampleRateHertz),
+		EffectsProfileId: c.EffectsProfileId,
+	}
+}
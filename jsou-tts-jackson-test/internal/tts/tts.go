@@ -2,81 +2,180 @@ package tts
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"time"
 
-	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	texttospeech "cloud.google.com/go/texttospeech/apiv1"
 	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"google.golang.org/api/option"
+	texttospeechbeta "google.golang.org/api/texttospeech/v1beta1"
 	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/types/known/anypb"
 )
 
-// Global TTS Client for reusability (Long Audio Synthesis).
-var client *texttospeech.TextToSpeechLongAudioSynthesizeClient
+// MaxStandardInputBytes is the largest input the synchronous SynthesizeSpeech API accepts.
+const MaxStandardInputBytes = 5000
 
-func init() {
-	var err error
-	client, err = texttospeech.NewTextToSpeechLongAudioSynthesizeClient(context.Background())
+// MaxLongAudioInputBytes is the largest input a single Long Audio Synthesis operation accepts.
+const MaxLongAudioInputBytes = 1000000
+
+// Client holds the Google Cloud Text-to-Speech clients: Long Audio Synthesis,
+// standard synthesis, and the v1beta1 REST API, the only API version that
+// returns timepoints.
+// Create it with NewClient; it's safe for concurrent use.
+type Client struct {
+	longAudio *texttospeech.TextToSpeechLongAudioSynthesizeClient
+	speech    *texttospeech.Client
+	beta      *texttospeechbeta.Service
+	limits    Limits
+}
+
+// NewClient creates the Google clients with the default credentials. endpoint,
+// a host with an optional port such as "eu-texttospeech.googleapis.com" (see
+// RegionalEndpoint), sends requests there instead of the global endpoint;
+// leave it empty for the global one.
+func NewClient(ctx context.Context, endpoint string) (*Client, error) {
+	var opts, restOpts []option.ClientOption
+	if endpoint != "" {
+		endpoint = withDefaultPort(endpoint)
+		opts = append(opts, option.WithEndpoint(endpoint))
+		restOpts = append(restOpts, option.WithEndpoint("https://"+endpoint+"/"))
+	}
+
+	longAudio, err := texttospeech.NewTextToSpeechLongAudioSynthesizeClient(ctx, opts...)
 	if err != nil {
-		log.Fatalf("Failed to create Text-to-Speech Long Audio Synthesis client in internal/tts: %v", err)
+		return nil, fmt.Errorf("failed to create Text-to-Speech Long Audio Synthesis client: %w", err)
+	}
+	speech, err := texttospeech.NewClient(ctx, opts...)
+	if err != nil {
+		longAudio.Close()
+		return nil, fmt.Errorf("failed to create Text-to-Speech client: %w", err)
+	}
+	beta, err := texttospeechbeta.NewService(ctx, restOpts...)
+	if err != nil {
+		longAudio.Close()
+		speech.Close()
+		return nil, fmt.Errorf("failed to create Text-to-Speech v1beta1 client: %w", err)
+	}
+	if endpoint != "" {
+		log.Printf("Using the Text-to-Speech endpoint %s.", endpoint)
 	}
+	return &Client{longAudio: longAudio, speech: speech, beta: beta}, nil
 }
 
-// SynthesizeLongAudio performs text-to-speech synthesis for long texts
-// and outputs the audio directly to a GCS URI. It polls the operation until completion.
-func SynthesizeLongAudio(ctx context.Context, text, projectNumber, location, outputGCSURI, voiceName string) error {
+// WithLimits returns a Client sharing the connections of c whose calls apply
+// limits.
+func (c *Client) WithLimits(limits Limits) *Client {
+	limited := *c
+	limited.limits = limits
+	return &limited
+}
+
+// Close closes the underlying clients, which every Client returned by
+// WithLimits shares.
+func (c *Client) Close() error {
+	return errors.Join(c.longAudio.Close(), c.speech.Close())
+}
+
+// SupportsLongAudio reports whether the Long Audio Synthesis API can write the given format.
+// It currently only produces LINEAR16.
+func SupportsLongAudio(format AudioFormat) bool {
+	return format.Encoding == texttospeechpb.AudioEncoding_LINEAR16
+}
+
+// SynthesizeSpeech performs synchronous text-to-speech synthesis for inputs up to
+// MaxStandardInputBytes and returns the encoded audio. Unlike long audio synthesis
+// it supports every AudioFormat and returns without a long-running operation.
+func (c *Client) SynthesizeSpeech(ctx context.Context, input Input, voice Voice, settings AudioSettings) ([]byte, error) {
+	if input.Len() > MaxStandardInputBytes {
+		return nil, fmt.Errorf("input of %d bytes exceeds the %d byte limit of standard synthesis", input.Len(), MaxStandardInputBytes)
+	}
+
+	req := texttospeechpb.SynthesizeSpeechRequest{
+		Input:       input.synthesisInput(),
+		Voice:       voice.params(),
+		AudioConfig: settings.audioConfig(),
+	}
+	if voice.Prompt != "" {
+		req.Input.Prompt = proto.String(voice.Prompt)
+	}
+
+	var resp *texttospeechpb.SynthesizeSpeechResponse
+	err := c.limits.withRetry(ctx, "SynthesizeSpeech", func(ctx context.Context) error {
+		var err error
+		resp, err = c.speech.SynthesizeSpeech(ctx, &req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize speech: %w", err)
+	}
+
+	log.Printf("Standard synthesis complete. Audio size: %d bytes.", len(resp.AudioContent))
+	return resp.AudioContent, nil
+}
+
+// StartLongAudio starts a Long Audio Synthesis operation writing to outputGCSURI
+// and returns its name without waiting for it. Use CheckLongAudio to follow it,
+// possibly from another invocation.
+func (c *Client) StartLongAudio(ctx context.Context, input Input, projectNumber, location, outputGCSURI string, voice Voice, settings AudioSettings) (string, error) {
 	req := texttospeechpb.SynthesizeLongAudioRequest{
-		Input: &texttospeechpb.SynthesisInput{
-			InputSource: &texttospeechpb.SynthesisInput_Text{Text: text},
-		},
-		AudioConfig: &texttospeechpb.AudioConfig{
-			AudioEncoding:   texttospeechpb.AudioEncoding_LINEAR16, // Changed from MP3 to LINEAR16
-			SampleRateHertz: 16000,                                 // LINEAR16 often requires a sample rate. 16kHz is common.
-		},
-		Voice: &texttospeechpb.VoiceSelectionParams{
-			LanguageCode: "en-US",
-			SsmlGender:   texttospeechpb.SsmlVoiceGender_NEUTRAL,
-			Name:         voiceName,
-		},
+		Input:        input.synthesisInput(),
+		AudioConfig:  settings.audioConfig(),
+		Voice:        voice.params(),
 		OutputGcsUri: outputGCSURI,
 		Parent:       fmt.Sprintf("projects/%s/locations/%s", projectNumber, location),
 	}
 
-	log.Println("Initiating Long Audio Synthesis...")
-	op, err := client.SynthesizeLongAudio(ctx, &req)
+	log.Printf("Initiating Long Audio Synthesis with voice %s (%s) and %s encoding...", voice.Name, req.Voice.LanguageCode, settings.Format)
+	var op *texttospeech.SynthesizeLongAudioOperation
+	err := c.limits.withRetry(ctx, "SynthesizeLongAudio", func(ctx context.Context) error {
+		var err error
+		op, err = c.longAudio.SynthesizeLongAudio(ctx, &req)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to initiate long audio synthesis: %w", err)
+		return "", fmt.Errorf("failed to initiate long audio synthesis: %w", err)
 	}
+	return op.Name(), nil
+}
+
+// OperationError is the failure of a long audio operation that has finished.
+// Polling it again can't change the outcome, so it's never retryable, whatever
+// the code of the status the operation failed with.
+type OperationError struct {
+	Operation string
+	Err       error
+}
 
-	log.Printf("Long Audio Synthesis operation started: %s. Waiting for completion...", op.Name())
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("long audio synthesis operation %s failed: %v", e.Operation, e.Err)
+}
 
-	for {
-		latestOp, err := client.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: op.Name()})
-		if err != nil {
-			return fmt.Errorf("failed to get operation status for %s: %w", op.Name(), err)
-		}
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
 
-		if latestOp.Done {
-			if latestOp.GetError() != nil {
-				return fmt.Errorf("long audio synthesis operation failed for %s: %v", op.Name(), latestOp.GetError().Message)
-			}
-			var metadata texttospeechpb.SynthesizeLongAudioMetadata
-			if latestOp.GetMetadata() != nil {
-				if err := anypb.UnmarshalTo(latestOp.GetMetadata(), &metadata, proto.UnmarshalOptions{}); err != nil {
-					log.Printf("Warning: Could not unmarshal operation metadata for %s: %v", op.Name(), err)
-				} else {
-					log.Printf("Long Audio Synthesis complete. Metadata: %s", &metadata)
-				}
-			}
-			log.Printf("Long Audio Synthesis operation %s completed successfully.", op.Name())
-			break
+// CheckLongAudio polls a previously started operation once. It reports whether
+// the operation is done and its progress percentage; a failed operation is
+// returned as an *OperationError with done set to true.
+func (c *Client) CheckLongAudio(ctx context.Context, operationName string) (done bool, progress float64, err error) {
+	op := c.longAudio.SynthesizeLongAudioOperation(operationName)
+	err = c.limits.withRetry(ctx, "poll "+operationName, func(ctx context.Context) error {
+		_, err := op.Poll(ctx)
+		if err != nil && op.Done() {
+			return &OperationError{Operation: operationName, Err: err}
 		}
-
-		log.Printf("Operation %s not yet complete. Retrying in 10 seconds...", op.Name())
-		time.Sleep(10 * time.Second)
+		return err
+	})
+	if metadata, mdErr := op.Metadata(); mdErr == nil && metadata != nil {
+		progress = metadata.GetProgressPercentage()
 	}
-
-	return nil
+	var opErr *OperationError
+	if errors.As(err, &opErr) {
+		return true, progress, err
+	}
+	if err != nil {
+		return op.Done(), progress, fmt.Errorf("long audio synthesis operation %s failed: %w", operationName, err)
+	}
+	return op.Done(), progress, nil
 }
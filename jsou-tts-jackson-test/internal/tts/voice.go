@@ -0,0 +1,149 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+)
+
+// DefaultLanguageCode is used when neither the voice name nor the document reveals a language.
+const DefaultLanguageCode = "en-US"
+
+// Voice selects the voice used for synthesis.
+type Voice struct {
+	Name         string // e.g. "de-DE-Wavenet-B", or a speaker such as "Kore" for Gemini voices
+	LanguageCode string // BCP-47, e.g. "de-DE"
+	Model        string // Model of Gemini voices, e.g. "gemini-2.5-flash-tts"; empty otherwise.
+	Prompt       string // Style instructions for Gemini voices, e.g. "Read this like a bedtime story."
+	// Gender lets the API pick a voice when Name is empty: "MALE", "FEMALE" or
+	// "NEUTRAL". It's not sent with a named voice, whose gender is fixed.
+	Gender string
+
+	// Custom voices replace the named voice. CustomModel is a trained Custom Voice
+	// model, "projects/<project>/locations/<location>/models/<model>", and
+	// CustomUsage its reported usage, "REALTIME" or "OFFLINE" ("" leaves the API
+	// default). CloningKey is the key of an instant custom voice (voice cloning),
+	// issued once the speaker's consent recording has been verified.
+	CustomModel string
+	CustomUsage string
+	CloningKey  string
+}
+
+// Custom reports whether the voice is a custom or cloned voice rather than a stock one.
+func (v Voice) Custom() bool {
+	return v.CustomModel != "" || v.CloningKey != ""
+}
+
+// ParseVoice parses a voice setting. Most voices, including the Chirp 3 HD ones
+// ("en-US-Chirp3-HD-Charon"), are plain voice names. Gemini voices are written
+// as "<model>:<speaker>", e.g. "gemini-2.5-flash-tts:Kore", because the same
+// speaker is offered by several models. The language comes from the name's
+// prefix when it has one.
+func ParseVoice(setting string) Voice {
+	if model, speaker, ok := strings.Cut(setting, ":"); ok {
+		return Voice{Name: speaker, Model: model, LanguageCode: LanguageFromVoice(speaker)}
+	}
+	return Voice{Name: setting, LanguageCode: LanguageFromVoice(setting)}
+}
+
+// String returns the voice in the form ParseVoice accepts.
+func (v Voice) String() string {
+	if v.Model != "" {
+		return v.Model + ":" + v.Name
+	}
+	return v.Name
+}
+
+// ParseGender validates a voice gender setting, case-insensitively, and returns
+// it in the form Voice.Gender takes. An empty setting yields "".
+func ParseGender(setting string) (string, error) {
+	switch gender := strings.ToUpper(strings.TrimSpace(setting)); gender {
+	case "", "MALE", "FEMALE", "NEUTRAL":
+		return gender, nil
+	default:
+		return "", fmt.Errorf("invalid voice gender %q (want male, female or neutral)", setting)
+	}
+}
+
+// voiceLanguagePattern matches the language prefix of Google voice names
+// such as "en-US-Wavenet-D" or "cmn-CN-Standard-A".
+var voiceLanguagePattern = regexp.MustCompile(`^([a-z]{2,3}-[A-Z]{2})-`)
+
+// LanguageFromVoice extracts the BCP-47 language code from a voice name,
+// e.g. "de-DE" from "de-DE-Wavenet-B". It returns "" if the name carries no language.
+func LanguageFromVoice(voiceName string) string {
+	if m := voiceLanguagePattern.FindStringSubmatch(voiceName); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// params converts the voice into the API's VoiceSelectionParams.
+func (v Voice) params() *texttospeechpb.VoiceSelectionParams {
+	languageCode := v.LanguageCode
+	if languageCode == "" {
+		languageCode = LanguageFromVoice(v.Name)
+	}
+	if languageCode == "" {
+		languageCode = DefaultLanguageCode
+	}
+	params := &texttospeechpb.VoiceSelectionParams{
+		LanguageCode: languageCode,
+		Name:         v.Name,
+		ModelName:    v.Model,
+	}
+	if v.Name == "" && v.Gender != "" {
+		params.SsmlGender = texttospeechpb.SsmlVoiceGender(texttospeechpb.SsmlVoiceGender_value[v.Gender])
+	}
+	if v.CustomModel != "" {
+		params.Name = "" // The model selects the voice.
+		params.CustomVoice = &texttospeechpb.CustomVoiceParams{Model: v.CustomModel}
+		if usage, ok := texttospeechpb.CustomVoiceParams_ReportedUsage_value[v.CustomUsage]; ok {
+			params.CustomVoice.ReportedUsage = texttospeechpb.CustomVoiceParams_ReportedUsage(usage)
+		}
+	}
+	if v.CloningKey != "" {
+		params.Name = ""
+		params.VoiceClone = &texttospeechpb.VoiceCloneParams{VoiceCloningKey: v.CloningKey}
+	}
+	return params
+}
+
+// VoiceMap maps language codes to the default voice for that language. Keys may be
+// full BCP-47 codes ("pt-BR") or bare languages ("de"); full codes win.
+type VoiceMap map[string]string
+
+// ParseVoiceMap parses a JSON object such as {"de-DE": "de-DE-Wavenet-B", "fr": "fr-FR-Neural2-A"}.
+// An empty string yields an empty map.
+func ParseVoiceMap(raw string) (VoiceMap, error) {
+	m := VoiceMap{}
+	if strings.TrimSpace(raw) == "" {
+		return m, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil, fmt.Errorf("invalid voice map JSON: %w", err)
+	}
+	for lang, name := range m {
+		if name == "" {
+			return nil, fmt.Errorf("voice map entry %q has no voice name", lang)
+		}
+	}
+	return m, nil
+}
+
+// Lookup returns the voice configured for languageCode, trying the full code
+// first and then its primary language subtag (case-insensitively).
+func (m VoiceMap) Lookup(languageCode string) (string, bool) {
+	lang, _, _ := strings.Cut(languageCode, "-")
+	for _, key := range []string{languageCode, lang} {
+		for k, name := range m {
+			if strings.EqualFold(k, key) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
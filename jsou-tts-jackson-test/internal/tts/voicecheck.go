@@ -0,0 +1,84 @@
+package tts
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// resolvedVoices caches ResolveVoice results for the life of the instance, so
+// ListVoices is called once per provider, language and voice, not per document.
+var (
+	resolvedVoicesMu sync.Mutex
+	resolvedVoices   = map[string]Voice{}
+)
+
+// ResolveVoice checks voice against the voices s lists for its language. If the
+// voice doesn't exist (e.g., it isn't offered in the region), it returns a
+// compatible voice for the same language instead: one of the same family if
+// there is one, otherwise one with the same SSML support. substituted reports
+// whether the voice was replaced. Gemini voices, which are speakers of a model,
+// custom voices and unnamed voices, which the API picks, are returned unchanged.
+func ResolveVoice(ctx context.Context, s Synthesizer, voice Voice) (resolved Voice, substituted bool, err error) {
+	if voice.Name == "" || voice.Model != "" || voice.Custom() {
+		return voice, false, nil
+	}
+	key := s.Name() + "|" + voice.LanguageCode + "|" + voice.Name
+	resolvedVoicesMu.Lock()
+	cached, ok := resolvedVoices[key]
+	resolvedVoicesMu.Unlock()
+	if ok {
+		return withVoiceName(voice, cached.Name), cached.Name != voice.Name, nil
+	}
+
+	available, err := s.ListVoices(ctx, voice.LanguageCode)
+	if err != nil {
+		return voice, false, err
+	}
+	resolved = voice
+	if !slices.ContainsFunc(available, func(v VoiceInfo) bool { return v.Name == voice.Name }) {
+		fallback, ok := compatibleVoice(s, voice, available)
+		if !ok {
+			return voice, false, fmt.Errorf("voice %s doesn't exist and %s offers no %s voices to use instead", voice.Name, s.Name(), voice.LanguageCode)
+		}
+		resolved = withVoiceName(voice, fallback)
+	}
+
+	resolvedVoicesMu.Lock()
+	resolvedVoices[key] = resolved
+	resolvedVoicesMu.Unlock()
+	return resolved, resolved.Name != voice.Name, nil
+}
+
+// withVoiceName returns voice with its name replaced, keeping the language.
+func withVoiceName(voice Voice, name string) Voice {
+	voice.Name = name
+	return voice
+}
+
+// compatibleVoice picks the available voice closest to voice: same family first,
+// then same SSML support, then by name so the choice is stable.
+func compatibleVoice(s Synthesizer, voice Voice, available []VoiceInfo) (string, bool) {
+	if len(available) == 0 {
+		return "", false
+	}
+	family := VoiceFamily(voice.Name)
+	ssml := s.Capabilities(voice).SSML
+	score := func(v VoiceInfo) int {
+		n := 0
+		if family != "" && VoiceFamily(v.Name) == family {
+			n += 2
+		}
+		if s.Capabilities(Voice{Name: v.Name, LanguageCode: voice.LanguageCode}).SSML == ssml {
+			n++
+		}
+		return n
+	}
+	best := slices.MinFunc(available, func(a, b VoiceInfo) int {
+		return cmp.Or(score(b)-score(a), strings.Compare(a.Name, b.Name))
+	})
+	return best.Name, true
+}
@@ -0,0 +1,385 @@
+package pdftospeech
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/jobtrack"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	v2 "github.com/cloudevents/sdk-go/v2"
+)
+
+// Job states. A job is queued when created, running once an invocation has
+// claimed it, and done or failed when the handler returns. A queued job the
+// Jobs API cancels is cancelled right away; a running one is cancelling until
+// the invocation running it notices and stops.
+const (
+	jobQueued     = "queued"
+	jobRunning    = "running"
+	jobDone       = "done"
+	jobFailed     = "failed"
+	jobCancelling = "cancelling"
+	jobCancelled  = "cancelled"
+)
+
+// jobCancelPollInterval is how often a running job's record is checked for a
+// cancellation.
+const jobCancelPollInterval = 10 * time.Second
+
+// jobHeartbeatInterval is how often a running job's updated_at is refreshed,
+// well within SWEEP_STUCK_AFTER, so the sweeper can tell it from a stuck one.
+const jobHeartbeatInterval = time.Minute
+
+// errJobCancelled is the cause of the context of a job cancelled while running.
+var errJobCancelled = errors.New("job cancelled")
+
+// jobIDPattern matches the IDs newJobID makes.
+var jobIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// onDemandJob is the record of a job started over HTTP, kept in JOBS_COLLECTION.
+type onDemandJob = jobtrack.OnDemandJob
+
+// jobStore keeps the records of on-demand jobs: the job tracker of
+// JOBS_COLLECTION, whose methods of the same names describe what each does.
+type jobStore interface {
+	CreateJob(ctx context.Context, job onDemandJob) error
+	GetJob(ctx context.Context, id string) (*onDemandJob, error)
+	UpdateJob(ctx context.Context, id string, update func(*onDemandJob) (bool, error)) (*onDemandJob, error)
+	ListJobs(ctx context.Context, withStatus, after string, limit int) ([]onDemandJob, error)
+	LatestJob(ctx context.Context, input string) (*onDemandJob, error)
+	JobsUpdatedBefore(ctx context.Context, statuses []string, cutoff time.Time) ([]onDemandJob, error)
+}
+
+// messagePublisher publishes messages to a Pub/Sub topic, as events.Publisher
+// does.
+type messagePublisher interface {
+	PublishData(ctx context.Context, data []byte, attributes map[string]string) error
+}
+
+// jobMessage is the Pub/Sub message published to JOBS_TOPIC for each queued
+// job, asking RunOnDemandJob to run it.
+type jobMessage struct {
+	Job string `json:"job"`
+}
+
+// jobRequest is the body of a POST to ProcessOnDemand.
+type jobRequest struct {
+	URI     string            `json:"uri"`
+	Options map[string]string `json:"options"`
+
+	contentType string // The input's content type, if the caller knows it.
+}
+
+// newJobID returns a random job ID.
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// processOnDemand serves the ProcessOnDemand entry point. POST /process with
+// {"uri": "gs://bucket/path/book.pdf", "options": {"tts-voice": ...}} queues a
+// job for a PDF in an input folder of BASE_GCS_BUCKET, e.g. with other
+// settings than its metadata's, and responds 202 with the job's record, whose id GET
+// /process?job=ID returns the current record for. Options are per-document
+// settings named like the metadata keys and override the object's metadata.
+// Records are kept in JOBS_COLLECTION, and each queued job is published to
+// JOBS_TOPIC for RunOnDemandJob to run. Like the Jobs API, requests need the
+// key in JOBS_API_KEY_SECRET.
+func processOnDemand(w http.ResponseWriter, r *http.Request) {
+	p, err := functionPipeline()
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, "service unavailable, try again", http.StatusServiceUnavailable)
+		return
+	}
+	cfg := p.cfg
+	bucket := cfg.BaseBucket
+	if bucket == "" {
+		log.Printf("Error: BASE_GCS_BUCKET must be set for ProcessOnDemand")
+		http.Error(w, "function misconfigured", http.StatusInternalServerError)
+		return
+	}
+	if p.jobsAPIKey == "" {
+		log.Printf("Error: JOBS_API_KEY_SECRET must be set for ProcessOnDemand")
+		http.Error(w, "function misconfigured", http.StatusInternalServerError)
+		return
+	}
+	if err := p.checkOnDemandJobs(); err != nil {
+		log.Printf("Error: %v for ProcessOnDemand", err)
+		http.Error(w, "function misconfigured", http.StatusInternalServerError)
+		return
+	}
+	p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) { p.serveOnDemand(w, r, bucket) })(w, r)
+}
+
+// serveOnDemand serves an authorized request to ProcessOnDemand for the jobs of
+// bucket.
+func (p *Pipeline) serveOnDemand(w http.ResponseWriter, r *http.Request, bucket string) {
+	switch r.Method {
+	case http.MethodGet:
+		id := r.URL.Query().Get("job")
+		if !jobIDPattern.MatchString(id) {
+			http.Error(w, "pass the job ID as ?job=", http.StatusBadRequest)
+			return
+		}
+		job, err := p.loadJob(r.Context(), id)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		writeJobJSON(w, http.StatusOK, job)
+	case http.MethodPost:
+		body, ok := readEventBody(w, r)
+		if !ok {
+			return
+		}
+		var req jobRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		job, status, err := p.queueJob(r.Context(), bucket, req)
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				log.Printf("Error: %v", err)
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		log.Printf("Queued job %s for %s.", job.ID, job.Input)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", r.URL.Path+"?job="+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	default:
+		http.Error(w, "POST a job or GET ?job=ID", http.StatusMethodNotAllowed)
+	}
+}
+
+// checkOnDemandJobs returns why on-demand jobs can't be queued or run, if they
+// can't: their records are kept in JOBS_COLLECTION, and they're handed to
+// RunOnDemandJob through JOBS_TOPIC.
+func (p *Pipeline) checkOnDemandJobs() error {
+	switch {
+	case p.jobs == nil:
+		return errors.New("JOBS_COLLECTION must be set")
+	case p.jobPublisher == nil:
+		return errors.New("JOBS_TOPIC must be set")
+	}
+	return nil
+}
+
+// queueJob checks a job request and queues a job for it. Only PDFs in an input
+// folder of bucket, or uploaded to the Jobs API, are accepted. On failure it
+// also returns the HTTP status to respond with.
+func (p *Pipeline) queueJob(ctx context.Context, bucket string, req jobRequest) (onDemandJob, int, error) {
+	inputBucket, inputObject, err := storage.ParseGCSURI(req.URI)
+	if err != nil {
+		return onDemandJob{}, http.StatusBadRequest, err
+	}
+	// Jobs only read what was put in bucket to be converted, not whatever else
+	// the service account can read.
+	if inputBucket != bucket || !p.cfg.isInput(inputObject) && !strings.HasPrefix(inputObject, jobUploadsPrefix) {
+		return onDemandJob{}, http.StatusForbidden, fmt.Errorf("%s isn't in an input folder of gs://%s", req.URI, bucket)
+	}
+	if !isPDFInput(inputObject, req.contentType) && !isAudiobookManifest(inputObject) {
+		return onDemandJob{}, http.StatusBadRequest, fmt.Errorf("%s isn't a PDF", req.URI)
+	}
+	if _, exists, err := p.store.ObjectMetadata(ctx, inputBucket, inputObject); err != nil {
+		return onDemandJob{}, http.StatusInternalServerError, err
+	} else if !exists {
+		return onDemandJob{}, http.StatusNotFound, fmt.Errorf("%s doesn't exist", req.URI)
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return onDemandJob{}, http.StatusInternalServerError, fmt.Errorf("failed to create a job ID: %w", err)
+	}
+	return p.createJob(ctx, id, req)
+}
+
+// createJob writes the record of a new queued job with the given ID for the
+// PDF of a checked request, and publishes it to JOBS_TOPIC. On failure it also
+// returns the HTTP status to respond with.
+func (p *Pipeline) createJob(ctx context.Context, id string, req jobRequest) (onDemandJob, int, error) {
+	now := time.Now().UTC()
+	job := onDemandJob{ID: id, Input: req.URI, Options: req.Options, Status: jobQueued, ContentType: req.contentType, CreatedAt: now, UpdatedAt: now}
+	if err := p.jobs.CreateJob(ctx, job); err != nil {
+		return onDemandJob{}, http.StatusInternalServerError, fmt.Errorf("failed to save job %s: %w", id, err)
+	}
+	if err := p.publishJob(ctx, id); err != nil {
+		// The caller is told the job wasn't queued and may submit it again, so
+		// it's failed rather than left for the sweeper to publish.
+		_, failErr := p.jobs.UpdateJob(context.WithoutCancel(ctx), id, func(job *onDemandJob) (bool, error) {
+			job.Status, job.Error = jobFailed, err.Error()
+			return true, nil
+		})
+		if failErr != nil {
+			log.Printf("Warning: Failed to record that job %s wasn't queued: %v", id, failErr)
+		}
+		return onDemandJob{}, http.StatusInternalServerError, err
+	}
+	return job, 0, nil
+}
+
+// publishJob publishes the queued job id to JOBS_TOPIC.
+func (p *Pipeline) publishJob(ctx context.Context, id string) error {
+	data, err := json.Marshal(jobMessage{Job: id})
+	if err != nil {
+		return err
+	}
+	if err := p.jobPublisher.PublishData(ctx, data, nil); err != nil {
+		return fmt.Errorf("failed to publish job %s: %w", id, err)
+	}
+	return nil
+}
+
+// runOnDemandJob serves the RunOnDemandJob entry point, which runs the jobs
+// published to JOBS_TOPIC. Malformed messages are dropped. A failure to claim
+// the job returns its error, so the subscription redelivers the message; how
+// the job itself ends is recorded in its record.
+func runOnDemandJob(ctx context.Context, e v2.Event) error {
+	var msg pubSubMessage
+	if err := e.DataAs(&msg); err != nil {
+		log.Printf("Error: Invalid Pub/Sub event %s: %v. Dropping it.", e.ID(), err)
+		return nil
+	}
+	var job jobMessage
+	if err := json.Unmarshal(msg.Message.Data, &job); err != nil || !jobIDPattern.MatchString(job.Job) {
+		log.Printf("Error: Pub/Sub message %s isn't a job (%v). Dropping it.", msg.Message.MessageID, err)
+		return nil
+	}
+	p, err := functionPipeline()
+	if err != nil {
+		return err
+	}
+	if p.jobs == nil {
+		return fmt.Errorf("JOBS_COLLECTION must be set for RunOnDemandJob")
+	}
+	return p.runQueuedJob(ctx, p.cfg, job.Job)
+}
+
+// runQueuedJob runs the job id. Only a queued job is run, after claiming it by
+// marking it running, so redelivered messages, and the earlier message of a job
+// the sweeper queued again, don't run it twice. Its outcome is recorded rather
+// than returned: a failed job is reported in its record and submitted again by
+// the user.
+func (p *Pipeline) runQueuedJob(ctx context.Context, cfg *Config, id string) error {
+	var claimed bool
+	job, err := p.jobs.UpdateJob(ctx, id, func(job *onDemandJob) (bool, error) {
+		claimed = job.Status == jobQueued
+		if claimed {
+			job.Status = jobRunning
+		}
+		return claimed, nil
+	})
+	if errors.Is(err, errNoJob) {
+		log.Printf("Warning: Job %s has no record. Dropping it.", id)
+		return nil
+	}
+	if err != nil || !claimed {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go p.watchJobCancellation(runCtx, cancel, job.ID)
+	err = p.runJob(runCtx, cfg, job)
+	job.Status = jobDone
+	switch {
+	case errors.Is(context.Cause(runCtx), errJobCancelled):
+		log.Printf("Job %s for %s was cancelled.", job.ID, job.Input)
+		job.Status = jobCancelled
+	case err != nil:
+		log.Printf("Error: Job %s for %s failed: %v", job.ID, job.Input, err)
+		job.Status, job.Error = jobFailed, err.Error()
+	}
+	if err := p.recordJobOutcome(context.WithoutCancel(ctx), *job); err != nil {
+		log.Printf("Warning: Failed to record the outcome of job %s: %v", job.ID, err)
+	}
+	return nil
+}
+
+// watchJobCancellation checks the record of a running job every
+// jobCancelPollInterval until ctx is done, and cancels ctx with errJobCancelled
+// once the Jobs API has marked the job cancelling. Every jobHeartbeatInterval it
+// also refreshes the record's updated_at, so a job that runs for long without
+// reporting progress, e.g. while its long audio is synthesized, isn't swept up
+// as stuck.
+func (p *Pipeline) watchJobCancellation(ctx context.Context, cancel context.CancelCauseFunc, id string) {
+	ticker := time.NewTicker(jobCancelPollInterval)
+	defer ticker.Stop()
+	lastBeat := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var job *onDemandJob
+		var err error
+		if time.Since(lastBeat) < jobHeartbeatInterval {
+			job, err = p.jobs.GetJob(ctx, id)
+		} else {
+			job, err = p.jobs.UpdateJob(ctx, id, func(job *onDemandJob) (bool, error) {
+				return job.Status == jobRunning, nil
+			})
+			if err == nil {
+				lastBeat = time.Now()
+			}
+		}
+		if err != nil {
+			continue // Checked again on the next tick.
+		}
+		if job.Status == jobCancelling {
+			cancel(errJobCancelled)
+			return
+		}
+	}
+}
+
+// recordJobOutcome saves the status, output and error of a job that ended. The
+// record may have been marked cancelling since the job was claimed; the outcome
+// replaces that, so a job that finished before noticing the cancellation is
+// recorded as it ended.
+func (p *Pipeline) recordJobOutcome(ctx context.Context, job onDemandJob) error {
+	_, err := p.jobs.UpdateJob(ctx, job.ID, func(saved *onDemandJob) (bool, error) {
+		saved.Status, saved.Output, saved.Error = job.Status, job.Output, job.Error
+		return true, nil
+	})
+	return err
+}
+
+// runJob runs the handler for the input of a job with its options.
+func (p *Pipeline) runJob(ctx context.Context, cfg *Config, job *onDemandJob) error {
+	inputBucket, inputObject, err := storage.ParseGCSURI(job.Input)
+	if err != nil {
+		return err
+	}
+	metadata, exists, err := p.store.ObjectMetadata(ctx, inputBucket, inputObject)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s no longer exists", job.Input)
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	maps.Copy(metadata, job.Options)
+	log.Printf("Running job %s for %s.", job.ID, job.Input)
+	return p.processPDFToSpeechHandler(ctx, cfg, StorageObjectData{Bucket: inputBucket, Name: inputObject, ContentType: job.ContentType, Metadata: metadata, job: job})
+}
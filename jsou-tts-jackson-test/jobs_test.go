@@ -0,0 +1,247 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJobStore keeps the records of on-demand jobs in memory, like the job
+// tracker does in Firestore.
+type fakeJobStore struct {
+	jobs map[string]onDemandJob
+}
+
+func (s *fakeJobStore) CreateJob(ctx context.Context, job onDemandJob) error {
+	if _, exists := s.jobs[job.ID]; exists {
+		return fmt.Errorf("job %s already exists", job.ID)
+	}
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *fakeJobStore) GetJob(ctx context.Context, id string) (*onDemandJob, error) {
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errNoJob, id)
+	}
+	return &job, nil
+}
+
+func (s *fakeJobStore) UpdateJob(ctx context.Context, id string, update func(*onDemandJob) (bool, error)) (*onDemandJob, error) {
+	job, err := s.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := update(job)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		job.UpdatedAt = time.Now().UTC()
+		s.jobs[id] = *job
+	}
+	return job, nil
+}
+
+func (s *fakeJobStore) ListJobs(ctx context.Context, withStatus, after string, limit int) ([]onDemandJob, error) {
+	jobs := s.newestFirst()
+	if after != "" {
+		cursor, ok := s.jobs[after]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errNoJob, after)
+		}
+		jobs = slices.DeleteFunc(jobs, func(job onDemandJob) bool { return compareNewestFirst(job, cursor) <= 0 })
+	}
+	if withStatus != "" {
+		jobs = slices.DeleteFunc(jobs, func(job onDemandJob) bool { return job.Status != withStatus })
+	}
+	return jobs[:min(limit, len(jobs))], nil
+}
+
+func (s *fakeJobStore) LatestJob(ctx context.Context, input string) (*onDemandJob, error) {
+	for _, job := range s.newestFirst() {
+		if job.Input == input {
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeJobStore) JobsUpdatedBefore(ctx context.Context, statuses []string, cutoff time.Time) ([]onDemandJob, error) {
+	return slices.DeleteFunc(s.newestFirst(), func(job onDemandJob) bool {
+		return !slices.Contains(statuses, job.Status) || !job.UpdatedAt.Before(cutoff)
+	}), nil
+}
+
+// newestFirst returns the jobs in the order of ListJobs.
+func (s *fakeJobStore) newestFirst() []onDemandJob {
+	return slices.SortedFunc(maps.Values(s.jobs), compareNewestFirst)
+}
+
+// compareNewestFirst orders jobs by creation time, newest first, and then by ID.
+func compareNewestFirst(a, b onDemandJob) int {
+	if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// fakePublisher records the data of the messages it's asked to publish, or
+// fails with err.
+type fakePublisher struct {
+	published [][]byte
+	err       error
+}
+
+func (p *fakePublisher) PublishData(ctx context.Context, data []byte, attributes map[string]string) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, data)
+	return nil
+}
+
+// publishedJobs returns the IDs of the jobs published to JOBS_TOPIC.
+func publishedJobs(t *testing.T, p *Pipeline) []string {
+	t.Helper()
+	var ids []string
+	for _, data := range p.jobPublisher.(*fakePublisher).published {
+		var msg jobMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, msg.Job)
+	}
+	return ids
+}
+
+// testJobID is a job ID of the form newJobID makes.
+const testJobID = "0123456789abcdef0123456789abcdef"
+
+func TestQueueJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		publishErr error
+		wantStatus string
+	}{
+		{name: "published", wantStatus: jobQueued},
+		{name: "publishing fails", publishErr: errors.New("topic not found"), wantStatus: jobFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			p.jobPublisher.(*fakePublisher).err = tt.publishErr
+			ctx := context.Background()
+			if err := p.store.UploadFile(ctx, testBucket, "pdf-input/book.pdf", []byte("%PDF-1.7"), pdfContentType); err != nil {
+				t.Fatal(err)
+			}
+
+			job, _, err := p.queueJob(ctx, testBucket, jobRequest{URI: "gs://library/pdf-input/book.pdf"})
+			if (err != nil) != (tt.publishErr != nil) {
+				t.Fatalf("queueJob returned %v, want an error: %v", err, tt.publishErr != nil)
+			}
+			jobs := p.jobs.(*fakeJobStore).jobs
+			if len(jobs) != 1 {
+				t.Fatalf("%d job records, want 1", len(jobs))
+			}
+			for _, saved := range jobs {
+				if saved.Status != tt.wantStatus {
+					t.Errorf("job is %s, want %s", saved.Status, tt.wantStatus)
+				}
+			}
+			if err == nil && !slices.Equal(publishedJobs(t, p), []string{job.ID}) {
+				t.Errorf("published %v, want job %s", publishedJobs(t, p), job.ID)
+			}
+		})
+	}
+}
+
+func TestRunQueuedJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		wantStatus string
+		wantError  string
+	}{
+		{name: "queued job whose input is gone", status: jobQueued, wantStatus: jobFailed, wantError: "no longer exists"},
+		{name: "job claimed by another invocation", status: jobRunning, wantStatus: jobRunning},
+		{name: "cancelled job", status: jobCancelled, wantStatus: jobCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			ctx := context.Background()
+			now := time.Now().UTC()
+			if err := p.jobs.CreateJob(ctx, onDemandJob{ID: testJobID, Input: "gs://library/pdf-input/book.pdf", Status: tt.status, CreatedAt: now, UpdatedAt: now}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := p.runQueuedJob(ctx, p.cfg, testJobID); err != nil {
+				t.Fatal(err)
+			}
+			job, err := p.jobs.GetJob(ctx, testJobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Status != tt.wantStatus || !strings.Contains(job.Error, tt.wantError) {
+				t.Errorf("job is %s with error %q, want %s with %q", job.Status, job.Error, tt.wantStatus, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestRunQueuedJobWithoutRecord(t *testing.T) {
+	p := newTestPipeline(t)
+	if err := p.runQueuedJob(context.Background(), p.cfg, testJobID); err != nil {
+		t.Errorf("runQueuedJob returned %v for a job without a record, want the message dropped", err)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	tests := []struct {
+		status     string
+		wantStatus string
+		wantErr    error
+	}{
+		{status: jobQueued, wantStatus: jobCancelled},
+		{status: jobRunning, wantStatus: jobCancelling},
+		{status: jobCancelling, wantStatus: jobCancelling},
+		{status: jobDone, wantStatus: jobDone, wantErr: errJobEnded},
+		{status: jobCancelled, wantStatus: jobCancelled, wantErr: errJobEnded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			p := newTestPipeline(t)
+			ctx := context.Background()
+			now := time.Now().UTC()
+			if err := p.jobs.CreateJob(ctx, onDemandJob{ID: testJobID, Input: "gs://library/pdf-input/book.pdf", Status: tt.status, CreatedAt: now, UpdatedAt: now}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := p.cancelJob(ctx, testJobID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("cancelJob returned %v, want %v", err, tt.wantErr)
+			}
+			job, err := p.jobs.GetJob(ctx, testJobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Status != tt.wantStatus {
+				t.Errorf("job is %s, want %s", job.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCancelMissingJob(t *testing.T) {
+	p := newTestPipeline(t)
+	if _, err := p.cancelJob(context.Background(), testJobID); !errors.Is(err, errNoJob) {
+		t.Errorf("cancelJob returned %v, want %v", err, errNoJob)
+	}
+}
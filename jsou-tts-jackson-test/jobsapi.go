@@ -0,0 +1,411 @@
+package pdftospeech
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/jobtrack"
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// maxUploadBytes caps a PDF uploaded to the Jobs API when MAX_INPUT_BYTES isn't
+// set: the request size Cloud Functions and Cloud Run accept.
+const maxUploadBytes = 32 << 20
+
+// jobUploadsPrefix holds the PDFs uploaded to the Jobs API, one folder per job,
+// e.g. "tts-jobs/uploads/<id>/book.pdf", so the audio keeps the PDF's name.
+const jobUploadsPrefix = "tts-jobs/uploads/"
+
+// Clients of the Jobs API send its key in the X-API-Key header. The upload
+// page, whose audio player can't set headers, trades it at POST /session for
+// the tts_session cookie, which the Jobs API takes in its place.
+const (
+	jobsAPIKeyHeader     = "X-API-Key"
+	jobsSessionCookie    = "tts_session"
+	jobsSessionTokenSalt = "upload page session"
+)
+
+// errWrongAPIKey rejects a request to the Jobs API without its key.
+var errWrongAPIKey = errors.New("missing or wrong API key")
+
+// jobStatus is a job as the Jobs API reports it: its record, with the stage and
+// progress recorded in JOBS_COLLECTION while it runs, and a signed URL of its
+// audio once it's done, if SIGNED_URL_TTL is set.
+type jobStatus struct {
+	onDemandJob
+	Stage     string             `json:"stage,omitempty"`
+	Progress  *jobtrack.Progress `json:"progress,omitempty"`
+	OutputURL string             `json:"output_url,omitempty"`
+}
+
+// jobsAPI serves the JobsAPI entry point, a REST API over the on-demand jobs
+// of ProcessOnDemand for running the pipeline as a service, e.g. on Cloud Run:
+//
+//	POST /jobs            queues a job for an uploaded or referenced PDF
+//	GET /jobs/{id}        returns the job's status, progress and output
+//	DELETE /jobs/{id}     cancels the job
+//	GET /jobs/{id}/audio  returns the audio of a done job
+//	GET /                 serves a web page for uploading PDFs
+//	POST /session         sets the web page's session cookie
+//
+// Jobs are kept and run like ProcessOnDemand's, through JOBS_COLLECTION and
+// JOBS_TOPIC. Requests other than for the web page need the key in
+// JOBS_API_KEY_SECRET.
+func jobsAPI(w http.ResponseWriter, r *http.Request) {
+	p, err := functionPipeline()
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, "service unavailable, try again", http.StatusServiceUnavailable)
+		return
+	}
+	cfg := p.cfg
+	if cfg.BaseBucket == "" {
+		log.Printf("Error: BASE_GCS_BUCKET must be set for JobsAPI")
+		http.Error(w, "function misconfigured", http.StatusInternalServerError)
+		return
+	}
+	if p.jobsAPIKey == "" {
+		log.Printf("Error: JOBS_API_KEY_SECRET must be set for JobsAPI")
+		http.Error(w, "function misconfigured", http.StatusInternalServerError)
+		return
+	}
+	if err := p.checkOnDemandJobs(); err != nil {
+		log.Printf("Error: %v for JobsAPI", err)
+		http.Error(w, "function misconfigured", http.StatusInternalServerError)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) { p.createAPIJob(w, r, cfg) }))
+	mux.HandleFunc("GET /jobs/{id}", p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) { p.getAPIJob(w, r, cfg) }))
+	mux.HandleFunc("DELETE /jobs/{id}", p.requireAPIKey(p.cancelAPIJob))
+	// The upload page, and what it needs besides the jobs.
+	mux.HandleFunc("GET /{$}", serveUploadPage)
+	mux.HandleFunc("POST /session", p.requireAPIKey(p.startSession))
+	mux.HandleFunc("GET /session", p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+	mux.HandleFunc("GET /voices", p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) { p.listVoiceOptions(w, r, cfg) }))
+	mux.HandleFunc("GET /preview", p.requireAPIKey(previewVoice))
+	mux.HandleFunc("GET /jobs/{id}/audio", p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) { p.serveJobAudio(w, r, cfg) }))
+	mux.ServeHTTP(w, r)
+}
+
+// requireAPIKey wraps h so it responds 401 to requests without the Jobs API
+// key in the X-API-Key header, or the upload page's tts_session cookie.
+func (p *Pipeline) requireAPIKey(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authorized := p.authorizedKey(r.Header.Get(jobsAPIKeyHeader))
+		if cookie, err := r.Cookie(jobsSessionCookie); !authorized && err == nil {
+			authorized = p.jobsAPIKey != "" && subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(p.sessionToken())) == 1
+		}
+		if !authorized {
+			http.Error(w, errWrongAPIKey.Error(), http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// authorizedKey reports whether key is the Jobs API key.
+func (p *Pipeline) authorizedKey(key string) bool {
+	return p.jobsAPIKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(p.jobsAPIKey)) == 1
+}
+
+// sessionToken returns the value of the tts_session cookie: an HMAC of the
+// Jobs API key rather than the key, so the cookie can't be used as one, and
+// changing the key ends the sessions.
+func (p *Pipeline) sessionToken() string {
+	mac := hmac.New(sha256.New, []byte(p.jobsAPIKey))
+	mac.Write([]byte(jobsSessionTokenSalt))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// createAPIJob serves POST /jobs. A JSON body references a PDF like a request to
+// ProcessOnDemand, {"uri": "gs://...", "options": {...}}. A PDF is uploaded
+// either as the body, with Content-Type application/pdf, its name in ?name= and
+// its options as tts-* query parameters, or as a multipart form with the PDF
+// in a "file" field and the options as JSON in an "options" field. It responds
+// 202 with the job's record.
+func (p *Pipeline) createAPIJob(w http.ResponseWriter, r *http.Request, cfg *Config) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var job onDemandJob
+	var status int
+	var err error
+	switch mediaType {
+	case "application/json", "":
+		body, ok := readEventBody(w, r)
+		if !ok {
+			return
+		}
+		var req jobRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		job, status, err = p.queueJob(r.Context(), cfg.BaseBucket, req)
+	case pdfContentType:
+		options := map[string]string{}
+		for key, values := range r.URL.Query() {
+			if strings.HasPrefix(key, "tts-") {
+				options[key] = values[0]
+			}
+		}
+		job, status, err = p.queueUploadedJob(r.Context(), cfg, r.URL.Query().Get("name"), http.MaxBytesReader(w, r.Body, uploadLimit(cfg)), options)
+	case "multipart/form-data":
+		job, status, err = p.queueMultipartJob(w, r, cfg)
+	default:
+		http.Error(w, "post JSON, a PDF or a multipart form", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Printf("Error: %v", err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Printf("Queued job %s for %s.", job.ID, job.Input)
+	w.Header().Set("Location", path.Join(r.URL.Path, job.ID))
+	writeJobJSON(w, http.StatusAccepted, job)
+}
+
+// queueMultipartJob queues a job for the PDF uploaded in the "file" field of a
+// multipart form, with the options in its "options" field. The parts are read
+// as they arrive, so the PDF isn't buffered: it's saved as it's read, and the
+// job is only queued once the rest of the form checks out. The PDF of a form
+// that doesn't is deleted again.
+func (p *Pipeline) queueMultipartJob(w http.ResponseWriter, r *http.Request, cfg *Config) (onDemandJob, int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, uploadLimit(cfg)+maxEventBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return onDemandJob{}, http.StatusBadRequest, err
+	}
+	var options map[string]string
+	var id, object string
+	fail := func(status int, err error) (onDemandJob, int, error) {
+		if object != "" {
+			if err := p.store.DeleteObject(context.WithoutCancel(r.Context()), cfg.BaseBucket, object); err != nil {
+				log.Printf("Warning: Failed to delete the upload of rejected job %s: %v", id, err)
+			}
+		}
+		return onDemandJob{}, status, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(http.StatusBadRequest, fmt.Errorf("invalid form: %w", err))
+		}
+		switch part.FormName() {
+		case "options":
+			if object != "" {
+				return fail(http.StatusBadRequest, errors.New("send the options before the file"))
+			}
+			if err := json.NewDecoder(io.LimitReader(part, maxEventBytes)).Decode(&options); err != nil {
+				return fail(http.StatusBadRequest, fmt.Errorf("invalid options: %w", err))
+			}
+		case "file":
+			if object != "" {
+				return fail(http.StatusBadRequest, errors.New("send one file per job"))
+			}
+			var status int
+			if id, object, status, err = p.saveUpload(r.Context(), cfg, part.FileName(), part); err != nil {
+				return onDemandJob{}, status, err
+			}
+		}
+	}
+	if object == "" {
+		return onDemandJob{}, http.StatusBadRequest, errors.New(`the form has no "file" field`)
+	}
+	return p.createJob(r.Context(), id, jobRequest{URI: fmt.Sprintf("gs://%s/%s", cfg.BaseBucket, object), Options: options})
+}
+
+// queueUploadedJob writes an uploaded PDF under tts-jobs/uploads/ and queues a
+// job for it. name is the PDF's file name, "document.pdf" if it's empty. On
+// failure it also returns the HTTP status to respond with.
+func (p *Pipeline) queueUploadedJob(ctx context.Context, cfg *Config, name string, body io.Reader, options map[string]string) (onDemandJob, int, error) {
+	id, object, status, err := p.saveUpload(ctx, cfg, name, body)
+	if err != nil {
+		return onDemandJob{}, status, err
+	}
+	return p.createJob(ctx, id, jobRequest{URI: fmt.Sprintf("gs://%s/%s", cfg.BaseBucket, object), Options: options})
+}
+
+// saveUpload writes an uploaded PDF under tts-jobs/uploads/, in the folder of a
+// new job, and returns the job's ID and the PDF's object name. name is the
+// PDF's file name, "document.pdf" if it's empty. On failure it also returns
+// the HTTP status to respond with.
+func (p *Pipeline) saveUpload(ctx context.Context, cfg *Config, name string, body io.Reader) (id, object string, status int, err error) {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = "document.pdf"
+	}
+	if !strings.EqualFold(path.Ext(name), ".pdf") {
+		name += ".pdf"
+	}
+	id, err = newJobID()
+	if err != nil {
+		return "", "", http.StatusInternalServerError, fmt.Errorf("failed to create a job ID: %w", err)
+	}
+	object = jobUploadsPrefix + id + "/" + name
+	if err := p.store.UploadReader(ctx, cfg.BaseBucket, object, body, pdfContentType); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return "", "", http.StatusRequestEntityTooLarge, fmt.Errorf("the PDF is over the limit of %d bytes", tooLarge.Limit)
+		}
+		return "", "", http.StatusInternalServerError, fmt.Errorf("failed to save the upload of job %s: %w", id, err)
+	}
+	return id, object, 0, nil
+}
+
+// uploadLimit returns the largest PDF the Jobs API takes: MAX_INPUT_BYTES, or
+// maxUploadBytes.
+func uploadLimit(cfg *Config) int64 {
+	if cfg.MaxInputBytes > 0 {
+		return cfg.MaxInputBytes
+	}
+	return maxUploadBytes
+}
+
+// Errors of the job operations shared by the Jobs API and the gRPC service.
+var (
+	errNoJob    = jobtrack.ErrNoJob
+	errJobEnded = errors.New("job ended")
+)
+
+// getAPIJob serves GET /jobs/{id}.
+func (p *Pipeline) getAPIJob(w http.ResponseWriter, r *http.Request, cfg *Config) {
+	job, err := p.loadJob(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	writeJobJSON(w, http.StatusOK, p.describeJob(r.Context(), cfg, job))
+}
+
+// cancelAPIJob serves DELETE /jobs/{id}. A queued job is cancelled at once and
+// responds 200; a running one is marked cancelling and responds 202. A job that
+// ended responds 409.
+func (p *Pipeline) cancelAPIJob(w http.ResponseWriter, r *http.Request) {
+	job, err := p.cancelJob(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	status := http.StatusAccepted
+	if job.Status == jobCancelled {
+		status = http.StatusOK
+	}
+	writeJobJSON(w, status, job)
+}
+
+// loadJob reads the record of a job. It fails with errNoJob if there's none.
+func (p *Pipeline) loadJob(ctx context.Context, id string) (onDemandJob, error) {
+	if !jobIDPattern.MatchString(id) {
+		return onDemandJob{}, fmt.Errorf("%w: %s", errNoJob, id)
+	}
+	job, err := p.jobs.GetJob(ctx, id)
+	if err != nil {
+		return onDemandJob{}, err
+	}
+	return *job, nil
+}
+
+// describeJob adds to the record of a job its stage and progress while it
+// runs, and the signed URL of its audio once it's done.
+func (p *Pipeline) describeJob(ctx context.Context, cfg *Config, job onDemandJob) jobStatus {
+	status := jobStatus{onDemandJob: job}
+	// An on-demand job's run is tracked in the job's own document, so two jobs
+	// for the same PDF report their own progress.
+	if p.tracker != nil && (job.Status == jobRunning || job.Status == jobCancelling) {
+		tracked, err := p.tracker.Get(ctx, job.ID)
+		if err != nil {
+			log.Printf("Warning: No progress for job %s: %v", job.ID, err)
+		} else if tracked != nil {
+			status.Stage, status.Progress = string(tracked.State), &tracked.Progress
+		}
+	}
+	if job.Status == jobDone && job.Output != "" && cfg.SignedURLTTL > 0 {
+		status.OutputURL = p.outputSignedURL(ctx, job.Output, cfg)
+	}
+	return status
+}
+
+// outputSignedURL returns a signed URL of the audio at outputURI, or "" if it
+// doesn't exist yet, e.g. while long audio is finalized, or can't be signed.
+func (p *Pipeline) outputSignedURL(ctx context.Context, outputURI string, cfg *Config) string {
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		return ""
+	}
+	if _, exists, err := p.store.ObjectMetadata(ctx, bucket, object); err != nil || !exists {
+		return ""
+	}
+	url, err := p.store.SignedURL(bucket, object, cfg.SignedURLTTL)
+	if err != nil {
+		log.Printf("Warning: No signed URL for %s: %v", outputURI, err)
+		return ""
+	}
+	return url
+}
+
+// cancelJob cancels a job and returns its record: a queued job is cancelled at
+// once; a running one is marked cancelling, and stops once the invocation
+// running it notices, within jobCancelPollInterval. A job that ended fails
+// with errJobEnded.
+func (p *Pipeline) cancelJob(ctx context.Context, id string) (onDemandJob, error) {
+	if !jobIDPattern.MatchString(id) {
+		return onDemandJob{}, fmt.Errorf("%w: %s", errNoJob, id)
+	}
+	job, err := p.jobs.UpdateJob(ctx, id, func(job *onDemandJob) (bool, error) {
+		switch job.Status {
+		case jobQueued:
+			job.Status = jobCancelled
+		case jobRunning:
+			job.Status = jobCancelling
+		case jobCancelling:
+			return false, nil
+		default:
+			return false, fmt.Errorf("%w: job %s is %s", errJobEnded, job.ID, job.Status)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return onDemandJob{}, err
+	}
+	log.Printf("Job %s for %s is %s.", job.ID, job.Input, job.Status)
+	return *job, nil
+}
+
+// writeJobError responds with the error of a job operation.
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNoJob):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errJobEnded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Error: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeJobJSON responds with v as JSON.
+func writeJobJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
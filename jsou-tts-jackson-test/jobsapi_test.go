@@ -0,0 +1,201 @@
+package pdftospeech
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		want   int
+	}{
+		{name: "no key", want: http.StatusUnauthorized},
+		{name: "key in header", header: "s3cret/key", want: http.StatusOK},
+		{name: "wrong key in header", header: "guess", want: http.StatusUnauthorized},
+		{name: "session cookie", cookie: (&Pipeline{jobsAPIKey: "s3cret/key"}).sessionToken(), want: http.StatusOK},
+		{name: "key in cookie", cookie: "s3cret/key", want: http.StatusUnauthorized},
+		{name: "session cookie of another key", cookie: (&Pipeline{jobsAPIKey: "guess"}).sessionToken(), want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Pipeline{jobsAPIKey: "s3cret/key"}
+			r := httptest.NewRequest(http.MethodGet, "/jobs/abc", nil)
+			if tt.header != "" {
+				r.Header.Set(jobsAPIKeyHeader, tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: jobsSessionCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) {})(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartSession(t *testing.T) {
+	p := &Pipeline{jobsAPIKey: "s3cret/key"}
+	w := httptest.NewRecorder()
+	p.startSession(w, httptest.NewRequest(http.MethodPost, "/session", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("%d cookies set, want 1", len(cookies))
+	}
+	cookie := cookies[0]
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("cookie is HttpOnly: %v, Secure: %v, SameSite: %v; want HttpOnly, Secure and strict", cookie.HttpOnly, cookie.Secure, cookie.SameSite)
+	}
+	if strings.Contains(cookie.Value, "s3cret") {
+		t.Errorf("cookie %q holds the key", cookie.Value)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/jobs/abc", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) {})(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d with the session cookie, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRequireAPIKeyWithoutKey(t *testing.T) {
+	p := &Pipeline{}
+	r := httptest.NewRequest(http.MethodGet, "/jobs/abc", nil)
+	w := httptest.NewRecorder()
+	p.requireAPIKey(func(w http.ResponseWriter, r *http.Request) {})(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status %d with no key configured, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// formPart is a part of a multipart form posted to the Jobs API.
+type formPart struct {
+	field, file, content string
+}
+
+func TestCreateAPIJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		parts       []formPart
+		want        int
+		wantJobs    int // Jobs recorded and published.
+		wantUploads int
+	}{
+		{
+			name:        "input in the bucket",
+			contentType: "application/json",
+			body:        `{"uri": "gs://library/pdf-input/book.pdf"}`,
+			want:        http.StatusAccepted,
+			wantJobs:    1,
+		},
+		{
+			name:        "input of another bucket",
+			contentType: "application/json",
+			body:        `{"uri": "gs://private/pdf-input/book.pdf"}`,
+			want:        http.StatusForbidden,
+		},
+		{
+			name:        "object outside the input folders",
+			contentType: "application/json",
+			body:        `{"uri": "gs://library/tts-usage/book.pdf"}`,
+			want:        http.StatusForbidden,
+		},
+		{
+			name:        "missing input",
+			contentType: "application/json",
+			body:        `{"uri": "gs://library/pdf-input/other.pdf"}`,
+			want:        http.StatusNotFound,
+		},
+		{
+			name:        "uploaded PDF",
+			contentType: pdfContentType,
+			body:        "%PDF-1.7",
+			want:        http.StatusAccepted,
+			wantJobs:    1,
+			wantUploads: 1,
+		},
+		{
+			name:        "form",
+			parts:       []formPart{{"options", "", `{"tts-voice": "en-GB-Neural2-B"}`}, {"file", "book.pdf", "%PDF-1.7"}},
+			want:        http.StatusAccepted,
+			wantJobs:    1,
+			wantUploads: 1,
+		},
+		{
+			name:  "form with the options after the file",
+			parts: []formPart{{"file", "book.pdf", "%PDF-1.7"}, {"options", "", `{}`}},
+			want:  http.StatusBadRequest,
+		},
+		{
+			name:  "form with two files",
+			parts: []formPart{{"file", "book.pdf", "%PDF-1.7"}, {"file", "other.pdf", "%PDF-1.7"}},
+			want:  http.StatusBadRequest,
+		},
+		{
+			name:  "form without a file",
+			parts: []formPart{{"options", "", `{}`}},
+			want:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			ctx := context.Background()
+			if err := p.store.UploadFile(ctx, testBucket, "pdf-input/book.pdf", []byte("%PDF-1.7"), pdfContentType); err != nil {
+				t.Fatal(err)
+			}
+			body, contentType := strings.NewReader(tt.body), tt.contentType
+			var r *http.Request
+			if tt.parts != nil {
+				var buf bytes.Buffer
+				form := multipart.NewWriter(&buf)
+				for _, part := range tt.parts {
+					var w io.Writer
+					var err error
+					if part.file != "" {
+						w, err = form.CreateFormFile(part.field, part.file)
+					} else {
+						w, err = form.CreateFormField(part.field)
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					w.Write([]byte(part.content))
+				}
+				form.Close()
+				r = httptest.NewRequest(http.MethodPost, "/jobs", &buf)
+				contentType = form.FormDataContentType()
+			} else {
+				r = httptest.NewRequest(http.MethodPost, "/jobs?name=book.pdf", body)
+			}
+			r.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			p.createAPIJob(w, r, p.cfg)
+			if w.Code != tt.want {
+				t.Fatalf("status %d (%s), want %d", w.Code, strings.TrimSpace(w.Body.String()), tt.want)
+			}
+
+			uploads, err := p.store.ListObjectsWithPrefix(ctx, testBucket, jobUploadsPrefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, published := len(p.jobs.(*fakeJobStore).jobs), len(publishedJobs(t, p))
+			if records != tt.wantJobs || published != tt.wantJobs || len(uploads) != tt.wantUploads {
+				t.Errorf("%d job records, %d published and %d uploads left, want %d, %d and %d", records, published, len(uploads), tt.wantJobs, tt.wantJobs, tt.wantUploads)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package jobspb is the gRPC Jobs service of the pipeline, generated from
+// jobs.proto, for clients to call the service with.
+package jobspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative jobs.proto
@@ -0,0 +1,803 @@
+// The Jobs service runs the PDF-to-speech pipeline for programmatic clients:
+// the on-demand jobs of the Jobs API, over gRPC, with progress streamed as it
+// happens. Regenerate the Go code with `go generate ./jobspb` after a change.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: jobs.proto
+
+package jobspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// JobStatus is where a job has got to.
+type JobStatus int32
+
+const (
+	JobStatus_JOB_STATUS_UNSPECIFIED JobStatus = 0
+	JobStatus_JOB_STATUS_QUEUED      JobStatus = 1
+	JobStatus_JOB_STATUS_RUNNING     JobStatus = 2
+	JobStatus_JOB_STATUS_DONE        JobStatus = 3
+	JobStatus_JOB_STATUS_FAILED      JobStatus = 4
+	// The job is being stopped after a cancellation.
+	JobStatus_JOB_STATUS_CANCELLING JobStatus = 5
+	JobStatus_JOB_STATUS_CANCELLED  JobStatus = 6
+)
+
+// Enum value maps for JobStatus.
+var (
+	JobStatus_name = map[int32]string{
+		0: "JOB_STATUS_UNSPECIFIED",
+		1: "JOB_STATUS_QUEUED",
+		2: "JOB_STATUS_RUNNING",
+		3: "JOB_STATUS_DONE",
+		4: "JOB_STATUS_FAILED",
+		5: "JOB_STATUS_CANCELLING",
+		6: "JOB_STATUS_CANCELLED",
+	}
+	JobStatus_value = map[string]int32{
+		"JOB_STATUS_UNSPECIFIED": 0,
+		"JOB_STATUS_QUEUED":      1,
+		"JOB_STATUS_RUNNING":     2,
+		"JOB_STATUS_DONE":        3,
+		"JOB_STATUS_FAILED":      4,
+		"JOB_STATUS_CANCELLING":  5,
+		"JOB_STATUS_CANCELLED":   6,
+	}
+)
+
+func (x JobStatus) Enum() *JobStatus {
+	p := new(JobStatus)
+	*p = x
+	return p
+}
+
+func (x JobStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (JobStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_jobs_proto_enumTypes[0].Descriptor()
+}
+
+func (JobStatus) Type() protoreflect.EnumType {
+	return &file_jobs_proto_enumTypes[0]
+}
+
+func (x JobStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use JobStatus.Descriptor instead.
+func (JobStatus) EnumDescriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{0}
+}
+
+// Job is an on-demand job.
+type Job struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// gs:// URI of the PDF.
+	Input string `protobuf:"bytes,2,opt,name=input,proto3" json:"input,omitempty"`
+	// Per-document settings, named like the object metadata (tts-voice, ...).
+	Options map[string]string `protobuf:"bytes,3,rep,name=options,proto3" json:"options,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Status  JobStatus         `protobuf:"varint,4,opt,name=status,proto3,enum=jsoutts.jobs.v1.JobStatus" json:"status,omitempty"`
+	// gs:// URI the audio is written to, once the job got that far.
+	Output string `protobuf:"bytes,5,opt,name=output,proto3" json:"output,omitempty"`
+	// Why the job failed.
+	Error      string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	CreateTime *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=create_time,json=createTime,proto3" json:"create_time,omitempty"`
+	UpdateTime *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=update_time,json=updateTime,proto3" json:"update_time,omitempty"`
+	// Stage of a running job: extracting, synthesizing or finalizing. Set with
+	// progress when JOBS_COLLECTION is.
+	Stage    string    `protobuf:"bytes,9,opt,name=stage,proto3" json:"stage,omitempty"`
+	Progress *Progress `protobuf:"bytes,10,opt,name=progress,proto3" json:"progress,omitempty"`
+	// Signed URL of the audio of a done job, if SIGNED_URL_TTL is set.
+	OutputUrl     string `protobuf:"bytes,11,opt,name=output_url,json=outputUrl,proto3" json:"output_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_jobs_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetInput() string {
+	if x != nil {
+		return x.Input
+	}
+	return ""
+}
+
+func (x *Job) GetOptions() map[string]string {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *Job) GetStatus() JobStatus {
+	if x != nil {
+		return x.Status
+	}
+	return JobStatus_JOB_STATUS_UNSPECIFIED
+}
+
+func (x *Job) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetCreateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreateTime
+	}
+	return nil
+}
+
+func (x *Job) GetUpdateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdateTime
+	}
+	return nil
+}
+
+func (x *Job) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *Job) GetProgress() *Progress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+func (x *Job) GetOutputUrl() string {
+	if x != nil {
+		return x.OutputUrl
+	}
+	return ""
+}
+
+// Progress is how far a running job has got.
+type Progress struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	PagesExtracted    int32                  `protobuf:"varint,1,opt,name=pages_extracted,json=pagesExtracted,proto3" json:"pages_extracted,omitempty"`
+	Pages             int32                  `protobuf:"varint,2,opt,name=pages,proto3" json:"pages,omitempty"`
+	ChunksSynthesized int32                  `protobuf:"varint,3,opt,name=chunks_synthesized,json=chunksSynthesized,proto3" json:"chunks_synthesized,omitempty"`
+	Chunks            int32                  `protobuf:"varint,4,opt,name=chunks,proto3" json:"chunks,omitempty"`
+	// Of the synthesis, from 0 to 100.
+	Percent       float64 `protobuf:"fixed64,5,opt,name=percent,proto3" json:"percent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_jobs_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Progress) GetPagesExtracted() int32 {
+	if x != nil {
+		return x.PagesExtracted
+	}
+	return 0
+}
+
+func (x *Progress) GetPages() int32 {
+	if x != nil {
+		return x.Pages
+	}
+	return 0
+}
+
+func (x *Progress) GetChunksSynthesized() int32 {
+	if x != nil {
+		return x.ChunksSynthesized
+	}
+	return 0
+}
+
+func (x *Progress) GetChunks() int32 {
+	if x != nil {
+		return x.Chunks
+	}
+	return 0
+}
+
+func (x *Progress) GetPercent() float64 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+type SubmitJobRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Source:
+	//
+	//	*SubmitJobRequest_Uri
+	//	*SubmitJobRequest_Pdf
+	Source isSubmitJobRequest_Source `protobuf_oneof:"source"`
+	// File name of the uploaded PDF, which names its audio. Defaults to
+	// document.pdf.
+	Name string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// Per-document settings, named like the object metadata (tts-voice, ...).
+	Options       map[string]string `protobuf:"bytes,4,rep,name=options,proto3" json:"options,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitJobRequest) Reset() {
+	*x = SubmitJobRequest{}
+	mi := &file_jobs_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitJobRequest) ProtoMessage() {}
+
+func (x *SubmitJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitJobRequest.ProtoReflect.Descriptor instead.
+func (*SubmitJobRequest) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SubmitJobRequest) GetSource() isSubmitJobRequest_Source {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *SubmitJobRequest) GetUri() string {
+	if x != nil {
+		if x, ok := x.Source.(*SubmitJobRequest_Uri); ok {
+			return x.Uri
+		}
+	}
+	return ""
+}
+
+func (x *SubmitJobRequest) GetPdf() []byte {
+	if x != nil {
+		if x, ok := x.Source.(*SubmitJobRequest_Pdf); ok {
+			return x.Pdf
+		}
+	}
+	return nil
+}
+
+func (x *SubmitJobRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SubmitJobRequest) GetOptions() map[string]string {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+type isSubmitJobRequest_Source interface {
+	isSubmitJobRequest_Source()
+}
+
+type SubmitJobRequest_Uri struct {
+	// gs:// URI of a PDF the service can read.
+	Uri string `protobuf:"bytes,1,opt,name=uri,proto3,oneof"`
+}
+
+type SubmitJobRequest_Pdf struct {
+	// Content of a PDF, up to MAX_INPUT_BYTES or 32 MiB.
+	Pdf []byte `protobuf:"bytes,2,opt,name=pdf,proto3,oneof"`
+}
+
+func (*SubmitJobRequest_Uri) isSubmitJobRequest_Source() {}
+
+func (*SubmitJobRequest_Pdf) isSubmitJobRequest_Source() {}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_jobs_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListJobsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// At most 200; defaults to 50.
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token of the previous page.
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// Only jobs with this status, if set.
+	Status        JobStatus `protobuf:"varint,3,opt,name=status,proto3,enum=jsoutts.jobs.v1.JobStatus" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsRequest) Reset() {
+	*x = ListJobsRequest{}
+	mi := &file_jobs_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsRequest) ProtoMessage() {}
+
+func (x *ListJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListJobsRequest) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListJobsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListJobsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListJobsRequest) GetStatus() JobStatus {
+	if x != nil {
+		return x.Status
+	}
+	return JobStatus_JOB_STATUS_UNSPECIFIED
+}
+
+type ListJobsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Jobs  []*Job                 `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	// Requests the next page; empty on the last one.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsResponse) Reset() {
+	*x = ListJobsResponse{}
+	mi := &file_jobs_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsResponse) ProtoMessage() {}
+
+func (x *ListJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListJobsResponse) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListJobsResponse) GetJobs() []*Job {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+func (x *ListJobsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type WatchJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchJobRequest) Reset() {
+	*x = WatchJobRequest{}
+	mi := &file_jobs_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchJobRequest) ProtoMessage() {}
+
+func (x *WatchJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchJobRequest.ProtoReflect.Descriptor instead.
+func (*WatchJobRequest) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CancelJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelJobRequest) Reset() {
+	*x = CancelJobRequest{}
+	mi := &file_jobs_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelJobRequest) ProtoMessage() {}
+
+func (x *CancelJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jobs_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelJobRequest.ProtoReflect.Descriptor instead.
+func (*CancelJobRequest) Descriptor() ([]byte, []int) {
+	return file_jobs_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CancelJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+var File_jobs_proto protoreflect.FileDescriptor
+
+const file_jobs_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"jobs.proto\x12\x0fjsoutts.jobs.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xec\x03\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05input\x18\x02 \x01(\tR\x05input\x12;\n" +
+	"\aoptions\x18\x03 \x03(\v2!.jsoutts.jobs.v1.Job.OptionsEntryR\aoptions\x122\n" +
+	"\x06status\x18\x04 \x01(\x0e2\x1a.jsoutts.jobs.v1.JobStatusR\x06status\x12\x16\n" +
+	"\x06output\x18\x05 \x01(\tR\x06output\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12;\n" +
+	"\vcreate_time\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"createTime\x12;\n" +
+	"\vupdate_time\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"updateTime\x12\x14\n" +
+	"\x05stage\x18\t \x01(\tR\x05stage\x125\n" +
+	"\bprogress\x18\n" +
+	" \x01(\v2\x19.jsoutts.jobs.v1.ProgressR\bprogress\x12\x1d\n" +
+	"\n" +
+	"output_url\x18\v \x01(\tR\toutputUrl\x1a:\n" +
+	"\fOptionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xaa\x01\n" +
+	"\bProgress\x12'\n" +
+	"\x0fpages_extracted\x18\x01 \x01(\x05R\x0epagesExtracted\x12\x14\n" +
+	"\x05pages\x18\x02 \x01(\x05R\x05pages\x12-\n" +
+	"\x12chunks_synthesized\x18\x03 \x01(\x05R\x11chunksSynthesized\x12\x16\n" +
+	"\x06chunks\x18\x04 \x01(\x05R\x06chunks\x12\x18\n" +
+	"\apercent\x18\x05 \x01(\x01R\apercent\"\xde\x01\n" +
+	"\x10SubmitJobRequest\x12\x12\n" +
+	"\x03uri\x18\x01 \x01(\tH\x00R\x03uri\x12\x12\n" +
+	"\x03pdf\x18\x02 \x01(\fH\x00R\x03pdf\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12H\n" +
+	"\aoptions\x18\x04 \x03(\v2..jsoutts.jobs.v1.SubmitJobRequest.OptionsEntryR\aoptions\x1a:\n" +
+	"\fOptionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\b\n" +
+	"\x06source\"\x1f\n" +
+	"\rGetJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x81\x01\n" +
+	"\x0fListJobsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x122\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x1a.jsoutts.jobs.v1.JobStatusR\x06status\"d\n" +
+	"\x10ListJobsResponse\x12(\n" +
+	"\x04jobs\x18\x01 \x03(\v2\x14.jsoutts.jobs.v1.JobR\x04jobs\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"!\n" +
+	"\x0fWatchJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\"\n" +
+	"\x10CancelJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id*\xb7\x01\n" +
+	"\tJobStatus\x12\x1a\n" +
+	"\x16JOB_STATUS_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11JOB_STATUS_QUEUED\x10\x01\x12\x16\n" +
+	"\x12JOB_STATUS_RUNNING\x10\x02\x12\x13\n" +
+	"\x0fJOB_STATUS_DONE\x10\x03\x12\x15\n" +
+	"\x11JOB_STATUS_FAILED\x10\x04\x12\x19\n" +
+	"\x15JOB_STATUS_CANCELLING\x10\x05\x12\x18\n" +
+	"\x14JOB_STATUS_CANCELLED\x10\x062\xe9\x02\n" +
+	"\x04Jobs\x12D\n" +
+	"\tSubmitJob\x12!.jsoutts.jobs.v1.SubmitJobRequest\x1a\x14.jsoutts.jobs.v1.Job\x12>\n" +
+	"\x06GetJob\x12\x1e.jsoutts.jobs.v1.GetJobRequest\x1a\x14.jsoutts.jobs.v1.Job\x12O\n" +
+	"\bListJobs\x12 .jsoutts.jobs.v1.ListJobsRequest\x1a!.jsoutts.jobs.v1.ListJobsResponse\x12D\n" +
+	"\bWatchJob\x12 .jsoutts.jobs.v1.WatchJobRequest\x1a\x14.jsoutts.jobs.v1.Job0\x01\x12D\n" +
+	"\tCancelJob\x12!.jsoutts.jobs.v1.CancelJobRequest\x1a\x14.jsoutts.jobs.v1.JobB$Z\"MODULE_NAME/jsou-tts/jobspb;jobspbb\x06proto3"
+
+var (
+	file_jobs_proto_rawDescOnce sync.Once
+	file_jobs_proto_rawDescData []byte
+)
+
+func file_jobs_proto_rawDescGZIP() []byte {
+	file_jobs_proto_rawDescOnce.Do(func() {
+		file_jobs_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_jobs_proto_rawDesc), len(file_jobs_proto_rawDesc)))
+	})
+	return file_jobs_proto_rawDescData
+}
+
+var file_jobs_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_jobs_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_jobs_proto_goTypes = []any{
+	(JobStatus)(0),                // 0: jsoutts.jobs.v1.JobStatus
+	(*Job)(nil),                   // 1: jsoutts.jobs.v1.Job
+	(*Progress)(nil),              // 2: jsoutts.jobs.v1.Progress
+	(*SubmitJobRequest)(nil),      // 3: jsoutts.jobs.v1.SubmitJobRequest
+	(*GetJobRequest)(nil),         // 4: jsoutts.jobs.v1.GetJobRequest
+	(*ListJobsRequest)(nil),       // 5: jsoutts.jobs.v1.ListJobsRequest
+	(*ListJobsResponse)(nil),      // 6: jsoutts.jobs.v1.ListJobsResponse
+	(*WatchJobRequest)(nil),       // 7: jsoutts.jobs.v1.WatchJobRequest
+	(*CancelJobRequest)(nil),      // 8: jsoutts.jobs.v1.CancelJobRequest
+	nil,                           // 9: jsoutts.jobs.v1.Job.OptionsEntry
+	nil,                           // 10: jsoutts.jobs.v1.SubmitJobRequest.OptionsEntry
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_jobs_proto_depIdxs = []int32{
+	9,  // 0: jsoutts.jobs.v1.Job.options:type_name -> jsoutts.jobs.v1.Job.OptionsEntry
+	0,  // 1: jsoutts.jobs.v1.Job.status:type_name -> jsoutts.jobs.v1.JobStatus
+	11, // 2: jsoutts.jobs.v1.Job.create_time:type_name -> google.protobuf.Timestamp
+	11, // 3: jsoutts.jobs.v1.Job.update_time:type_name -> google.protobuf.Timestamp
+	2,  // 4: jsoutts.jobs.v1.Job.progress:type_name -> jsoutts.jobs.v1.Progress
+	10, // 5: jsoutts.jobs.v1.SubmitJobRequest.options:type_name -> jsoutts.jobs.v1.SubmitJobRequest.OptionsEntry
+	0,  // 6: jsoutts.jobs.v1.ListJobsRequest.status:type_name -> jsoutts.jobs.v1.JobStatus
+	1,  // 7: jsoutts.jobs.v1.ListJobsResponse.jobs:type_name -> jsoutts.jobs.v1.Job
+	3,  // 8: jsoutts.jobs.v1.Jobs.SubmitJob:input_type -> jsoutts.jobs.v1.SubmitJobRequest
+	4,  // 9: jsoutts.jobs.v1.Jobs.GetJob:input_type -> jsoutts.jobs.v1.GetJobRequest
+	5,  // 10: jsoutts.jobs.v1.Jobs.ListJobs:input_type -> jsoutts.jobs.v1.ListJobsRequest
+	7,  // 11: jsoutts.jobs.v1.Jobs.WatchJob:input_type -> jsoutts.jobs.v1.WatchJobRequest
+	8,  // 12: jsoutts.jobs.v1.Jobs.CancelJob:input_type -> jsoutts.jobs.v1.CancelJobRequest
+	1,  // 13: jsoutts.jobs.v1.Jobs.SubmitJob:output_type -> jsoutts.jobs.v1.Job
+	1,  // 14: jsoutts.jobs.v1.Jobs.GetJob:output_type -> jsoutts.jobs.v1.Job
+	6,  // 15: jsoutts.jobs.v1.Jobs.ListJobs:output_type -> jsoutts.jobs.v1.ListJobsResponse
+	1,  // 16: jsoutts.jobs.v1.Jobs.WatchJob:output_type -> jsoutts.jobs.v1.Job
+	1,  // 17: jsoutts.jobs.v1.Jobs.CancelJob:output_type -> jsoutts.jobs.v1.Job
+	13, // [13:18] is the sub-list for method output_type
+	8,  // [8:13] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_jobs_proto_init() }
+func file_jobs_proto_init() {
+	if File_jobs_proto != nil {
+		return
+	}
+	file_jobs_proto_msgTypes[2].OneofWrappers = []any{
+		(*SubmitJobRequest_Uri)(nil),
+		(*SubmitJobRequest_Pdf)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_jobs_proto_rawDesc), len(file_jobs_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_jobs_proto_goTypes,
+		DependencyIndexes: file_jobs_proto_depIdxs,
+		EnumInfos:         file_jobs_proto_enumTypes,
+		MessageInfos:      file_jobs_proto_msgTypes,
+	}.Build()
+	File_jobs_proto = out.File
+	file_jobs_proto_goTypes = nil
+	file_jobs_proto_depIdxs = nil
+}
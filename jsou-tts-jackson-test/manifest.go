@@ -0,0 +1,96 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// jobManifest describes how an output was made, written next to the audio so
+// downstream systems can consume results without parsing logs.
+type jobManifest struct {
+	Input           string `json:"input"`
+	InputGeneration string `json:"input_generation,omitempty"`
+	Output          string `json:"output"`
+	Pages           int    `json:"pages"`
+	FailedPages     []int  `json:"failed_pages,omitempty"`
+	Characters      int    `json:"characters"`
+	Chunks          int    `json:"chunks"`
+	Provider        string `json:"provider"`
+	Voice           string `json:"voice"`
+	LanguageCode    string `json:"language_code"`
+	Encoding        string `json:"encoding"`
+	Mode            string `json:"mode"`
+	// DurationSeconds is the playing time of the audio. It's left out for long
+	// audio, which is written by the API and never read by the function.
+	DurationSeconds float64         `json:"duration_seconds,omitempty"`
+	Timings         manifestTimings `json:"timings"`
+	PipelineVersion string          `json:"pipeline_version"`
+	// SignedURL downloads the audio without bucket access until SignedURLExpiresAt,
+	// if SIGNED_URL_TTL is set.
+	SignedURL          string     `json:"signed_url,omitempty"`
+	SignedURLExpiresAt *time.Time `json:"signed_url_expires_at,omitempty"`
+}
+
+// manifestTimings records when the job ran and how long its stages took.
+type manifestTimings struct {
+	ReceivedAt        time.Time `json:"received_at"`
+	CompletedAt       time.Time `json:"completed_at"`
+	ExtractionSeconds float64   `json:"extraction_seconds"`
+	SynthesisSeconds  float64   `json:"synthesis_seconds"`
+	TotalSeconds      float64   `json:"total_seconds"`
+}
+
+// maxSignedURLTTL is the longest lifetime of a V4 signed URL.
+const maxSignedURLTTL = 7 * 24 * time.Hour
+
+// manifestObjectName returns the manifest for an audio object, e.g.
+// "mp3-output/book.mp3" -> "mp3-output/book.manifest.json".
+func manifestObjectName(audioObjectName string) string {
+	return strings.TrimSuffix(audioObjectName, path.Ext(audioObjectName)) + ".manifest.json"
+}
+
+// writeManifest completes the timings of m, with synthesis having started at
+// synthesisStart, adds a signed URL if configured, and uploads it next to its
+// output. It returns the completed manifest. A failure is only logged, since the
+// audio is done either way.
+func (p *Pipeline) writeManifest(ctx context.Context, cfg *Config, m jobManifest, synthesisStart time.Time) jobManifest {
+	m.Timings.CompletedAt = time.Now().UTC()
+	m.Timings.SynthesisSeconds = m.Timings.CompletedAt.Sub(synthesisStart).Seconds()
+	m.Timings.TotalSeconds = m.Timings.CompletedAt.Sub(m.Timings.ReceivedAt).Seconds()
+
+	bucket, object, err := storage.ParseGCSURI(m.Output)
+	if err == nil {
+		p.addSignedURL(&m, bucket, object, cfg.SignedURLTTL)
+		var data []byte
+		data, err = json.MarshalIndent(m, "", "  ")
+		if err == nil {
+			err = p.store.UploadFile(ctx, bucket, manifestObjectName(object), data, "application/json")
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to write the manifest of %s: %v", m.Output, err)
+	}
+	return m
+}
+
+// addSignedURL publishes a signed URL for the audio in m, valid for ttl, if
+// SIGNED_URL_TTL sets one.
+func (p *Pipeline) addSignedURL(m *jobManifest, bucket, object string, ttl time.Duration) {
+	if ttl == 0 {
+		return
+	}
+	url, err := p.store.SignedURL(bucket, object, ttl)
+	if err != nil {
+		log.Printf("Warning: No signed URL for %s: %v", m.Output, err)
+		return
+	}
+	expiresAt := m.Timings.CompletedAt.Add(ttl)
+	m.SignedURL, m.SignedURLExpiresAt = url, &expiresAt
+	log.Printf("Signed URL for %s is valid until %s.", m.Output, expiresAt.Format(time.RFC3339))
+}
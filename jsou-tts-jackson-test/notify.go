@@ -0,0 +1,152 @@
+package pdftospeech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"path"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/email"
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// notifyCompletion tells the document's callback URL, the address in its
+// tts-notify-email metadata, the chat channel and the EVENTS_TOPIC topic how
+// the document ended.
+func (p *Pipeline) notifyCompletion(ctx context.Context, cfg *Config, callback, recipient string, payload webhookPayload) {
+	payload.Timestamp = time.Now().UTC()
+	p.publishEvent(ctx, payload)
+	p.notifyWebhook(ctx, cfg, callback, payload)
+	p.notifyEmail(ctx, cfg, recipient, payload)
+	p.notifyChat(ctx, cfg, payload)
+}
+
+// notifyChat posts a message about the document to the Slack or Google Chat
+// channel whose incoming webhook URL is in the secret CHAT_WEBHOOK_SECRET, if
+// set. Both accept the same {"text": ...} body and <url|label> links. A
+// failure is only logged.
+func (p *Pipeline) notifyChat(ctx context.Context, cfg *Config, payload webhookPayload) {
+	if cfg.ChatWebhookSecret == "" {
+		return
+	}
+	hook, err := p.secrets.Access(ctx, cfg.ChatWebhookSecret)
+	if err != nil {
+		log.Printf("Warning: Not posting about %s to chat: failed to read the webhook URL: %v", payload.Input, err)
+		return
+	}
+	_, inputName, _ := storage.ParseGCSURI(payload.Input)
+	var text string
+	switch payload.Event {
+	case webhookSucceeded:
+		_, outputName, _ := storage.ParseGCSURI(payload.Output)
+		text = fmt.Sprintf("✅ Finished *%s*", inputName)
+		if payload.Stats != nil && payload.Stats.DurationSeconds > 0 {
+			text += fmt.Sprintf(" (%v of audio)", (time.Duration(payload.Stats.DurationSeconds) * time.Second).Round(time.Second))
+		}
+		text += fmt.Sprintf(": <%s|%s>", p.downloadLink(cfg, payload.Output), path.Base(outputName))
+	default:
+		text = fmt.Sprintf("❌ *%s* failed during %s: %s", inputName, payload.Stage, payload.Error)
+		if payload.Retryable {
+			text += " (may succeed if uploaded again)"
+		}
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(hook), bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		var resp *http.Response
+		if resp, err = webhookClient.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("chat webhook returned %s", resp.Status)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to post about %s to chat: %v", payload.Input, err)
+	}
+}
+
+// Types of the CloudEvents published to EVENTS_TOPIC.
+const (
+	eventSucceeded = "pdftospeech.document.succeeded"
+	eventFailed    = "pdftospeech.document.failed"
+)
+
+// publishEvent publishes a CloudEvent whose data is the payload also sent to
+// webhooks to EVENTS_TOPIC, if set, with the input object as its subject. A
+// failure is only logged.
+func (p *Pipeline) publishEvent(ctx context.Context, payload webhookPayload) {
+	if p.publisher == nil {
+		return
+	}
+	eventType := eventFailed
+	if payload.Event == webhookSucceeded {
+		eventType = eventSucceeded
+	}
+	_, subject, _ := storage.ParseGCSURI(payload.Input)
+	if err := p.publisher.Publish(ctx, eventType, subject, payload); err != nil {
+		log.Printf("Warning: Failed to publish the outcome of %s: %v", payload.Input, err)
+	}
+}
+
+// notifyEmail emails recipient, e.g. the uploader, a link to the finished
+// audio or the reason the document failed, when EMAIL_PROVIDER is set. The link
+// is a signed URL valid for SIGNED_URL_TTL if that's set, and otherwise the
+// Cloud Console download link, which needs access to the bucket. A failure is
+// only logged.
+func (p *Pipeline) notifyEmail(ctx context.Context, cfg *Config, recipient string, payload webhookPayload) {
+	if recipient == "" || p.mailer == nil {
+		return
+	}
+	if _, err := mail.ParseAddress(recipient); err != nil {
+		log.Printf("Warning: Not emailing %s about %s: invalid address: %v", recipient, payload.Input, err)
+		return
+	}
+	_, inputName, _ := storage.ParseGCSURI(payload.Input)
+	name := inputName[strings.LastIndex(inputName, "/")+1:]
+
+	var subject, text string
+	switch payload.Event {
+	case webhookSucceeded:
+		subject = fmt.Sprintf("Your audio for %s is ready", name)
+		text = fmt.Sprintf("The audio version of %s is ready:\n\n%s\n", name, p.downloadLink(cfg, payload.Output))
+	default:
+		subject = fmt.Sprintf("Converting %s to audio failed", name)
+		text = fmt.Sprintf("%s couldn't be converted to audio. It failed during %s:\n\n%s\n", name, payload.Stage, payload.Error)
+		if payload.Retryable {
+			text += "\nThe problem may be temporary; uploading the file again may succeed.\n"
+		}
+	}
+	err := p.mailer.Send(ctx, email.Message{From: cfg.EmailFrom, To: recipient, Subject: subject, Text: text})
+	if err != nil {
+		log.Printf("Warning: Failed to email %s about %s: %v", recipient, payload.Input, err)
+		return
+	}
+	log.Printf("Emailed %s that %s %s.", recipient, payload.Input, payload.Event)
+}
+
+// downloadLink returns a link to the object at uri for an email.
+func (p *Pipeline) downloadLink(cfg *Config, uri string) string {
+	bucket, object, err := storage.ParseGCSURI(uri)
+	if err != nil {
+		return uri
+	}
+	if cfg.SignedURLTTL > 0 {
+		url, err := p.store.SignedURL(bucket, object, cfg.SignedURLTTL)
+		if err == nil {
+			return url
+		}
+		log.Printf("Warning: No signed URL for %s: %v", uri, err)
+	}
+	return fmt.Sprintf("https://storage.cloud.google.com/%s/%s", bucket, object)
+}
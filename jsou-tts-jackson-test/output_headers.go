@@ -0,0 +1,51 @@
+package pdftospeech
+
+import (
+	"context"
+	"log"
+	"mime"
+	"path"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// outputHeaders returns the headers the output audio of inputName, stored as
+// outputObject, is served with:
+//   - Cache-Control from OUTPUT_CACHE_CONTROL, e.g. "public, max-age=86400".
+//   - Content-Disposition from OUTPUT_CONTENT_DISPOSITION, "attachment" or
+//     "inline", with the input's name and the audio's extension as the download
+//     file name, e.g. "document.mp3" for "pdf-input/reports/document.pdf".
+//   - Content-Language from OUTPUT_CONTENT_LANGUAGE, defaulting to
+//     languageCode, the language of the voice; "-" leaves it unset.
+//
+// Unset settings leave the header unset.
+func outputHeaders(cfg *Config, inputName, outputObject, languageCode string) storage.ObjectHeaders {
+	h := storage.ObjectHeaders{CacheControl: cfg.CacheControl, ContentLanguage: languageCode}
+	if cfg.ContentDisposition != "" {
+		filename := strings.TrimSuffix(path.Base(inputName), path.Ext(inputName)) + path.Ext(outputObject)
+		h.ContentDisposition = mime.FormatMediaType(cfg.ContentDisposition, map[string]string{"filename": filename})
+	}
+	switch language := cfg.ContentLanguage; language {
+	case "":
+	case "-":
+		h.ContentLanguage = ""
+	default:
+		h.ContentLanguage = language
+	}
+	return h
+}
+
+// setOutputHeaders sets headers on the output at outputURI, for audio written
+// by a long audio operation or copied from an earlier output rather than
+// uploaded by the function. A failure is logged but doesn't fail the document;
+// the audio is still usable.
+func (p *Pipeline) setOutputHeaders(ctx context.Context, outputURI string, headers storage.ObjectHeaders) {
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err == nil {
+		err = p.store.SetObjectHeaders(ctx, bucket, object, headers)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to set the headers of %s: %v", outputURI, err)
+	}
+}
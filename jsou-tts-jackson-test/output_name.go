@@ -0,0 +1,94 @@
+package pdftospeech
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// defaultOutputNameTemplate mirrors the input's path below pdf-input/, e.g.
+// "mp3-output/reports/2024/q1.mp3" for "pdf-input/reports/2024/q1.pdf", so
+// inputs of the same name in different folders don't collide.
+const defaultOutputNameTemplate = "{dir}/{basename}"
+
+// outputNameVariable matches a "{name}" placeholder in an output name template.
+var outputNameVariable = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// outputNameVariables lists the placeholders an output name template may use.
+var outputNameVariables = map[string]string{
+	"dir":       "folder of the input below the input folder, e.g. reports/2024",
+	"basename":  "input file name without .pdf (or .audiobook.json)",
+	"voice":     "voice name",
+	"language":  "language code of the voice",
+	"date":      "processing date, YYYY-MM-DD (UTC)",
+	"timestamp": "processing time, YYYYMMDDTHHMMSSZ (UTC)",
+}
+
+// audioExtensions are replaced by the output format's extension when a template
+// ends in one, so "{basename}.mp3" still yields ".wav" for LINEAR16 output.
+var audioExtensions = []string{".mp3", ".wav", ".ogg"}
+
+// outputNameTemplate is a validated OUTPUT_NAME_TEMPLATE, such as
+// "{dir}/{basename}/{voice}/{date}".
+type outputNameTemplate string
+
+// parseOutputNameTemplate validates a template, rejecting unknown placeholders.
+// An empty template selects defaultOutputNameTemplate.
+func parseOutputNameTemplate(raw string) (outputNameTemplate, error) {
+	if strings.TrimSpace(raw) == "" {
+		return defaultOutputNameTemplate, nil
+	}
+	for _, m := range outputNameVariable.FindAllStringSubmatch(raw, -1) {
+		if _, ok := outputNameVariables[m[1]]; !ok {
+			return "", fmt.Errorf("unknown placeholder %s in output name template %q", m[0], raw)
+		}
+	}
+	return outputNameTemplate(raw), nil
+}
+
+// objectName renders the template for an input into an output object name under
+// prefix, ending in the format's extension. {dir} is the input's folder below the
+// input folder at inputPrefix. Empty path segments, e.g. from {dir} for inputs
+// directly in the input folder, are dropped.
+func (t outputNameTemplate) objectName(prefix, inputPrefix, inputName string, voice tts.Voice, at time.Time, format tts.AudioFormat) string {
+	rel := strings.TrimPrefix(inputName, inputPrefix)
+	dir := path.Dir(rel)
+	if dir == "." {
+		dir = ""
+	}
+	base := path.Base(rel)
+	basename := strings.TrimSuffix(base, path.Ext(base))
+	if isAudiobookManifest(base) {
+		basename = base[:len(base)-len(audiobookSuffix)]
+	}
+	vars := map[string]string{
+		"dir":       dir,
+		"basename":  basename,
+		"voice":     strings.ReplaceAll(voice.String(), ":", "-"),
+		"language":  voice.LanguageCode,
+		"date":      at.UTC().Format("2006-01-02"),
+		"timestamp": at.UTC().Format("20060102T150405Z"),
+	}
+	name := outputNameVariable.ReplaceAllStringFunc(string(t), func(m string) string {
+		return vars[m[1:len(m)-1]]
+	})
+
+	var segments []string
+	for _, segment := range strings.Split(name, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	name = strings.Join(segments, "/")
+	for _, ext := range audioExtensions {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	return prefix + name + format.Extension
+}
@@ -0,0 +1,96 @@
+package pdftospeech
+
+import (
+	"context"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// versionedObjectName returns the name the audio made from the given generation
+// of an input is kept under once a newer version replaces it, e.g.
+// "mp3-output/book.v1712345678901234.mp3".
+func versionedObjectName(outputObject, generation string) string {
+	ext := path.Ext(outputObject)
+	return strings.TrimSuffix(outputObject, ext) + ".v" + generation + ext
+}
+
+// archivePreviousOutput keeps the audio made from an earlier version of the
+// input of e under a versioned name before the new version's audio is made, so
+// an updated PDF doesn't lose the audio of the old one, nor leave it looking
+// current. The previous audio is the output at the new output's name, if it was
+// made from another generation, or else the output recorded on the input's
+// copy in processed/, which a template with the voice or date may have named
+// differently: that one is moved rather than copied. Its manifest and
+// timepoints go with it. Failures are only logged; the document is processed
+// either way.
+func (p *Pipeline) archivePreviousOutput(ctx context.Context, cfg *Config, e StorageObjectData, outputURI string) {
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		return
+	}
+	metadata, exists, err := p.store.ObjectMetadata(ctx, bucket, object)
+	if err != nil {
+		log.Printf("Warning: Failed to check the previous output of %s: %v", e.Name, err)
+		return
+	}
+	if exists {
+		if metadata[sourceGenerationKey] != e.Generation {
+			p.archiveOutput(ctx, e, bucket, object, metadata[sourceGenerationKey], false)
+		}
+		return
+	}
+
+	folder, ok := cfg.inputFolder(e.Name)
+	if !ok {
+		return
+	}
+	processed, ok, err := p.store.ObjectMetadata(ctx, e.Bucket, processedObjectName(folder, e.Name))
+	if err != nil || !ok || processed[outputKey] == "" || processed[outputKey] == outputURI {
+		return
+	}
+	previousBucket, previousObject, err := storage.ParseGCSURI(processed[outputKey])
+	if err != nil {
+		return
+	}
+	metadata, exists, err = p.store.ObjectMetadata(ctx, previousBucket, previousObject)
+	if err != nil {
+		log.Printf("Warning: Failed to check the previous output of %s: %v", e.Name, err)
+		return
+	}
+	if exists && metadata[sourceGenerationKey] != e.Generation {
+		p.archiveOutput(ctx, e, previousBucket, previousObject, metadata[sourceGenerationKey], true)
+	}
+}
+
+// archiveOutput copies, or moves, the output object made from the given
+// generation of the input of e, with its manifest and timepoints, to its
+// versioned name. Outputs that don't record their source are versioned by the
+// current time instead.
+func (p *Pipeline) archiveOutput(ctx context.Context, e StorageObjectData, bucket, object, generation string, move bool) {
+	if generation == "" {
+		generation = time.Now().UTC().Format("20060102T150405Z")
+	}
+	versioned := versionedObjectName(object, generation)
+	for _, pair := range [][2]string{
+		{object, versioned},
+		{manifestObjectName(object), manifestObjectName(versioned)},
+		{timepointsObjectName(object), timepointsObjectName(versioned)},
+	} {
+		var err error
+		if move {
+			err = p.store.MoveObject(ctx, bucket, pair[0], pair[1], nil)
+		} else {
+			_, err = p.store.CopyObject(ctx, bucket, pair[0], bucket, pair[1])
+		}
+		// Timepoints, and the manifests of older outputs, may not exist.
+		if err != nil && pair[0] == object {
+			log.Printf("Warning: Failed to archive the previous output gs://%s/%s of %s: %v", bucket, object, e.Name, err)
+			return
+		}
+	}
+	log.Printf("%s changed (generation %s). Archived its previous output to gs://%s/%s.", e.Name, e.Generation, bucket, versioned)
+}
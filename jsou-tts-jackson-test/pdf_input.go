@@ -0,0 +1,22 @@
+package pdftospeech
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// pdfContentType is the content type of PDF documents.
+const pdfContentType = "application/pdf"
+
+// isPDFInput reports whether an object looks like a PDF by its name or content
+// type: a PDF uploaded without the .pdf extension, or with a wrong one, is
+// still processed if it was uploaded as application/pdf. Whether its content is
+// a PDF is only checked once it's read.
+func isPDFInput(name, contentType string) bool {
+	if strings.EqualFold(path.Ext(name), ".pdf") {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == pdfContentType
+}
@@ -0,0 +1,189 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/jobtrack"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// pendingPrefix holds one record per long audio operation that was started but
+// not yet seen to completion. The records are picked up by FinalizePendingSyntheses.
+const pendingPrefix = "tts-pending/"
+
+// pendingSynthesis is the persisted state of a started long audio operation, or
+// of the operations of a document split across several of them.
+type pendingSynthesis struct {
+	Operation   string    `json:"operation,omitempty"`
+	Bucket      string    `json:"bucket"`
+	InputObject string    `json:"input_object"`
+	OutputURI   string    `json:"output_uri"`
+	StartedAt   time.Time `json:"started_at"`
+	Provider    string    `json:"provider,omitempty"` // Synthesizer that runs the operation; empty means Google.
+	// Slot is the synthesis slot held for the operation, released once it's finished.
+	Slot *synthesisSlot `json:"slot,omitempty"`
+	// Parts replaces Operation for a document split across several operations.
+	// Their outputs are joined into OutputURI in Format once all are done.
+	Parts  []synthesisPart `json:"parts,omitempty"`
+	Format string          `json:"format,omitempty"`
+	// Source identifies the input version, recorded on the output once it's done.
+	Source map[string]string `json:"source,omitempty"`
+	// ContentKey registers the output for identical documents once it's done.
+	ContentKey string `json:"content_key,omitempty"`
+	// Manifest is written next to the output once it's done.
+	Manifest *jobManifest `json:"manifest,omitempty"`
+	// Headers are set on the output once it's done.
+	Headers storage.ObjectHeaders `json:"headers"`
+	// Callback is the document's tts-callback-url, called once it's done or failed.
+	Callback string `json:"callback,omitempty"`
+	// NotifyEmail is the document's tts-notify-email, emailed once it's done or failed.
+	NotifyEmail string `json:"notify_email,omitempty"`
+	// Job is the ID of the on-demand job the synthesis is for, whose document tracks it.
+	Job string `json:"job,omitempty"`
+}
+
+// pendingObjectName returns where the record for an output object is stored,
+// e.g. "tts-pending/mp3-output/book.wav.json".
+func pendingObjectName(outputObjectName string) string {
+	return pendingPrefix + outputObjectName + ".json"
+}
+
+// savePending persists a pending operation record in the bucket.
+func (p *Pipeline) savePending(ctx context.Context, outputObjectName string, pending pendingSynthesis) error {
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pending operation: %w", err)
+	}
+	if err := p.store.UploadFile(ctx, pending.Bucket, pendingObjectName(outputObjectName), data, "application/json"); err != nil {
+		return fmt.Errorf("failed to save pending synthesis of %s: %w", pending.OutputURI, err)
+	}
+	if len(pending.Parts) > 0 {
+		log.Printf("Recorded %d pending operations for %s.", len(pending.Parts), pending.OutputURI)
+		return nil
+	}
+	log.Printf("Recorded pending operation %s for %s.", pending.Operation, pending.OutputURI)
+	return nil
+}
+
+// saveRenewedSlot writes the record of a pending operation back to object once
+// its synthesis slot was renewed, so the slot's new generation is released when
+// the operation is done. A failure is only logged: the slot is then reclaimed
+// once it's stale.
+func (p *Pipeline) saveRenewedSlot(ctx context.Context, bucketName, object string, pending pendingSynthesis) {
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err == nil {
+		err = p.store.UploadFile(ctx, bucketName, object, data, "application/json")
+	}
+	if err != nil {
+		log.Printf("Error saving the renewed synthesis slot of %s: %v", object, err)
+	}
+}
+
+// finalizePendingSyntheses checks every pending operation in the bucket once.
+// Finished operations have their record removed; failed ones are logged and
+// removed; running ones are left for the next run. It returns an error only if
+// the records can't be listed, so one bad record doesn't block the others.
+func (p *Pipeline) finalizePendingSyntheses(ctx context.Context, cfg *Config, bucketName string) error {
+	objects, err := p.store.ListObjectsWithPrefix(ctx, bucketName, pendingPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list pending operations: %w", err)
+	}
+	log.Printf("Checking %d pending long audio operation(s) in %s.", len(objects), bucketName)
+
+	for _, obj := range objects {
+		if shuttingDown() {
+			log.Printf("The instance is shutting down. Leaving the remaining pending operations to the next run.")
+			return nil
+		}
+		if !strings.HasSuffix(obj.Name, ".json") {
+			continue
+		}
+		data, err := p.store.ReadObject(ctx, bucketName, obj.Name)
+		if err != nil {
+			log.Printf("Error reading pending record %s: %v", obj.Name, err)
+			continue
+		}
+		var pending pendingSynthesis
+		if err := json.Unmarshal(data, &pending); err != nil || (pending.Operation == "" && len(pending.Parts) == 0) {
+			log.Printf("Error: Pending record %s is invalid (%v). Removing it.", obj.Name, err)
+			p.store.DeleteObject(ctx, bucketName, obj.Name)
+			continue
+		}
+
+		synth, err := tts.NewSynthesizer(ctx, pending.Provider, p.providerConfig(cfg))
+		if err != nil {
+			log.Printf("Error: Pending record %s has an unusable provider: %v", obj.Name, err)
+			continue
+		}
+		done, progress, err := p.checkSynthesis(ctx, synth, &pending)
+		generation := pending.Source[sourceGenerationKey]
+		input := fmt.Sprintf("gs://%s/%s", pending.Bucket, pending.InputObject)
+		switch {
+		case err != nil && done:
+			log.Printf("Error: Long audio synthesis for %s failed: %v", pending.InputObject, err)
+			p.deleteParts(ctx, pending.Parts)
+			p.writeFailureReport(ctx, cfg, pending.Bucket, pending.InputObject, failureReport{
+				Input:      fmt.Sprintf("gs://%s/%s", pending.Bucket, pending.InputObject),
+				Generation: generation,
+				Stage:      stageSynthesis,
+				Error:      err.Error(),
+				Retryable:  isRetryableFailure(err),
+			})
+			p.trackJob(ctx, pending.Bucket, pending.InputObject, generation, pending.Job, jobtrack.Record{State: jobtrack.Failed, Output: pending.OutputURI, Stage: stageSynthesis, Error: err.Error(), Retryable: isRetryableFailure(err)})
+			p.notifyCompletion(ctx, cfg, pending.Callback, pending.NotifyEmail, webhookPayload{Event: webhookFailed, Input: input, Generation: generation, Output: pending.OutputURI, Stage: stageSynthesis, Error: err.Error(), Retryable: isRetryableFailure(err)})
+		case err != nil:
+			log.Printf("Error checking long audio synthesis for %s: %v. Will retry on the next run.", pending.InputObject, err)
+			continue
+		case !done:
+			log.Printf("Long audio synthesis for %s is %.1f%% complete (running for %v).", pending.InputObject, progress, time.Since(pending.StartedAt).Round(time.Second))
+			p.progressReporter(ctx, pending.Bucket, pending.InputObject, generation, pending.Job)(jobtrack.Progress{Percent: progress})
+			if p.renewSynthesisSlot(ctx, pending.Slot) {
+				p.saveRenewedSlot(ctx, bucketName, obj.Name, pending)
+			}
+			continue
+		default:
+			p.trackJob(ctx, pending.Bucket, pending.InputObject, generation, pending.Job, jobtrack.Record{State: jobtrack.Finalizing, Output: pending.OutputURI})
+			p.setOutputHeaders(ctx, pending.OutputURI, pending.Headers)
+			if pending.Source != nil {
+				p.markOutputSource(ctx, pending.OutputURI, pending.Source)
+			}
+			if pending.ContentKey != "" {
+				p.registerSynthesizedAudio(ctx, pending.Bucket, pending.ContentKey, pending.InputObject, pending.OutputURI)
+			}
+			if pending.Manifest != nil {
+				*pending.Manifest = p.writeManifest(ctx, cfg, *pending.Manifest, pending.StartedAt)
+			}
+			p.cleanupIntermediates(ctx, pending.OutputURI)
+			if err := p.deliverOutput(ctx, cfg, pending.OutputURI); err != nil {
+				// The audio is done; uploading the PDF again repeats only the delivery.
+				log.Printf("Error: %v", err)
+				p.writeFailureReport(ctx, cfg, pending.Bucket, pending.InputObject, failureReport{
+					Input:      fmt.Sprintf("gs://%s/%s", pending.Bucket, pending.InputObject),
+					Generation: generation,
+					Stage:      stageDelivery,
+					Error:      err.Error(),
+					Retryable:  isRetryableFailure(err),
+				})
+				p.trackJob(ctx, pending.Bucket, pending.InputObject, generation, pending.Job, jobtrack.Record{State: jobtrack.Failed, Output: pending.OutputURI, Stage: stageDelivery, Error: err.Error(), Retryable: isRetryableFailure(err)})
+				p.notifyCompletion(ctx, cfg, pending.Callback, pending.NotifyEmail, webhookPayload{Event: webhookFailed, Input: input, Generation: generation, Output: pending.OutputURI, Stage: stageDelivery, Error: err.Error(), Retryable: isRetryableFailure(err)})
+				break
+			}
+			p.archiveInput(ctx, cfg, pending.Bucket, pending.InputObject, pending.OutputURI)
+			p.trackJob(ctx, pending.Bucket, pending.InputObject, generation, pending.Job, jobtrack.Record{State: jobtrack.Done, Output: pending.OutputURI})
+			p.notifyCompletion(ctx, cfg, pending.Callback, pending.NotifyEmail, webhookPayload{Event: webhookSucceeded, Input: input, Generation: generation, Output: pending.OutputURI, Stats: pending.Manifest})
+			log.Printf("Successfully processed %s. Output: %s", pending.InputObject, pending.OutputURI)
+		}
+
+		p.releaseSynthesisSlot(ctx, pending.Slot)
+		if err := p.store.DeleteObject(ctx, bucketName, obj.Name); err != nil {
+			log.Printf("Error removing pending record %s: %v", obj.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package pdftospeech
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"maps"
+	"sync"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/drive"
+	"MODULE_NAME/jsou-tts/internal/dropbox"
+	"MODULE_NAME/jsou-tts/internal/email"
+	"MODULE_NAME/jsou-tts/internal/events"
+	"MODULE_NAME/jsou-tts/internal/jobtrack"
+	"MODULE_NAME/jsou-tts/internal/pdf-to-text/pdfprocessor"
+	"MODULE_NAME/jsou-tts/internal/secrets"
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tasks"
+	"MODULE_NAME/jsou-tts/internal/tts"
+	"golang.org/x/time/rate"
+)
+
+// Storage is where a Pipeline reads PDFs and writes their audio and records.
+// Objects are addressed by bucket and name as in Cloud Storage.
+type Storage = storage.Storage
+
+// ObjectInfo describes an object listed by a Storage.
+type ObjectInfo = storage.ObjectInfo
+
+// ObjectHeaders are the HTTP headers a Storage serves an object with.
+type ObjectHeaders = storage.ObjectHeaders
+
+// SecretReader reads the secrets the configuration names, e.g.
+// AZURE_SPEECH_KEY_SECRET, by their Secret Manager resource names.
+type SecretReader = secrets.Reader
+
+// Extraction is the text an Extractor read from a PDF, with its page count and
+// the pages it couldn't read.
+type Extraction = pdfprocessor.Extraction
+
+// ExtractOptions select the pages an Extractor reads, from the tts-pages
+// setting, and how it reports its progress.
+type ExtractOptions = pdfprocessor.ExtractOptions
+
+// Extractor reads the text of PDFs, e.g. with OCR for scanned books.
+type Extractor interface {
+	// Extract returns the text of the PDF of size bytes read through r. name
+	// identifies it in logs.
+	Extract(ctx context.Context, r io.ReaderAt, size int64, name string, opts ExtractOptions) (Extraction, error)
+}
+
+// Notification tells a Notifier how a document ended.
+type Notification struct {
+	// Event is "succeeded" or "failed".
+	Event      string
+	Input      string // gs:// URI of the PDF.
+	Generation string
+	Output     string // gs:// URI of the audio.
+	// Stage, Error and Retryable describe a failure.
+	Stage     string
+	Error     string
+	Retryable bool
+	Timestamp time.Time
+}
+
+// Notifier is told how each document a Pipeline processes ends, besides the
+// webhooks, emails and events configured.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Source is a PDF for a Pipeline to convert: an object in its storage, with
+// per-document settings named like the object metadata the function reads
+// (tts-voice, tts-pages, tts-speaking-rate, ...).
+type Source struct {
+	Bucket string
+	Object string
+	// ContentType tells a PDF whose name doesn't end in ".pdf" apart, as
+	// application/pdf. It's only needed for such a PDF.
+	ContentType string
+	Metadata    map[string]string
+}
+
+// Pipeline converts PDFs to speech: it extracts their text, normalizes it and
+// synthesizes it into audio next to them, as the function does for uploads. It
+// can be embedded in other Go programs; the Cloud Function is a thin wrapper
+// around one.
+//
+// The configuration comes from the same environment variables as the
+// function's. Each Pipeline has its own configuration, storage and clients, so
+// pipelines with different options can run side by side in one process.
+type Pipeline struct {
+	cfg       *Config
+	store     Storage
+	ownsStore bool // Whether NewPipeline created store, rather than WithStorage.
+	secrets   SecretReader
+	// ownsSecrets is whether NewPipeline created secrets, rather than
+	// WithSecrets.
+	ownsSecrets bool
+	voice       string
+	extractor   Extractor
+	notifiers   []Notifier
+
+	// The clients created by loadClients.
+	ttsClient  *tts.Client       // Only created when the provider is Google.
+	tracker    *jobtrack.Tracker // Only created when JOBS_COLLECTION is set.
+	jobs       jobStore          // The tracker, keeping the records of on-demand jobs.
+	mailer     email.Sender      // Only created when EMAIL_PROVIDER is set.
+	publisher  *events.Publisher // Only created when EVENTS_TOPIC is set.
+	retryQueue *tasks.Queue      // Only created when RETRY_QUEUE is set.
+	// textPublisher hands extracted text to the synthesis stage. Only created
+	// when EXTRACTED_TEXT_TOPIC is set.
+	textPublisher *events.Publisher
+	// chapterPublisher fans out the chapters of books. Only created when
+	// CHAPTER_TOPIC is set.
+	chapterPublisher *events.Publisher
+	// jobPublisher hands queued on-demand jobs to RunOnDemandJob. Only created
+	// when JOBS_TOPIC is set.
+	jobPublisher  messagePublisher
+	driveClient   *drive.Client   // Only created when DRIVE_FOLDER_ID is set.
+	dropboxClient *dropbox.Client // Only created when DROPBOX_FOLDER is set.
+	jobsAPIKey    string          // Only read when JOBS_API_KEY_SECRET is set.
+	// chunkSlots hold the chunk requests of the pipeline's documents to
+	// INSTANCE_CHUNK_CONCURRENCY; nil if that isn't set.
+	chunkSlots tts.ChunkSlots
+	// ttsLimiter holds the TTS API requests of the pipeline to TTS_QPS. Only
+	// created when TTS_QPS is set without TTS_MAX_CONCURRENT_JOBS, which gives
+	// each job a limiter of its own.
+	ttsLimiter *rate.Limiter
+	// azureKey keeps the Azure Speech key for the synthesizers of the
+	// pipeline.
+	azureKey tts.KeyCache
+}
+
+// Option configures a Pipeline.
+type Option func(*Pipeline)
+
+// WithVoice makes the pipeline's default voice the named one, e.g.
+// "en-GB-Neural2-B", rather than TTS_VOICE_NAME. Like TTS_VOICE_NAME, it's used
+// for documents whose tts-voice setting, from their metadata or their folder's
+// _config.json, doesn't name one, and VOICE_MAP still switches it for
+// documents in other languages.
+func WithVoice(name string) Option {
+	return func(p *Pipeline) { p.voice = name }
+}
+
+// WithExtractor makes the pipeline read the text of PDFs with e rather than its
+// own extractor.
+func WithExtractor(e Extractor) Option {
+	return func(p *Pipeline) { p.extractor = e }
+}
+
+// WithStorage makes the pipeline use s rather than the backend chosen by
+// STORAGE_BACKEND.
+func WithStorage(s Storage) Option {
+	return func(p *Pipeline) { p.store = s }
+}
+
+// WithSecrets makes the pipeline read its secrets with r rather than from
+// Secret Manager.
+func WithSecrets(r SecretReader) Option {
+	return func(p *Pipeline) { p.secrets = r }
+}
+
+// WithNotifier adds n to the notifiers told how each document ends.
+func WithNotifier(n Notifier) Option {
+	return func(p *Pipeline) { p.notifiers = append(p.notifiers, n) }
+}
+
+// NewPipeline creates a Pipeline with the given options, loading the
+// configuration and creating the storage backend and clients it needs. Call
+// Close when done with it.
+func NewPipeline(opts ...Option) (_ *Pipeline, err error) {
+	p := &Pipeline{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	cfg, err := loadEnvConfig()
+	if err != nil {
+		return nil, err
+	}
+	// A pipeline that fails to start doesn't leave what it created open.
+	defer func() {
+		if err != nil {
+			p.Close()
+		}
+	}()
+	if p.secrets == nil {
+		p.secrets, p.ownsSecrets = &secrets.Client{}, true
+	}
+	// The clients outlive the invocation, so they're created without its context.
+	ctx := context.Background()
+	if p.store == nil {
+		s, err := p.newStorage(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		p.store, p.ownsStore = s, true
+	}
+	if err := cfg.loadConfigObject(ctx, p.store); err != nil {
+		return nil, err
+	}
+	p.cfg = cfg
+	if err := p.loadClients(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Close closes the clients of the pipeline, and its storage and secret reader
+// unless they were given with WithStorage and WithSecrets. The pipeline can't be used afterwards.
+func (p *Pipeline) Close() error {
+	var errs []error
+	closeClient := func(c io.Closer) {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c, ok := p.store.(io.Closer); ok && p.ownsStore {
+		closeClient(c)
+	}
+	if c, ok := p.secrets.(io.Closer); ok && p.ownsSecrets {
+		closeClient(c)
+	}
+	if p.ttsClient != nil {
+		closeClient(p.ttsClient)
+	}
+	if p.tracker != nil {
+		closeClient(p.tracker)
+	}
+	for _, pub := range []*events.Publisher{p.publisher, p.textPublisher, p.chapterPublisher} {
+		if pub != nil {
+			closeClient(pub)
+		}
+	}
+	if c, ok := p.jobPublisher.(io.Closer); ok {
+		closeClient(c)
+	}
+	if p.retryQueue != nil {
+		closeClient(p.retryQueue)
+	}
+	return errors.Join(errs...)
+}
+
+// Process converts the PDF of src to speech and returns the gs:// URI of the
+// audio, or "" if the PDF had no text to read. The PDF needn't be in an input
+// folder, and isn't moved to processed/ once done. With ASYNC_LONG_AUDIO, long
+// audio is left to FinalizePendingSyntheses and the URI is where it will appear.
+func (p *Pipeline) Process(ctx context.Context, src Source) (string, error) {
+	cfg := p.cfg
+	if p.voice != "" {
+		withVoice := *p.cfg
+		withVoice.VoiceName = p.voice
+		cfg = &withVoice
+	}
+	metadata := maps.Clone(src.Metadata)
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	job := &onDemandJob{}
+	err := p.processPDFToSpeechHandler(ctx, cfg, StorageObjectData{Bucket: src.Bucket, Name: src.Object, ContentType: src.ContentType, Metadata: metadata, job: job})
+	return job.Output, err
+}
+
+// handle runs the pipeline for the object of an event.
+func (p *Pipeline) handle(ctx context.Context, e StorageObjectData) error {
+	return p.processPDFToSpeechHandler(ctx, p.cfg, e)
+}
+
+// extract reads the text of a PDF with the pipeline's extractor, or the
+// built-in one.
+func (p *Pipeline) extract(ctx context.Context, r io.ReaderAt, size int64, name string, opts ExtractOptions) (Extraction, error) {
+	if p.extractor == nil {
+		return pdfprocessor.ExtractPagesFromReader(r, size, name, opts)
+	}
+	return p.extractor.Extract(ctx, r, size, name, opts)
+}
+
+// notify tells the pipeline's notifiers how a document ended. A failure is
+// only logged.
+func (p *Pipeline) notify(ctx context.Context, payload webhookPayload) {
+	n := Notification{
+		Event:      payload.Event,
+		Input:      payload.Input,
+		Generation: payload.Generation,
+		Output:     payload.Output,
+		Stage:      payload.Stage,
+		Error:      payload.Error,
+		Retryable:  payload.Retryable,
+		Timestamp:  time.Now().UTC(),
+	}
+	for _, notifier := range p.notifiers {
+		if err := notifier.Notify(ctx, n); err != nil {
+			log.Printf("Warning: Failed to notify about %s: %v", n.Input, err)
+		}
+	}
+}
+
+// The Pipeline the function's entry points run, created by functionPipeline.
+var (
+	functionPipelineMu sync.Mutex
+	defaultPipeline    *Pipeline
+)
+
+// functionPipeline returns the Pipeline of the function's entry points, with
+// the configuration from the environment, creating it on first use, and
+// starts watching for SIGTERM. A failure is returned so the invocation fails
+// and is retried.
+func functionPipeline() (*Pipeline, error) {
+	WatchShutdown()
+	functionPipelineMu.Lock()
+	defer functionPipelineMu.Unlock()
+	if defaultPipeline == nil {
+		p, err := NewPipeline()
+		if err != nil {
+			return nil, err
+		}
+		defaultPipeline = p
+	}
+	return defaultPipeline, nil
+}
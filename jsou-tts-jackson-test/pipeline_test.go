@@ -0,0 +1,118 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// testBucket is the BASE_GCS_BUCKET of the pipelines of newTestPipeline.
+const testBucket = "library"
+
+// newTestPipeline returns a pipeline with the default configuration over a
+// Local store in a temporary directory, keeping its on-demand jobs in a
+// fakeJobStore and publishing them to a fakePublisher.
+func newTestPipeline(t *testing.T) *Pipeline {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.BaseBucket = testBucket
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Pipeline{cfg: cfg, store: store, jobs: &fakeJobStore{jobs: map[string]onDemandJob{}}, jobPublisher: &fakePublisher{}}
+}
+
+// putJSON writes v as JSON to object in testBucket.
+func putJSON(t *testing.T, p *Pipeline, object string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.store.UploadFile(context.Background(), testBucket, object, data, "application/json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getJSON reads object in testBucket as JSON into v, and returns its
+// generation, or 0 if it doesn't exist.
+func getJSON(t *testing.T, p *Pipeline, object string, v any) int64 {
+	t.Helper()
+	data, generation, err := p.store.ReadObjectGeneration(context.Background(), testBucket, object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generation != 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return generation
+}
+
+// backdate sets the creation time of object in testBucket, which a Local
+// store takes from the file's modification time.
+func backdate(t *testing.T, p *Pipeline, object string, created time.Time) {
+	t.Helper()
+	name := filepath.Join(p.store.(*storage.Local).Root, testBucket, filepath.FromSlash(object))
+	if err := os.Chtimes(name, created, created); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeExtractor returns no text, recording the PDFs it was asked to read.
+type fakeExtractor struct {
+	read []string
+}
+
+func (e *fakeExtractor) Extract(ctx context.Context, r io.ReaderAt, size int64, name string, opts ExtractOptions) (Extraction, error) {
+	e.read = append(e.read, name)
+	return Extraction{}, nil
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		object      string
+		contentType string
+		wantRead    bool
+	}{
+		{name: "PDF", object: "books/book.pdf", wantRead: true},
+		{name: "PDF named without .pdf", object: "books/book", contentType: pdfContentType, wantRead: true},
+		{name: "other file", object: "books/book"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			p.cfg.ProjectNumber, p.cfg.Location = "123", "global"
+			p.ttsClient = &tts.Client{}
+			extractor := &fakeExtractor{}
+			p.extractor = extractor
+			if err := p.store.UploadFile(context.Background(), testBucket, tt.object, []byte("%PDF-1.7"), tt.contentType); err != nil {
+				t.Fatal(err)
+			}
+			metadata := map[string]string{"tts-pages": "1-2"}
+			output, err := p.Process(context.Background(), Source{Bucket: testBucket, Object: tt.object, ContentType: tt.contentType, Metadata: metadata})
+			if err != nil || output != "" {
+				t.Fatalf("Process returned %q, %v; want no audio of a PDF without text", output, err)
+			}
+			if read := len(extractor.read) > 0; read != tt.wantRead {
+				t.Errorf("PDF read: %v, want %v", read, tt.wantRead)
+			}
+			if len(metadata) != 1 {
+				t.Errorf("Process changed the source's metadata to %v", metadata)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package pdftospeech
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"MODULE_NAME/jsou-tts/internal/ssml"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// previewText is read when a preview request brings no text of its own.
+const previewText = "It was a bright cold day in April, and the clocks were striking thirteen. " +
+	"This is a short sample of how the voice reads a book: its pace, its pauses, and the way it handles numbers like 1,984 and dates like March 3rd, 2021."
+
+// maxPreviewChars caps the text of a preview, so the endpoint can't be used to
+// synthesize whole documents outside the pipeline's budgets.
+const maxPreviewChars = 1000
+
+// previewVoice serves the PreviewVoice entry point. It synthesizes a short
+// sample with the voice and audio settings given as query parameters and
+// returns the audio, so a voice can be auditioned before a whole book is read
+// with it. Parameters use the same names as the per-document metadata
+// (tts-voice, tts-speaking-rate, tts-pitch, tts-prompt, ...), falling back to the
+// deployment's configuration; text and encoding set the sample and the format.
+func previewVoice(w http.ResponseWriter, r *http.Request) {
+	p, err := functionPipeline()
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, "service unavailable, try again", http.StatusServiceUnavailable)
+		return
+	}
+	cfg := p.cfg
+
+	query := r.URL.Query()
+	metadata := map[string]string{}
+	for key := range query {
+		if strings.HasPrefix(key, "tts-") {
+			metadata[key] = query.Get(key)
+		}
+	}
+
+	text := query.Get("text")
+	if text == "" {
+		text = previewText
+	}
+	if n := utf8.RuneCountInString(text); n > maxPreviewChars {
+		http.Error(w, fmt.Sprintf("text has %d characters; previews are limited to %d", n, maxPreviewChars), http.StatusBadRequest)
+		return
+	}
+	encoding := query.Get("encoding")
+	if encoding == "" {
+		encoding = cfg.AudioEncoding
+	}
+	audioFormat, err := tts.ParseAudioFormat(encoding)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audioSettings, err := audioSettingsFor(cfg, audioFormat, metadata)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid audio settings: %v", err), http.StatusBadRequest)
+		return
+	}
+	voiceName := lookupSetting(metadata, "tts-voice", cfg.VoiceName)
+	if voiceName == "" {
+		http.Error(w, "no voice given; pass tts-voice", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	synth, err := tts.NewSynthesizer(ctx, cfg.Provider, p.providerConfig(cfg))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid TTS_PROVIDER: %v", err), http.StatusInternalServerError)
+		return
+	}
+	voice := tts.ParseVoice(voiceName)
+	if voice.LanguageCode == "" {
+		voice.LanguageCode = tts.DefaultLanguageCode
+	}
+	if voice.Model != "" {
+		voice.Prompt = lookupSetting(metadata, "tts-prompt", cfg.GeminiPrompt)
+	}
+	capabilities := synth.Capabilities(voice)
+	audioSettings = capabilities.Adapt(audioSettings)
+
+	opts := ssml.DefaultOptions
+	opts.LanguageCode = voice.LanguageCode
+	inputs := buildInputs(text, opts, capabilities.SSML, capabilities.ChunkBytes())
+	if len(inputs) != 1 {
+		http.Error(w, "text is too long for a single request with this voice", http.StatusBadRequest)
+		return
+	}
+	if err := validateInputs(inputs, capabilities.ChunkBytes()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audio, err := synth.SynthesizeChunk(ctx, inputs[0], voice, audioSettings)
+	if err != nil {
+		log.Printf("Error: Voice preview with %s failed: %v", voice, err)
+		http.Error(w, fmt.Sprintf("synthesis failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	log.Printf("Synthesized a %d-character preview with voice %s.", utf8.RuneCountInString(text), voice)
+	w.Header().Set("Content-Type", audioFormat.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", "preview"+audioFormat.Extension))
+	w.Write(audio)
+}
@@ -0,0 +1,16 @@
+package pdftospeech
+
+import "context"
+
+// ProcessObject runs the pipeline for the PDF object in bucket, as an upload to
+// pdf-input/ would but wherever the object is, with metadata as its
+// per-document settings. It returns the URI of the audio. It's a shorthand for
+// Process on a Pipeline without options; the clients are created from the
+// configuration on first use, like the function's.
+func ProcessObject(ctx context.Context, bucket, object string, metadata map[string]string) (string, error) {
+	p, err := NewPipeline()
+	if err != nil {
+		return "", err
+	}
+	return p.Process(ctx, Source{Bucket: bucket, Object: object, Metadata: metadata})
+}
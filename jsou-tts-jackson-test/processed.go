@@ -0,0 +1,46 @@
+package pdftospeech
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// processedPrefix is where input PDFs are moved once their audio is done.
+const processedPrefix = "processed/"
+
+// Metadata keys of a moved input, recording when it was finished and the name
+// it had in its input folder.
+const (
+	completedAtKey = "tts-completed-at"
+	inputKey       = "tts-input"
+)
+
+// processedObjectName returns where a finished input is moved, keeping its path
+// below the input folder at inputPrefix, e.g. "processed/series/book.pdf".
+func processedObjectName(inputPrefix, inputName string) string {
+	return processedPrefix + strings.TrimPrefix(inputName, inputPrefix)
+}
+
+// archiveInput moves a finished input PDF to processed/ with its completion time,
+// original name and output in the metadata, so the input folder only holds work still to do.
+// MOVE_PROCESSED=false leaves inputs in place, only recording their output when
+// DELETE_OUTPUTS_WITH_INPUT needs it, as are PDFs outside the input folder
+// processed on demand. A failure is only logged, since the audio is done either way.
+func (p *Pipeline) archiveInput(ctx context.Context, cfg *Config, bucket, inputName, outputURI string) {
+	folder, ok := cfg.inputFolder(inputName)
+	if !ok {
+		return
+	}
+	if !cfg.MoveProcessed {
+		if cfg.DeleteOutputsWithInput {
+			p.recordOutput(ctx, bucket, inputName, outputURI)
+		}
+		return
+	}
+	metadata := map[string]string{completedAtKey: time.Now().UTC().Format(time.RFC3339), inputKey: inputName, outputKey: outputURI}
+	if err := p.store.MoveObject(ctx, bucket, inputName, processedObjectName(folder, inputName), metadata); err != nil {
+		log.Printf("Warning: Failed to move %s to %s: %v", inputName, processedPrefix, err)
+	}
+}
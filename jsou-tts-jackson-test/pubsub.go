@@ -0,0 +1,71 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+
+	v2 "github.com/cloudevents/sdk-go/v2"
+)
+
+// pubSubMessage is the payload of a Pub/Sub CloudEvent. Data arrives base64
+// encoded and is decoded by encoding/json.
+type pubSubMessage struct {
+	Message struct {
+		Data      []byte `json:"data"`
+		MessageID string `json:"messageId"`
+	} `json:"message"`
+}
+
+// processingRequest asks for a PDF already in storage to be processed, e.g.
+//
+//	{"bucket": "my-bucket", "object": "pdf-input/book.pdf", "options": {"tts-voice": "en-GB-Neural2-B", "tts-force": "true"}}
+//
+// Options are per-document settings named like the object metadata keys, and
+// take precedence over the object's own metadata.
+type processingRequest struct {
+	Bucket  string            `json:"bucket"`
+	Object  string            `json:"object"`
+	Options map[string]string `json:"options"`
+}
+
+// processPubSubRequest serves the ProcessPubSubRequest entry point, which runs
+// the pipeline for the PDF named in a Pub/Sub message, so processing can be
+// requested programmatically or replayed without uploading the file again.
+// Malformed messages are logged and acknowledged, since redelivering them
+// can't help; a run that failed transiently returns its error, so a
+// subscription with retries redelivers the message.
+func processPubSubRequest(ctx context.Context, e v2.Event) error {
+	var msg pubSubMessage
+	if err := e.DataAs(&msg); err != nil {
+		log.Printf("Error: Invalid Pub/Sub event %s: %v. Dropping it.", e.ID(), err)
+		return nil
+	}
+	var req processingRequest
+	if err := json.Unmarshal(msg.Message.Data, &req); err != nil || req.Bucket == "" || req.Object == "" {
+		log.Printf("Error: Pub/Sub message %s isn't a processing request with a bucket and an object (%v). Dropping it.", msg.Message.MessageID, err)
+		return nil
+	}
+	p, err := functionPipeline()
+	if err != nil {
+		return err
+	}
+	cfg := p.cfg
+
+	metadata, exists, err := p.store.ObjectMetadata(ctx, req.Bucket, req.Object)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata of %s in bucket %s: %w", req.Object, req.Bucket, err)
+	}
+	if !exists {
+		log.Printf("Warning: %s doesn't exist in bucket %s. Dropping the request.", req.Object, req.Bucket)
+		return nil
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	maps.Copy(metadata, req.Options)
+	log.Printf("Processing %s in bucket %s as requested by Pub/Sub message %s.", req.Object, req.Bucket, msg.Message.MessageID)
+	return retryableOnly(req.Object, p.processPDFToSpeechHandler(ctx, cfg, StorageObjectData{Bucket: req.Bucket, Name: req.Object, Metadata: metadata}))
+}
@@ -0,0 +1,188 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+// leasePrefix holds one object per synthesis slot in BASE_GCS_BUCKET. A job may
+// synthesize only while it holds a slot, which caps the number of jobs, and long
+// audio operations, that run at once across every function instance and bucket.
+const leasePrefix = "tts-leases/"
+
+// staleLeaseAge is how long a lease may go without being renewed before it's
+// assumed to belong to a crashed invocation and is reclaimed. It must exceed the
+// function timeout, since a lease held by an invocation isn't renewed.
+const staleLeaseAge = 2 * time.Hour
+
+// leaseRenewInterval is how often the lease of a long audio operation followed
+// by FinalizePendingSyntheses is renewed, well within staleLeaseAge.
+const leaseRenewInterval = 30 * time.Minute
+
+// Bounds of the wait between attempts to get a free slot.
+const (
+	initialSlotWait = 5 * time.Second
+	maxSlotWait     = 60 * time.Second
+)
+
+// synthesisSlot identifies a claimed slot. The generation keeps a holder from
+// renewing or releasing the slot after it was reclaimed and handed to another
+// job.
+type synthesisSlot struct {
+	Bucket     string    `json:"bucket"`
+	Object     string    `json:"object"`
+	Generation int64     `json:"generation"`
+	Lease      slotLease `json:"lease"`
+}
+
+// slotLease is the content of a lease object.
+type slotLease struct {
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	// RenewedAt is when the holder last showed it's still at work; a lease
+	// that hasn't been renewed for staleLeaseAge is reclaimed.
+	RenewedAt time.Time `json:"renewed_at"`
+}
+
+// ttsRequestRate returns this job's share of qps, the TTS API requests per
+// second allowed across all jobs by TTS_QPS. With the slot gate enabled the
+// budget is split evenly between the slots; without it, it applies per instance.
+func ttsRequestRate(qps float64, slots int) float64 {
+	if slots > 0 {
+		qps /= float64(slots)
+	}
+	return qps
+}
+
+// acquireSynthesisSlot waits until one of the slots slots in the bucket is free
+// and claims it for holder, to be released with releaseSynthesisSlot. With no
+// slots configured it returns nil immediately.
+func (p *Pipeline) acquireSynthesisSlot(ctx context.Context, bucketName, holder string, slots int) (*synthesisSlot, error) {
+	if slots <= 0 {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	record := slotLease{Holder: holder, AcquiredAt: now, RenewedAt: now}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lease: %w", err)
+	}
+
+	wait := initialSlotWait
+	for {
+		// Start at a random slot so waiting jobs don't all contend for slot 0.
+		first := rand.IntN(slots)
+		for i := range slots {
+			lease := fmt.Sprintf("%sslot-%03d", leasePrefix, (first+i)%slots)
+			generation, err := p.store.CreateObjectIfAbsent(ctx, bucketName, lease, data, "application/json")
+			if err != nil {
+				return nil, fmt.Errorf("failed to acquire synthesis slot: %w", err)
+			}
+			if generation != 0 {
+				log.Printf("Acquired synthesis slot %s for %s.", lease, holder)
+				return &synthesisSlot{Bucket: bucketName, Object: lease, Generation: generation, Lease: record}, nil
+			}
+		}
+
+		if reclaimed := p.reclaimStaleLeases(ctx, bucketName); reclaimed > 0 {
+			continue
+		}
+		log.Printf("All %d synthesis slots are busy. Waiting %v before trying again for %s.", slots, wait, holder)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("stopped waiting for a synthesis slot: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxSlotWait)
+	}
+}
+
+// reclaimStaleLeases deletes the leases that haven't been renewed for
+// staleLeaseAge and returns how many it removed. Errors are logged, since
+// waiting for a slot can simply go on.
+func (p *Pipeline) reclaimStaleLeases(ctx context.Context, bucketName string) int {
+	leases, err := p.store.ListObjectsWithPrefix(ctx, bucketName, leasePrefix)
+	if err != nil {
+		log.Printf("Error listing synthesis slots: %v", err)
+		return 0
+	}
+	reclaimed := 0
+	for _, lease := range leases {
+		// A lease is written when it's acquired or renewed, so one created
+		// recently is live without reading it.
+		if time.Since(lease.Created) < staleLeaseAge {
+			continue
+		}
+		data, generation, err := p.store.ReadObjectGeneration(ctx, bucketName, lease.Name)
+		if err != nil {
+			log.Printf("Error reading synthesis slot %s: %v", lease.Name, err)
+			continue
+		}
+		if data == nil {
+			continue // Released in the meantime.
+		}
+		var record slotLease
+		if err := json.Unmarshal(data, &record); err == nil && time.Since(record.RenewedAt) < staleLeaseAge {
+			continue
+		}
+		ok, err := p.store.DeleteObjectGeneration(ctx, bucketName, lease.Name, generation)
+		if err != nil {
+			log.Printf("Error reclaiming stale synthesis slot %s: %v", lease.Name, err)
+			continue
+		}
+		if ok {
+			log.Printf("Warning: Reclaimed synthesis slot %s of %s, last renewed at %v.", lease.Name, record.Holder, record.RenewedAt)
+			reclaimed++
+		}
+	}
+	return reclaimed
+}
+
+// renewSynthesisSlot renews the lease of slot if it's due, so it isn't
+// reclaimed while the long audio operation holding it still runs, and records
+// the lease's new generation in slot. It reports whether it did. Errors are only
+// logged: a lease that can't be renewed is tried again on the next call.
+func (p *Pipeline) renewSynthesisSlot(ctx context.Context, slot *synthesisSlot) bool {
+	if slot == nil || time.Since(slot.Lease.RenewedAt) < leaseRenewInterval {
+		return false
+	}
+	record := slot.Lease
+	record.RenewedAt = time.Now().UTC()
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error encoding synthesis slot %s: %v", slot.Object, err)
+		return false
+	}
+	written, err := p.store.UpdateObjectIfGeneration(ctx, slot.Bucket, slot.Object, data, "application/json", slot.Generation)
+	switch {
+	case err != nil:
+		log.Printf("Error renewing synthesis slot %s: %v", slot.Object, err)
+		return false
+	case written == 0:
+		log.Printf("Warning: Synthesis slot %s was reclaimed before it was renewed.", slot.Object)
+		return false
+	}
+	slot.Generation, slot.Lease = written, record
+	return true
+}
+
+// releaseSynthesisSlot frees a slot claimed by acquireSynthesisSlot. A nil slot
+// is a no-op. Errors are only logged: the slot is reclaimed once it's stale.
+func (p *Pipeline) releaseSynthesisSlot(ctx context.Context, slot *synthesisSlot) {
+	if slot == nil {
+		return
+	}
+	ok, err := p.store.DeleteObjectGeneration(context.WithoutCancel(ctx), slot.Bucket, slot.Object, slot.Generation)
+	switch {
+	case err != nil:
+		log.Printf("Error releasing synthesis slot %s: %v", slot.Object, err)
+	case !ok:
+		log.Printf("Warning: Synthesis slot %s was reclaimed before it was released.", slot.Object)
+	default:
+		log.Printf("Released synthesis slot %s.", slot.Object)
+	}
+}
@@ -0,0 +1,75 @@
+package pdftospeech
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReclaimStaleLeases(t *testing.T) {
+	const lease = leasePrefix + "slot-000"
+	old := time.Now().Add(-3 * time.Hour).UTC()
+	tests := []struct {
+		name          string
+		lease         any
+		wantReclaimed int
+	}{
+		{
+			name:  "renewed lease of an old slot",
+			lease: slotLease{Holder: "gs://library/pdf-input/book.pdf", AcquiredAt: old, RenewedAt: time.Now().UTC()},
+		},
+		{
+			name:          "lease not renewed for too long",
+			lease:         slotLease{Holder: "gs://library/pdf-input/book.pdf", AcquiredAt: old, RenewedAt: old},
+			wantReclaimed: 1,
+		},
+		{
+			name:          "invalid lease",
+			lease:         "not a lease",
+			wantReclaimed: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			putJSON(t, p, lease, tt.lease)
+			backdate(t, p, lease, old)
+
+			reclaimed := p.reclaimStaleLeases(context.Background(), testBucket)
+			if reclaimed != tt.wantReclaimed {
+				t.Errorf("%d leases reclaimed, want %d", reclaimed, tt.wantReclaimed)
+			}
+		})
+	}
+}
+
+func TestRenewSynthesisSlot(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPipeline(t)
+	slot, err := p.acquireSynthesisSlot(ctx, testBucket, "gs://library/pdf-input/book.pdf", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.renewSynthesisSlot(ctx, slot) {
+		t.Fatal("slot renewed right after it was acquired")
+	}
+
+	slot.Lease.RenewedAt = time.Now().Add(-leaseRenewInterval).UTC()
+	acquired := slot.Generation
+	if !p.renewSynthesisSlot(ctx, slot) {
+		t.Fatal("slot not renewed once due")
+	}
+	var stored slotLease
+	generation := getJSON(t, p, slot.Object, &stored)
+	if generation != slot.Generation || generation == acquired {
+		t.Errorf("lease at generation %d, slot at %d, acquired at %d", generation, slot.Generation, acquired)
+	}
+	if time.Since(stored.RenewedAt) > time.Minute {
+		t.Errorf("lease last renewed at %v", stored.RenewedAt)
+	}
+
+	p.releaseSynthesisSlot(ctx, slot)
+	if generation := getJSON(t, p, slot.Object, &stored); generation != 0 {
+		t.Errorf("lease still held after its renewed slot was released")
+	}
+}
@@ -0,0 +1,209 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// reprocessRequest is the body of a POST to ReprocessInputs. Prefix narrows the
+// PDFs considered to a folder of the input folder, and Options are per-document
+// settings passed to every job queued. With DryRun, nothing is queued.
+type reprocessRequest struct {
+	Prefix  string            `json:"prefix"`
+	Options map[string]string `json:"options"`
+	DryRun  bool              `json:"dry_run"`
+}
+
+// reprocessReport is the response of ReprocessInputs: the PDFs that had no
+// output or one made from an earlier version, and the jobs queued for them.
+type reprocessReport struct {
+	Missing    []string      `json:"missing"`
+	Stale      []string      `json:"stale"`
+	UpToDate   int           `json:"up_to_date"`
+	InProgress int           `json:"in_progress"`
+	Queued     []onDemandJob `json:"queued"`
+	DryRun     bool          `json:"dry_run,omitempty"`
+}
+
+// reprocessInputs serves the ReprocessInputs entry point, which catches up on
+// the PDFs in the input folder of BASE_GCS_BUCKET that have no output, or whose
+// output was made from an earlier version of the file, e.g. after an outage or
+// once a failing document is fixed. Each such PDF is queued as a job, as
+// ProcessOnDemand does, and the response lists what was found and queued.
+func reprocessInputs(w http.ResponseWriter, r *http.Request) {
+	body, ok := readEventBody(w, r)
+	if !ok {
+		return
+	}
+	var req reprocessRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	p, err := functionPipeline()
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, "service unavailable, try again", http.StatusServiceUnavailable)
+		return
+	}
+	cfg := p.cfg
+	if req.Prefix != "" && !slices.ContainsFunc(cfg.inputFolders(), func(folder string) bool { return strings.HasPrefix(req.Prefix, folder) }) {
+		http.Error(w, fmt.Sprintf("prefix must be in an input folder (%s)", strings.Join(cfg.inputFolders(), ", ")), http.StatusBadRequest)
+		return
+	}
+	if cfg.BaseBucket == "" {
+		log.Printf("Error: BASE_GCS_BUCKET must be set for ReprocessInputs")
+		http.Error(w, "function misconfigured", http.StatusInternalServerError)
+		return
+	}
+	if err := p.checkOnDemandJobs(); err != nil && !req.DryRun {
+		log.Printf("Error: %v for ReprocessInputs", err)
+		http.Error(w, "function misconfigured", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := p.reprocess(r.Context(), cfg, req)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// reprocess diffs the PDFs under req.Prefix, or in every input folder, in
+// BASE_GCS_BUCKET against the outputs described by the manifests in the output
+// folder and the pending long audio operations, and queues a job for each PDF without an up-to-date output. The
+// handler still skips a stale PDF whose content matches its output's, e.g. one
+// uploaded again unchanged. A failure to queue one job is logged and the rest
+// are still queued.
+func (p *Pipeline) reprocess(ctx context.Context, cfg *Config, req reprocessRequest) (reprocessReport, error) {
+	bucket := cfg.BaseBucket
+	prefixes := []string{req.Prefix}
+	if req.Prefix == "" {
+		prefixes = cfg.inputFolders()
+	}
+	var inputs []storage.ObjectInfo
+	for _, prefix := range prefixes {
+		objects, err := p.store.ListObjectsWithPrefix(ctx, bucket, prefix)
+		if err != nil {
+			return reprocessReport{}, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		inputs = append(inputs, objects...)
+	}
+	outputs, err := p.outputGenerations(ctx, cfg, bucket)
+	if err != nil {
+		return reprocessReport{}, err
+	}
+	running, err := p.pendingGenerations(ctx, bucket)
+	if err != nil {
+		return reprocessReport{}, err
+	}
+
+	report := reprocessReport{Missing: []string{}, Stale: []string{}, Queued: []onDemandJob{}, DryRun: req.DryRun}
+	for _, obj := range inputs {
+		if !strings.HasSuffix(strings.ToLower(obj.Name), ".pdf") || !cfg.isInput(obj.Name) {
+			continue
+		}
+		generation := strconv.FormatInt(obj.Generation, 10)
+		if running[obj.Name] == generation {
+			report.InProgress++
+			continue
+		}
+		made, exists := outputs[obj.Name]
+		switch {
+		case !exists:
+			report.Missing = append(report.Missing, obj.Name)
+		case made != generation:
+			report.Stale = append(report.Stale, obj.Name)
+		default:
+			report.UpToDate++
+			continue
+		}
+		if req.DryRun {
+			continue
+		}
+		uri := fmt.Sprintf("gs://%s/%s", bucket, obj.Name)
+		job, _, err := p.queueJob(ctx, bucket, jobRequest{URI: uri, Options: req.Options})
+		if err != nil {
+			log.Printf("Warning: Failed to queue %s for reprocessing: %v", uri, err)
+			continue
+		}
+		report.Queued = append(report.Queued, job)
+	}
+	log.Printf("Reprocessing %s: %d missing, %d stale, %d up to date, %d in progress, %d queued.",
+		strings.Join(prefixes, ", "), len(report.Missing), len(report.Stale), report.UpToDate, report.InProgress, len(report.Queued))
+	return report, nil
+}
+
+// outputGenerations maps the inputs in bucket that have an output to the
+// generation each output was made from, read from the manifests in the output
+// folder. An input with several outputs maps to the newest manifest's.
+func (p *Pipeline) outputGenerations(ctx context.Context, cfg *Config, inputBucket string) (map[string]string, error) {
+	outputBucket, outputPrefix := cfg.outputLocation(inputBucket)
+	objects, err := p.store.ListObjectsWithPrefix(ctx, outputBucket, outputPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outputs: %w", err)
+	}
+	generations := map[string]string{}
+	completed := map[string]storage.ObjectInfo{}
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Name, ".manifest.json") {
+			continue
+		}
+		data, err := p.store.ReadObject(ctx, outputBucket, obj.Name)
+		if err != nil {
+			log.Printf("Warning: Failed to read manifest %s: %v", obj.Name, err)
+			continue
+		}
+		var manifest jobManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			log.Printf("Warning: Skipping invalid manifest %s: %v", obj.Name, err)
+			continue
+		}
+		b, input, err := storage.ParseGCSURI(manifest.Input)
+		if err != nil || b != inputBucket {
+			continue
+		}
+		if prev, ok := completed[input]; ok && prev.Created.After(obj.Created) {
+			continue
+		}
+		completed[input] = obj
+		generations[input] = manifest.InputGeneration
+	}
+	return generations, nil
+}
+
+// pendingGenerations maps the inputs in bucket with a long audio operation
+// still pending to the generation being synthesized.
+func (p *Pipeline) pendingGenerations(ctx context.Context, bucket string) (map[string]string, error) {
+	objects, err := p.store.ListObjectsWithPrefix(ctx, bucket, pendingPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending operations: %w", err)
+	}
+	generations := map[string]string{}
+	for _, obj := range objects {
+		data, err := p.store.ReadObject(ctx, bucket, obj.Name)
+		if err != nil {
+			log.Printf("Warning: Failed to read pending record %s: %v", obj.Name, err)
+			continue
+		}
+		var pending pendingSynthesis
+		if json.Unmarshal(data, &pending) != nil || pending.InputObject == "" {
+			continue
+		}
+		generations[pending.InputObject] = pending.Source[sourceGenerationKey]
+	}
+	return generations, nil
+}
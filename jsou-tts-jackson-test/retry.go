@@ -0,0 +1,124 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults of RETRY_MAX_ATTEMPTS and RETRY_BASE_DELAY.
+const (
+	defaultRetryMaxAttempts = 5
+	defaultRetryBaseDelay   = 5 * time.Minute
+)
+
+// maxRetryDelay caps the backoff between retries of a document.
+const maxRetryDelay = 6 * time.Hour
+
+// retryTask is the body of a Cloud Tasks request to RetryDocument: the event of
+// the document that failed, and which retry this is.
+type retryTask struct {
+	Object  StorageObjectData `json:"object"`
+	Attempt int               `json:"attempt"`
+}
+
+// retryDelay returns how long to wait before retry number attempt: RETRY_BASE_DELAY,
+// doubling with every retry, up to maxRetryDelay.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// scheduleRetry queues retry number attempt of the document in e with Cloud
+// Tasks, when RETRY_QUEUE is set and RETRY_MAX_ATTEMPTS isn't used up. It
+// reports whether the retry was queued.
+func (p *Pipeline) scheduleRetry(ctx context.Context, cfg *Config, e StorageObjectData, attempt int) bool {
+	if p.retryQueue == nil || attempt > cfg.RetryMaxAttempts {
+		return false
+	}
+	body, err := json.Marshal(retryTask{Object: e, Attempt: attempt})
+	if err != nil {
+		log.Printf("Warning: Failed to encode the retry of %s: %v", e.Name, err)
+		return false
+	}
+	delay := retryDelay(cfg.RetryBaseDelay, attempt)
+	task, err := p.retryQueue.Enqueue(ctx, body, time.Now().Add(delay))
+	if err != nil {
+		log.Printf("Warning: Failed to schedule a retry of %s: %v", e.Name, err)
+		return false
+	}
+	log.Printf("Scheduled retry %d of %d for %s in %v as task %s.", attempt, cfg.RetryMaxAttempts, e.Name, delay, task)
+	return true
+}
+
+// retryDocument serves the RetryDocument entry point, which Cloud Tasks calls
+// with a retryTask to process a document again after a transient failure. A
+// document uploaded again since is left to the event of its new version. A
+// transient failure the handler couldn't queue another retry for, though
+// attempts are left, responds with 500 so the queue's own retries take over;
+// other failures are only logged, like those of an upload.
+func retryDocument(w http.ResponseWriter, r *http.Request) {
+	body, ok := readEventBody(w, r)
+	if !ok {
+		return
+	}
+	var task retryTask
+	if err := json.Unmarshal(body, &task); err != nil || task.Object.Bucket == "" || task.Object.Name == "" {
+		http.Error(w, fmt.Sprintf("invalid retry task: %v", err), http.StatusBadRequest)
+		return
+	}
+	p, err := functionPipeline()
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, "service unavailable, try again", http.StatusServiceUnavailable)
+		return
+	}
+	cfg := p.cfg
+
+	e := task.Object
+	ctx := r.Context()
+	current, err := p.currentGeneration(ctx, e.Bucket, e.Name)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if e.Generation != "" && current != e.Generation {
+		log.Printf("Skipping retry %d of %s: generation %s was replaced by %q.", task.Attempt, e.Name, e.Generation, current)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	log.Printf("Retrying %s (attempt %d of %d).", e.Name, task.Attempt, cfg.RetryMaxAttempts)
+	e.retryAttempt = task.Attempt
+	if err := p.processPDFToSpeechHandler(ctx, cfg, e); err != nil {
+		log.Printf("Error: Retry %d of %s failed: %v", task.Attempt, e.Name, err)
+		if isRetryableFailure(err) && task.Attempt < cfg.RetryMaxAttempts {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentGeneration returns the generation of the object, or "" if it no
+// longer exists.
+func (p *Pipeline) currentGeneration(ctx context.Context, bucket, object string) (string, error) {
+	objects, err := p.store.ListObjectsWithPrefix(ctx, bucket, object)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s in bucket %s: %w", object, bucket, err)
+	}
+	for _, obj := range objects {
+		if obj.Name == object {
+			return strconv.FormatInt(obj.Generation, 10), nil
+		}
+	}
+	return "", nil
+}
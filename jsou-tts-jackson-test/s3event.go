@@ -0,0 +1,106 @@
+package pdftospeech
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+)
+
+// s3EventNotification is an S3 event notification, as delivered by S3 to SQS,
+// SNS or Lambda. Object keys in it are URL-encoded.
+type s3EventNotification struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+		EventName   string `json:"eventName"`
+		S3          struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				ETag string `json:"eTag"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// s3EventBridgeEvent is an "Object Created" event from S3 through Amazon
+// EventBridge. Object keys in it aren't encoded.
+type s3EventBridgeEvent struct {
+	Source     string `json:"source"`
+	DetailType string `json:"detail-type"`
+	Detail     struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key  string `json:"key"`
+			ETag string `json:"etag"`
+		} `json:"object"`
+	} `json:"detail"`
+}
+
+// s3EventObjects returns the objects created according to an S3 event
+// notification or an EventBridge event. Other events, such as deletions and
+// the s3:TestEvent sent when a notification is configured, yield no objects.
+func s3EventObjects(body []byte) ([]StorageObjectData, error) {
+	var bridge s3EventBridgeEvent
+	if err := json.Unmarshal(body, &bridge); err != nil {
+		return nil, fmt.Errorf("invalid S3 event: %w", err)
+	}
+	if bridge.Source == "aws.s3" {
+		if bridge.DetailType != "Object Created" {
+			return nil, nil
+		}
+		return []StorageObjectData{s3Object(bridge.Detail.Bucket.Name, bridge.Detail.Object.Key, bridge.Detail.Object.ETag)}, nil
+	}
+
+	var notification s3EventNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("invalid S3 event: %w", err)
+	}
+	var objects []StorageObjectData
+	for _, record := range notification.Records {
+		if record.EventSource != "aws:s3" || !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			continue
+		}
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object key %q in S3 event: %w", record.S3.Object.Key, err)
+		}
+		objects = append(objects, s3Object(record.S3.Bucket.Name, key, record.S3.Object.ETag))
+	}
+	return objects, nil
+}
+
+// s3Object describes an S3 object the way a GCS event would. The generation is
+// the one the S3 store reports for the object's ETag.
+func s3Object(bucket, key, etag string) StorageObjectData {
+	e := StorageObjectData{Bucket: bucket, Name: key}
+	if etag != "" {
+		e.Generation = strconv.FormatInt(storage.ETagGeneration(etag), 10)
+	}
+	return e
+}
+
+// processS3Event serves the ProcessS3Event entry point, which runs the pipeline
+// for objects created in S3 when the function is deployed with
+// STORAGE_BACKEND=s3. Post S3 event notifications or EventBridge "Object
+// Created" events to it, e.g. from an EventBridge API destination.
+func processS3Event(w http.ResponseWriter, r *http.Request) {
+	body, ok := readEventBody(w, r)
+	if !ok {
+		return
+	}
+	objects, err := s3EventObjects(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	processEventObjects(w, r, objects)
+}
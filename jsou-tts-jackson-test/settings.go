@@ -0,0 +1,167 @@
+package pdftospeech
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// lookupSetting returns the object's custom metadata value for metaKey if present
+// (set as x-goog-meta-<metaKey> when uploading), falling back to the deployment's
+// setting. This lets a single document override deployment-wide defaults.
+func lookupSetting(metadata map[string]string, metaKey, fallback string) string {
+	if v, ok := metadata[metaKey]; ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// boolSetting returns whether the object's custom metadata value for metaKey is
+// "true", or the deployment's setting when it's absent.
+func boolSetting(metadata map[string]string, metaKey string, fallback bool) bool {
+	if v, ok := metadata[metaKey]; ok && v != "" {
+		return strings.EqualFold(v, "true")
+	}
+	return fallback
+}
+
+// floatSetting overrides dst with the object's custom metadata value for
+// metaKey, if present.
+func floatSetting(dst *float64, metadata map[string]string, metaKey string) error {
+	raw := metadata[metaKey]
+	if raw == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("metadata %s: %q is not a number", metaKey, raw)
+	}
+	*dst = v
+	return nil
+}
+
+// audioSettingsFor builds the AudioSettings for a document from the
+// configuration and the input object's custom metadata.
+func audioSettingsFor(cfg *Config, format tts.AudioFormat, metadata map[string]string) (tts.AudioSettings, error) {
+	settings := tts.AudioSettings{
+		Format:          format,
+		SpeakingRate:    cfg.SpeakingRate,
+		Pitch:           cfg.Pitch,
+		VolumeGainDb:    cfg.VolumeGainDb,
+		SampleRateHertz: cfg.SampleRateHertz,
+	}
+
+	if err := floatSetting(&settings.SpeakingRate, metadata, "tts-speaking-rate"); err != nil {
+		return settings, err
+	}
+	if err := floatSetting(&settings.Pitch, metadata, "tts-pitch"); err != nil {
+		return settings, err
+	}
+	if err := floatSetting(&settings.VolumeGainDb, metadata, "tts-volume-gain-db"); err != nil {
+		return settings, err
+	}
+	if raw := metadata["tts-sample-rate-hertz"]; raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return settings, fmt.Errorf("metadata tts-sample-rate-hertz: %q is not an integer", raw)
+		}
+		settings.SampleRateHertz = int32(v)
+	}
+
+	if raw := lookupSetting(metadata, "tts-effects-profile", cfg.EffectsProfile); raw != "" {
+		for _, id := range strings.Split(raw, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				settings.EffectsProfileIDs = append(settings.EffectsProfileIDs, id)
+			}
+		}
+	}
+
+	return settings, settings.Validate()
+}
+
+// providerConfig collects the settings of every TTS provider from the configuration.
+func (p *Pipeline) providerConfig(cfg *Config) tts.ProviderConfig {
+	return tts.ProviderConfig{
+		Google:              p.ttsClient,
+		Storage:             p.store,
+		Secrets:             p.secrets,
+		ProjectNumber:       cfg.ProjectNumber,
+		Location:            cfg.ttsLocation(),
+		PollyEngine:         cfg.PollyEngine,
+		PollyOutputBucket:   cfg.PollyOutputBucket,
+		AzureRegion:         cfg.AzureSpeechRegion,
+		AzureKeySecret:      cfg.AzureSpeechKey,
+		AzureKey:            &p.azureKey,
+		ElevenLabsModel:     cfg.ElevenLabsModel,
+		ElevenLabsKeySecret: cfg.ElevenLabsKeySecret,
+		OpenAIModel:         cfg.OpenAIModel,
+		OpenAIKeySecret:     cfg.OpenAIKeySecret,
+		PiperBinary:         cfg.PiperBinary,
+		PiperModelDir:       cfg.PiperModelDir,
+		Limits:              p.ttsLimits(cfg),
+	}
+}
+
+// ttsLimits returns the limits of the TTS API calls of a synthesizer:
+// TTS_MAX_ATTEMPTS attempts at transient errors, and TTS_QPS. With
+// TTS_MAX_CONCURRENT_JOBS, each synthesizer, made for one job, gets its own
+// limiter at the job's share of TTS_QPS; without it, they share the pipeline's.
+// All of them share the pipeline's INSTANCE_CHUNK_CONCURRENCY slots.
+func (p *Pipeline) ttsLimits(cfg *Config) tts.Limits {
+	retry := tts.DefaultRetryPolicy
+	retry.MaxAttempts = cfg.MaxAttempts
+	limiter := p.ttsLimiter
+	if cfg.MaxConcurrentJobs > 0 {
+		limiter = tts.NewRateLimiter(ttsRequestRate(cfg.QPS, cfg.MaxConcurrentJobs))
+	}
+	return tts.Limits{Retry: retry, Limiter: limiter, ChunkSlots: p.chunkSlots}
+}
+
+// customVoice applies the Custom Voice settings to voice, the narrator selected by
+// name and language. CUSTOM_VOICE_MODEL names a trained Custom Voice model and
+// CUSTOM_VOICE_USAGE its reported usage (realtime or offline);
+// VOICE_CLONING_KEY_SECRET names the Secret Manager secret holding the key of an
+// instant custom voice. The voice keeps its language, which must be the one the
+// custom voice was created for.
+func (p *Pipeline) customVoice(ctx context.Context, cfg *Config, voice tts.Voice) (tts.Voice, error) {
+	voice.CustomModel = cfg.CustomVoiceModel
+	voice.CustomUsage = cfg.CustomVoiceUsage
+	if cfg.CloningKeySecret != "" {
+		key, err := p.secrets.Access(ctx, cfg.CloningKeySecret)
+		if err != nil {
+			return voice, fmt.Errorf("failed to read the voice cloning key: %w", err)
+		}
+		voice.CloningKey = strings.TrimSpace(key)
+	}
+	return voice, nil
+}
+
+// defaultInputPrefix is where input PDFs are uploaded when neither INPUT_PREFIX
+// nor INPUT_PATTERNS is set.
+const defaultInputPrefix = "pdf-input/"
+
+// defaultOutputPrefix is where audio goes when OUTPUT_PREFIX isn't set.
+const defaultOutputPrefix = "mp3-output/"
+
+// checkLongAudioEncryption checks, when KMS_KEY_NAME sets key, that the output
+// bucket encrypts new objects with that key by default. Long Audio Synthesis
+// writes its output itself, so the key can't be set per object like uploads.
+func (p *Pipeline) checkLongAudioEncryption(ctx context.Context, bucket, key string) error {
+	if key == "" {
+		return nil
+	}
+	bucketKey, err := p.store.BucketKMSKey(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check the encryption of output bucket %s: %w", bucket, err)
+	}
+	if bucketKey == "" {
+		bucketKey = "Google-managed encryption"
+	}
+	if bucketKey != key {
+		return fmt.Errorf("KMS_KEY_NAME is set, but Long Audio Synthesis output in bucket %s would use %s; set the bucket's default key to %s", bucket, bucketKey, key)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package pdftospeech
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// shutdownGrace is how long the invocations under way are given to wind down
+// once the instance is told to shut down. Cloud Run, and so Cloud Functions,
+// kill an instance 10 seconds after sending it SIGTERM.
+const shutdownGrace = 8 * time.Second
+
+// errShuttingDown is the failure of work stopped, or refused, because the
+// instance is shutting down. It's transient: the event is redelivered to
+// another instance, which resumes from the checkpoint.
+var errShuttingDown = errors.New("the instance is shutting down")
+
+// The shutdown state of the instance. shutdown is closed on SIGTERM once
+// WatchShutdown has been called; running counts the invocations under way,
+// which startWork registers.
+var (
+	shutdownMu    sync.Mutex
+	shutdown      = make(chan struct{})
+	running       int
+	watchShutdown sync.Once
+)
+
+// WatchShutdown makes SIGTERM, which the platform sends before scaling an
+// instance down, wind the process's pipelines down before it takes effect. The
+// function's entry points and the commands call it; other programs embedding
+// the package keep their own signal handling unless they do.
+func WatchShutdown() {
+	watchShutdown.Do(func() { go awaitShutdown() })
+}
+
+// awaitShutdown waits for SIGTERM. It then closes shutdown, so the invocations
+// under way stop starting chunks and polling operations, and gives them
+// shutdownGrace to return and save their state before the signal takes its
+// default effect.
+func awaitShutdown() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	<-signals
+
+	shutdownMu.Lock()
+	close(shutdown)
+	n := running
+	shutdownMu.Unlock()
+	if n > 0 {
+		log.Printf("Received SIGTERM. Stopping %d invocation(s) under way.", n)
+	}
+	for deadline := time.Now().Add(shutdownGrace); n > 0 && time.Now().Before(deadline); {
+		time.Sleep(100 * time.Millisecond)
+		shutdownMu.Lock()
+		n = running
+		shutdownMu.Unlock()
+	}
+	if n > 0 {
+		log.Printf("Warning: %d invocation(s) still running after %v. Exiting anyway; their events are redelivered.", n, shutdownGrace)
+	}
+	signal.Reset(syscall.SIGTERM)
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(syscall.SIGTERM)
+	}
+}
+
+// shuttingDown reports whether the instance has been told to shut down.
+func shuttingDown() bool {
+	select {
+	case <-shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// startWork registers an invocation, so the instance waits for it when it shuts
+// down, and returns its context, which makes chunked syntheses stop starting
+// chunks once the shutdown begins. The returned func must be called when the
+// invocation returns. Once the shutdown has begun, new work is refused with a
+// transient error, so its event is redelivered elsewhere.
+func startWork(ctx context.Context) (context.Context, func(), error) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	if shuttingDown() {
+		return nil, nil, transient(errShuttingDown)
+	}
+	running++
+	done := func() {
+		shutdownMu.Lock()
+		running--
+		shutdownMu.Unlock()
+	}
+	return tts.WithStop(ctx, shutdown), done, nil
+}
+
+// untilShutdown returns a copy of ctx that is cancelled, with errShuttingDown
+// as its cause, once the instance is told to shut down, for waits that should
+// stop then rather than be killed midway.
+func untilShutdown(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-shutdown:
+			cancel(errShuttingDown)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
@@ -0,0 +1,132 @@
+package pdftospeech
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// synthesisPart is one long audio operation of a split document, or one chapter
+// of a book fanned out to SynthesizeChapter, which has no operation.
+type synthesisPart struct {
+	Operation string `json:"operation"`
+	OutputURI string `json:"output_uri"`
+	Done      bool   `json:"done,omitempty"`
+}
+
+// partURI returns where part i of the output at outputURI is written until it's
+// joined into the output, so the output folder never shows partial files, e.g.
+// "gs://bucket/tmp/mp3-output/book.wav/part-001.wav".
+func partURI(outputURI string, i int, format tts.AudioFormat) (string, error) {
+	bucket, object, err := storage.ParseGCSURI(outputURI)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("gs://%s/%spart-%03d%s", bucket, tmpObjectPrefix(object), i+1, format.Extension), nil
+}
+
+// waitForSynthesis waits for the operation of p, or for each of its parts in
+// turn. Finished parts are marked done, so a record handed off to the finalizer
+// after a timeout doesn't wait for them again.
+func waitForSynthesis(ctx context.Context, synth tts.Synthesizer, p *pendingSynthesis) error {
+	if len(p.Parts) == 0 {
+		return tts.WaitForOperation(ctx, synth, p.Operation)
+	}
+	// Each part's progress is reported as a share of the whole document's.
+	report := tts.ProgressFromContext(ctx)
+	for i := range p.Parts {
+		part := &p.Parts[i]
+		if part.Done {
+			continue
+		}
+		partCtx := ctx
+		if report != nil {
+			partCtx = tts.WithProgress(ctx, func(pr tts.Progress) {
+				report(tts.Progress{Percent: (100*float64(i) + pr.Percent) / float64(len(p.Parts))})
+			})
+		}
+		if err := tts.WaitForOperation(partCtx, synth, part.Operation); err != nil {
+			return fmt.Errorf("part %d of %d: %w", i+1, len(p.Parts), err)
+		}
+		part.Done = true
+	}
+	return nil
+}
+
+// checkSynthesis polls the operation of p once, like Synthesizer.CheckOperation.
+// For a split document it checks every unfinished part, reports the average
+// progress, and joins the parts into the output once all of them are done.
+// Chapters count as done once their audio is written.
+func (p *Pipeline) checkSynthesis(ctx context.Context, synth tts.Synthesizer, pending *pendingSynthesis) (done bool, progress float64, err error) {
+	if len(pending.Parts) == 0 {
+		return synth.CheckOperation(ctx, pending.Operation)
+	}
+	done = true
+	for i := range pending.Parts {
+		part := &pending.Parts[i]
+		if part.Done {
+			progress += 100
+			continue
+		}
+		var partDone bool
+		var partProgress float64
+		var err error
+		if part.Operation == "" {
+			partDone, err = p.chapterDone(ctx, *part)
+		} else {
+			partDone, partProgress, err = synth.CheckOperation(ctx, part.Operation)
+		}
+		if err != nil {
+			return partDone, 0, fmt.Errorf("part %d of %d: %w", i+1, len(pending.Parts), err)
+		}
+		part.Done = partDone
+		done = done && partDone
+		if partDone {
+			partProgress = 100
+		}
+		progress += partProgress
+	}
+	progress /= float64(len(pending.Parts))
+	if !done {
+		return false, progress, nil
+	}
+	if err := p.joinParts(ctx, *pending); err != nil {
+		return false, progress, err // Retried on the next run; the parts are still there.
+	}
+	return true, 100, nil
+}
+
+// joinParts writes the audio of all parts of p to its output and removes the parts.
+func (p *Pipeline) joinParts(ctx context.Context, pending pendingSynthesis) error {
+	format, err := tts.ParseAudioFormat(pending.Format)
+	if err != nil {
+		return err
+	}
+	uris := make([]string, len(pending.Parts))
+	for i, part := range pending.Parts {
+		uris[i] = part.OutputURI
+	}
+	if err := tts.ConcatAudioObjects(ctx, p.store, format, uris, pending.OutputURI); err != nil {
+		return fmt.Errorf("failed to join %d parts of %s: %w", len(pending.Parts), pending.InputObject, err)
+	}
+	log.Printf("Joined %d long audio parts into %s.", len(pending.Parts), pending.OutputURI)
+	p.deleteParts(ctx, pending.Parts)
+	return nil
+}
+
+// deleteParts removes the part outputs of a split document. Failures are only
+// logged; leftover parts are just wasted storage.
+func (p *Pipeline) deleteParts(ctx context.Context, parts []synthesisPart) {
+	for _, part := range parts {
+		bucket, object, err := storage.ParseGCSURI(part.OutputURI)
+		if err == nil {
+			err = p.store.DeleteObject(ctx, bucket, object)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to remove long audio part %s: %v", part.OutputURI, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/pdf-to-text/pdfprocessor"
+	v2 "github.com/cloudevents/sdk-go/v2"
+)
+
+// extractedTextPrefix holds the text the extraction stage of a staged pipeline
+// (EXTRACTED_TEXT_TOPIC) hands to the synthesis stage, next to pdf-input/.
+const extractedTextPrefix = "tts-text/"
+
+// extractedDocument is the text of a document, written by the extraction stage
+// for the synthesis stage to pick up, along with the event that triggered it.
+type extractedDocument struct {
+	Object            StorageObjectData `json:"object"`
+	Text              string            `json:"text"`
+	Pages             int               `json:"pages"`
+	FailedPages       []int             `json:"failed_pages,omitempty"`
+	ExtractionSeconds float64           `json:"extraction_seconds"`
+	ReceivedAt        time.Time         `json:"received_at"`
+}
+
+// extractedTextMessage is the Pub/Sub message published to EXTRACTED_TEXT_TOPIC
+// for each extracted document, naming the object holding its text.
+type extractedTextMessage struct {
+	Bucket string `json:"bucket"`
+	Object string `json:"object"`
+}
+
+// extractedTextName returns the name of the object holding the extracted text
+// of a version of an input.
+func extractedTextName(object, generation string) string {
+	return fmt.Sprintf("%s%s.%s.json", extractedTextPrefix, object, generation)
+}
+
+// handOffText writes the extracted text of a document to tts-text/ and
+// publishes its name to EXTRACTED_TEXT_TOPIC, ending the extraction stage.
+func (p *Pipeline) handOffText(ctx context.Context, e StorageObjectData, doc extractedDocument) error {
+	if p.textPublisher == nil {
+		return fmt.Errorf("EXTRACTED_TEXT_TOPIC is set, but its publisher wasn't created")
+	}
+	doc.Object = e
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode the extracted text of %s: %w", e.Name, err)
+	}
+	name := extractedTextName(e.Name, e.Generation)
+	if err := p.store.UploadFile(ctx, e.Bucket, name, body, "application/json"); err != nil {
+		return fmt.Errorf("failed to write the extracted text of %s: %w", e.Name, err)
+	}
+	msg, err := json.Marshal(extractedTextMessage{Bucket: e.Bucket, Object: name})
+	if err != nil {
+		return fmt.Errorf("failed to encode the hand-off of %s: %w", e.Name, err)
+	}
+	if err := p.textPublisher.PublishData(ctx, msg, nil); err != nil {
+		return fmt.Errorf("failed to hand off the text of %s for synthesis: %w", e.Name, err)
+	}
+	log.Printf("Handed off %d characters of %s for synthesis as gs://%s/%s.", len(doc.Text), e.Name, e.Bucket, name)
+	return nil
+}
+
+// synthesizeExtractedText serves the SynthesizeExtractedText entry point, the
+// synthesis stage of a staged pipeline: it reads the text named in a message
+// from EXTRACTED_TEXT_TOPIC and runs the rest of the pipeline on it, deleting
+// the text once the document is through. Malformed messages and text that's
+// already gone are logged and acknowledged; a run that failed transiently
+// returns its error, so the subscription redelivers the message.
+func synthesizeExtractedText(ctx context.Context, e v2.Event) error {
+	var msg pubSubMessage
+	if err := e.DataAs(&msg); err != nil {
+		log.Printf("Error: Invalid Pub/Sub event %s: %v. Dropping it.", e.ID(), err)
+		return nil
+	}
+	var req extractedTextMessage
+	if err := json.Unmarshal(msg.Message.Data, &req); err != nil || req.Bucket == "" || req.Object == "" {
+		log.Printf("Error: Pub/Sub message %s doesn't name extracted text with a bucket and an object (%v). Dropping it.", msg.Message.MessageID, err)
+		return nil
+	}
+	p, err := functionPipeline()
+	if err != nil {
+		return err
+	}
+	cfg := p.cfg
+
+	if _, exists, err := p.store.ObjectMetadata(ctx, req.Bucket, req.Object); err != nil {
+		return fmt.Errorf("failed to look up extracted text %s in bucket %s: %w", req.Object, req.Bucket, err)
+	} else if !exists {
+		log.Printf("Warning: Extracted text %s is gone from bucket %s, so it was already synthesized. Dropping the message.", req.Object, req.Bucket)
+		return nil
+	}
+	data, err := p.store.ReadObject(ctx, req.Bucket, req.Object)
+	if err != nil {
+		return fmt.Errorf("failed to read extracted text %s: %w", req.Object, err)
+	}
+	var doc extractedDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		log.Printf("Error: Extracted text %s in bucket %s is invalid: %v. Dropping it.", req.Object, req.Bucket, err)
+		return nil
+	}
+
+	obj := doc.Object
+	obj.extracted = &doc
+	log.Printf("Synthesizing the extracted text of %s from %s.", obj.Name, req.Object)
+	if err := retryableOnly(obj.Name, p.processPDFToSpeechHandler(ctx, cfg, obj)); err != nil {
+		return err
+	}
+	if err := p.store.DeleteObject(ctx, req.Bucket, req.Object); err != nil {
+		log.Printf("Warning: Failed to delete extracted text %s: %v", req.Object, err)
+	}
+	return nil
+}
+
+// extraction returns the document's extraction as the extraction stage
+// recorded it.
+func (d *extractedDocument) extraction() pdfprocessor.Extraction {
+	return pdfprocessor.Extraction{Text: d.Text, Pages: d.Pages, FailedPages: d.FailedPages}
+}
@@ -0,0 +1,54 @@
+package pdftospeech
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// streamingOutput publishes the audio of a document segment by segment while it's
+// synthesized: each segment is uploaded as soon as it and all earlier ones are
+// done, and an M3U playlist next to the output lists the segments so far. For
+// "mp3-output/book.mp3", the segments are "mp3-output/book/part-0001.mp3", ... and
+// the playlist is "mp3-output/book.m3u".
+type streamingOutput struct {
+	store          storage.Storage
+	bucket         string
+	playlistObject string
+	segmentPrefix  string
+	format         tts.AudioFormat
+	playlist       strings.Builder
+}
+
+// newStreamingOutput prepares the segments and playlist of outputObjectName in
+// store.
+func newStreamingOutput(store storage.Storage, bucket, outputObjectName string, format tts.AudioFormat) *streamingOutput {
+	base := strings.TrimSuffix(outputObjectName, path.Ext(outputObjectName))
+	s := &streamingOutput{store: store, bucket: bucket, playlistObject: base + ".m3u", segmentPrefix: base + "/", format: format}
+	s.playlist.WriteString("#EXTM3U\n")
+	return s
+}
+
+// add uploads segment i and rewrites the playlist to include it. Segments must
+// be added in order, as tts.SynthesizeSegmentsInOrder does.
+func (s *streamingOutput) add(ctx context.Context, i int, audio []byte) error {
+	name := fmt.Sprintf("part-%04d%s", i+1, s.format.Extension)
+	if err := s.store.UploadFile(ctx, s.bucket, s.segmentPrefix+name, audio, s.format.ContentType); err != nil {
+		return err
+	}
+	seconds := -1 // Unknown length, as M3U allows.
+	if d, err := tts.AudioDuration(s.format, audio); err == nil {
+		seconds = int(d.Seconds() + 0.5)
+	}
+	fmt.Fprintf(&s.playlist, "#EXTINF:%d,Part %d\n%s%s\n", seconds, i+1, path.Base(s.segmentPrefix)+"/", name)
+	if err := s.store.UploadFile(ctx, s.bucket, s.playlistObject, []byte(s.playlist.String()), "audio/x-mpegurl"); err != nil {
+		return err
+	}
+	log.Printf("Published part %d of gs://%s/%s.", i+1, s.bucket, s.playlistObject)
+	return nil
+}
@@ -0,0 +1,241 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultStuckAfter is how long an input or a job may go without an outcome
+// before the sweeper requeues it when SWEEP_STUCK_AFTER isn't set. Like
+// staleEventClaimAge, it must exceed the function timeout.
+const defaultStuckAfter = 2 * time.Hour
+
+// maxFunctionTimeout is the longest timeout the function can be deployed with.
+// SWEEP_STUCK_AFTER must be longer, so the sweeper doesn't take an input an
+// invocation is still working on for a stuck one.
+const maxFunctionTimeout = time.Hour
+
+// maxJobRequeues is how many times the sweeper puts a job stuck running back in
+// the queue before failing it, so a document that crashes every invocation
+// running it doesn't go round forever.
+const maxJobRequeues = 2
+
+// sweepReport is what a run of SweepMissedInputs found and did.
+type sweepReport struct {
+	Missed        int // Inputs no invocation ever claimed.
+	Stuck         int // Inputs whose claim was abandoned.
+	Queued        int
+	UpToDate      int
+	InProgress    int
+	Claimed       int // Inputs an invocation is still processing.
+	AlreadyQueued int
+	AlreadyFailed int
+	TooRecent     int
+	JobsRequeued  int
+	JobsFailed    int
+	JobsCancelled int
+}
+
+// sweepMissedInputs serves the SweepMissedInputs entry point, which makes the
+// pipeline heal itself from dropped events and crashed invocations. The
+// on-demand jobs that stayed queued, running or cancelling for longer than
+// SWEEP_STUCK_AFTER are fixed first: a queued job is published to JOBS_TOPIC
+// again, a running one is queued again up to maxJobRequeues times and then
+// failed, and a cancelling one is cancelled. Then the PDFs and audiobook
+// manifests in the input folders of BASE_GCS_BUCKET older than
+// SWEEP_STUCK_AFTER, recognized as the handler does, are compared with the
+// outputs, pending long audio, failure reports, event claims and jobs, and those no
+// invocation has finished or is still working on are queued as jobs, as
+// ReprocessInputs does. Since the queued job is then the input's latest,
+// each version of an input is queued once; if that job gets stuck, the first
+// part takes care of it. Failures with one input or job are logged and the
+// rest are still swept.
+func (p *Pipeline) sweepMissedInputs(ctx context.Context, cfg *Config) error {
+	bucket := cfg.BaseBucket
+	now := time.Now()
+	var report sweepReport
+
+	if err := p.sweepJobs(ctx, now.Add(-cfg.StuckAfter), &report); err != nil {
+		return err
+	}
+	outputs, err := p.outputGenerations(ctx, cfg, bucket)
+	if err != nil {
+		return err
+	}
+	running, err := p.pendingGenerations(ctx, bucket)
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range cfg.inputFolders() {
+		objects, err := p.store.ListObjectsWithPrefix(ctx, bucket, folder)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", folder, err)
+		}
+		for _, obj := range objects {
+			if !isPDFInput(obj.Name, obj.ContentType) && !isAudiobookManifest(obj.Name) || !cfg.isInput(obj.Name) {
+				continue
+			}
+			generation := strconv.FormatInt(obj.Generation, 10)
+			uri := fmt.Sprintf("gs://%s/%s", bucket, obj.Name)
+			switch {
+			case now.Sub(obj.Created) < cfg.StuckAfter:
+				// Its event may still be on its way or being processed.
+				report.TooRecent++
+				continue
+			case outputs[obj.Name] == generation:
+				report.UpToDate++
+				continue
+			case running[obj.Name] == generation:
+				report.InProgress++
+				continue
+			}
+			job, err := p.jobs.LatestJob(ctx, uri)
+			if err != nil {
+				log.Printf("Warning: Skipping %s: %v", uri, err)
+				continue
+			}
+			if job != nil && !job.CreatedAt.Before(obj.Created) {
+				report.AlreadyQueued++
+				continue
+			}
+			failed, err := p.failedGeneration(ctx, cfg, bucket, obj.Name, generation)
+			if err != nil {
+				log.Printf("Warning: Skipping %s: %v", uri, err)
+				continue
+			}
+			if failed {
+				report.AlreadyFailed++
+				continue
+			}
+			claim, err := p.readEventClaim(ctx, bucket, obj.Name, generation)
+			if err != nil {
+				log.Printf("Warning: Skipping %s: %v", uri, err)
+				continue
+			}
+			switch {
+			case claim == nil:
+				report.Missed++
+			case claim.Done:
+				// Finished without an output to show for it, e.g. filtered or
+				// skipped as unchanged.
+				report.UpToDate++
+				continue
+			case now.Sub(claim.ClaimedAt) < staleEventClaimAge:
+				report.Claimed++
+				continue
+			default:
+				report.Stuck++
+			}
+			queued, _, err := p.queueJob(ctx, bucket, jobRequest{URI: uri, contentType: obj.ContentType})
+			if err != nil {
+				log.Printf("Warning: Failed to queue %s: %v", uri, err)
+				continue
+			}
+			log.Printf("Queued job %s for %s, which had no outcome since %v.", queued.ID, uri, obj.Created)
+			report.Queued++
+		}
+	}
+	log.Printf("Swept %s: %d missed, %d stuck, %d queued, %d up to date, %d in progress, %d claimed, %d already queued, %d failed, %d too recent; jobs: %d requeued, %d failed, %d cancelled.",
+		strings.Join(cfg.inputFolders(), ", "), report.Missed, report.Stuck, report.Queued, report.UpToDate, report.InProgress,
+		report.Claimed, report.AlreadyQueued, report.AlreadyFailed, report.TooRecent, report.JobsRequeued, report.JobsFailed, report.JobsCancelled)
+	return nil
+}
+
+// sweepJobs fixes the on-demand jobs that were last updated before cutoff
+// without ending.
+func (p *Pipeline) sweepJobs(ctx context.Context, cutoff time.Time, report *sweepReport) error {
+	jobs, err := p.jobs.JobsUpdatedBefore(ctx, []string{jobQueued, jobRunning, jobCancelling}, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to list stuck jobs: %w", err)
+	}
+	for _, job := range jobs {
+		if err := p.unstickJob(ctx, job, report); err != nil {
+			log.Printf("Warning: Failed to requeue stuck job %s: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// unstickJob fixes a job whose record hasn't changed for too long, unless it
+// changed since it was read.
+func (p *Pipeline) unstickJob(ctx context.Context, job onDemandJob, report *sweepReport) error {
+	since, was := job.UpdatedAt, job.Status
+	var count *int
+	saved, err := p.jobs.UpdateJob(ctx, job.ID, func(job *onDemandJob) (bool, error) {
+		count = nil
+		if !job.UpdatedAt.Equal(since) {
+			return false, nil // It moved on in the meantime.
+		}
+		switch job.Status {
+		case jobQueued:
+			// Its message was lost; it's published again below.
+			count = &report.JobsRequeued
+		case jobRunning:
+			if job.Requeues >= maxJobRequeues {
+				job.Status = jobFailed
+				job.Error = fmt.Sprintf("stuck running since %v after %d attempts", since.Format(time.RFC3339), job.Requeues+1)
+				count = &report.JobsFailed
+				break
+			}
+			// The invocation running it crashed or timed out.
+			job.Status = jobQueued
+			job.Requeues++
+			count = &report.JobsRequeued
+		case jobCancelling:
+			// The invocation running it is gone, so nothing else will finish it.
+			job.Status = jobCancelled
+			count = &report.JobsCancelled
+		}
+		return count != nil, nil
+	})
+	if err != nil || count == nil {
+		return err
+	}
+	if saved.Status == jobQueued {
+		if err := p.publishJob(ctx, saved.ID); err != nil {
+			return err
+		}
+	}
+	*count++
+	log.Printf("Job %s for %s was %s since %v; now %s.", saved.ID, saved.Input, was, since, saved.Status)
+	return nil
+}
+
+// failedGeneration reports whether the failure report of the input in bucket
+// is about its generation, so the failure was recorded rather than lost.
+func (p *Pipeline) failedGeneration(ctx context.Context, cfg *Config, bucket, input, generation string) (bool, error) {
+	folder, _ := cfg.inputFolder(input)
+	data, _, err := p.store.ReadObjectGeneration(ctx, bucket, failureObjectName(folder, input))
+	if err != nil {
+		return false, fmt.Errorf("failed to read its failure report: %w", err)
+	}
+	var report failureReport
+	if data == nil || json.Unmarshal(data, &report) != nil {
+		return false, nil
+	}
+	return report.Generation == generation, nil
+}
+
+// readEventClaim returns the event claim of a version of an input in bucket,
+// or nil if it has none.
+func (p *Pipeline) readEventClaim(ctx context.Context, bucket, input, generation string) (*eventClaimRecord, error) {
+	name := eventClaimName(input, generation)
+	data, _, err := p.store.ReadObjectGeneration(ctx, bucket, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event claim %s: %w", name, err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var record eventClaimRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, nil // Taken over by the next delivery, like a missing one.
+	}
+	return &record, nil
+}
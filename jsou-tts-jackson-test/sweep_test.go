@@ -0,0 +1,198 @@
+package pdftospeech
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSweepJobs(t *testing.T) {
+	stale := time.Now().Add(-3 * time.Hour).UTC()
+	tests := []struct {
+		name          string
+		job           onDemandJob
+		wantStatus    string
+		wantRequeues  int
+		wantPublished bool
+		wantUpdated   bool // Whether the record is written.
+		wantReport    sweepReport
+	}{
+		{
+			name:          "queued job is published again",
+			job:           onDemandJob{Status: jobQueued, UpdatedAt: stale},
+			wantStatus:    jobQueued,
+			wantPublished: true,
+			wantUpdated:   true,
+			wantReport:    sweepReport{JobsRequeued: 1},
+		},
+		{
+			name:          "running job is requeued",
+			job:           onDemandJob{Status: jobRunning, Requeues: 1, UpdatedAt: stale},
+			wantStatus:    jobQueued,
+			wantRequeues:  2,
+			wantPublished: true,
+			wantUpdated:   true,
+			wantReport:    sweepReport{JobsRequeued: 1},
+		},
+		{
+			name:         "running job out of requeues fails",
+			job:          onDemandJob{Status: jobRunning, Requeues: maxJobRequeues, UpdatedAt: stale},
+			wantStatus:   jobFailed,
+			wantRequeues: maxJobRequeues,
+			wantUpdated:  true,
+			wantReport:   sweepReport{JobsFailed: 1},
+		},
+		{
+			name:        "cancelling job is cancelled",
+			job:         onDemandJob{Status: jobCancelling, UpdatedAt: stale},
+			wantStatus:  jobCancelled,
+			wantUpdated: true,
+			wantReport:  sweepReport{JobsCancelled: 1},
+		},
+		{
+			name:       "job that ended is left alone",
+			job:        onDemandJob{Status: jobDone, UpdatedAt: stale},
+			wantStatus: jobDone,
+		},
+		{
+			name:       "recently updated job is left alone",
+			job:        onDemandJob{Status: jobRunning, UpdatedAt: time.Now().UTC()},
+			wantStatus: jobRunning,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			ctx := context.Background()
+			job := tt.job
+			job.ID, job.Input, job.CreatedAt = testJobID, "gs://library/pdf-input/book.pdf", stale
+			if err := p.jobs.CreateJob(ctx, job); err != nil {
+				t.Fatal(err)
+			}
+
+			var report sweepReport
+			if err := p.sweepJobs(ctx, time.Now().Add(-time.Hour), &report); err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.jobs.GetJob(ctx, job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus || got.Requeues != tt.wantRequeues {
+				t.Errorf("job is %s after %d requeues, want %s after %d", got.Status, got.Requeues, tt.wantStatus, tt.wantRequeues)
+			}
+			if report != tt.wantReport {
+				t.Errorf("report %+v, want %+v", report, tt.wantReport)
+			}
+			if updated := !got.UpdatedAt.Equal(job.UpdatedAt); updated != tt.wantUpdated {
+				t.Errorf("record updated: %v, want %v", updated, tt.wantUpdated)
+			}
+			if published := len(publishedJobs(t, p)) > 0; published != tt.wantPublished {
+				t.Errorf("job published: %v, want %v", published, tt.wantPublished)
+			}
+		})
+	}
+}
+
+func TestSweepMissedInputs(t *testing.T) {
+	const input = "pdf-input/book.pdf"
+	const uri = "gs://library/" + input
+	created := time.Now().Add(-3 * time.Hour)
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, p *Pipeline, generation string)
+		wantQueued int // Jobs queued for the input.
+	}{
+		{
+			name:       "never claimed",
+			setup:      func(t *testing.T, p *Pipeline, generation string) {},
+			wantQueued: 1,
+		},
+		{
+			name: "claim taken over after a crash",
+			setup: func(t *testing.T, p *Pipeline, generation string) {
+				putJSON(t, p, eventClaimName(input, generation), eventClaimRecord{ClaimedAt: created})
+			},
+			wantQueued: 1,
+		},
+		{
+			name: "claimed and still processing",
+			setup: func(t *testing.T, p *Pipeline, generation string) {
+				putJSON(t, p, eventClaimName(input, generation), eventClaimRecord{ClaimedAt: time.Now().UTC()})
+			},
+		},
+		{
+			name: "claim done",
+			setup: func(t *testing.T, p *Pipeline, generation string) {
+				putJSON(t, p, eventClaimName(input, generation), eventClaimRecord{ClaimedAt: created, Done: true})
+			},
+		},
+		{
+			name: "already queued",
+			setup: func(t *testing.T, p *Pipeline, generation string) {
+				now := time.Now().UTC()
+				if err := p.jobs.CreateJob(context.Background(), onDemandJob{ID: testJobID, Input: uri, Status: jobQueued, CreatedAt: now, UpdatedAt: now}); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "queued before this version was uploaded",
+			setup: func(t *testing.T, p *Pipeline, generation string) {
+				before := created.Add(-time.Hour).UTC()
+				if err := p.jobs.CreateJob(context.Background(), onDemandJob{ID: testJobID, Input: uri, Status: jobDone, CreatedAt: before, UpdatedAt: before}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantQueued: 1,
+		},
+		{
+			name: "failure recorded",
+			setup: func(t *testing.T, p *Pipeline, generation string) {
+				putJSON(t, p, failureObjectName("pdf-input/", input), failureReport{Input: input, Generation: generation})
+			},
+		},
+		{
+			name: "too recent",
+			setup: func(t *testing.T, p *Pipeline, generation string) {
+				backdate(t, p, input, time.Now())
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPipeline(t)
+			ctx := context.Background()
+			if err := p.store.UploadFile(ctx, testBucket, input, []byte("%PDF-1.7"), pdfContentType); err != nil {
+				t.Fatal(err)
+			}
+			backdate(t, p, input, created)
+			_, generation, err := p.store.ReadObjectGeneration(ctx, testBucket, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(t, p, strconv.FormatInt(generation, 10))
+			before := countJobs(t, p, uri)
+
+			if err := p.sweepMissedInputs(ctx, p.cfg); err != nil {
+				t.Fatal(err)
+			}
+			if queued := countJobs(t, p, uri) - before; queued != tt.wantQueued {
+				t.Errorf("%d jobs queued, want %d", queued, tt.wantQueued)
+			}
+		})
+	}
+}
+
+// countJobs returns the number of jobs for the input at uri.
+func countJobs(t *testing.T, p *Pipeline, uri string) int {
+	t.Helper()
+	n := 0
+	for _, job := range p.jobs.(*fakeJobStore).jobs {
+		if job.Input == uri {
+			n++
+		}
+	}
+	return n
+}
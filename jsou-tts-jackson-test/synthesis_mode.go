@@ -0,0 +1,58 @@
+package pdftospeech
+
+import (
+	"fmt"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// synthesisMode selects how a document's audio is produced.
+type synthesisMode string
+
+const (
+	// modeAuto picks chunked synthesis for documents that fit in a single standard
+	// request or whose voice or format can't use long audio, and Long Audio Synthesis otherwise.
+	modeAuto synthesisMode = "auto"
+	// modeChunked synthesizes chunks in parallel with the standard API and concatenates them.
+	modeChunked synthesisMode = "chunked"
+	// modeLongAudio runs one Long Audio Synthesis operation writing straight to GCS.
+	modeLongAudio synthesisMode = "long-audio"
+	// modeStreaming synthesizes chunks like modeChunked but publishes each one, in
+	// order, as soon as it's ready, so listening can start before the document is done.
+	modeStreaming synthesisMode = "streaming"
+	// modeChapters is how a book fanned out over CHAPTER_TOPIC was synthesized:
+	// each chapter by its own invocation. It's not a SYNTHESIS_MODE setting.
+	modeChapters synthesisMode = "chapters"
+)
+
+// longAudioWritable reports whether synth's long audio operations can write to
+// store. Google's Long Audio Synthesis writes straight to Cloud Storage, while
+// the other providers upload the result through the store.
+func longAudioWritable(synth tts.Synthesizer, store storage.Storage) bool {
+	_, gcs := store.(*storage.Client)
+	return gcs || synth.Name() != tts.ProviderGoogle
+}
+
+// synthesisModeFor resolves the SYNTHESIS_MODE setting into a concrete mode for a
+// document split into numChunks standard-sized chunks.
+func synthesisModeFor(setting string, numChunks int, format tts.AudioFormat, capabilities tts.Capabilities) (synthesisMode, error) {
+	mode := synthesisMode(strings.ToLower(strings.TrimSpace(setting)))
+	switch mode {
+	case "", modeAuto:
+		if numChunks == 1 || !capabilities.SupportsLongAudioFormat(format) || !capabilities.LongAudio {
+			return modeChunked, nil
+		}
+		return modeLongAudio, nil
+	case modeLongAudio:
+		if !capabilities.LongAudio {
+			return "", fmt.Errorf("SYNTHESIS_MODE=long-audio is not supported by %s voices; use auto or chunked", capabilities.Family)
+		}
+		return mode, nil
+	case modeChunked, modeStreaming:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid SYNTHESIS_MODE %q (want auto, chunked, streaming or long-audio)", setting)
+	}
+}
@@ -0,0 +1,57 @@
+package pdftospeech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/ssml"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// sentenceTimepoint is one entry of the timepoints JSON file.
+type sentenceTimepoint struct {
+	Index        int     `json:"index"`
+	Text         string  `json:"text"`
+	StartSeconds float64 `json:"start_seconds"`
+}
+
+// timepointsEnabled reports whether sentence timestamps were requested for a
+// document, via the tts-timepoints object metadata or the TIMEPOINTS setting.
+func timepointsEnabled(cfg *Config, metadata map[string]string) bool {
+	return boolSetting(metadata, "tts-timepoints", cfg.Timepoints)
+}
+
+// timepointsObjectName returns the name of the timepoints file written next to
+// an audio object, e.g. "mp3-output/book.timepoints.json" for "mp3-output/book.mp3".
+func timepointsObjectName(audioObjectName string) string {
+	return strings.TrimSuffix(audioObjectName, path.Ext(audioObjectName)) + ".timepoints.json"
+}
+
+// writeTimepoints uploads a JSON file listing every marked sentence with the
+// time, in seconds from the start of the audio, at which it is spoken.
+func (p *Pipeline) writeTimepoints(ctx context.Context, bucketName, objectName string, marks *ssml.Marks, timepoints []tts.Timepoint) error {
+	offsets := make(map[string]float64, len(timepoints))
+	for _, tp := range timepoints {
+		offsets[tp.MarkName] = tp.Offset.Seconds()
+	}
+
+	entries := make([]sentenceTimepoint, 0, len(marks.Sentences))
+	for i, sentence := range marks.Sentences {
+		start, ok := offsets[ssml.MarkName(i)]
+		if !ok {
+			continue // The API skips marks it couldn't place; leave them out rather than guess.
+		}
+		entries = append(entries, sentenceTimepoint{Index: i, Text: sentence, StartSeconds: start})
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Sentences []sentenceTimepoint `json:"sentences"`
+	}{entries}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode timepoints: %w", err)
+	}
+	return p.store.UploadFile(ctx, bucketName, objectName, data, "application/json")
+}
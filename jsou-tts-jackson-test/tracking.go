@@ -0,0 +1,64 @@
+package pdftospeech
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"MODULE_NAME/jsou-tts/internal/jobtrack"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// trackJob records that the given version of an input reached a state, in the
+// JOBS_COLLECTION Firestore collection if it's set: in the document of the
+// on-demand job processing it if job isn't "", or else the input's own. A
+// failure is only logged: the document is processed either way.
+func (p *Pipeline) trackJob(ctx context.Context, bucket, object, generation, job string, r jobtrack.Record) {
+	if p.tracker == nil {
+		return
+	}
+	r.Input = fmt.Sprintf("gs://%s/%s", bucket, object)
+	r.Generation = generation
+	r.Job = job
+	if err := p.tracker.Record(ctx, r); err != nil {
+		log.Printf("Warning: Failed to record job state %s for %s: %v", r.State, r.Input, err)
+	}
+}
+
+// progressInterval is the least time between two progress updates of a job,
+// so documents with thousands of pages or chunks don't cost a write each.
+const progressInterval = 15 * time.Second
+
+// progressReporter returns a func recording the progress of the given version
+// of an input in JOBS_COLLECTION, in the document trackJob updates, at most
+// once per progressInterval apart from the update completing the extraction or
+// synthesis. It's safe for concurrent use. A failure is only logged.
+func (p *Pipeline) progressReporter(ctx context.Context, bucket, object, generation, job string) func(jobtrack.Progress) {
+	var mu sync.Mutex
+	var last time.Time
+	input := fmt.Sprintf("gs://%s/%s", bucket, object)
+	return func(progress jobtrack.Progress) {
+		if p.tracker == nil {
+			return
+		}
+		complete := progress.Pages > 0 && progress.PagesExtracted == progress.Pages || progress.Percent >= 100
+		mu.Lock()
+		defer mu.Unlock()
+		if !complete && time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+		if err := p.tracker.RecordProgress(ctx, input, generation, job, progress); err != nil {
+			log.Printf("Warning: Failed to record the progress of %s: %v", input, err)
+		}
+	}
+}
+
+// synthesisProgress adapts report to the synthesis progress reported by tts.
+func synthesisProgress(report func(jobtrack.Progress)) tts.ProgressFunc {
+	return func(p tts.Progress) {
+		report(jobtrack.Progress{ChunksSynthesized: p.ChunksDone, Chunks: p.Chunks, Percent: p.Percent})
+	}
+}
@@ -0,0 +1,113 @@
+package pdftospeech
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Webhook events.
+const (
+	webhookSucceeded = "succeeded"
+	webhookFailed    = "failed"
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the request body, keyed
+// with the secret in WEBHOOK_SIGNING_KEY_SECRET, as "sha256=<hex>".
+const webhookSignatureHeader = "X-Webhook-Signature-256"
+
+// webhookAttempts is how often a callback is tried before giving up.
+const webhookAttempts = 3
+
+// webhookClient sends the callbacks; a slow receiver doesn't hold up the function.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookPayload is the JSON body POSTed to a callback URL when a document is
+// done or has failed.
+type webhookPayload struct {
+	Event      string `json:"event"`
+	Input      string `json:"input"`
+	Generation string `json:"generation,omitempty"`
+	Output     string `json:"output,omitempty"`
+	// Stats is the manifest of a successful document: pages, characters,
+	// voice, audio length, timings and so on.
+	Stats     *jobManifest `json:"stats,omitempty"`
+	Stage     string       `json:"stage,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Retryable bool         `json:"retryable,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// notifyWebhook POSTs the payload, signed, to the document's callback URL from
+// its tts-callback-url metadata, or to WEBHOOK_URL. A callback named in
+// metadata must be https. Failures are retried a few times and then only
+// logged: the document's outcome doesn't depend on the receiver.
+func (p *Pipeline) notifyWebhook(ctx context.Context, cfg *Config, callback string, payload webhookPayload) {
+	if callback == "" {
+		callback = cfg.WebhookURL
+	} else if u, err := url.Parse(callback); err != nil || u.Scheme != "https" || u.Host == "" {
+		log.Printf("Warning: Ignoring callback URL %q for %s: it must be an https URL.", callback, payload.Input)
+		return
+	}
+	if callback == "" {
+		return
+	}
+	if cfg.WebhookKeySecret == "" {
+		log.Printf("Warning: Not calling back %s for %s: WEBHOOK_SIGNING_KEY_SECRET isn't set.", callback, payload.Input)
+		return
+	}
+	key, err := p.secrets.Access(ctx, cfg.WebhookKeySecret)
+	if err != nil {
+		log.Printf("Warning: Not calling back %s for %s: failed to read the signing key: %v", callback, payload.Input, err)
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Warning: Failed to encode the callback for %s: %v", payload.Input, err)
+		return
+	}
+	mac := hmac.New(sha256.New, []byte(strings.TrimSpace(key)))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	for attempt := 1; ; attempt++ {
+		err = postWebhook(ctx, callback, body, signature)
+		if err == nil {
+			log.Printf("Called back %s: %s %s.", callback, payload.Input, payload.Event)
+			return
+		}
+		if attempt == webhookAttempts || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	log.Printf("Warning: Failed to call back %s for %s after %d attempts: %v", callback, payload.Input, webhookAttempts, err)
+}
+
+// postWebhook sends one callback request, failing on any status but 2xx.
+func postWebhook(ctx context.Context, callback string, body []byte, signature string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookSignatureHeader, signature)
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("callback returned %s", resp.Status)
+	}
+	return nil
+}
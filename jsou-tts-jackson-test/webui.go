@@ -0,0 +1,159 @@
+package pdftospeech
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"MODULE_NAME/jsou-tts/internal/storage"
+	"MODULE_NAME/jsou-tts/internal/tts"
+)
+
+// uploadPage is the web page of the Jobs API, for people who'd rather not call
+// it themselves.
+//
+//go:embed web/index.html
+var uploadPage []byte
+
+// voiceOption is a voice the upload page offers.
+type voiceOption struct {
+	Name   string `json:"name"`
+	Gender string `json:"gender,omitempty"`
+}
+
+// serveUploadPage serves GET / of the Jobs API: a page where a PDF is uploaded
+// with a voice and speed, and its job followed until the audio can be played
+// or downloaded.
+func serveUploadPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'; media-src 'self' https:")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(uploadPage)
+}
+
+// startSession serves POST /session of the Jobs API, which the upload page
+// sends the key it asks for to: it responds 204 with the tts_session cookie,
+// which authorizes the page's requests from then on, its audio player's
+// included. The cookie is HttpOnly, so the page's scripts don't keep the key,
+// and lasts until the browser is closed.
+func (p *Pipeline) startSession(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     jobsSessionCookie,
+		Value:    p.sessionToken(),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listVoiceOptions serves GET /voices?language=CODE of the Jobs API: the voices
+// of TTS_PROVIDER for the language, by default that of TTS_VOICE_NAME, with
+// TTS_VOICE_NAME first so the upload page selects it.
+func (p *Pipeline) listVoiceOptions(w http.ResponseWriter, r *http.Request, cfg *Config) {
+	defaultVoice := tts.ParseVoice(cfg.VoiceName)
+	language := r.URL.Query().Get("language")
+	if language == "" {
+		language = defaultVoice.LanguageCode
+	}
+	if language == "" {
+		language = tts.DefaultLanguageCode
+	}
+	synth, err := tts.NewSynthesizer(r.Context(), cfg.Provider, p.providerConfig(cfg))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid TTS_PROVIDER: %v", err), http.StatusInternalServerError)
+		return
+	}
+	voices, err := synth.ListVoices(r.Context(), language)
+	if err != nil {
+		log.Printf("Error: Failed to list the voices for %s: %v", language, err)
+		http.Error(w, fmt.Sprintf("failed to list voices: %v", err), http.StatusBadGateway)
+		return
+	}
+	options := []voiceOption{}
+	for _, v := range voices {
+		options = append(options, voiceOption{Name: v.Name, Gender: strings.ToLower(v.Gender)})
+	}
+	slices.SortFunc(options, func(a, b voiceOption) int {
+		switch {
+		case a.Name == cfg.VoiceName:
+			return -1
+		case b.Name == cfg.VoiceName:
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	writeJobJSON(w, http.StatusOK, map[string]any{"language": language, "voices": options})
+}
+
+// serveJobAudio serves GET /jobs/{id}/audio of the Jobs API: the audio of a
+// done job, with range requests so it can be played from any point, and as an
+// attachment with ?download=1. It lets the upload page play the audio without
+// bucket access or signed URLs. A store that can read ranges on request, such
+// as Cloud Storage, only fetches the range asked for, instead of reading the
+// whole audio for each request as OpenReaderAt does.
+func (p *Pipeline) serveJobAudio(w http.ResponseWriter, r *http.Request, cfg *Config) {
+	job, err := p.loadJob(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	if job.Status != jobDone || job.Output == "" {
+		http.Error(w, fmt.Sprintf("job %s is %s", job.ID, job.Status), http.StatusConflict)
+		return
+	}
+	bucket, object, err := storage.ParseGCSURI(job.Output)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	if _, exists, err := p.store.ObjectMetadata(r.Context(), bucket, object); err != nil {
+		writeJobError(w, err)
+		return
+	} else if !exists {
+		// Long audio is still being finalized.
+		http.Error(w, fmt.Sprintf("the audio of job %s isn't ready yet", job.ID), http.StatusNotFound)
+		return
+	}
+	audio, err := p.openAudio(r.Context(), bucket, object)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	defer audio.Close()
+	name := path.Base(object)
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	disposition := "inline"
+	if r.URL.Query().Get("download") != "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	http.ServeContent(w, r, name, job.UpdatedAt, audio)
+}
+
+// openAudio opens an audio object for serving with http.ServeContent, with
+// range reads made on request where the store supports them.
+func (p *Pipeline) openAudio(ctx context.Context, bucket, object string) (io.ReadSeekCloser, error) {
+	if rr, ok := p.store.(storage.RangeReader); ok {
+		return rr.OpenRangeReader(ctx, bucket, object)
+	}
+	r, err := p.store.OpenReaderAt(ctx, bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	return nopSeekCloser{r}, nil
+}
+
+// nopSeekCloser is an io.ReadSeeker with a Close that does nothing.
+type nopSeekCloser struct{ io.ReadSeeker }
+
+func (nopSeekCloser) Close() error { return nil }